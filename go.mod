@@ -15,6 +15,7 @@ require (
 	github.com/square/go-jose/v3 v3.0.0-20191119004800-96c717272387
 	github.com/stretchr/testify v1.4.0
 	github.com/trustbloc/edge-core v0.1.4-0.20200709143857-e104bb29f6c6
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/crypto v0.0.0-20200210222208-86ce3cb69678
 )
 
@@ -73,4 +73,52 @@ type OperationInfo struct {
 	Data         []byte
 	UniqueSuffix string
 	Namespace    string
+	Priority     Priority `json:",omitempty"`
+}
+
+// Priority defines the priority of an operation in the operation queue of the batch writer
+type Priority uint
+
+const (
+
+	// PriorityNormal is the default priority
+	PriorityNormal Priority = iota
+
+	// PriorityHigh is the priority of an operation that should be included in the next batch
+	// (e.g. a recover or deactivate operation in response to a key compromise)
+	PriorityHigh
+)
+
+// ProcessOption is an option for processing (i.e. submitting) an operation
+type ProcessOption func(opts *ProcessOptions)
+
+// ProcessOptions contains the options for processing an operation
+type ProcessOptions struct {
+	// Priority is the priority of the operation in the operation queue of the batch writer
+	Priority Priority
+}
+
+// WithPriority sets the priority of the operation in the operation queue of the batch writer.
+// Note that the priority is ignored unless the batch writer is configured with a priority queue.
+func WithPriority(priority Priority) ProcessOption {
+	return func(opts *ProcessOptions) {
+		opts.Priority = priority
+	}
+}
+
+// GetProcessOptions returns the process options populated from the given options
+func GetProcessOptions(opts ...ProcessOption) ProcessOptions {
+	options := ProcessOptions{}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// RejectedOperation contains an operation that was rejected along with the reason why it was rejected
+type RejectedOperation struct {
+	Operation *Operation
+	Reason    string
 }
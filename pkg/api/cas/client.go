@@ -16,3 +16,15 @@ type Client interface {
 	// returns the content of the given address.
 	Read(address string) ([]byte, error)
 }
+
+// DeletableClient is implemented by CAS clients that support the enumeration and deletion of content
+// (e.g. for garbage collection of batch files that aren't referenced by any anchored transaction)
+type DeletableClient interface {
+	Client
+
+	// List returns the addresses of all of the content in CAS.
+	List() ([]string, error)
+
+	// Delete deletes the content of the given address from CAS.
+	Delete(address string) error
+}
@@ -0,0 +1,75 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstatus
+
+import (
+	"crypto/sha256"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+)
+
+// Status is the processing status of an operation
+type Status string
+
+const (
+	// Queued indicates that the operation was accepted and added to the operation queue
+	Queued Status = "queued"
+	// Batched indicates that the operation was included in a batch (i.e. the anchor string is known)
+	Batched Status = "batched"
+	// Anchored indicates that the anchor string of the batch was written to the ledger
+	Anchored Status = "anchored"
+	// Confirmed indicates that the observer processed the Sidetree transaction and stored the operation
+	Confirmed Status = "confirmed"
+	// Rejected indicates that the operation was rejected (see the reason of the operation status)
+	Rejected Status = "rejected"
+	// Failed indicates that the anchor of the batch that contained the operation wasn't confirmed (see the reason of
+	// the operation status). The operation remains in the operation queue, i.e. it's batched again.
+	Failed Status = "failed"
+)
+
+// OperationStatus contains the status of an operation
+type OperationStatus struct {
+	// ID is the operation ID (see the ID function)
+	ID string `json:"id"`
+	// Namespace is the namespace of the document
+	Namespace string `json:"namespace"`
+	// UniqueSuffix is the unique suffix of the document
+	UniqueSuffix string `json:"uniqueSuffix"`
+	// Type is the operation type
+	Type batch.OperationType `json:"type,omitempty"`
+	// Status is the processing status of the operation
+	Status Status `json:"status"`
+	// AnchorString is the anchor string of the batch that contains the operation
+	AnchorString string `json:"anchorString,omitempty"`
+	// TransactionTime is the ledger time of the Sidetree transaction (only set when confirmed)
+	TransactionTime uint64 `json:"transactionTime,omitempty"`
+	// TransactionNumber is the number of the Sidetree transaction (only set when confirmed)
+	TransactionNumber uint64 `json:"transactionNumber,omitempty"`
+	// Reason is the reason why the operation was rejected
+	Reason string `json:"reason,omitempty"`
+}
+
+// Store stores the status of operations
+type Store interface {
+	// Put stores the status of an operation
+	Put(status *OperationStatus) error
+
+	// Get returns the status of the operation with the given ID
+	Get(id string) (*OperationStatus, error)
+
+	// GetByAnchor returns the status of the operation for the given unique suffix that was
+	// batched with the given anchor string
+	GetByAnchor(anchorString, uniqueSuffix string) (*OperationStatus, error)
+}
+
+// ID returns the ID of the operation with the given operation buffer (i.e. the original operation request)
+func ID(operationBuffer []byte) string {
+	hash := sha256.Sum256(operationBuffer)
+
+	return docutil.EncodeToString(hash[:])
+}
@@ -31,6 +31,9 @@ type Client interface {
 
 	// Current returns latest version of protocol
 	Current() Protocol
+
+	// Get returns the version of protocol that applies to the given transaction time
+	Get(transactionTime uint64) (Protocol, error)
 }
 
 // ClientProvider returns a protocol client for the given namespace
@@ -7,10 +7,17 @@ SPDX-License-Identifier: Apache-2.0
 package cutter
 
 import (
+	"bytes"
+	"sync"
+
 	"github.com/trustbloc/edge-core/pkg/log"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/compression"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/operation"
+	"github.com/trustbloc/sidetree-core-go/pkg/txnhandler/models"
 )
 
 var logger = log.New("sidetree-core-cutter")
@@ -19,70 +26,381 @@ var logger = log.New("sidetree-core-cutter")
 type OperationQueue interface {
 	// Add adds the given operation to the tail of the queue and returns the new length of the queue
 	Add(data *batch.OperationInfo) (uint, error)
-	// Remove removes (up to) the given number of items from the head of the queue.
-	// Returns the actual number of items that were removed and the new length of the queue.
-	Remove(num uint) (uint, uint, error)
+	// Remove removes the given operations (as returned by Peek) from the queue. The operations are identified by
+	// identity, i.e. they're removed regardless of their position in the queue (e.g. even if operations with a higher
+	// priority were added in the meantime). Returns the actual number of items that were removed and the new length
+	// of the queue.
+	Remove(ops []*batch.OperationInfo) (uint, uint, error)
 	// Peek returns (up to) the given number of operations from the head of the queue but does not remove them.
 	Peek(num uint) ([]*batch.OperationInfo, error)
 	// Len returns the number of operation in the queue
 	Len() uint
 }
 
-// Committer is invoked to commit a batch Cut. The new number of pending items
-// in the queue is returned.
-type Committer = func() (pending uint, err error)
+// Committer is invoked to commit a batch Cut. The operations of the batch are removed from the queue except for
+// the given deferred operations (i.e. operations of the batch that weren't processed), which remain in the queue
+// (at their position) so that they're included in a subsequent batch. The new number of pending items in the
+// queue (excluding the operations of other batches that were cut but not committed yet) is returned.
+// A batch whose operations can't be processed at all is released by deferring all of its operations.
+type Committer = func(deferred ...*batch.OperationInfo) (pending uint, err error)
 
 // BatchCutter implements batch cutting
 type BatchCutter struct {
+	mutex        sync.Mutex
 	pendingBatch OperationQueue
 	client       protocol.Client
+	// pending counts the operations in the queue by unique suffix (loaded from the queue on first use)
+	pending    map[suffixKey]int
+	compressor models.Compressor
+	// parsed caches the parsed operations in the queue (nil if an operation can't be parsed)
+	parsed map[*batch.OperationInfo]*batch.Operation
+	// inflight counts the operations of the batches that were cut but not committed yet
+	inflight    map[*batch.OperationInfo]int
+	numInflight uint
+}
+
+type suffixKey struct {
+	namespace    string
+	uniqueSuffix string
+}
+
+// Option is an option for the batch cutter
+type Option func(r *BatchCutter)
+
+// WithCompressionProvider sets the compression provider that is used to estimate the compressed sizes of the batch
+// files (the default compression algorithms are used by default)
+func WithCompressionProvider(cp models.Compressor) Option {
+	return func(r *BatchCutter) {
+		r.compressor = cp
+	}
 }
 
 // New creates a Cutter implementation
-func New(client protocol.Client, queue OperationQueue) *BatchCutter {
-	return &BatchCutter{
+func New(client protocol.Client, queue OperationQueue, opts ...Option) *BatchCutter {
+	r := &BatchCutter{
 		client:       client,
 		pendingBatch: queue,
 	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.compressor == nil {
+		r.compressor = compression.New(compression.WithDefaultAlgorithms())
+	}
+
+	return r
 }
 
 // Add adds the given operation to pending batch queue and returns the total
-// number of pending operations.
+// number of pending operations. An error with code errs.Conflict is returned if
+// an operation for the same unique suffix is already pending (i.e. in the queue).
 func (r *BatchCutter) Add(operation *batch.OperationInfo) (uint, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pending, err := r.getPending()
+	if err != nil {
+		return 0, err
+	}
+
+	key := newSuffixKey(operation)
+	if pending[key] > 0 {
+		return 0, errs.New(errs.Conflict, "an operation for unique suffix [%s] is already pending", operation.UniqueSuffix)
+	}
+
 	// Enqueuing operation into batch
-	return r.pendingBatch.Add(operation)
+	l, err := r.pendingBatch.Add(operation)
+	if err != nil {
+		// the operation may have been added to the queue anyway
+		r.pending = nil
+
+		return 0, err
+	}
+
+	pending[key]++
+
+	return l, nil
+}
+
+// IsPending returns true if an operation for the given unique suffix of the given namespace is pending
+// (i.e. in the queue)
+func (r *BatchCutter) IsPending(namespace, uniqueSuffix string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pending, err := r.getPending()
+	if err != nil {
+		return false, err
+	}
+
+	return pending[suffixKey{namespace: namespace, uniqueSuffix: uniqueSuffix}] > 0, nil
 }
 
 // Cut returns the current batch along with number of items that should be remaining in the queue after the committer is called.
-// If force is false then the batch will be cut only if it has reached the max batch size (as specified in the protocol)
+// The batch is full if it has reached the max batch size (as specified in the protocol) or if adding the next operation
+// would cause the estimated size of any of the batch files (anchor, map or chunk files) to exceed the protocol limits.
+// If force is false then the batch will be cut only if it's full
 // If force is true then the batch will be cut if there is at least one Data in the batch
 // Note that the operations are removed from the queue when the committer is invoked, otherwise they remain in the queue.
+// The operations of a batch are in flight until the committer is invoked, i.e. they aren't included in another batch
+// (so that multiple batches may be processed at the same time).
 func (r *BatchCutter) Cut(force bool) ([]*batch.OperationInfo, uint, Committer, error) {
-	pending := r.pendingBatch.Len()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	maxOperationsPerBatch := r.client.Current().MaxOperationsPerBatch
-	if !force && pending < maxOperationsPerBatch {
-		return nil, pending, nil, nil
-	}
+	queued := r.pendingBatch.Len()
+	pending := r.numPending(queued)
+
+	p := r.client.Current()
 
-	batchSize := min(pending, maxOperationsPerBatch)
-	ops, err := r.pendingBatch.Peek(batchSize)
+	// the operations that are in flight are skipped
+	ops, err := r.pendingBatch.Peek(min(queued, r.numInflight+p.MaxOperationsPerBatch))
 	if err != nil {
 		return nil, pending, nil, err
 	}
 
+	ops = r.withoutInflight(ops)
+	ops = ops[:min(uint(len(ops)), p.MaxOperationsPerBatch)]
+
+	batchSize, full := r.getBatchSize(ops, p)
+	if !force && !full {
+		return nil, pending, nil, nil
+	}
+
+	ops = ops[:batchSize]
 	pending -= batchSize
 
-	logger.Infof("Pending Size: %d, MaxOperationsPerBatch: %d, Batch Size: %d", pending, maxOperationsPerBatch, batchSize)
+	logger.Infof("Pending Size: %d, MaxOperationsPerBatch: %d, Batch Size: %d", pending, p.MaxOperationsPerBatch, batchSize)
+
+	r.setInflight(ops, 1)
+
+	return ops, pending, r.newCommitter(ops), nil
+}
+
+// Reserve marks the queued operations that match the given operations (e.g. the operations of a batch whose anchor
+// was written before a restart) as in flight, i.e. they aren't included in a subsequent batch until the committer
+// is invoked. The operations are matched by namespace, unique suffix and data since the given operations may have
+// been loaded from storage. The matching operations are returned (none if the operations are no longer queued)
+// along with the committer.
+func (r *BatchCutter) Reserve(ops []*batch.OperationInfo) ([]*batch.OperationInfo, Committer, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	queued, err := r.pendingBatch.Peek(r.pendingBatch.Len())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queued = r.withoutInflight(queued)
+
+	var reserved []*batch.OperationInfo
 
-	committer := func() (uint, error) {
-		logger.Infof("Removing %d operations from the queue", batchSize)
+	// each queued operation is reserved at most once
+	matched := make(map[int]bool)
 
-		_, p, err := r.pendingBatch.Remove(batchSize)
-		return p, err
+	for _, op := range ops {
+		for i, q := range queued {
+			if matched[i] || q.Namespace != op.Namespace || q.UniqueSuffix != op.UniqueSuffix || !bytes.Equal(q.Data, op.Data) {
+				continue
+			}
+
+			reserved = append(reserved, q)
+			matched[i] = true
+
+			break
+		}
 	}
 
-	return ops, pending, committer, nil
+	r.setInflight(reserved, 1)
+
+	return reserved, r.newCommitter(reserved), nil
+}
+
+// newCommitter returns the committer for the given batch
+func (r *BatchCutter) newCommitter(ops []*batch.OperationInfo) Committer {
+	return func(deferred ...*batch.OperationInfo) (uint, error) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		// the same operation may be in the batch more than once, so deferred operations are counted
+		numDeferred := make(map[*batch.OperationInfo]int)
+		for _, op := range deferred {
+			logger.Infof("Deferring operation for suffix [%s] to the next batch", op.UniqueSuffix)
+
+			numDeferred[op]++
+		}
+
+		var processed []*batch.OperationInfo
+		for _, op := range ops {
+			if numDeferred[op] > 0 {
+				numDeferred[op]--
+				continue
+			}
+
+			processed = append(processed, op)
+		}
+
+		logger.Infof("Removing %d operations from the queue", len(processed))
+
+		r.setInflight(ops, -1)
+
+		_, p, err := r.pendingBatch.Remove(processed)
+		if err != nil {
+			// some of the operations may have been removed from the queue anyway
+			r.pending = nil
+
+			return r.numPending(p), err
+		}
+
+		for _, op := range processed {
+			r.removed(op)
+		}
+
+		return r.numPending(p), nil
+	}
+}
+
+// setInflight adds the given delta to the in-flight count of each of the given operations
+func (r *BatchCutter) setInflight(ops []*batch.OperationInfo, delta int) {
+	if r.inflight == nil {
+		r.inflight = make(map[*batch.OperationInfo]int)
+	}
+
+	for _, op := range ops {
+		if r.inflight[op] += delta; r.inflight[op] <= 0 {
+			delete(r.inflight, op)
+		}
+
+		r.numInflight = uint(int(r.numInflight) + delta)
+	}
+}
+
+// withoutInflight returns the given operations without the operations that are in flight
+func (r *BatchCutter) withoutInflight(ops []*batch.OperationInfo) []*batch.OperationInfo {
+	if r.numInflight == 0 {
+		return ops
+	}
+
+	// the same operation may be in the queue more than once, so in-flight operations are counted
+	skip := make(map[*batch.OperationInfo]int)
+	for op, n := range r.inflight {
+		skip[op] = n
+	}
+
+	var result []*batch.OperationInfo
+	for _, op := range ops {
+		if skip[op] > 0 {
+			skip[op]--
+			continue
+		}
+
+		result = append(result, op)
+	}
+
+	return result
+}
+
+// numPending returns the number of operations in the queue that aren't in flight
+func (r *BatchCutter) numPending(queued uint) uint {
+	if queued < r.numInflight {
+		return 0
+	}
+
+	return queued - r.numInflight
+}
+
+// getBatchSize returns the number of the given operations (from the head of the queue) that fit into the batch files
+// and whether or not the batch is full. At least one operation is included in a non-empty batch. (The operation handler
+// excludes an operation that doesn't fit into the batch files on its own.)
+func (r *BatchCutter) getBatchSize(ops []*batch.OperationInfo, p protocol.Protocol) (uint, bool) {
+	estimator := models.NewBatchSizeEstimator(p, r.compressor)
+
+	for i, op := range ops {
+		parsed := r.getParsedOperation(op, p)
+
+		// an operation that can't be parsed is rejected by the batch writer so it doesn't add to the batch files
+		if parsed == nil {
+			continue
+		}
+
+		if err := estimator.Add(parsed); err != nil {
+			if i == 0 {
+				logger.Warnf("Operation for suffix [%s] doesn't fit into the batch files: %s", op.UniqueSuffix, err)
+
+				return 1, true
+			}
+
+			logger.Infof("Batch is full after %d operations: %s", i, err)
+
+			return uint(i), true
+		}
+	}
+
+	return uint(len(ops)), uint(len(ops)) == p.MaxOperationsPerBatch
+}
+
+// getParsedOperation returns the given operation parsed (or nil if it can't be parsed). The parsed operations are
+// cached until the operations are removed from the queue since the operations at the head of the queue are peeked
+// whenever a batch is cut.
+func (r *BatchCutter) getParsedOperation(op *batch.OperationInfo, p protocol.Protocol) *batch.Operation {
+	if parsed, ok := r.parsed[op]; ok {
+		return parsed
+	}
+
+	parsed, err := operation.ParseOperation(op.Namespace, op.Data, p)
+	if err != nil {
+		logger.Debugf("Unable to parse operation for suffix [%s]: %s", op.UniqueSuffix, err)
+	}
+
+	if r.parsed == nil {
+		r.parsed = make(map[*batch.OperationInfo]*batch.Operation)
+	}
+
+	r.parsed[op] = parsed
+
+	return parsed
+}
+
+// getPending returns the number of pending operations by unique suffix
+func (r *BatchCutter) getPending() (map[suffixKey]int, error) {
+	if r.pending != nil {
+		return r.pending, nil
+	}
+
+	ops, err := r.pendingBatch.Peek(r.pendingBatch.Len())
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[suffixKey]int)
+	for _, op := range ops {
+		pending[newSuffixKey(op)]++
+	}
+
+	r.pending = pending
+
+	return pending, nil
+}
+
+// removed updates the pending suffixes and the parsed operations after the given operation was removed from the queue
+func (r *BatchCutter) removed(op *batch.OperationInfo) {
+	delete(r.parsed, op)
+
+	if r.pending == nil {
+		return
+	}
+
+	key := newSuffixKey(op)
+
+	if r.pending[key]--; r.pending[key] <= 0 {
+		delete(r.pending, key)
+	}
+}
+
+func newSuffixKey(op *batch.OperationInfo) suffixKey {
+	return suffixKey{namespace: op.Namespace, uniqueSuffix: op.UniqueSuffix}
 }
 
 func min(i, j uint) uint {
@@ -7,15 +7,27 @@ SPDX-License-Identifier: Apache-2.0
 package cutter
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/batch/opqueue"
+	"github.com/trustbloc/sidetree-core-go/pkg/commitment"
+	"github.com/trustbloc/sidetree-core-go/pkg/compression"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+	"github.com/trustbloc/sidetree-core-go/pkg/operation"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/helper"
+	"github.com/trustbloc/sidetree-core-go/pkg/txnhandler/models"
 )
 
+const sha2_256 = 18
+
 var (
 	operation1 = &batch.OperationInfo{UniqueSuffix: "1", Data: []byte("operation1")}
 	operation2 = &batch.OperationInfo{UniqueSuffix: "2", Data: []byte("operation2")}
@@ -53,7 +65,17 @@ func TestBatchCutter(t *testing.T) {
 	require.Equal(t, operation2, ops[1])
 	require.Zero(t, pending)
 
-	// Without committing, the operations should still be in the queue
+	// Without committing, the operations are in flight, i.e. they aren't included in another batch
+	ops, pending, _, err = r.Cut(true)
+	require.NoError(t, err)
+	require.Empty(t, ops)
+	require.Zero(t, pending)
+
+	// The operations are still in the queue after the batch was released (by deferring all of its operations)
+	pending, err = commit(operation1, operation2)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), pending)
+
 	ops, pending, commit, err = r.Cut(true)
 	require.NoError(t, err)
 	require.Len(t, ops, 2)
@@ -114,3 +136,432 @@ func TestBatchCutter(t *testing.T) {
 	require.NoError(t, err)
 	require.Zero(t, pending)
 }
+
+func TestBatchCutter_AddDuplicateSuffix(t *testing.T) {
+	c := mocks.NewMockProtocolClient()
+	c.Protocol.MaxOperationsPerBatch = 3
+
+	t.Run("conflict", func(t *testing.T) {
+		r := New(c, &opqueue.MemQueue{})
+
+		_, err := r.Add(operation1)
+		require.NoError(t, err)
+
+		pending, err := r.IsPending("", "1")
+		require.NoError(t, err)
+		require.True(t, pending)
+
+		pending, err = r.IsPending("other", "1")
+		require.NoError(t, err)
+		require.False(t, pending)
+
+		l, err := r.Add(&batch.OperationInfo{UniqueSuffix: "1", Data: []byte("operation5")})
+		require.Error(t, err)
+		require.True(t, errors.Is(err, errs.ErrConflict))
+		require.Contains(t, err.Error(), "an operation for unique suffix [1] is already pending")
+		require.Zero(t, l)
+
+		// an operation for the same suffix in a different namespace is not a conflict
+		l, err = r.Add(&batch.OperationInfo{Namespace: "other", UniqueSuffix: "1", Data: []byte("operation5")})
+		require.NoError(t, err)
+		require.Equal(t, uint(2), l)
+
+		_, _, commit, err := r.Cut(true)
+		require.NoError(t, err)
+
+		// the operation may be added again after the pending operation was committed
+		_, err = r.Add(operation1)
+		require.Error(t, err)
+
+		_, err = commit()
+		require.NoError(t, err)
+
+		l, err = r.Add(operation1)
+		require.NoError(t, err)
+		require.Equal(t, uint(1), l)
+	})
+
+	t.Run("pending suffixes are loaded from the queue once", func(t *testing.T) {
+		q := &opqueue.MemQueue{}
+
+		_, err := q.Add(operation1)
+		require.NoError(t, err)
+
+		mq := &mocks.OperationQueue{}
+		mq.PeekStub = q.Peek
+		mq.LenStub = q.Len
+		mq.AddStub = q.Add
+
+		r := New(c, mq)
+
+		_, err = r.Add(operation1)
+		require.True(t, errors.Is(err, errs.ErrConflict))
+
+		_, err = r.Add(operation2)
+		require.NoError(t, err)
+
+		_, err = r.Add(operation2)
+		require.True(t, errors.Is(err, errs.ErrConflict))
+		require.Equal(t, 1, mq.PeekCallCount())
+
+		// the pending suffixes are loaded again after a queue error
+		mq.AddReturns(0, errors.New("injected add error"))
+
+		_, err = r.Add(operation3)
+		require.Error(t, err)
+
+		mq.AddStub = q.Add
+
+		_, err = r.Add(operation3)
+		require.NoError(t, err)
+		require.Equal(t, 2, mq.PeekCallCount())
+	})
+
+	t.Run("queue error", func(t *testing.T) {
+		errExpected := errors.New("injected peek error")
+
+		q := &mocks.OperationQueue{}
+		q.PeekReturns(nil, errExpected)
+
+		r := New(c, q)
+
+		_, err := r.Add(operation1)
+		require.EqualError(t, err, errExpected.Error())
+
+		_, err = r.IsPending("", "1")
+		require.EqualError(t, err, errExpected.Error())
+	})
+}
+
+func TestBatchCutter_CommitDeferred(t *testing.T) {
+	c := mocks.NewMockProtocolClient()
+	c.Protocol.MaxOperationsPerBatch = 3
+
+	t.Run("success", func(t *testing.T) {
+		r := New(c, &opqueue.MemQueue{})
+
+		for _, op := range []*batch.OperationInfo{operation1, operation2, operation3, operation4} {
+			_, err := r.Add(op)
+			require.NoError(t, err)
+		}
+
+		ops, pending, commit, err := r.Cut(false)
+		require.NoError(t, err)
+		require.Len(t, ops, 3)
+		require.Equal(t, uint(1), pending)
+
+		pending, err = commit(operation1, operation3)
+		require.NoError(t, err)
+		require.Equal(t, uint(3), pending)
+
+		// the deferred operations are still pending
+		_, err = r.Add(operation1)
+		require.True(t, errors.Is(err, errs.ErrConflict))
+
+		// the deferred operations remain at the head of the queue
+		ops, pending, _, err = r.Cut(true)
+		require.NoError(t, err)
+		require.Zero(t, pending)
+		require.Equal(t, []*batch.OperationInfo{operation1, operation3, operation4}, ops)
+	})
+
+	t.Run("queue error", func(t *testing.T) {
+		errExpected := errors.New("injected remove error")
+
+		q := &mocks.OperationQueue{}
+		q.LenReturns(2)
+		q.PeekReturns([]*batch.OperationInfo{operation1, operation2}, nil)
+		q.RemoveReturns(0, 2, errExpected)
+
+		r := New(c, q)
+
+		_, _, commit, err := r.Cut(true)
+		require.NoError(t, err)
+
+		_, err = commit(operation1)
+		require.EqualError(t, err, errExpected.Error())
+
+		// only the operations that weren't deferred are removed
+		require.Equal(t, 1, q.RemoveCallCount())
+		require.Equal(t, []*batch.OperationInfo{operation2}, q.RemoveArgsForCall(0))
+	})
+}
+
+func TestBatchCutter_Inflight(t *testing.T) {
+	c := mocks.NewMockProtocolClient()
+	c.Protocol.MaxOperationsPerBatch = 2
+
+	r := New(c, &opqueue.MemQueue{})
+
+	for _, op := range []*batch.OperationInfo{operation1, operation2, operation3, operation4} {
+		_, err := r.Add(op)
+		require.NoError(t, err)
+	}
+
+	ops1, pending, commit1, err := r.Cut(false)
+	require.NoError(t, err)
+	require.Equal(t, []*batch.OperationInfo{operation1, operation2}, ops1)
+	require.Equal(t, uint(2), pending)
+
+	// the next batch is cut while the first batch is in flight
+	ops2, pending, commit2, err := r.Cut(false)
+	require.NoError(t, err)
+	require.Equal(t, []*batch.OperationInfo{operation3, operation4}, ops2)
+	require.Zero(t, pending)
+
+	ops, pending, _, err := r.Cut(true)
+	require.NoError(t, err)
+	require.Empty(t, ops)
+	require.Zero(t, pending)
+
+	// the batches may be committed in any order
+	pending, err = commit2(operation4)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), pending)
+
+	pending, err = commit1()
+	require.NoError(t, err)
+	require.Equal(t, uint(1), pending)
+
+	ops, pending, _, err = r.Cut(true)
+	require.NoError(t, err)
+	require.Equal(t, []*batch.OperationInfo{operation4}, ops)
+	require.Zero(t, pending)
+}
+
+func TestBatchCutter_Reserve(t *testing.T) {
+	c := mocks.NewMockProtocolClient()
+	c.Protocol.MaxOperationsPerBatch = 2
+
+	t.Run("success", func(t *testing.T) {
+		r := New(c, &opqueue.MemQueue{})
+
+		for _, op := range []*batch.OperationInfo{operation1, operation2, operation3, operation4} {
+			_, err := r.Add(op)
+			require.NoError(t, err)
+		}
+
+		// the operations are matched by value (e.g. operations that were loaded from storage)
+		loaded := []*batch.OperationInfo{
+			{UniqueSuffix: "2", Data: []byte("operation2")},
+			{UniqueSuffix: "3", Data: []byte("operation3")},
+			{UniqueSuffix: "5", Data: []byte("operation5")},
+		}
+
+		ops, commit, err := r.Reserve(loaded)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{operation2, operation3}, ops)
+
+		// the reserved operations are in flight
+		ops, pending, _, err := r.Cut(true)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{operation1, operation4}, ops)
+		require.Zero(t, pending)
+
+		pending, err = commit()
+		require.NoError(t, err)
+		require.Equal(t, uint(0), pending)
+
+		require.Equal(t, uint(2), r.pendingBatch.Len())
+
+		// the operations are no longer queued
+		ops, _, err = r.Reserve(loaded)
+		require.NoError(t, err)
+		require.Empty(t, ops)
+	})
+
+	t.Run("queue error", func(t *testing.T) {
+		errExpected := errors.New("injected peek error")
+
+		q := &mocks.OperationQueue{}
+		q.PeekReturns(nil, errExpected)
+
+		r := New(c, q)
+
+		_, _, err := r.Reserve([]*batch.OperationInfo{operation1})
+		require.EqualError(t, err, errExpected.Error())
+	})
+}
+
+func TestBatchCutter_PriorityQueue(t *testing.T) {
+	c := mocks.NewMockProtocolClient()
+	c.Protocol.MaxOperationsPerBatch = 2
+
+	r := New(c, opqueue.NewPriorityQueue())
+
+	for _, op := range []*batch.OperationInfo{operation1, operation2, operation3} {
+		_, err := r.Add(op)
+		require.NoError(t, err)
+	}
+
+	ops, pending, commit, err := r.Cut(false)
+	require.NoError(t, err)
+	require.Equal(t, []*batch.OperationInfo{operation1, operation2}, ops)
+	require.Equal(t, uint(1), pending)
+
+	highPriorityOp := &batch.OperationInfo{UniqueSuffix: "5", Data: []byte("operation5"), Priority: batch.PriorityHigh}
+
+	_, err = r.Add(highPriorityOp)
+	require.NoError(t, err)
+
+	// the high priority operation isn't removed along with the batch that was cut before it was added
+	pending, err = commit()
+	require.NoError(t, err)
+	require.Equal(t, uint(2), pending)
+
+	ops, pending, _, err = r.Cut(false)
+	require.NoError(t, err)
+	require.Equal(t, []*batch.OperationInfo{highPriorityOp, operation3}, ops)
+	require.Zero(t, pending)
+}
+
+func TestBatchCutter_CutBySize(t *testing.T) {
+	var ops []*batch.OperationInfo
+	for i := 0; i < 10; i++ {
+		ops = append(ops, newCreateOperation(t, i))
+	}
+
+	c := mocks.NewMockProtocolClient()
+	c.Protocol.MaxOperationsPerBatch = 10
+
+	t.Run("anchor file size", func(t *testing.T) {
+		// the (compressed) anchor file fits three operations but not four
+		var size uint
+		for i := 0; i < 3; i++ {
+			if fileSize := getAnchorFileSize(t, c.Protocol, ops[3*i:3*i+3]); fileSize > size {
+				size = fileSize
+			}
+		}
+
+		for i := 0; i < 3; i++ {
+			require.True(t, getAnchorFileSize(t, c.Protocol, ops[3*i:3*i+4]) > size)
+		}
+
+		c.Protocol.MaxAnchorFileSize = size
+
+		r := New(c, &opqueue.MemQueue{})
+
+		for _, op := range ops[:3] {
+			_, err := r.Add(op)
+			require.NoError(t, err)
+		}
+
+		// the batch isn't full since the operations fit into the anchor file
+		batchOps, pending, commit, err := r.Cut(false)
+		require.NoError(t, err)
+		require.Empty(t, batchOps)
+		require.Equal(t, uint(3), pending)
+		require.Nil(t, commit)
+
+		for _, op := range ops[3:] {
+			_, err = r.Add(op)
+			require.NoError(t, err)
+		}
+
+		for i := 0; i < 3; i++ {
+			batchOps, pending, commit, err = r.Cut(false)
+			require.NoError(t, err)
+			require.Equal(t, ops[3*i:3*i+3], batchOps)
+			require.Equal(t, uint(7-3*i), pending)
+
+			pending, err = commit()
+			require.NoError(t, err)
+			require.Equal(t, uint(7-3*i), pending)
+		}
+
+		batchOps, pending, commit, err = r.Cut(false)
+		require.NoError(t, err)
+		require.Empty(t, batchOps)
+		require.Equal(t, uint(1), pending)
+		require.Nil(t, commit)
+
+		batchOps, pending, _, err = r.Cut(true)
+		require.NoError(t, err)
+		require.Equal(t, ops[9:], batchOps)
+		require.Zero(t, pending)
+	})
+
+	t.Run("operation doesn't fit into batch files", func(t *testing.T) {
+		c.Protocol.MaxAnchorFileSize = 100
+
+		r := New(c, &opqueue.MemQueue{})
+
+		for _, op := range ops[:2] {
+			_, err := r.Add(op)
+			require.NoError(t, err)
+		}
+
+		// the operation is cut on its own so that it's excluded by the operation handler
+		batchOps, pending, _, err := r.Cut(false)
+		require.NoError(t, err)
+		require.Equal(t, ops[:1], batchOps)
+		require.Equal(t, uint(1), pending)
+	})
+
+	t.Run("operation can't be parsed", func(t *testing.T) {
+		c.Protocol.MaxAnchorFileSize = getAnchorFileSize(t, c.Protocol, ops[:1])
+
+		r := New(c, &opqueue.MemQueue{})
+
+		for _, op := range []*batch.OperationInfo{ops[0], operation1, operation2} {
+			_, err := r.Add(op)
+			require.NoError(t, err)
+		}
+
+		// the operations that can't be parsed don't add to the batch files
+		batchOps, pending, commit, err := r.Cut(false)
+		require.NoError(t, err)
+		require.Empty(t, batchOps)
+		require.Equal(t, uint(3), pending)
+		require.Nil(t, commit)
+	})
+}
+
+// getAnchorFileSize returns the maximum anchor file size that fits exactly the given operations
+func getAnchorFileSize(t *testing.T, p protocol.Protocol, ops []*batch.OperationInfo) uint {
+	for size := uint(1); ; size++ {
+		p.MaxAnchorFileSize = size
+
+		estimator := models.NewBatchSizeEstimator(p, compression.New(compression.WithDefaultAlgorithms()))
+
+		fits := true
+		for _, op := range ops {
+			parsed, err := operation.ParseOperation(op.Namespace, op.Data, p)
+			require.NoError(t, err)
+
+			if estimator.Add(parsed) != nil {
+				fits = false
+				break
+			}
+		}
+
+		if fits {
+			return size
+		}
+	}
+}
+
+func newCreateOperation(t *testing.T, num int) *batch.OperationInfo {
+	jwk := &jws.JWK{
+		Crv: "crv",
+		Kty: "kty",
+		X:   "x",
+	}
+
+	c, err := commitment.Calculate(jwk, sha2_256)
+	require.NoError(t, err)
+
+	request, err := helper.NewCreateRequest(&helper.CreateRequestInfo{
+		OpaqueDocument:     fmt.Sprintf(`{"test":%d}`, num),
+		RecoveryCommitment: c,
+		UpdateCommitment:   c,
+		MultihashCode:      sha2_256,
+	})
+	require.NoError(t, err)
+
+	return &batch.OperationInfo{
+		Namespace:    mocks.DefaultNS,
+		UniqueSuffix: fmt.Sprintf("create-%d", num),
+		Data:         request,
+	}
+}
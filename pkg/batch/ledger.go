@@ -0,0 +1,151 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package batch
+
+import (
+	"sync"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+)
+
+// txnReader reads the transactions on the ledger
+type txnReader interface {
+	// Read returns the transaction following the given transaction number (-1 for the first transaction)
+	// and true if there are more transactions
+	Read(sinceTransactionNumber int) (bool, *txn.SidetreeTxn)
+}
+
+// ledgerReader watches the ledger for the anchors that were written by the batch writers. A ledger reader may be
+// shared by the writers of multiple namespaces (see MultiWriter), in which case the transactions on the ledger are
+// read once for all of the namespaces.
+type ledgerReader struct {
+	mutex       sync.Mutex
+	reader      txnReader
+	cursor      int
+	initialized bool
+	// watched are the anchor strings (by namespace) that are awaiting confirmation
+	watched map[string]map[string]bool
+	// confirmed are the watched anchor strings (by namespace) that were included in the ledger
+	confirmed map[string]map[string]bool
+}
+
+func newLedgerReader(reader txnReader) *ledgerReader {
+	return &ledgerReader{
+		reader:    reader,
+		cursor:    -1,
+		watched:   make(map[string]map[string]bool),
+		confirmed: make(map[string]map[string]bool),
+	}
+}
+
+// init sets the position from which the transactions are read to the head of the ledger (once), since the anchors
+// that are written by the batch writers are included in the ledger after the current head. If the reader doesn't
+// implement LedgerHeadReader then the transactions are read up to the head of the ledger instead.
+func (l *ledgerReader) init() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.initialized {
+		return
+	}
+
+	l.initialized = true
+
+	if headReader, ok := l.reader.(LedgerHeadReader); ok {
+		head, err := headReader.LastTransactionNumber()
+		if err == nil {
+			l.cursor = head
+			return
+		}
+
+		logger.Warnf("Error getting the head of the ledger: %s. Reading the transactions up to the head instead.", err)
+	}
+
+	l.readTxns()
+}
+
+// watch adds the given anchor string of the given namespace to the anchors that are awaiting confirmation.
+// The anchor must be watched before it's written so that it's confirmed even if the transaction is read by
+// the writer of another namespace.
+func (l *ledgerReader) watch(namespace, anchorString string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.watched[namespace] == nil {
+		l.watched[namespace] = make(map[string]bool)
+	}
+
+	l.watched[namespace][anchorString] = true
+}
+
+// unwatch removes the given anchor string of the given namespace from the anchors that are awaiting confirmation
+func (l *ledgerReader) unwatch(namespace, anchorString string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.watched[namespace], anchorString)
+	delete(l.confirmed[namespace], anchorString)
+}
+
+// read reads the transactions that were included in the ledger since the last read and returns the watched anchors
+// of the given namespace that were confirmed (until they're unwatched)
+func (l *ledgerReader) read(namespace string) map[string]bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.readTxns()
+
+	confirmed := make(map[string]bool, len(l.confirmed[namespace]))
+	for anchorString := range l.confirmed[namespace] {
+		confirmed[anchorString] = true
+	}
+
+	return confirmed
+}
+
+// position returns the number of the last transaction that was read from the ledger
+func (l *ledgerReader) position() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.cursor
+}
+
+// rewind sets the position from which the transactions are read back to the given transaction number (if the
+// transactions following the given transaction number were read already), e.g. in order to confirm an anchor that
+// was written before a restart
+func (l *ledgerReader) rewind(txnNumber int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if txnNumber < l.cursor {
+		l.cursor = txnNumber
+	}
+}
+
+func (l *ledgerReader) readTxns() {
+	for {
+		more, sidetreeTxn := l.reader.Read(l.cursor)
+		if sidetreeTxn == nil {
+			return
+		}
+
+		l.cursor = int(sidetreeTxn.TransactionNumber)
+
+		if l.watched[sidetreeTxn.Namespace][sidetreeTxn.AnchorString] {
+			if l.confirmed[sidetreeTxn.Namespace] == nil {
+				l.confirmed[sidetreeTxn.Namespace] = make(map[string]bool)
+			}
+
+			l.confirmed[sidetreeTxn.Namespace][sidetreeTxn.AnchorString] = true
+		}
+
+		if !more {
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package batch
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/cas"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+	"github.com/trustbloc/sidetree-core-go/pkg/batch/cutter"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
+// SharedBlockchainClient defines an interface to access a blockchain that is shared by multiple namespaces
+type SharedBlockchainClient interface {
+	// WriteNamespaceAnchor writes the anchor string of the given namespace as a transaction to blockchain
+	WriteNamespaceAnchor(namespace, anchor string) error
+	// Read ledger transaction
+	Read(sinceTransactionNumber int) (bool, *txn.SidetreeTxn)
+}
+
+// NamespaceContext contains the context of a namespace of the multi-namespace writer, i.e. the protocol client,
+// the content addressable storage client and the operation queue. (The blockchain client is shared by all namespaces.)
+type NamespaceContext interface {
+	Protocol() protocol.Client
+	CAS() cas.Client
+	OperationQueue() cutter.OperationQueue
+}
+
+// MultiWriter multiplexes the operations of multiple namespaces over a single blockchain client. Each namespace
+// is handled by its own Writer (i.e. it has its own protocol client, operation queue and batch timeout) so that
+// a failure in one namespace (e.g. a CAS error) doesn't stall the other namespaces. The writers share the position
+// from which the transactions on the ledger are read (i.e. the ledger is read once for all namespaces in order to
+// confirm the anchors). The head of the ledger is read if the shared blockchain client implements LedgerHeadReader.
+type MultiWriter struct {
+	blockchain SharedBlockchainClient
+	ledger     *ledgerReader
+	mutex      sync.RWMutex
+	writers    map[string]*Writer
+	started    bool
+	stopped    uint32
+}
+
+// NewMultiWriter returns a new multi-namespace writer that writes anchors to the given blockchain client
+func NewMultiWriter(blockchain SharedBlockchainClient) *MultiWriter {
+	return &MultiWriter{
+		blockchain: blockchain,
+		ledger:     newLedgerReader(blockchain),
+		writers:    make(map[string]*Writer),
+	}
+}
+
+// AddNamespace adds a namespace with the given context and writer options (e.g. batch timeout). If the
+// multi-namespace writer was already started then the writer of the namespace is started immediately.
+func (w *MultiWriter) AddNamespace(namespace string, context NamespaceContext, opts ...Option) error {
+	if w.Stopped() {
+		return errors.New("writer is stopped")
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.writers[namespace]; ok {
+		return fmt.Errorf("namespace [%s] was already added", namespace)
+	}
+
+	writer, err := New(namespace, &namespaceContext{
+		NamespaceContext: context,
+		blockchain:       &namespaceBlockchainClient{namespace: namespace, client: w.blockchain},
+	}, append(opts, withLedgerReader(w.ledger))...)
+	if err != nil {
+		return err
+	}
+
+	if w.started {
+		writer.Start()
+	}
+
+	w.writers[namespace] = writer
+
+	return nil
+}
+
+// Start starts the writers of all namespaces
+func (w *MultiWriter) Start() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.started {
+		return
+	}
+
+	for _, writer := range w.writers {
+		writer.Start()
+	}
+
+	w.started = true
+}
+
+// Stop stops the writers of all namespaces
+func (w *MultiWriter) Stop() {
+	if !atomic.CompareAndSwapUint32(&w.stopped, 0, 1) {
+		// Already stopped
+		return
+	}
+
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	for _, writer := range w.writers {
+		writer.Stop()
+	}
+}
+
+// Stopped returns true if the writer has been stopped
+func (w *MultiWriter) Stopped() bool {
+	return atomic.LoadUint32(&w.stopped) == 1
+}
+
+// Add adds the given operation to the queue of the operation's namespace.
+// An error with code errs.BadRequest is returned if the namespace wasn't added.
+func (w *MultiWriter) Add(operation *batch.OperationInfo) error {
+	writer, err := w.Writer(operation.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return writer.Add(operation)
+}
+
+// IsPending returns true if an operation for the given unique suffix is pending in the writer of the given namespace.
+// An error with code errs.BadRequest is returned if the namespace wasn't added.
+func (w *MultiWriter) IsPending(namespace, uniqueSuffix string) (bool, error) {
+	writer, err := w.Writer(namespace)
+	if err != nil {
+		return false, err
+	}
+
+	return writer.IsPending(namespace, uniqueSuffix)
+}
+
+// Writer returns the writer of the given namespace
+func (w *MultiWriter) Writer(namespace string) (*Writer, error) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	writer, ok := w.writers[namespace]
+	if !ok {
+		return nil, errs.New(errs.BadRequest, "namespace [%s] is not supported by the batch writer", namespace)
+	}
+
+	return writer, nil
+}
+
+// namespaceContext is the context of the writer of a namespace
+type namespaceContext struct {
+	NamespaceContext
+	blockchain BlockchainClient
+}
+
+// Blockchain returns the blockchain client of the namespace
+func (c *namespaceContext) Blockchain() BlockchainClient {
+	return c.blockchain
+}
+
+// namespaceBlockchainClient writes the anchors of a namespace to the shared blockchain client
+type namespaceBlockchainClient struct {
+	namespace string
+	client    SharedBlockchainClient
+}
+
+// WriteAnchor writes the anchor string of the namespace as a transaction to blockchain
+func (c *namespaceBlockchainClient) WriteAnchor(anchor string) error {
+	return c.client.WriteNamespaceAnchor(c.namespace, anchor)
+}
+
+// Read ledger transaction
+func (c *namespaceBlockchainClient) Read(sinceTransactionNumber int) (bool, *txn.SidetreeTxn) {
+	return c.client.Read(sinceTransactionNumber)
+}
@@ -0,0 +1,276 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+	"github.com/trustbloc/sidetree-core-go/pkg/txnhandler"
+)
+
+const testNamespace = "did:sidetree:test"
+
+func TestMultiWriter(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		ctx1 := newMockContext()
+		ctx2 := newMockContext()
+		ctx2.ProtocolClient.Protocol.MaxOperationsPerBatch = 1
+
+		w := NewMultiWriter(blockchain)
+		require.NoError(t, w.AddNamespace(namespace, ctx1))
+		require.NoError(t, w.AddNamespace(testNamespace, ctx2, WithBatchTimeout(100*time.Millisecond)))
+
+		w.Start()
+		defer w.Stop()
+
+		for _, op := range generateOperations(2) {
+			require.NoError(t, w.Add(op))
+			require.NoError(t, w.Add(withNamespace(op, testNamespace)))
+		}
+
+		time.Sleep(time.Second)
+
+		// all of the anchors are written to the shared blockchain client
+		require.Len(t, blockchain.GetAnchors(), 3)
+		require.Len(t, blockchain.GetNamespaceAnchors(namespace), 1)
+		require.Len(t, blockchain.GetNamespaceAnchors(testNamespace), 2)
+
+		for _, anchor := range blockchain.GetNamespaceAnchors(testNamespace) {
+			ad, err := txnhandler.ParseAnchorData(anchor)
+			require.NoError(t, err)
+
+			// the batch files are written to the CAS of the namespace
+			_, _, _, err = getBatchFiles(ctx2.CasClient, ad.AnchorAddress)
+			require.NoError(t, err)
+
+			_, _, _, err = getBatchFiles(ctx1.CasClient, ad.AnchorAddress)
+			require.Error(t, err)
+		}
+
+		// the namespace is included in the transactions read from the blockchain
+		namespaces := make(map[string]int)
+		for i := -1; i < 2; i++ {
+			_, sidetreeTxn := blockchain.Read(i)
+			require.NotNil(t, sidetreeTxn)
+
+			namespaces[sidetreeTxn.Namespace]++
+		}
+
+		require.Equal(t, map[string]int{namespace: 1, testNamespace: 2}, namespaces)
+	})
+
+	t.Run("namespace added after start", func(t *testing.T) {
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		w := NewMultiWriter(blockchain)
+		w.Start()
+		defer w.Stop()
+
+		require.NoError(t, w.AddNamespace(testNamespace, newMockContext()))
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, w.Add(withNamespace(op, testNamespace)))
+
+		pending, err := w.IsPending(testNamespace, op.UniqueSuffix)
+		require.NoError(t, err)
+		require.True(t, pending)
+
+		time.Sleep(time.Second)
+
+		require.Len(t, blockchain.GetNamespaceAnchors(testNamespace), 1)
+	})
+
+	t.Run("failure in one namespace doesn't affect other namespaces", func(t *testing.T) {
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		ctx1 := newMockContext()
+		ctx1.CasClient = mocks.NewMockCasClient(fmt.Errorf("CAS Error"))
+
+		w := NewMultiWriter(blockchain)
+		require.NoError(t, w.AddNamespace(namespace, ctx1))
+		require.NoError(t, w.AddNamespace(testNamespace, newMockContext()))
+
+		w.Start()
+		defer w.Stop()
+
+		for _, op := range generateOperations(3) {
+			require.NoError(t, w.Add(op))
+			require.NoError(t, w.Add(withNamespace(op, testNamespace)))
+		}
+
+		time.Sleep(time.Second)
+
+		require.Empty(t, blockchain.GetNamespaceAnchors(namespace))
+		require.Len(t, blockchain.GetNamespaceAnchors(testNamespace), 2)
+		require.Equal(t, uint(3), ctx1.OpQueue.Len())
+
+		// the namespace recovers once the error is cleared
+		ctx1.CasClient.SetError(nil)
+
+		time.Sleep(3 * time.Second)
+
+		require.Len(t, blockchain.GetNamespaceAnchors(namespace), 2)
+	})
+
+	t.Run("ledger is read once for all namespaces", func(t *testing.T) {
+		client := mocks.NewMockBlockchainClient(nil)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, client.WriteNamespaceAnchor("other", fmt.Sprintf("anchor%d", i)))
+		}
+
+		blockchain := &readingBlockchainClient{MockBlockchainClient: client}
+
+		// the shared blockchain client doesn't implement LedgerHeadReader
+		w := NewMultiWriter(struct{ SharedBlockchainClient }{blockchain})
+		require.NoError(t, w.AddNamespace(namespace, newMockContext(), WithBatchTimeout(100*time.Millisecond)))
+		require.NoError(t, w.AddNamespace(testNamespace, newMockContext(), WithBatchTimeout(100*time.Millisecond)))
+
+		w.Start()
+		defer w.Stop()
+
+		time.Sleep(100 * time.Millisecond)
+
+		require.Equal(t, []int{-1, 0, 1}, blockchain.Reads())
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, w.Add(op))
+		require.NoError(t, w.Add(withNamespace(op, testNamespace)))
+
+		time.Sleep(time.Second)
+
+		require.Len(t, client.GetNamespaceAnchors(namespace), 1)
+		require.Len(t, client.GetNamespaceAnchors(testNamespace), 1)
+
+		for _, pending := range []string{namespace, testNamespace} {
+			isPending, err := w.IsPending(pending, op.UniqueSuffix)
+			require.NoError(t, err)
+			require.False(t, isPending)
+		}
+
+		require.Equal(t, 1, countReads(blockchain.Reads(), -1))
+	})
+
+	t.Run("head of the ledger is read from the shared blockchain client", func(t *testing.T) {
+		client := mocks.NewMockBlockchainClient(nil)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, client.WriteNamespaceAnchor("other", fmt.Sprintf("anchor%d", i)))
+		}
+
+		blockchain := &readingBlockchainClient{MockBlockchainClient: client}
+
+		w := NewMultiWriter(blockchain)
+		require.NoError(t, w.AddNamespace(namespace, newMockContext(), WithBatchTimeout(100*time.Millisecond)))
+		require.NoError(t, w.AddNamespace(testNamespace, newMockContext(), WithBatchTimeout(100*time.Millisecond)))
+
+		w.Start()
+		defer w.Stop()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, w.Add(op))
+		require.NoError(t, w.Add(withNamespace(op, testNamespace)))
+
+		time.Sleep(time.Second)
+
+		require.Len(t, client.GetNamespaceAnchors(namespace), 1)
+		require.Len(t, client.GetNamespaceAnchors(testNamespace), 1)
+		require.Zero(t, countReads(blockchain.Reads(), -1))
+		require.Equal(t, 2, blockchain.Reads()[0])
+	})
+
+	t.Run("error - namespace not supported", func(t *testing.T) {
+		w := NewMultiWriter(mocks.NewMockBlockchainClient(nil))
+		require.NoError(t, w.AddNamespace(namespace, newMockContext()))
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+
+		err = w.Add(withNamespace(op, testNamespace))
+		require.Error(t, err)
+		require.True(t, errors.Is(err, errs.ErrBadRequest))
+		require.Contains(t, err.Error(), "namespace [did:sidetree:test] is not supported by the batch writer")
+
+		_, err = w.Writer(testNamespace)
+		require.Error(t, err)
+
+		_, err = w.IsPending(testNamespace, op.UniqueSuffix)
+		require.True(t, errors.Is(err, errs.ErrBadRequest))
+
+		writer, err := w.Writer(namespace)
+		require.NoError(t, err)
+		require.NotNil(t, writer)
+	})
+
+	t.Run("error - namespace already added", func(t *testing.T) {
+		w := NewMultiWriter(mocks.NewMockBlockchainClient(nil))
+		require.NoError(t, w.AddNamespace(namespace, newMockContext()))
+
+		err := w.AddNamespace(namespace, newMockContext())
+		require.EqualError(t, err, "namespace [did:sidetree] was already added")
+	})
+
+	t.Run("error - writer options", func(t *testing.T) {
+		w := NewMultiWriter(mocks.NewMockBlockchainClient(nil))
+
+		err := w.AddNamespace(namespace, newMockContext(), withError())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to read opts: test error")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		w := NewMultiWriter(mocks.NewMockBlockchainClient(nil))
+		require.NoError(t, w.AddNamespace(namespace, newMockContext()))
+
+		w.Start()
+		require.False(t, w.Stopped())
+
+		w.Stop()
+		// Should be able to call stop multiple times
+		w.Stop()
+		require.True(t, w.Stopped())
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.EqualError(t, w.Add(op), "writer is stopped")
+
+		require.EqualError(t, w.AddNamespace(testNamespace, newMockContext()), "writer is stopped")
+	})
+}
+
+func withNamespace(op *batch.OperationInfo, namespace string) *batch.OperationInfo {
+	return &batch.OperationInfo{
+		Namespace:    namespace,
+		UniqueSuffix: op.UniqueSuffix,
+		Data:         op.Data,
+	}
+}
+
+func countReads(reads []int, since int) int {
+	count := 0
+
+	for _, read := range reads {
+		if read == since {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,595 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opqueue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+)
+
+var logger = log.New("sidetree-core-opqueue")
+
+const (
+	// DefaultMaxSegmentSize is the default maximum size (in bytes) of a segment file
+	DefaultMaxSegmentSize = 1 << 20
+
+	segmentFileExt = ".log"
+	headFileName   = "head"
+	tempFileExt    = ".tmp"
+
+	// each record is prefixed by payload length and payload checksum
+	recordHeaderSize = 8
+
+	dirPerm  = 0700
+	filePerm = 0600
+)
+
+// FileQueue implements a persistent operation queue. Operations are appended to a log that is split
+// into segment files. The position of the head of the queue is stored in a separate file that is
+// updated (and synced to disk) only when operations are removed from the queue. This means that
+// operations which were peeked but not removed (e.g. due to a crash before the batch was committed)
+// are still in the queue after restart. Operations that are removed out of order (i.e. operations that
+// aren't at the head of the queue) are recorded in the head file until the head moves past them.
+type FileQueue struct {
+	mutex          sync.RWMutex
+	dir            string
+	maxSegmentSize int64
+	items          []*entry
+	head           position
+	removed        []position
+	tail           *os.File
+	tailSeq        uint64
+	tailSize       int64
+}
+
+// position is the location of a record in the log
+type position struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// headState is the content of the head file: the position of the head of the queue and the end positions of
+// the records after the head whose operations were removed
+type headState struct {
+	position
+	Removed []position `json:"removed,omitempty"`
+}
+
+type entry struct {
+	op *batch.OperationInfo
+	// end is the position immediately after this entry's record
+	end position
+}
+
+// FileQueueOption defines file queue options such as maximum segment size
+type FileQueueOption func(q *FileQueue)
+
+// WithMaxSegmentSize sets the maximum size (in bytes) of a segment file. A new segment file is started
+// when appending an operation would exceed this size.
+func WithMaxSegmentSize(size int64) FileQueueOption {
+	return func(q *FileQueue) {
+		q.maxSegmentSize = size
+	}
+}
+
+// NewFileQueue opens (or creates) the persistent operation queue in the given directory.
+// Any operations that were added but not removed before the queue was last closed are loaded.
+func NewFileQueue(dir string, opts ...FileQueueOption) (*FileQueue, error) {
+	q := &FileQueue{
+		dir:            dir,
+		maxSegmentSize: DefaultMaxSegmentSize,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create queue directory [%s]", dir)
+	}
+
+	if err := q.load(); err != nil {
+		return nil, errors.WithMessagef(err, "load queue from directory [%s]", dir)
+	}
+
+	return q, nil
+}
+
+// Add adds the given data to the tail of the queue and returns the new length of the queue.
+// The operation is synced to disk before Add returns.
+func (q *FileQueue) Add(data *batch.OperationInfo) (uint, error) {
+	record, err := newRecord(data)
+	if err != nil {
+		return 0, err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.tail == nil {
+		return 0, errors.New("queue is closed")
+	}
+
+	if q.tailSize > 0 && q.tailSize+int64(len(record)) > q.maxSegmentSize {
+		if err := q.rollSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := q.append(record); err != nil {
+		return 0, err
+	}
+
+	q.items = append(q.items, &entry{
+		op:  data,
+		end: position{Segment: q.tailSeq, Offset: q.tailSize},
+	})
+
+	return uint(len(q.items)), nil
+}
+
+// Peek returns (up to) the given number of operations from the head of the queue but does not remove them.
+func (q *FileQueue) Peek(num uint) ([]*batch.OperationInfo, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	n := int(num)
+	if len(q.items) < n {
+		n = len(q.items)
+	}
+
+	ops := make([]*batch.OperationInfo, n)
+	for i := 0; i < n; i++ {
+		ops[i] = q.items[i].op
+	}
+
+	return ops, nil
+}
+
+// Remove removes the given operations (as returned by Peek) from the queue. The operations are identified
+// by identity, i.e. they're removed regardless of their position in the queue.
+// The new head of the queue is synced to disk before Remove returns.
+// Returns the actual number of items that were removed and the new length of the queue.
+func (q *FileQueue) Remove(ops []*batch.OperationInfo) (uint, uint, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	items, removed := q.filter(ops)
+	if len(removed) == 0 {
+		return 0, uint(len(q.items)), nil
+	}
+
+	head := advanceHead(q.head, append(append([]position{}, q.removed...), removed...), items)
+
+	if err := q.writeHead(head); err != nil {
+		return 0, uint(len(q.items)), err
+	}
+
+	q.head = head.position
+	q.removed = head.Removed
+	q.items = items
+
+	q.removeConsumedSegments()
+
+	return uint(len(removed)), uint(len(q.items)), nil
+}
+
+// filter returns the entries of the queue without the given operations along with the end positions
+// of the records of the given operations
+func (q *FileQueue) filter(ops []*batch.OperationInfo) ([]*entry, []position) {
+	toRemove := make(map[*batch.OperationInfo]int)
+	for _, op := range ops {
+		toRemove[op]++
+	}
+
+	var items []*entry
+	var removed []position
+
+	for _, e := range q.items {
+		if toRemove[e.op] > 0 {
+			toRemove[e.op]--
+			removed = append(removed, e.end)
+
+			continue
+		}
+
+		items = append(items, e)
+	}
+
+	return items, removed
+}
+
+// Len returns the length of the queue.
+func (q *FileQueue) Len() uint {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	return uint(len(q.items))
+}
+
+// Close closes the queue. The queue may be re-opened using NewFileQueue.
+func (q *FileQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.tail == nil {
+		return nil
+	}
+
+	err := q.tail.Close()
+	q.tail = nil
+
+	return err
+}
+
+func (q *FileQueue) load() error {
+	head, err := q.readHead()
+	if err != nil {
+		return err
+	}
+
+	removed := make(map[position]bool)
+	for _, pos := range head.Removed {
+		removed[pos] = true
+	}
+
+	seqs, err := q.listSegments()
+	if err != nil {
+		return err
+	}
+
+	var segments []uint64
+
+	for _, seq := range seqs {
+		if seq < head.Segment {
+			// segment was consumed but not deleted before the queue was closed
+			q.deleteSegment(seq)
+			continue
+		}
+
+		segments = append(segments, seq)
+	}
+
+	for i, seq := range segments {
+		offset := int64(0)
+		if seq == head.Segment {
+			offset = head.Offset
+		}
+
+		size, err := q.loadSegment(seq, offset, i == len(segments)-1)
+		if err != nil {
+			return err
+		}
+
+		q.tailSeq = seq
+		q.tailSize = size
+	}
+
+	// drop the operations that were removed out of order
+	items := q.items[:0]
+	for _, e := range q.items {
+		if !removed[e.end] {
+			items = append(items, e)
+		}
+	}
+
+	q.items = items
+	q.head = head.position
+	q.removed = head.Removed
+
+	if len(segments) == 0 {
+		q.tailSeq = head.Segment + 1
+		q.tailSize = 0
+	}
+
+	tail, err := os.OpenFile(q.segmentPath(q.tailSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return errors.Wrapf(err, "open segment [%d]", q.tailSeq)
+	}
+
+	q.tail = tail
+
+	return q.syncDir()
+}
+
+// loadSegment reads the records of the given segment starting at the given offset and returns the size of the
+// valid portion of the segment. An incomplete or corrupted record at the end of the last segment is the result
+// of a crash during Add, so the segment is truncated to the last valid record.
+func (q *FileQueue) loadSegment(seq uint64, offset int64, last bool) (int64, error) {
+	path := q.segmentPath(seq)
+
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return 0, errors.Wrapf(err, "read segment [%d]", seq)
+	}
+
+	if offset > int64(len(content)) {
+		return 0, fmt.Errorf("head offset %d is beyond the end of segment [%d]", offset, seq)
+	}
+
+	for offset < int64(len(content)) {
+		op, size, err := parseRecord(content[offset:])
+		if err != nil {
+			if !last {
+				return 0, errors.Wrapf(err, "corrupted record at offset %d in segment [%d]", offset, seq)
+			}
+
+			logger.Warnf("Truncating segment [%d] at offset %d: %s", seq, offset, err)
+
+			if err := os.Truncate(path, offset); err != nil {
+				return 0, errors.Wrapf(err, "truncate segment [%d]", seq)
+			}
+
+			return offset, nil
+		}
+
+		offset += size
+
+		q.items = append(q.items, &entry{
+			op:  op,
+			end: position{Segment: seq, Offset: offset},
+		})
+	}
+
+	return offset, nil
+}
+
+func (q *FileQueue) append(record []byte) error {
+	if _, err := q.tail.Write(record); err != nil {
+		// remove partially written record so that subsequent records are readable
+		if e := q.tail.Truncate(q.tailSize); e != nil {
+			logger.Errorf("Failed to truncate segment [%d] after write error: %s", q.tailSeq, e)
+		}
+
+		return errors.Wrapf(err, "append to segment [%d]", q.tailSeq)
+	}
+
+	if err := q.tail.Sync(); err != nil {
+		return errors.Wrapf(err, "sync segment [%d]", q.tailSeq)
+	}
+
+	q.tailSize += int64(len(record))
+
+	return nil
+}
+
+func (q *FileQueue) rollSegment() error {
+	seq := q.tailSeq + 1
+
+	tail, err := os.OpenFile(q.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return errors.Wrapf(err, "create segment [%d]", seq)
+	}
+
+	if err := q.syncDir(); err != nil {
+		return err
+	}
+
+	if err := q.tail.Close(); err != nil {
+		logger.Warnf("Failed to close segment [%d]: %s", q.tailSeq, err)
+	}
+
+	q.tail = tail
+	q.tailSeq = seq
+	q.tailSize = 0
+
+	return nil
+}
+
+// removeConsumedSegments deletes all segments before the head segment. Failure to delete a segment
+// is not fatal since consumed segments are also deleted when the queue is loaded.
+func (q *FileQueue) removeConsumedSegments() {
+	seqs, err := q.listSegments()
+	if err != nil {
+		logger.Warnf("Failed to list segments: %s", err)
+		return
+	}
+
+	for _, seq := range seqs {
+		if seq < q.head.Segment {
+			q.deleteSegment(seq)
+		}
+	}
+}
+
+func (q *FileQueue) deleteSegment(seq uint64) {
+	logger.Debugf("Deleting consumed segment [%d]", seq)
+
+	if err := os.Remove(q.segmentPath(seq)); err != nil {
+		logger.Warnf("Failed to delete segment [%d]: %s", seq, err)
+	}
+}
+
+func (q *FileQueue) readHead() (headState, error) {
+	var head headState
+
+	content, err := ioutil.ReadFile(filepath.Join(q.dir, headFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return head, nil
+		}
+
+		return head, errors.Wrap(err, "read head")
+	}
+
+	if err := json.Unmarshal(content, &head); err != nil {
+		return head, errors.Wrap(err, "unmarshal head")
+	}
+
+	return head, nil
+}
+
+// writeHead atomically replaces the head file (write to temp file, sync and rename)
+func (q *FileQueue) writeHead(head headState) error {
+	content, err := json.Marshal(head)
+	if err != nil {
+		return errors.Wrap(err, "marshal head")
+	}
+
+	path := filepath.Join(q.dir, headFileName)
+	tmpPath := path + tempFileExt
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return errors.Wrap(err, "create head")
+	}
+
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "write head")
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.Wrap(err, "rename head")
+	}
+
+	return q.syncDir()
+}
+
+func (q *FileQueue) listSegments() ([]uint64, error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "list segments")
+	}
+
+	var seqs []uint64
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, segmentFileExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentFileExt), 10, 64)
+		if err != nil {
+			logger.Debugf("Ignoring file [%s] in queue directory", name)
+			continue
+		}
+
+		seqs = append(seqs, seq)
+	}
+
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	return seqs, nil
+}
+
+func (q *FileQueue) segmentPath(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, segmentFileExt))
+}
+
+// syncDir syncs the queue directory so that created, renamed and deleted files are persisted
+func (q *FileQueue) syncDir() error {
+	d, err := os.Open(q.dir)
+	if err != nil {
+		return errors.Wrap(err, "open queue directory")
+	}
+
+	err = d.Sync()
+
+	if e := d.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "sync queue directory")
+	}
+
+	return nil
+}
+
+// advanceHead returns the new head state after the records that end at the given positions were removed. The head
+// moves past the removed records that precede the first remaining entry; the positions of the other removed records
+// are retained.
+func advanceHead(head position, removed []position, items []*entry) headState {
+	state := headState{position: head}
+
+	for _, pos := range removed {
+		if len(items) > 0 && !pos.before(items[0].end) {
+			state.Removed = append(state.Removed, pos)
+			continue
+		}
+
+		if state.position.before(pos) {
+			state.position = pos
+		}
+	}
+
+	return state
+}
+
+// before returns true if the position is before the given position in the log
+func (p position) before(other position) bool {
+	if p.Segment == other.Segment {
+		return p.Offset < other.Offset
+	}
+
+	return p.Segment < other.Segment
+}
+
+// newRecord creates a log record: payload length (4 bytes), payload checksum (4 bytes) and payload
+func newRecord(op *batch.OperationInfo) ([]byte, error) {
+	payload, err := json.Marshal(op)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal operation")
+	}
+
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+
+	return record, nil
+}
+
+// parseRecord parses the record at the beginning of the given content and returns the operation and record size
+func parseRecord(content []byte) (*batch.OperationInfo, int64, error) {
+	if len(content) < recordHeaderSize {
+		return nil, 0, errors.New("incomplete record header")
+	}
+
+	size := int64(binary.BigEndian.Uint32(content[0:4]))
+	checksum := binary.BigEndian.Uint32(content[4:8])
+
+	if int64(len(content)) < recordHeaderSize+size {
+		return nil, 0, errors.New("incomplete record payload")
+	}
+
+	payload := content[recordHeaderSize : recordHeaderSize+size]
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, 0, errors.New("record checksum mismatch")
+	}
+
+	op := &batch.OperationInfo{}
+	if err := json.Unmarshal(payload, op); err != nil {
+		return nil, 0, errors.Wrap(err, "unmarshal operation")
+	}
+
+	return op, recordHeaderSize + size, nil
+}
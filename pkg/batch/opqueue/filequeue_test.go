@@ -0,0 +1,501 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opqueue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/batch/cutter"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+)
+
+func TestFileQueue(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	q, err := NewFileQueue(dir)
+	require.NoError(t, err)
+	defer closeQueue(t, q)
+
+	require.Zero(t, q.Len())
+
+	ops, err := q.Peek(1)
+	require.NoError(t, err)
+	require.Empty(t, ops)
+
+	n, l, err := q.Remove(ops)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Zero(t, l)
+
+	l, err = q.Add(op1)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), l)
+	require.Equal(t, uint(1), q.Len())
+
+	l, err = q.Add(op2)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), l)
+	require.Equal(t, uint(2), q.Len())
+
+	l, err = q.Add(op3)
+	require.NoError(t, err)
+	require.Equal(t, uint(3), l)
+	require.Equal(t, uint(3), q.Len())
+
+	ops, err = q.Peek(1)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	require.Equal(t, ops[0], op1)
+
+	ops, err = q.Peek(4)
+	require.NoError(t, err)
+	require.Len(t, ops, 3)
+	require.Equal(t, ops[0], op1)
+	require.Equal(t, ops[1], op2)
+	require.Equal(t, ops[2], op3)
+
+	n, l, err = q.Remove(ops[:1])
+	require.NoError(t, err)
+	require.Equal(t, uint(1), n)
+	require.Equal(t, uint(2), l)
+
+	ops, err = q.Peek(1)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	require.Equal(t, ops[0], op2)
+
+	// operations that aren't in the queue are ignored
+	n, l, err = q.Remove([]*batch.OperationInfo{op1})
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, uint(2), l)
+
+	ops, err = q.Peek(5)
+	require.NoError(t, err)
+
+	n, l, err = q.Remove(ops)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), n)
+	require.Zero(t, l)
+}
+
+func TestFileQueue_Close(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	q, err := NewFileQueue(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Close())
+	require.NoError(t, q.Close())
+
+	l, err := q.Add(op1)
+	require.EqualError(t, err, "queue is closed")
+	require.Zero(t, l)
+}
+
+func TestFileQueue_Recovery(t *testing.T) {
+	t.Run("operations survive restart", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+		require.NoError(t, q.Close())
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op1, op2, op3)
+	})
+
+	t.Run("crash between peek and remove", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+
+		ops, err := q.Peek(2)
+		require.NoError(t, err)
+		require.Len(t, ops, 2)
+
+		// simulate crash - queue is not closed and operations are not removed
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op1, op2, op3)
+	})
+
+	t.Run("removed operations are not recovered", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+
+		n, l, err := q.Remove(peek(t, q, 2))
+		require.NoError(t, err)
+		require.Equal(t, uint(2), n)
+		require.Equal(t, uint(1), l)
+
+		// simulate crash right after remove
+		q = openQueue(t, dir)
+
+		requireOps(t, q, op3)
+
+		n, l, err = q.Remove(peek(t, q, 1))
+		require.NoError(t, err)
+		require.Equal(t, uint(1), n)
+		require.Zero(t, l)
+		require.NoError(t, q.Close())
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		require.Zero(t, q.Len())
+	})
+
+	t.Run("operations removed out of order are not recovered", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+
+		ops := peek(t, q, 3)
+
+		n, l, err := q.Remove(ops[1:2])
+		require.NoError(t, err)
+		require.Equal(t, uint(1), n)
+		require.Equal(t, uint(2), l)
+
+		// simulate crash right after remove
+		q = openQueue(t, dir)
+
+		requireOps(t, q, op1, op3)
+
+		// the head moves past the operation that was removed out of order
+		n, l, err = q.Remove(peek(t, q, 1))
+		require.NoError(t, err)
+		require.Equal(t, uint(1), n)
+		require.Equal(t, uint(1), l)
+		require.Empty(t, q.removed)
+		require.NoError(t, q.Close())
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op3)
+	})
+
+	t.Run("crash during add - incomplete record", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+		require.NoError(t, q.Close())
+
+		// append record header without payload
+		appendToSegment(t, lastSegmentPath(t, dir), []byte{0, 0, 1, 0, 1, 2, 3, 4, '{'})
+
+		q = openQueue(t, dir)
+		requireOps(t, q, op1, op2, op3)
+
+		// new operations must be appended after the last valid record
+		_, err := q.Add(op1)
+		require.NoError(t, err)
+		require.NoError(t, q.Close())
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op1, op2, op3, op1)
+	})
+
+	t.Run("crash during add - incomplete record header", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+		require.NoError(t, q.Close())
+
+		appendToSegment(t, lastSegmentPath(t, dir), []byte{0, 0})
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op1, op2, op3)
+	})
+
+	t.Run("corrupted last record", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+		require.NoError(t, q.Close())
+
+		path := lastSegmentPath(t, dir)
+
+		content, err := ioutil.ReadFile(filepath.Clean(path))
+		require.NoError(t, err)
+
+		// flip the last byte of the last record's payload so that the checksum doesn't match
+		content[len(content)-1] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(path, content, filePerm))
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op1, op2)
+	})
+
+	t.Run("crash during head update", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+
+		_, _, err := q.Remove(peek(t, q, 1))
+		require.NoError(t, err)
+
+		// leftover temporary head file must be ignored
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, headFileName+tempFileExt), []byte("{"), filePerm))
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op2, op3)
+	})
+
+	t.Run("segments", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		// each record fits into its own segment
+		q, err := NewFileQueue(dir, WithMaxSegmentSize(1))
+		require.NoError(t, err)
+
+		for _, op := range []*batch.OperationInfo{op1, op2, op3} {
+			_, err = q.Add(op)
+			require.NoError(t, err)
+		}
+
+		seqs, err := q.listSegments()
+		require.NoError(t, err)
+		require.Len(t, seqs, 3)
+
+		_, _, err = q.Remove(peek(t, q, 2))
+		require.NoError(t, err)
+
+		// first segment is consumed and deleted, second segment is retained since the head points to its end
+		seqs, err = q.listSegments()
+		require.NoError(t, err)
+		require.Len(t, seqs, 2)
+
+		// simulate crash
+		q = openQueue(t, dir, WithMaxSegmentSize(1))
+		requireOps(t, q, op3)
+
+		_, err = q.Add(op1)
+		require.NoError(t, err)
+
+		_, _, err = q.Remove(peek(t, q, 1))
+		require.NoError(t, err)
+		require.NoError(t, q.Close())
+
+		q = openQueue(t, dir, WithMaxSegmentSize(1))
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op1)
+
+		seqs, err = q.listSegments()
+		require.NoError(t, err)
+		require.Len(t, seqs, 2)
+	})
+
+	t.Run("consumed segment not deleted before crash", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q, err := NewFileQueue(dir, WithMaxSegmentSize(1))
+		require.NoError(t, err)
+
+		for _, op := range []*batch.OperationInfo{op1, op2, op3} {
+			_, err = q.Add(op)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, q.writeHead(headState{position: q.items[1].end}))
+		require.NoError(t, q.Close())
+
+		q = openQueue(t, dir)
+		defer closeQueue(t, q)
+
+		requireOps(t, q, op3)
+
+		seqs, err := q.listSegments()
+		require.NoError(t, err)
+		require.Len(t, seqs, 2)
+	})
+
+	t.Run("error - corrupted record in consumed segment", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q, err := NewFileQueue(dir, WithMaxSegmentSize(1))
+		require.NoError(t, err)
+
+		for _, op := range []*batch.OperationInfo{op1, op2} {
+			_, err = q.Add(op)
+			require.NoError(t, err)
+		}
+
+		seqs, err := q.listSegments()
+		require.NoError(t, err)
+		require.NoError(t, q.Close())
+
+		appendToSegment(t, q.segmentPath(seqs[0]), []byte{0, 0})
+
+		q, err = NewFileQueue(dir)
+		require.Error(t, err)
+		require.Nil(t, q)
+		require.Contains(t, err.Error(), "corrupted record at offset")
+	})
+
+	t.Run("error - invalid head", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, headFileName), []byte("{"), filePerm))
+
+		q, err := NewFileQueue(dir)
+		require.Error(t, err)
+		require.Nil(t, q)
+		require.Contains(t, err.Error(), "unmarshal head")
+	})
+
+	t.Run("error - head beyond end of segment", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		q := newQueueWithOps(t, dir)
+		require.NoError(t, q.writeHead(headState{position: position{Segment: q.tailSeq, Offset: q.tailSize + 1}}))
+		require.NoError(t, q.Close())
+
+		q, err := NewFileQueue(dir)
+		require.Error(t, err)
+		require.Nil(t, q)
+		require.Contains(t, err.Error(), "is beyond the end of segment")
+	})
+}
+
+func TestFileQueue_BatchCutter(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	pc := mocks.NewMockProtocolClient()
+	pc.Protocol.MaxOperationsPerBatch = 2
+
+	q := newQueueWithOps(t, dir)
+
+	ops, pending, commit, err := cutter.New(pc, q).Cut(false)
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	require.Equal(t, uint(1), pending)
+	require.NotNil(t, commit)
+
+	// simulate crash before the batch is committed
+	q = openQueue(t, dir)
+
+	c := cutter.New(pc, q)
+
+	ops, pending, commit, err = c.Cut(false)
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	require.Equal(t, op1, ops[0])
+	require.Equal(t, op2, ops[1])
+	require.Equal(t, uint(1), pending)
+
+	pending, err = commit()
+	require.NoError(t, err)
+	require.Equal(t, uint(1), pending)
+
+	// simulate crash after the batch is committed
+	q = openQueue(t, dir)
+	defer closeQueue(t, q)
+
+	requireOps(t, q, op3)
+}
+
+func newTestDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "opqueue")
+	require.NoError(t, err)
+
+	return dir, func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func newQueueWithOps(t *testing.T, dir string) *FileQueue {
+	q := openQueue(t, dir)
+
+	for _, op := range []*batch.OperationInfo{op1, op2, op3} {
+		_, err := q.Add(op)
+		require.NoError(t, err)
+	}
+
+	return q
+}
+
+func openQueue(t *testing.T, dir string, opts ...FileQueueOption) *FileQueue {
+	q, err := NewFileQueue(dir, opts...)
+	require.NoError(t, err)
+
+	return q
+}
+
+func closeQueue(t *testing.T, q *FileQueue) {
+	require.NoError(t, q.Close())
+}
+
+func requireOps(t *testing.T, q *FileQueue, expected ...*batch.OperationInfo) {
+	require.Equal(t, uint(len(expected)), q.Len())
+
+	ops, err := q.Peek(q.Len())
+	require.NoError(t, err)
+	require.Equal(t, expected, ops)
+}
+
+func peek(t *testing.T, q *FileQueue, num uint) []*batch.OperationInfo {
+	ops, err := q.Peek(num)
+	require.NoError(t, err)
+
+	return ops
+}
+
+func lastSegmentPath(t *testing.T, dir string) string {
+	q := &FileQueue{dir: dir}
+
+	seqs, err := q.listSegments()
+	require.NoError(t, err)
+	require.NotEmpty(t, seqs)
+
+	return q.segmentPath(seqs[len(seqs)-1])
+}
+
+func appendToSegment(t *testing.T, path string, content []byte) {
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_APPEND, filePerm)
+	require.NoError(t, err)
+
+	_, err = f.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
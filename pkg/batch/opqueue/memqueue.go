@@ -41,21 +41,33 @@ func (q *MemQueue) Peek(num uint) ([]*batch.OperationInfo, error) {
 	return q.items[0:n], nil
 }
 
-// Remove removes (up to) the given number of items from the head of the queue.
+// Remove removes the given operations (as returned by Peek) from the queue. The operations are identified
+// by identity, i.e. they're removed regardless of their position in the queue.
 // Returns the actual number of items that were removed and the new length of the queue.
-func (q *MemQueue) Remove(num uint) (uint, uint, error) {
+func (q *MemQueue) Remove(ops []*batch.OperationInfo) (uint, uint, error) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	n := int(num)
-	if len(q.items) < n {
-		n = len(q.items)
+	toRemove := make(map[*batch.OperationInfo]int)
+	for _, op := range ops {
+		toRemove[op]++
+	}
+
+	items := make([]*batch.OperationInfo, 0, len(q.items))
+
+	for _, item := range q.items {
+		if toRemove[item] > 0 {
+			toRemove[item]--
+			continue
+		}
+
+		items = append(items, item)
 	}
 
-	items := q.items[0:n]
-	q.items = q.items[n:]
+	n := len(q.items) - len(items)
+	q.items = items
 
-	return uint(len(items)), uint(len(q.items)), nil
+	return uint(n), uint(len(q.items)), nil
 }
 
 // Len returns the length of the queue.
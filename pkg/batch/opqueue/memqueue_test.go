@@ -55,7 +55,7 @@ func TestMemQueue(t *testing.T) {
 	require.Equal(t, ops[1], op2)
 	require.Equal(t, ops[2], op3)
 
-	n, l, err := q.Remove(1)
+	n, l, err := q.Remove(ops[:1])
 	require.NoError(t, err)
 	require.Equal(t, uint(1), n)
 	require.Equal(t, uint(2), l)
@@ -65,7 +65,10 @@ func TestMemQueue(t *testing.T) {
 	require.Len(t, ops, 1)
 	require.Equal(t, ops[0], op2)
 
-	n, l, err = q.Remove(5)
+	ops, err = q.Peek(5)
+	require.NoError(t, err)
+
+	n, l, err = q.Remove(ops)
 	require.NoError(t, err)
 	require.Equal(t, uint(2), n)
 	require.Zero(t, l)
@@ -0,0 +1,149 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opqueue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+)
+
+// DefaultMaxDelay is the default maximum time that a normal priority operation is delayed by high priority operations
+const DefaultMaxDelay = 30 * time.Second
+
+// PriorityQueue implements an in-memory operation queue in which high priority operations (see batch.PriorityHigh)
+// jump ahead of normal priority operations so that they're included in the next batch. In order to prevent
+// starvation, a high priority operation only jumps ahead of normal priority operations that were added less than
+// the maximum delay before it, i.e. a normal priority operation is only delayed by the high priority operations
+// that are added within the maximum delay after it.
+type PriorityQueue struct {
+	mutex    sync.RWMutex
+	maxDelay time.Duration
+	items    []*priorityItem
+	seq      uint64
+	now      func() time.Time
+}
+
+type priorityItem struct {
+	op *batch.OperationInfo
+	// due is the time at which the operation is due, i.e. the time after which it's not overtaken anymore
+	due time.Time
+	seq uint64
+}
+
+// PriorityQueueOption defines priority queue options such as maximum delay
+type PriorityQueueOption func(q *PriorityQueue)
+
+// WithMaxDelay sets the maximum time that a normal priority operation is delayed by high priority operations
+func WithMaxDelay(maxDelay time.Duration) PriorityQueueOption {
+	return func(q *PriorityQueue) {
+		q.maxDelay = maxDelay
+	}
+}
+
+// NewPriorityQueue returns a new priority queue
+func NewPriorityQueue(opts ...PriorityQueueOption) *PriorityQueue {
+	q := &PriorityQueue{
+		maxDelay: DefaultMaxDelay,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// Add adds the given operation to the queue according to its priority and returns the new length of the queue
+func (q *PriorityQueue) Add(data *batch.OperationInfo) (uint, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.seq++
+
+	item := &priorityItem{op: data, due: q.now(), seq: q.seq}
+	if data.Priority < batch.PriorityHigh {
+		item.due = item.due.Add(q.maxDelay)
+	}
+
+	i := sort.Search(len(q.items), func(i int) bool {
+		return item.before(q.items[i])
+	})
+
+	q.items = append(q.items, nil)
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = item
+
+	return uint(len(q.items)), nil
+}
+
+// Peek returns (up to) the given number of operations from the head of the queue but does not remove them.
+func (q *PriorityQueue) Peek(num uint) ([]*batch.OperationInfo, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	n := int(num)
+	if len(q.items) < n {
+		n = len(q.items)
+	}
+
+	ops := make([]*batch.OperationInfo, n)
+	for i, item := range q.items[0:n] {
+		ops[i] = item.op
+	}
+
+	return ops, nil
+}
+
+// Remove removes the given operations (as returned by Peek) from the queue. The operations are identified
+// by identity, i.e. they're removed even if high priority operations were added ahead of them in the meantime.
+// Returns the actual number of items that were removed and the new length of the queue.
+func (q *PriorityQueue) Remove(ops []*batch.OperationInfo) (uint, uint, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	toRemove := make(map[*batch.OperationInfo]int)
+	for _, op := range ops {
+		toRemove[op]++
+	}
+
+	items := make([]*priorityItem, 0, len(q.items))
+
+	for _, item := range q.items {
+		if toRemove[item.op] > 0 {
+			toRemove[item.op]--
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	n := len(q.items) - len(items)
+	q.items = items
+
+	return uint(n), uint(len(q.items)), nil
+}
+
+// Len returns the length of the queue.
+func (q *PriorityQueue) Len() uint {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	return uint(len(q.items))
+}
+
+// before returns true if the item goes before the given item in the queue
+func (item *priorityItem) before(other *priorityItem) bool {
+	if item.due.Equal(other.due) {
+		return item.seq < other.seq
+	}
+
+	return item.due.Before(other.due)
+}
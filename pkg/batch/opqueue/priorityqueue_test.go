@@ -0,0 +1,141 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+)
+
+var (
+	highOp1 = &batch.OperationInfo{Namespace: "ns", UniqueSuffix: "high1", Data: []byte("high1"), Priority: batch.PriorityHigh}
+	highOp2 = &batch.OperationInfo{Namespace: "ns", UniqueSuffix: "high2", Data: []byte("high2"), Priority: batch.PriorityHigh}
+)
+
+func TestPriorityQueue(t *testing.T) {
+	t.Run("normal priority", func(t *testing.T) {
+		q := NewPriorityQueue()
+		require.Zero(t, q.Len())
+
+		ops, err := q.Peek(1)
+		require.NoError(t, err)
+		require.Empty(t, ops)
+
+		for i, op := range []*batch.OperationInfo{op1, op2, op3} {
+			l, err := q.Add(op)
+			require.NoError(t, err)
+			require.Equal(t, uint(i+1), l)
+		}
+
+		require.Equal(t, uint(3), q.Len())
+
+		ops, err = q.Peek(4)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{op1, op2, op3}, ops)
+
+		n, l, err := q.Remove(ops[:1])
+		require.NoError(t, err)
+		require.Equal(t, uint(1), n)
+		require.Equal(t, uint(2), l)
+
+		ops, err = q.Peek(1)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{op2}, ops)
+
+		ops, err = q.Peek(5)
+		require.NoError(t, err)
+
+		n, l, err = q.Remove(ops)
+		require.NoError(t, err)
+		require.Equal(t, uint(2), n)
+		require.Zero(t, l)
+	})
+
+	t.Run("high priority", func(t *testing.T) {
+		q := NewPriorityQueue()
+
+		for _, op := range []*batch.OperationInfo{op1, highOp1, op2, highOp2, op3} {
+			_, err := q.Add(op)
+			require.NoError(t, err)
+		}
+
+		// high priority operations jump ahead of normal priority operations (in the order they were added)
+		ops, err := q.Peek(5)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{highOp1, highOp2, op1, op2, op3}, ops)
+	})
+
+	t.Run("starvation protection", func(t *testing.T) {
+		now := time.Now()
+
+		q := NewPriorityQueue(WithMaxDelay(time.Minute))
+		q.now = func() time.Time { return now }
+
+		_, err := q.Add(op1)
+		require.NoError(t, err)
+
+		now = now.Add(30 * time.Second)
+
+		_, err = q.Add(op2)
+		require.NoError(t, err)
+		_, err = q.Add(highOp1)
+		require.NoError(t, err)
+
+		// the high priority operation that is added after the maximum delay doesn't overtake the first operation
+		now = now.Add(31 * time.Second)
+
+		_, err = q.Add(highOp2)
+		require.NoError(t, err)
+
+		ops, err := q.Peek(4)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{highOp1, op1, highOp2, op2}, ops)
+	})
+
+	t.Run("remove peeked operations", func(t *testing.T) {
+		q := NewPriorityQueue()
+
+		for _, op := range []*batch.OperationInfo{op1, op2, op3} {
+			_, err := q.Add(op)
+			require.NoError(t, err)
+		}
+
+		ops, err := q.Peek(2)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{op1, op2}, ops)
+
+		_, err = q.Add(highOp1)
+		require.NoError(t, err)
+
+		// the peeked operations are removed even though the high priority operation is now at the head of the queue
+		n, l, err := q.Remove(ops)
+		require.NoError(t, err)
+		require.Equal(t, uint(2), n)
+		require.Equal(t, uint(2), l)
+
+		ops, err = q.Peek(2)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{highOp1, op3}, ops)
+
+		// peeking again doesn't affect which operations are removed
+		_, err = q.Peek(2)
+		require.NoError(t, err)
+
+		n, l, err = q.Remove([]*batch.OperationInfo{highOp1})
+		require.NoError(t, err)
+		require.Equal(t, uint(1), n)
+		require.Equal(t, uint(1), l)
+
+		ops, err = q.Peek(1)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.OperationInfo{op3}, ops)
+	})
+}
@@ -0,0 +1,161 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package batch
+
+import (
+	"time"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/operation"
+)
+
+// PendingAnchor is an anchor that was written to the blockchain but that wasn't confirmed yet
+type PendingAnchor struct {
+	Namespace    string `json:"namespace"`
+	AnchorString string `json:"anchorString"`
+	// Operations are the queued operations that were included in the batch files of the anchor
+	Operations []*batch.OperationInfo `json:"operations"`
+	// TransactionNumber is the number of the last transaction that was read from the ledger before the anchor
+	// was written (i.e. the anchor is included in the ledger after this transaction)
+	TransactionNumber int       `json:"transactionNumber"`
+	Written           time.Time `json:"written"`
+	Resubmissions     int       `json:"resubmissions"`
+}
+
+// PendingAnchorStore stores the pending anchors of the batch writer so that the batches of the pending anchors are
+// confirmed after a restart (instead of the operations being anchored again). Pending anchors are keyed by namespace
+// and anchor string.
+type PendingAnchorStore interface {
+	// Put stores (or replaces) the pending anchor
+	Put(anchor *PendingAnchor) error
+
+	// List returns the pending anchors of the given namespace
+	List(namespace string) ([]*PendingAnchor, error)
+
+	// Delete deletes the pending anchor for the given namespace and anchor string
+	Delete(namespace, anchorString string) error
+}
+
+// restore restores the pending anchors of the namespace that were stored before a restart. The queued operations of
+// a pending anchor are in flight (i.e. they aren't batched again) until the anchor is confirmed or the batch is
+// released. The transactions on the ledger are read again from the position at which the anchor was written.
+func (r *Writer) restore() {
+	if r.pendingStore != nil {
+		anchors, err := r.pendingStore.List(r.namespace)
+		if err != nil {
+			logger.Errorf("[%s] Error loading pending anchors: %s", r.namespace, err)
+
+			// the retained batch files may belong to the pending anchors
+			return
+		}
+
+		for _, anchor := range anchors {
+			if err := r.restoreAnchor(anchor); err != nil {
+				logger.Errorf("[%s] Error restoring pending anchor [%s]: %s", r.namespace, anchor.AnchorString, err)
+			}
+		}
+	}
+
+	r.releaseStaleTxnFiles()
+}
+
+// releaseStaleTxnFiles releases the retained batch files of the anchors that weren't restored, i.e. the batch files
+// that were retained before a restart but that no longer belong to a pending anchor
+func (r *Writer) releaseStaleTxnFiles() {
+	lister, ok := r.opsHandler.(RetainedTxnFilesLister)
+	if !ok {
+		return
+	}
+
+	pending := make(map[string]bool)
+	for _, b := range r.pending {
+		pending[b.anchorString] = true
+	}
+
+	for _, anchorString := range lister.RetainedTxnFiles() {
+		if !pending[anchorString] {
+			logger.Infof("[%s] Releasing batch files of anchor [%s] which isn't pending", r.namespace, anchorString)
+
+			r.releaseTxnFiles(anchorString)
+		}
+	}
+}
+
+// restoreAnchor restores the batch of the given pending anchor. A pending anchor whose operations are no longer
+// queued (e.g. the batch was committed before the pending anchor was deleted) is deleted.
+func (r *Writer) restoreAnchor(anchor *PendingAnchor) error {
+	anchored, commit, err := r.batchCutter.Reserve(anchor.Operations)
+	if err != nil {
+		return err
+	}
+
+	b := &anchoredBatch{
+		anchorString:  anchor.AnchorString,
+		anchored:      anchored,
+		numProcessed:  len(anchored),
+		commit:        commit,
+		written:       anchor.Written,
+		resubmissions: anchor.Resubmissions,
+		txnNumber:     anchor.TransactionNumber,
+	}
+
+	if len(anchored) == 0 {
+		logger.Infof("[%s] Operations of pending anchor [%s] are no longer queued", r.namespace, anchor.AnchorString)
+
+		r.deletePending(b)
+
+		return nil
+	}
+
+	for _, op := range anchored {
+		// the operations are parsed in order to update their status (they were parsed when they were anchored)
+		parsed, err := operation.ParseOperation(op.Namespace, op.Data, r.protocol.Current())
+		if err != nil {
+			logger.Warnf("[%s] Unable to parse operation for suffix [%s] of pending anchor [%s]: %s", r.namespace, op.UniqueSuffix, anchor.AnchorString, err)
+
+			continue
+		}
+
+		b.operations = append(b.operations, parsed)
+	}
+
+	r.ledger.watch(r.namespace, b.anchorString)
+	r.ledger.rewind(b.txnNumber)
+
+	r.pending = append(r.pending, b)
+
+	logger.Infof("[%s] Restored %d operations of pending anchor [%s]. Waiting for confirmation ...", r.namespace, len(anchored), b.anchorString)
+
+	return nil
+}
+
+// savePending stores the anchor of the given batch if a pending anchor store was provided
+func (r *Writer) savePending(b *anchoredBatch) error {
+	if r.pendingStore == nil {
+		return nil
+	}
+
+	return r.pendingStore.Put(&PendingAnchor{
+		Namespace:         r.namespace,
+		AnchorString:      b.anchorString,
+		Operations:        b.anchored,
+		TransactionNumber: b.txnNumber,
+		Written:           b.written,
+		Resubmissions:     b.resubmissions,
+	})
+}
+
+// deletePending deletes the anchor of the given batch if a pending anchor store was provided
+func (r *Writer) deletePending(b *anchoredBatch) {
+	if r.pendingStore == nil {
+		return
+	}
+
+	if err := r.pendingStore.Delete(r.namespace, b.anchorString); err != nil {
+		logger.Errorf("[%s] Error deleting pending anchor [%s]: %s", r.namespace, b.anchorString, err)
+	}
+}
@@ -16,6 +16,11 @@ SPDX-License-Identifier: Apache-2.0
 // 4) create an anchor file based on batch file address
 // 5) store anchor file into CAS
 // 6) write the address of anchor file to the underlying blockchain
+// 7) wait for the anchor to be confirmed (i.e. read from the blockchain) before the operations are removed from the
+//    queue; the anchor is written again if it isn't confirmed within the confirmation timeout and the batch is
+//    released (i.e. its operations are batched again) if the anchor isn't confirmed after the maximum number of
+//    resubmissions; the pending anchors may be stored (see PendingAnchorStore) so that they're confirmed after
+//    a restart
 package batch
 
 import (
@@ -28,6 +33,7 @@ import (
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/cas"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
 	"github.com/trustbloc/sidetree-core-go/pkg/batch/cutter"
@@ -39,8 +45,11 @@ import (
 var logger = log.New("sidetree-core-writer")
 
 const (
-	defaultBatchTimeout    = 2 * time.Second
-	defaultSendChannelSize = 100
+	defaultBatchTimeout         = 2 * time.Second
+	defaultConfirmationTimeout  = 10 * time.Minute
+	defaultConfirmationInterval = time.Second
+	defaultMaxResubmissions     = 5
+	defaultSendChannelSize      = 100
 )
 
 // Option defines Writer options such as batch timeout
@@ -48,7 +57,9 @@ type Option func(opts *Options) error
 
 type batchCutter interface {
 	Add(operation *batch.OperationInfo) (uint, error)
+	IsPending(namespace, uniqueSuffix string) (bool, error)
 	Cut(force bool) (ops []*batch.OperationInfo, pending uint, commit cutter.Committer, err error)
+	Reserve(ops []*batch.OperationInfo) (reserved []*batch.OperationInfo, commit cutter.Committer, err error)
 }
 
 type process struct {
@@ -59,15 +70,44 @@ type process struct {
 
 // Writer implements batch writer
 type Writer struct {
-	namespace    string
-	context      Context
-	batchCutter  batchCutter
-	sendChan     chan process
-	exitChan     chan struct{}
-	batchTimeout time.Duration
-	opsHandler   TxnHandler
-	stopped      uint32
-	protocol     protocol.Client
+	namespace            string
+	context              Context
+	batchCutter          batchCutter
+	sendChan             chan process
+	exitChan             chan struct{}
+	batchTimeout         time.Duration
+	confirmationTimeout  time.Duration
+	confirmationInterval time.Duration
+	maxResubmissions     int
+	opsHandler           TxnHandler
+	stopped              uint32
+	protocol             protocol.Client
+	statusStore          opstatus.Store
+	pendingStore         PendingAnchorStore
+	ledger               *ledgerReader
+
+	// the following fields are only accessed by the main goroutine
+	// pending are the batches whose anchors were written but not confirmed yet
+	pending []*anchoredBatch
+}
+
+// anchoredBatch is a batch whose anchor was written to the blockchain
+type anchoredBatch struct {
+	anchorString string
+	// operations are the operations that were included in the batch files
+	operations []*batch.Operation
+	// anchored are the queued operations that were included in the batch files
+	anchored []*batch.OperationInfo
+	// deferred are the operations of the batch that were deferred to the next batch
+	deferred []*batch.OperationInfo
+	// numProcessed is the number of operations of the batch that weren't deferred
+	numProcessed int
+	commit       cutter.Committer
+	written      time.Time
+	// resubmissions is the number of times that the anchor was written again
+	resubmissions int
+	// txnNumber is the number of the last transaction that was read from the ledger before the anchor was written
+	txnNumber int
 }
 
 // Context contains batch writer context
@@ -89,6 +129,15 @@ type BlockchainClient interface {
 	Read(sinceTransactionNumber int) (bool, *txn.SidetreeTxn)
 }
 
+// LedgerHeadReader is implemented by a blockchain client that is able to return the number of the last transaction
+// on the ledger (-1 if there are no transactions). The batch writer looks for the anchors that it writes starting
+// from the head of the ledger at startup. If the blockchain client doesn't implement this interface then the
+// transactions are read up to the head of the ledger on startup instead. The writers of a MultiWriter read the head
+// of the ledger from the shared blockchain client.
+type LedgerHeadReader interface {
+	LastTransactionNumber() (int, error)
+}
+
 // TxnHandler defines an interface for creating chunks, map and anchor files
 type TxnHandler interface {
 
@@ -96,6 +145,20 @@ type TxnHandler interface {
 	PrepareTxnFiles(ops []*batch.Operation) (string, error)
 }
 
+// TxnFilesReleaser is implemented by a TxnHandler that retains the batch files of a prepared batch until they're
+// released (e.g. txnhandler.OperationHandler). The files are released once the anchor was confirmed or if the
+// anchor couldn't be written.
+type TxnFilesReleaser interface {
+	ReleaseTxnFiles(anchorString string)
+}
+
+// RetainedTxnFilesLister is implemented by a TxnFilesReleaser whose retained batch files survive a restart (e.g.
+// txnhandler.OperationHandler with a persistent write manifest). The batch files of the anchors that aren't restored
+// on startup (e.g. the batch was prepared but the anchor wasn't written) are released.
+type RetainedTxnFilesLister interface {
+	RetainedTxnFiles() []string
+}
+
 // CompressionProvider defines an interface for handling different types of compression
 type CompressionProvider interface {
 
@@ -107,6 +170,9 @@ type CompressionProvider interface {
 // Writer accepts operations being delivered via Add, orders them, and then uses the batch
 // cutter to form the operations batch file. This batch file will then be used to create
 // an anchor file. The hash of anchor file will be written to the given ledger.
+// The operations of a batch are removed from the queue once the anchor was confirmed (i.e. read from the ledger).
+// Multiple batches may be awaiting confirmation at the same time. The anchors that are awaiting confirmation are
+// kept in memory only, so the operations of those batches are anchored again after a restart.
 func New(namespace string, context Context, options ...Option) (*Writer, error) {
 	rOpts, err := prepareOptsFromOptions(options...)
 	if err != nil {
@@ -118,6 +184,21 @@ func New(namespace string, context Context, options ...Option) (*Writer, error)
 		batchTimeout = rOpts.BatchTimeout
 	}
 
+	confirmationTimeout := defaultConfirmationTimeout
+	if rOpts.ConfirmationTimeout != 0 {
+		confirmationTimeout = rOpts.ConfirmationTimeout
+	}
+
+	confirmationInterval := defaultConfirmationInterval
+	if rOpts.ConfirmationInterval != 0 {
+		confirmationInterval = rOpts.ConfirmationInterval
+	}
+
+	maxResubmissions := defaultMaxResubmissions
+	if rOpts.MaxResubmissions != 0 {
+		maxResubmissions = rOpts.MaxResubmissions
+	}
+
 	var compressionProvider CompressionProvider
 	if rOpts.CompressionProvider != nil {
 		compressionProvider = rOpts.CompressionProvider
@@ -132,15 +213,26 @@ func New(namespace string, context Context, options ...Option) (*Writer, error)
 		txnHandler = txnhandler.NewOperationHandler(context.CAS(), context.Protocol(), compressionProvider)
 	}
 
+	ledger := rOpts.ledger
+	if ledger == nil {
+		ledger = newLedgerReader(context.Blockchain())
+	}
+
 	return &Writer{
-		namespace:    namespace,
-		batchCutter:  cutter.New(context.Protocol(), context.OperationQueue()),
-		sendChan:     make(chan process, defaultSendChannelSize),
-		exitChan:     make(chan struct{}),
-		batchTimeout: batchTimeout,
-		context:      context,
-		opsHandler:   txnHandler,
-		protocol:     context.Protocol(),
+		namespace:            namespace,
+		batchCutter:          cutter.New(context.Protocol(), context.OperationQueue(), cutter.WithCompressionProvider(compressionProvider)),
+		sendChan:             make(chan process, defaultSendChannelSize),
+		exitChan:             make(chan struct{}),
+		batchTimeout:         batchTimeout,
+		confirmationTimeout:  confirmationTimeout,
+		confirmationInterval: confirmationInterval,
+		maxResubmissions:     maxResubmissions,
+		context:              context,
+		opsHandler:           txnHandler,
+		protocol:             context.Protocol(),
+		statusStore:          rOpts.StatusStore,
+		pendingStore:         rOpts.PendingAnchorStore,
+		ledger:               ledger,
 	}, nil
 }
 
@@ -170,6 +262,7 @@ func (r *Writer) Stopped() bool {
 }
 
 // Add the given operation to a queue of operations to be batched and anchored on blockchain.
+// An error with code errs.Conflict is returned if an operation for the same unique suffix is already pending.
 func (r *Writer) Add(operation *batch.OperationInfo) error {
 	if r.Stopped() {
 		return errors.New("writer is stopped")
@@ -190,8 +283,18 @@ func (r *Writer) Add(operation *batch.OperationInfo) error {
 	}
 }
 
+// IsPending returns true if an operation for the given unique suffix of the given namespace is pending
+// (i.e. it was added but it wasn't anchored yet)
+func (r *Writer) IsPending(namespace, uniqueSuffix string) (bool, error) {
+	return r.batchCutter.IsPending(namespace, uniqueSuffix)
+}
+
 func (r *Writer) main() {
 	var timer <-chan time.Time
+	var confirmationTimer <-chan time.Time
+
+	r.ledger.init()
+	r.restore()
 
 	// On startup, there may be operations in the queue. Send a notification
 	// so that any pending items in the queue may be immediately processed.
@@ -203,11 +306,19 @@ func (r *Writer) main() {
 			logger.Infof("[%s] Handling process notification for batch writer: %v", r.namespace, p)
 			pending := r.processAvailable(p.force) > 0
 			timer = r.handleTimer(timer, pending)
+			confirmationTimer = r.handleConfirmationTimer(confirmationTimer)
 
 		case <-timer:
 			logger.Infof("[%s] Handling batch writer timeout", r.namespace)
 			pending := r.processAvailable(true) > 0
 			timer = r.handleTimer(nil, pending)
+			confirmationTimer = r.handleConfirmationTimer(confirmationTimer)
+
+		case <-confirmationTimer:
+			logger.Debugf("[%s] Handling anchor confirmation check", r.namespace)
+			pending := r.processAvailable(false) > 0
+			timer = r.handleTimer(timer, pending)
+			confirmationTimer = r.handleConfirmationTimer(nil)
 
 		case <-r.exitChan:
 			logger.Infof("[%s] exiting batch writer", r.namespace)
@@ -260,6 +371,11 @@ func (r *Writer) drain() (pending uint, err error) {
 }
 
 func (r *Writer) cutAndProcess(forceCut bool) (numProcessed int, pending uint, err error) {
+	// the batches whose anchors were confirmed are committed first so that their operations aren't counted as pending
+	if err := r.confirm(); err != nil {
+		return 0, 0, err
+	}
+
 	operations, pending, commit, err := r.batchCutter.Cut(forceCut)
 	if err != nil {
 		logger.Errorf("[%s] Error cutting batch: %s", r.namespace, err)
@@ -273,15 +389,140 @@ func (r *Writer) cutAndProcess(forceCut bool) (numProcessed int, pending uint, e
 
 	logger.Infof("[%s] processing %d batch operations ...", r.namespace, len(operations))
 
-	err = r.process(operations)
+	b, err := r.process(operations)
 	if err != nil {
 		logger.Errorf("[%s] Error processing %d batch operations: %s", r.namespace, len(operations), err)
+
+		// the operations are released (i.e. they remain in the queue) so that they're processed again
+		if _, e := commit(operations...); e != nil {
+			logger.Errorf("[%s] Error releasing %d batch operations: %s", r.namespace, len(operations), e)
+		}
+
 		return 0, pending + uint(len(operations)), err
 	}
 
-	logger.Infof("[%s] Successfully processed %d batch operations. Committing to batch cutter ...", r.namespace, len(operations))
+	b.numProcessed = len(operations) - len(b.deferred)
+	b.commit = commit
+
+	if len(b.operations) == 0 {
+		// nothing was anchored so there's nothing to confirm
+		return r.commit(b)
+	}
+
+	logger.Infof("[%s] Successfully processed %d batch operations (%d deferred to the next batch). Waiting for confirmation of anchor [%s] ...", r.namespace, b.numProcessed, len(b.deferred), b.anchorString)
+
+	r.pending = append(r.pending, b)
+
+	// the anchor may have been included in the blockchain already
+	if err := r.confirm(); err != nil {
+		return 0, pending, err
+	}
+
+	return b.numProcessed, pending, nil
+}
+
+// confirm commits the pending batches whose anchors were included in the blockchain. A batch is committed (i.e. its
+// operations are removed from the queue) only after its anchor was confirmed so that the operations aren't lost if
+// the transaction is dropped. The anchor of a batch is written again if it isn't confirmed within the confirmation
+// timeout. If the anchor still isn't confirmed after the maximum number of resubmissions then the batch is released,
+// i.e. its operations are no longer in flight so that they're included in a subsequent batch (and their unique
+// suffixes are no longer pending once they're anchored).
+func (r *Writer) confirm() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+
+	confirmed := r.ledger.read(r.namespace)
+
+	var unconfirmed []*anchoredBatch
+
+	for i, b := range r.pending {
+		if !confirmed[b.anchorString] {
+			if time.Since(b.written) < r.confirmationTimeout {
+				unconfirmed = append(unconfirmed, b)
+
+				continue
+			}
+
+			if b.resubmissions >= r.maxResubmissions {
+				r.release(b)
+
+				continue
+			}
+
+			r.resubmit(b)
+
+			unconfirmed = append(unconfirmed, b)
 
-	pending, err = commit()
+			continue
+		}
+
+		logger.Infof("[%s] Anchor [%s] was confirmed", r.namespace, b.anchorString)
+
+		r.ledger.unwatch(r.namespace, b.anchorString)
+		r.deletePending(b)
+		r.releaseTxnFiles(b.anchorString)
+
+		if _, _, err := r.commit(b); err != nil {
+			r.pending = append(unconfirmed, r.pending[i+1:]...)
+
+			return err
+		}
+	}
+
+	r.pending = unconfirmed
+
+	return nil
+}
+
+// resubmit writes the anchor of the given batch to the blockchain again. (The original transaction may still be
+// included in the blockchain, in which case the operations of the transaction that is included last are ignored by
+// the operation filter of the observer, see processor.OperationValidationFilter.)
+func (r *Writer) resubmit(b *anchoredBatch) {
+	logger.Warnf("[%s] Anchor [%s] wasn't confirmed within %s: writing anchor again", r.namespace, b.anchorString, r.confirmationTimeout)
+
+	b.resubmissions++
+
+	if err := r.context.Blockchain().WriteAnchor(b.anchorString); err != nil {
+		logger.Errorf("[%s] Error writing anchor [%s] again: %s", r.namespace, b.anchorString, err)
+		return
+	}
+
+	b.written = time.Now()
+
+	if err := r.savePending(b); err != nil {
+		logger.Errorf("[%s] Error storing pending anchor [%s]: %s", r.namespace, b.anchorString, err)
+	}
+}
+
+// release releases the given batch whose anchor wasn't confirmed after the maximum number of resubmissions, i.e. all of
+// the operations that weren't rejected remain in the queue and they're included in a subsequent batch. (If the anchor
+// is included in the blockchain after all then the operations that are anchored again are ignored by the operation
+// filter of the observer.)
+func (r *Writer) release(b *anchoredBatch) {
+	reason := fmt.Sprintf("anchor [%s] wasn't confirmed after %d resubmissions", b.anchorString, b.resubmissions)
+
+	logger.Warnf("[%s] Releasing batch: %s", r.namespace, reason)
+
+	r.updateStatus(b.operations, opstatus.Failed, b.anchorString, reason)
+	r.ledger.unwatch(r.namespace, b.anchorString)
+	r.deletePending(b)
+	r.releaseTxnFiles(b.anchorString)
+
+	pending, err := b.commit(append(b.anchored, b.deferred...)...)
+	if err != nil {
+		logger.Errorf("[%s] Error releasing batch of anchor [%s]: %s", r.namespace, b.anchorString, err)
+		return
+	}
+
+	logger.Infof("[%s] Released %d operations of anchor [%s]. Pending operations: %d", r.namespace, len(b.anchored), b.anchorString, pending)
+}
+
+// commit removes the operations of the given batch from the queue (the deferred operations remain in the queue)
+func (r *Writer) commit(b *anchoredBatch) (numProcessed int, pending uint, err error) {
+	logger.Infof("[%s] Committing %d batch operations to batch cutter ...", r.namespace, b.numProcessed)
+
+	pending, err = b.commit(b.deferred...)
 	if err != nil {
 		logger.Errorf("[%s] Batch operations were committed but could not be removed from the queue due to error [%s]. Stopping the batch writer so that no further operations are added.", r.namespace, err)
 		r.Stop()
@@ -290,42 +531,212 @@ func (r *Writer) cutAndProcess(forceCut bool) (numProcessed int, pending uint, e
 
 	logger.Infof("[%s] Successfully committed to batch cutter. Pending operations: %d", r.namespace, pending)
 
-	return len(operations), pending, nil
+	return b.numProcessed, pending, nil
 }
 
-func (r *Writer) process(ops []*batch.OperationInfo) error {
+// process anchors the given operations and returns the anchored batch along with the operations that were deferred
+// to the next batch (in their original order), i.e. operations for a unique suffix that already has an operation in
+// the batch. Operations that can never be anchored (i.e. operations that can't be parsed and operations that can't
+// be included in the batch files) are rejected, i.e. they're removed from the queue along with the batch.
+func (r *Writer) process(ops []*batch.OperationInfo) (*anchoredBatch, error) {
 	if len(ops) == 0 {
-		return errors.New("create batch called with no pending operations, should not happen")
+		return nil, errors.New("create batch called with no pending operations, should not happen")
 	}
 
+	var operations []*batch.Operation
+	var deferredOps []*batch.OperationInfo
 	batchSuffixes := make(map[string]bool)
+	queued := make(map[*batch.Operation]*batch.OperationInfo)
 
-	var operations []*batch.Operation
 	for _, d := range ops {
 		op, err := operation.ParseOperation(d.Namespace, d.Data, r.protocol.Current())
 		if err != nil {
-			return err
+			r.reject(&batch.Operation{Namespace: d.Namespace, UniqueSuffix: d.UniqueSuffix, OperationBuffer: d.Data}, err.Error())
+
+			continue
 		}
 
-		_, ok := batchSuffixes[op.UniqueSuffix]
-		if ok {
-			logger.Warnf("[%s] duplicate suffix[%s] found in batch operations: discarding operation %v", r.namespace, op.UniqueSuffix, op)
+		// the batch cutter rejects an operation for a unique suffix that is already pending, but a persistent queue
+		// that is reloaded from disk may still contain multiple operations for the same unique suffix if it was
+		// populated without that check (e.g. by a previous version), and a batch may not contain more than one
+		// operation per unique suffix
+		if batchSuffixes[op.UniqueSuffix] {
+			logger.Infof("[%s] duplicate suffix[%s] found in batch operations: deferring operation to the next batch", r.namespace, op.UniqueSuffix)
+			deferredOps = append(deferredOps, d)
+
 			continue
 		}
 
-		operations = append(operations, op)
 		batchSuffixes[op.UniqueSuffix] = true
+		operations = append(operations, op)
+		queued[op] = d
 	}
 
-	anchorString, err := r.opsHandler.PrepareTxnFiles(operations)
+	anchorString, batchOps, err := r.prepareTxnFiles(operations)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if len(batchOps) == 0 {
+		logger.Warnf("[%s] None of the %d batch operations were anchored (%d deferred to the next batch)", r.namespace, len(ops), len(deferredOps))
+		return &anchoredBatch{deferred: deferredOps}, nil
+	}
+
+	anchored := make([]*batch.OperationInfo, len(batchOps))
+	for i, op := range batchOps {
+		anchored[i] = queued[op]
 	}
 
-	logger.Infof("[%s] writing anchor string: %s", r.namespace, anchorString)
+	b := &anchoredBatch{
+		anchorString: anchorString,
+		operations:   batchOps,
+		anchored:     anchored,
+		deferred:     deferredOps,
+	}
+
+	if err := r.writeAnchor(b); err != nil {
+		r.releaseTxnFiles(anchorString)
+
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// prepareTxnFiles prepares the batch files for the given operations. Operations that can't be included in the batch
+// files (even if they were the only operation in the batch) are rejected and the batch files are prepared for the
+// remaining operations. The anchor string is returned along with the operations in the batch (none if all of the
+// operations were rejected).
+func (r *Writer) prepareTxnFiles(operations []*batch.Operation) (string, []*batch.Operation, error) {
+	rejected := make(map[*batch.Operation]bool)
+
+	for {
+		batchOps := getOperations(operations, rejected)
+		if len(batchOps) == 0 {
+			return "", nil, nil
+		}
+
+		anchorString, err := r.opsHandler.PrepareTxnFiles(batchOps)
+		if err == nil {
+			return anchorString, batchOps, nil
+		}
+
+		excludedErr := &txnhandler.ExcludedOperationsError{}
+		if !errors.As(err, &excludedErr) {
+			return "", nil, err
+		}
+
+		numRejected := len(rejected)
+
+		for _, excluded := range excludedErr.Operations {
+			if rejected[excluded.Operation] {
+				continue
+			}
+
+			r.reject(excluded.Operation, excluded.Reason)
+			rejected[excluded.Operation] = true
+		}
+
+		if len(rejected) == numRejected {
+			// none of the excluded operations are in the batch so trying again won't help
+			return "", nil, err
+		}
+	}
+}
+
+// releaseTxnFiles releases the batch files of the given anchor if the operation handler retains the batch files
+func (r *Writer) releaseTxnFiles(anchorString string) {
+	if releaser, ok := r.opsHandler.(TxnFilesReleaser); ok {
+		releaser.ReleaseTxnFiles(anchorString)
+	}
+}
+
+// reject sets the status of the given operation (which can never be anchored) to rejected. The operation is removed
+// from the queue when the batch is committed.
+func (r *Writer) reject(op *batch.Operation, reason string) {
+	logger.Warnf("[%s] Rejecting operation for suffix [%s]: %s", r.namespace, op.UniqueSuffix, reason)
+
+	r.updateStatus([]*batch.Operation{op}, opstatus.Rejected, "", reason)
+}
+
+// writeAnchor writes the anchor of the given batch to the blockchain. The pending anchor is stored before it's written
+// so that the batch is confirmed after a restart, even if the anchor was included in the ledger in the meantime.
+func (r *Writer) writeAnchor(b *anchoredBatch) error {
+	r.updateStatus(b.operations, opstatus.Batched, b.anchorString, "")
+
+	logger.Infof("[%s] writing anchor string: %s", r.namespace, b.anchorString)
+
+	// the anchor is watched before it's written since it may be included in the ledger right away
+	r.ledger.watch(r.namespace, b.anchorString)
+
+	b.txnNumber = r.ledger.position()
+	b.written = time.Now()
+
+	if err := r.savePending(b); err != nil {
+		r.ledger.unwatch(r.namespace, b.anchorString)
+
+		return errors.WithMessagef(err, "store pending anchor [%s]", b.anchorString)
+	}
 
 	// Create Sidetree transaction in blockchain (write anchor string)
-	return r.context.Blockchain().WriteAnchor(anchorString)
+	err := r.context.Blockchain().WriteAnchor(b.anchorString)
+	if err != nil {
+		r.ledger.unwatch(r.namespace, b.anchorString)
+		r.deletePending(b)
+
+		return err
+	}
+
+	r.updateStatus(b.operations, opstatus.Anchored, b.anchorString, "")
+
+	return nil
+}
+
+// getOperations returns the operations that aren't rejected
+func getOperations(operations []*batch.Operation, rejected map[*batch.Operation]bool) []*batch.Operation {
+	var ops []*batch.Operation
+	for _, op := range operations {
+		if !rejected[op] {
+			ops = append(ops, op)
+		}
+	}
+
+	return ops
+}
+
+// updateStatus updates the status of the given operations if an operation status store was provided
+func (r *Writer) updateStatus(ops []*batch.Operation, status opstatus.Status, anchorString, reason string) {
+	if r.statusStore == nil {
+		return
+	}
+
+	for _, op := range ops {
+		err := r.statusStore.Put(&opstatus.OperationStatus{
+			ID:           opstatus.ID(op.OperationBuffer),
+			Namespace:    op.Namespace,
+			UniqueSuffix: op.UniqueSuffix,
+			Type:         op.Type,
+			Status:       status,
+			AnchorString: anchorString,
+			Reason:       reason,
+		})
+		if err != nil {
+			// the status is for informational purposes only so the batch is processed regardless
+			logger.Warnf("[%s] Failed to update status of operation for suffix [%s] to [%s]: %s", r.namespace, op.UniqueSuffix, status, err)
+		}
+	}
+}
+
+// handleConfirmationTimer starts the timer for checking the confirmation of the anchors if there are pending batches
+func (r *Writer) handleConfirmationTimer(timer <-chan time.Time) <-chan time.Time {
+	switch {
+	case len(r.pending) == 0:
+		return nil
+	case timer == nil:
+		return time.After(r.confirmationInterval)
+	default:
+		return timer
+	}
 }
 
 func (r *Writer) handleTimer(timer <-chan time.Time, pending bool) <-chan time.Time {
@@ -352,6 +763,31 @@ func WithBatchTimeout(batchTimeout time.Duration) Option {
 	}
 }
 
+//WithConfirmationTimeout allows for specifying the time after which an anchor that wasn't confirmed is written again
+func WithConfirmationTimeout(confirmationTimeout time.Duration) Option {
+	return func(o *Options) error {
+		o.ConfirmationTimeout = confirmationTimeout
+		return nil
+	}
+}
+
+//WithConfirmationInterval allows for specifying the interval at which the confirmation of an anchor is checked
+func WithConfirmationInterval(confirmationInterval time.Duration) Option {
+	return func(o *Options) error {
+		o.ConfirmationInterval = confirmationInterval
+		return nil
+	}
+}
+
+//WithMaxResubmissions allows for specifying the number of times that an anchor that wasn't confirmed is written again
+//before its batch is released (i.e. the operations of the batch are batched again)
+func WithMaxResubmissions(maxResubmissions int) Option {
+	return func(o *Options) error {
+		o.MaxResubmissions = maxResubmissions
+		return nil
+	}
+}
+
 //WithOperationHandler allows for specifying handler for creating anchor/batch files
 func WithOperationHandler(opsHandler TxnHandler) Option {
 	return func(o *Options) error {
@@ -368,11 +804,44 @@ func WithCompressionProvider(compressionProvider CompressionProvider) Option {
 	}
 }
 
+//WithOperationStatusStore allows for specifying the store that is updated when operations are batched and anchored
+func WithOperationStatusStore(store opstatus.Store) Option {
+	return func(o *Options) error {
+		o.StatusStore = store
+		return nil
+	}
+}
+
+//WithPendingAnchorStore allows for specifying the store of the anchors that were written but not confirmed yet, i.e.
+//the batches of the pending anchors are confirmed after a restart instead of being anchored again
+func WithPendingAnchorStore(store PendingAnchorStore) Option {
+	return func(o *Options) error {
+		o.PendingAnchorStore = store
+		return nil
+	}
+}
+
+// withLedgerReader sets the ledger reader that is shared by the writers of a multi-namespace writer
+func withLedgerReader(ledger *ledgerReader) Option {
+	return func(o *Options) error {
+		o.ledger = ledger
+		return nil
+	}
+}
+
 // Options allows the user to specify more advanced options
 type Options struct {
-	BatchTimeout        time.Duration
-	OpsHandler          TxnHandler
-	CompressionProvider CompressionProvider
+	BatchTimeout         time.Duration
+	ConfirmationTimeout  time.Duration
+	ConfirmationInterval time.Duration
+	MaxResubmissions     int
+	OpsHandler           TxnHandler
+	CompressionProvider  CompressionProvider
+	StatusStore          opstatus.Store
+	PendingAnchorStore   PendingAnchorStore
+
+	// ledger is the ledger reader that is shared by the writers of a multi-namespace writer
+	ledger *ledgerReader
 }
 
 //prepareOptsFromOptions reads options
@@ -7,9 +7,13 @@ SPDX-License-Identifier: Apache-2.0
 package batch
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,13 +21,17 @@ import (
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/cas"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
 	"github.com/trustbloc/sidetree-core-go/pkg/batch/cutter"
 	"github.com/trustbloc/sidetree-core-go/pkg/batch/opqueue"
 	"github.com/trustbloc/sidetree-core-go/pkg/commitment"
 	"github.com/trustbloc/sidetree-core-go/pkg/compression"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+	"github.com/trustbloc/sidetree-core-go/pkg/operation"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/helper"
 	"github.com/trustbloc/sidetree-core-go/pkg/txnhandler"
 	"github.com/trustbloc/sidetree-core-go/pkg/txnhandler/models"
@@ -183,43 +191,282 @@ func TestBatchTimer(t *testing.T) {
 	require.Equal(t, 1, len(cf.Deltas))
 }
 
-func TestDiscardDuplicateSuffixInBatchFile(t *testing.T) {
+func TestAddDuplicateSuffix(t *testing.T) {
 	ctx := newMockContext()
 	writer, err := New(namespace, ctx)
 	require.Nil(t, err)
 
-	writer.Start()
-	defer writer.Stop()
-
 	operation, err := generateOperation(1)
 	require.NoError(t, err)
 
 	err = writer.Add(operation)
 	require.Nil(t, err)
 
-	// add same operation again
+	// add same operation again - rejected since the first operation is still pending
 	err = writer.Add(operation)
-	require.Nil(t, err)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, errs.ErrConflict))
+
+	writer.Start()
+	defer writer.Stop()
 
 	time.Sleep(time.Second)
 
-	// we should have 1 anchors: 2 operations % max 2 operations per batch
 	require.Equal(t, 1, len(ctx.BlockchainClient.GetAnchors()))
 
-	ad, err := txnhandler.ParseAnchorData(ctx.BlockchainClient.GetAnchors()[0])
+	// the operation is no longer pending
+	err = writer.Add(operation)
+	require.Nil(t, err)
+}
+
+func TestDeferDuplicateSuffixInBatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opqueue")
 	require.NoError(t, err)
 
-	// Check that first anchor has one operation per batch; second one has been discarded
-	af, mf, cf, err := getBatchFiles(ctx.CasClient, ad.AnchorAddress)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	operations := generateOperations(2)
+
+	// the persistent queue was populated without the duplicate check of the batch cutter (e.g. by a previous
+	// version), so the queue contains two operations for the same unique suffix when it's reloaded from disk
+	q, err := opqueue.NewFileQueue(dir)
+	require.NoError(t, err)
+
+	for _, op := range []*batch.OperationInfo{operations[0], operations[0], operations[1]} {
+		_, err = q.Add(op)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, q.Close())
+
+	q, err = opqueue.NewFileQueue(dir)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, q.Close())
+	}()
+
+	require.Equal(t, uint(3), q.Len())
+
+	ctx := newMockContext()
+	ctx.ProtocolClient.Protocol.MaxOperationsPerBatch = 3
+	ctx.OpQueue = q
+
+	writer, err := New(namespace, ctx)
 	require.Nil(t, err)
 
-	require.Equal(t, 1, len(af.Operations.Create))
-	require.Equal(t, 0, len(af.Operations.Recover))
-	require.Equal(t, 0, len(af.Operations.Deactivate))
+	writer.Start()
+	defer writer.Stop()
 
-	require.Equal(t, 0, len(mf.Operations.Update))
+	time.Sleep(time.Second)
 
-	require.Equal(t, 1, len(cf.Deltas))
+	// the duplicate operation is deferred to the next batch
+	anchors := ctx.BlockchainClient.GetAnchors()
+	require.Equal(t, 2, len(anchors))
+	require.Zero(t, ctx.OpQueue.Len())
+
+	for i, expected := range []int{2, 1} {
+		ad, err := txnhandler.ParseAnchorData(anchors[i])
+		require.NoError(t, err)
+
+		af, _, cf, err := getBatchFiles(ctx.CasClient, ad.AnchorAddress)
+		require.Nil(t, err)
+
+		require.Equal(t, expected, len(af.Operations.Create))
+		require.Equal(t, expected, len(cf.Deltas))
+	}
+}
+
+func TestRejectOperations(t *testing.T) {
+	t.Run("excluded operation", func(t *testing.T) {
+		ctx := newMockContext()
+		ctx.ProtocolClient.Protocol.MaxOperationsPerBatch = 3
+
+		operations := generateOperations(3)
+
+		opsHandler := &excludingOpsHandler{
+			TxnHandler: txnhandler.NewOperationHandler(ctx.CasClient, ctx.ProtocolClient, compression.New(compression.WithDefaultAlgorithms())),
+			exclude:    operations[1].Data,
+		}
+
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		writer, err := New(namespace, ctx, WithOperationHandler(opsHandler), WithOperationStatusStore(statusStore))
+		require.Nil(t, err)
+
+		for _, op := range operations {
+			require.NoError(t, writer.Add(op))
+		}
+
+		writer.Start()
+		defer writer.Stop()
+
+		time.Sleep(time.Second)
+
+		// the excluded operation is removed from the queue and the remaining operations are anchored
+		anchors := ctx.BlockchainClient.GetAnchors()
+		require.Equal(t, 1, len(anchors))
+		require.Zero(t, ctx.OpQueue.Len())
+
+		ad, err := txnhandler.ParseAnchorData(anchors[0])
+		require.NoError(t, err)
+		require.Equal(t, 2, ad.NumberOfOperations)
+
+		status, err := statusStore.Get(opstatus.ID(operations[1].Data))
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Rejected, status.Status)
+		require.Equal(t, "excluded", status.Reason)
+	})
+
+	t.Run("all operations excluded", func(t *testing.T) {
+		ctx := newMockContext()
+		ctx.ProtocolClient.Protocol.MaxChunkFileSize = 20
+
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		writer, err := New(namespace, ctx, WithBatchTimeout(100*time.Millisecond), WithOperationStatusStore(statusStore))
+		require.Nil(t, err)
+
+		operations := generateOperations(2)
+		for _, op := range operations {
+			require.NoError(t, writer.Add(op))
+		}
+
+		writer.Start()
+		defer writer.Stop()
+
+		time.Sleep(300 * time.Millisecond)
+
+		// the operations can never be anchored so they're removed from the queue
+		require.Zero(t, len(ctx.BlockchainClient.GetAnchors()))
+		require.Zero(t, ctx.OpQueue.Len())
+
+		for _, op := range operations {
+			status, err := statusStore.Get(opstatus.ID(op.Data))
+			require.NoError(t, err)
+			require.Equal(t, opstatus.Rejected, status.Status)
+			require.NotEmpty(t, status.Reason)
+		}
+	})
+
+	t.Run("operation can't be parsed", func(t *testing.T) {
+		ctx := newMockContext()
+
+		invalidOp := &batch.OperationInfo{Data: []byte("invalid"), UniqueSuffix: "invalid", Namespace: namespace}
+
+		validOp, err := generateOperation(1)
+		require.NoError(t, err)
+
+		// the invalid operation was added to the queue directly
+		for _, op := range []*batch.OperationInfo{invalidOp, validOp} {
+			_, err = ctx.OpQueue.Add(op)
+			require.NoError(t, err)
+		}
+
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		writer, err := New(namespace, ctx, WithBatchTimeout(100*time.Millisecond), WithOperationStatusStore(statusStore))
+		require.Nil(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		time.Sleep(300 * time.Millisecond)
+
+		// the invalid operation doesn't prevent the valid operation from being anchored
+		anchors := ctx.BlockchainClient.GetAnchors()
+		require.Equal(t, 1, len(anchors))
+		require.Zero(t, ctx.OpQueue.Len())
+
+		ad, err := txnhandler.ParseAnchorData(anchors[0])
+		require.NoError(t, err)
+		require.Equal(t, 1, ad.NumberOfOperations)
+
+		status, err := statusStore.Get(opstatus.ID(invalidOp.Data))
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Rejected, status.Status)
+		require.Equal(t, "invalid", status.UniqueSuffix)
+		require.NotEmpty(t, status.Reason)
+	})
+}
+
+func TestOperationStatus(t *testing.T) {
+	t.Run("anchored", func(t *testing.T) {
+		ctx := newMockContext()
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		writer, err := New(namespace, ctx, WithOperationStatusStore(statusStore))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		operations := generateOperations(2)
+		for _, op := range operations {
+			require.NoError(t, writer.Add(op))
+		}
+
+		time.Sleep(time.Second)
+
+		anchors := ctx.BlockchainClient.GetAnchors()
+		require.Len(t, anchors, 1)
+
+		for _, op := range operations {
+			status, err := statusStore.Get(opstatus.ID(op.Data))
+			require.NoError(t, err)
+			require.Equal(t, opstatus.Anchored, status.Status)
+			require.Equal(t, anchors[0], status.AnchorString)
+			require.Equal(t, batch.OperationTypeCreate, status.Type)
+			require.NotEmpty(t, status.UniqueSuffix)
+		}
+	})
+
+	t.Run("blockchain error", func(t *testing.T) {
+		ctx := newMockContext()
+		ctx.BlockchainClient = mocks.NewMockBlockchainClient(fmt.Errorf("blockchain error"))
+
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		writer, err := New(namespace, ctx, WithOperationStatusStore(statusStore))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(time.Second)
+
+		status, err := statusStore.Get(opstatus.ID(op.Data))
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Batched, status.Status)
+		require.NotEmpty(t, status.AnchorString)
+	})
+
+	t.Run("status store error", func(t *testing.T) {
+		ctx := newMockContext()
+		statusStore := mocks.NewMockOperationStatusStore()
+		statusStore.Err = fmt.Errorf("status store error")
+
+		writer, err := New(namespace, ctx, WithOperationStatusStore(statusStore))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(time.Second)
+
+		// the operation is anchored regardless
+		require.Len(t, ctx.BlockchainClient.GetAnchors(), 1)
+	})
 }
 
 func TestProcessOperationsError(t *testing.T) {
@@ -265,6 +512,382 @@ func TestBlockchainError(t *testing.T) {
 	require.Equal(t, 0, len(ctx.BlockchainClient.GetAnchors()))
 }
 
+func TestAnchorConfirmation(t *testing.T) {
+	t.Run("dropped anchor is written again", func(t *testing.T) {
+		ctx := newMockContext()
+		blockchain := &droppingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient, drop: 1}
+
+		writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain},
+			WithConfirmationTimeout(500*time.Millisecond), WithConfirmationInterval(20*time.Millisecond))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		for _, op := range generateOperations(4) {
+			require.NoError(t, writer.Add(op))
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		// the next batch is anchored while the anchor of the first batch isn't confirmed and the operations of the
+		// first batch remain in the queue until its anchor is confirmed
+		require.Len(t, ctx.BlockchainClient.GetAnchors(), 1)
+		require.Equal(t, 2, blockchain.Writes())
+		require.Equal(t, uint(2), ctx.OpQueue.Len())
+
+		time.Sleep(time.Second)
+
+		require.Len(t, ctx.BlockchainClient.GetAnchors(), 2)
+		require.Equal(t, 3, blockchain.Writes())
+		require.Zero(t, ctx.OpQueue.Len())
+	})
+
+	t.Run("error writing anchor again", func(t *testing.T) {
+		ctx := newMockContext()
+		blockchain := &droppingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient, drop: 1, errs: 1}
+
+		writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain},
+			WithConfirmationTimeout(100*time.Millisecond), WithConfirmationInterval(20*time.Millisecond))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		for _, op := range generateOperations(2) {
+			require.NoError(t, writer.Add(op))
+		}
+
+		time.Sleep(time.Second)
+
+		// the anchor is written again after the error
+		require.Len(t, ctx.BlockchainClient.GetAnchors(), 1)
+		require.Equal(t, 3, blockchain.Writes())
+		require.Zero(t, ctx.OpQueue.Len())
+	})
+
+	t.Run("batch is released after max resubmissions", func(t *testing.T) {
+		ctx := newMockContext()
+		blockchain := &droppingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient, drop: 100}
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain},
+			WithConfirmationTimeout(100*time.Millisecond), WithConfirmationInterval(20*time.Millisecond),
+			WithMaxResubmissions(1), WithOperationStatusStore(statusStore))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		// the batch isn't full so the operation is batched again after the batch timeout
+		operations := generateOperations(1)
+		for _, op := range operations {
+			require.NoError(t, writer.Add(op))
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		require.Equal(t, 2, blockchain.Writes())
+		require.Equal(t, uint(1), ctx.OpQueue.Len())
+
+		for _, op := range operations {
+			status, err := statusStore.Get(opstatus.ID(op.Data))
+			require.NoError(t, err)
+			require.Equal(t, opstatus.Failed, status.Status)
+			require.Contains(t, status.Reason, "wasn't confirmed after 1 resubmissions")
+
+			pending, err := writer.IsPending(op.Namespace, op.UniqueSuffix)
+			require.NoError(t, err)
+			require.True(t, pending)
+		}
+
+		blockchain.mutex.Lock()
+		blockchain.drop = 0
+		blockchain.mutex.Unlock()
+
+		time.Sleep(2500 * time.Millisecond)
+
+		require.Equal(t, 3, blockchain.Writes())
+		require.Len(t, ctx.BlockchainClient.GetAnchors(), 1)
+		require.Zero(t, ctx.OpQueue.Len())
+
+		for _, op := range operations {
+			status, err := statusStore.Get(opstatus.ID(op.Data))
+			require.NoError(t, err)
+			require.Equal(t, opstatus.Anchored, status.Status)
+		}
+	})
+
+	t.Run("anchor of another namespace", func(t *testing.T) {
+		ctx := newMockContext()
+
+		writer, err := New(namespace, ctx, WithConfirmationInterval(20*time.Millisecond))
+		require.NoError(t, err)
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+
+		anchorString, err := txnhandler.NewOperationHandler(ctx.CasClient, ctx.ProtocolClient,
+			compression.New(compression.WithDefaultAlgorithms())).PrepareTxnFiles(getBatchOperations(t, op))
+		require.NoError(t, err)
+
+		// the same anchor string is written by another namespace
+		require.NoError(t, ctx.BlockchainClient.WriteNamespaceAnchor("other", anchorString))
+
+		writer.Start()
+		defer writer.Stop()
+
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(200 * time.Millisecond)
+
+		require.Len(t, ctx.BlockchainClient.GetNamespaceAnchors(namespace), 1)
+		require.Zero(t, ctx.OpQueue.Len())
+	})
+
+	t.Run("anchors are read from the head of the ledger", func(t *testing.T) {
+		ctx := newMockContext()
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, ctx.BlockchainClient.WriteNamespaceAnchor("other", fmt.Sprintf("anchor%d", i)))
+		}
+
+		blockchain := &readingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient}
+
+		writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain})
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(200 * time.Millisecond)
+
+		require.Len(t, ctx.BlockchainClient.GetNamespaceAnchors(namespace), 1)
+		require.Zero(t, ctx.OpQueue.Len())
+		require.Equal(t, []int{2}, blockchain.Reads())
+	})
+
+	t.Run("transactions are read up to the head of the ledger on startup", func(t *testing.T) {
+		ctx := newMockContext()
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, ctx.BlockchainClient.WriteNamespaceAnchor("other", fmt.Sprintf("anchor%d", i)))
+		}
+
+		blockchain := &readingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient}
+
+		// the blockchain client doesn't implement LedgerHeadReader
+		writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: struct{ BlockchainClient }{blockchain}})
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(200 * time.Millisecond)
+
+		require.Len(t, ctx.BlockchainClient.GetNamespaceAnchors(namespace), 1)
+		require.Zero(t, ctx.OpQueue.Len())
+		require.Equal(t, []int{-1, 0, 1, 2}, blockchain.Reads())
+	})
+
+	t.Run("batch files are retained until the anchor is confirmed", func(t *testing.T) {
+		ctx := newMockContext()
+		blockchain := &droppingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient, drop: 1}
+		manifest := txnhandler.NewWriteManifest()
+
+		opsHandler := txnhandler.NewOperationHandler(ctx.CasClient, ctx.ProtocolClient,
+			compression.New(compression.WithDefaultAlgorithms()), txnhandler.WithWriteManifest(manifest))
+
+		writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain},
+			WithOperationHandler(opsHandler),
+			WithConfirmationTimeout(500*time.Millisecond), WithConfirmationInterval(20*time.Millisecond))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		for _, op := range generateOperations(2) {
+			require.NoError(t, writer.Add(op))
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		require.Empty(t, ctx.BlockchainClient.GetAnchors())
+		require.Len(t, manifest.Addresses(), 3)
+
+		time.Sleep(time.Second)
+
+		require.Len(t, ctx.BlockchainClient.GetAnchors(), 1)
+		require.Empty(t, manifest.Addresses())
+	})
+}
+
+func TestPendingAnchors(t *testing.T) {
+	t.Run("pending anchor is confirmed after restart", func(t *testing.T) {
+		ctx := newMockContext()
+		blockchain := &droppingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient, drop: 1}
+		store := newPendingAnchorStore()
+
+		writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain},
+			WithBatchTimeout(100*time.Millisecond), WithPendingAnchorStore(store))
+		require.NoError(t, err)
+
+		writer.Start()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(300 * time.Millisecond)
+
+		writer.Stop()
+
+		anchors, err := store.List(namespace)
+		require.NoError(t, err)
+		require.Len(t, anchors, 1)
+		require.Equal(t, []*batch.OperationInfo{op}, anchors[0].Operations)
+		require.Equal(t, -1, anchors[0].TransactionNumber)
+		require.Equal(t, uint(1), ctx.OpQueue.Len())
+
+		// the transaction is included in the ledger while the batch writer is stopped
+		require.NoError(t, ctx.BlockchainClient.WriteNamespaceAnchor(namespace, anchors[0].AnchorString))
+		require.NoError(t, ctx.BlockchainClient.WriteNamespaceAnchor("other", "anchor"))
+
+		writer, err = New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain},
+			WithBatchTimeout(100*time.Millisecond), WithPendingAnchorStore(store))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		time.Sleep(300 * time.Millisecond)
+
+		// the operation isn't anchored again
+		require.Equal(t, 1, blockchain.Writes())
+		require.Len(t, ctx.BlockchainClient.GetNamespaceAnchors(namespace), 1)
+		require.Zero(t, ctx.OpQueue.Len())
+
+		anchors, err = store.List(namespace)
+		require.NoError(t, err)
+		require.Empty(t, anchors)
+	})
+
+	t.Run("batch files of pending anchor are retained after restart", func(t *testing.T) {
+		ctx := newMockContext()
+		blockchain := &droppingBlockchainClient{MockBlockchainClient: ctx.BlockchainClient, drop: 1}
+		store := newPendingAnchorStore()
+		mstore := newManifestStore()
+
+		newWriter := func() (*Writer, *txnhandler.WriteManifest) {
+			manifest, err := txnhandler.NewPersistentWriteManifest(mstore)
+			require.NoError(t, err)
+
+			opsHandler := txnhandler.NewOperationHandler(ctx.CasClient, ctx.ProtocolClient,
+				compression.New(compression.WithDefaultAlgorithms()), txnhandler.WithWriteManifest(manifest))
+
+			writer, err := New(namespace, &blockchainContext{mockContext: ctx, blockchain: blockchain},
+				WithOperationHandler(opsHandler), WithBatchTimeout(100*time.Millisecond),
+				WithConfirmationInterval(20*time.Millisecond), WithPendingAnchorStore(store))
+			require.NoError(t, err)
+
+			return writer, manifest
+		}
+
+		writer, manifest := newWriter()
+
+		writer.Start()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(300 * time.Millisecond)
+
+		writer.Stop()
+
+		// a batch was prepared before the restart but its anchor wasn't written
+		require.NoError(t, manifest.Prepared("stale", []string{"address"}))
+
+		anchors, err := store.List(namespace)
+		require.NoError(t, err)
+		require.Len(t, anchors, 1)
+
+		writer, manifest = newWriter()
+		require.Len(t, manifest.Addresses(), 4)
+
+		writer.Start()
+		defer writer.Stop()
+
+		time.Sleep(100 * time.Millisecond)
+
+		// the files of the pending anchor are retained and the files of the stale batch are released
+		require.Equal(t, []string{anchors[0].AnchorString}, manifest.Batches())
+		require.Len(t, manifest.Addresses(), 3)
+
+		require.NoError(t, ctx.BlockchainClient.WriteNamespaceAnchor(namespace, anchors[0].AnchorString))
+
+		time.Sleep(300 * time.Millisecond)
+
+		require.Empty(t, manifest.Addresses())
+
+		batches, err := mstore.Get()
+		require.NoError(t, err)
+		require.Empty(t, batches)
+	})
+
+	t.Run("operations of pending anchor are no longer queued", func(t *testing.T) {
+		ctx := newMockContext()
+		store := newPendingAnchorStore()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+
+		require.NoError(t, store.Put(&PendingAnchor{Namespace: namespace, AnchorString: "anchor", Operations: []*batch.OperationInfo{op}}))
+
+		writer, err := New(namespace, ctx, WithPendingAnchorStore(store))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		time.Sleep(100 * time.Millisecond)
+
+		anchors, err := store.List(namespace)
+		require.NoError(t, err)
+		require.Empty(t, anchors)
+	})
+
+	t.Run("error storing pending anchor", func(t *testing.T) {
+		ctx := newMockContext()
+		store := newPendingAnchorStore()
+		store.err = errors.New("injected store error")
+
+		writer, err := New(namespace, ctx, WithBatchTimeout(100*time.Millisecond), WithPendingAnchorStore(store))
+		require.NoError(t, err)
+
+		writer.Start()
+		defer writer.Stop()
+
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
+		time.Sleep(300 * time.Millisecond)
+
+		// the anchor isn't written so the operation remains in the queue
+		require.Empty(t, ctx.BlockchainClient.GetAnchors())
+		require.Equal(t, uint(1), ctx.OpQueue.Len())
+	})
+}
+
 func TestAddAfterStop(t *testing.T) {
 	writer, err := New(namespace, newMockContext())
 	require.Nil(t, err)
@@ -356,26 +979,32 @@ func TestStartWithExistingItems(t *testing.T) {
 
 func TestProcessError(t *testing.T) {
 	t.Run("process operation error", func(t *testing.T) {
-		q := &mocks.OperationQueue{}
-
-		invalidQueue := []*batch.OperationInfo{{Data: []byte(""), UniqueSuffix: "unique", Namespace: "ns"}}
-
-		q.LenReturns(1)
-		q.PeekReturns(invalidQueue, nil)
-
 		ctx := newMockContext()
-		ctx.ProtocolClient.Protocol.MaxOperationsPerBatch = 1
-		ctx.OpQueue = q
+		ctx.CasClient = mocks.NewMockCasClient(errors.New("injected CAS error"))
 
-		writer, err := New("test1", ctx, WithBatchTimeout(10*time.Millisecond))
+		writer, err := New(namespace, ctx, WithBatchTimeout(10*time.Millisecond))
 		require.NoError(t, err)
 
+		op, err := generateOperation(1)
+		require.NoError(t, err)
+		require.NoError(t, writer.Add(op))
+
 		writer.Start()
 		defer writer.Stop()
 
 		time.Sleep(50 * time.Millisecond)
 
+		// the operation remains in the queue
 		require.Zero(t, len(ctx.BlockchainClient.GetAnchors()))
+		require.Equal(t, uint(1), ctx.OpQueue.Len())
+
+		// the operation is processed again once the error is cleared
+		ctx.CasClient.SetError(nil)
+
+		time.Sleep(100 * time.Millisecond)
+
+		require.Len(t, ctx.BlockchainClient.GetAnchors(), 1)
+		require.Zero(t, ctx.OpQueue.Len())
 	})
 
 	t.Run("Cut error", func(t *testing.T) {
@@ -414,7 +1043,7 @@ func TestProcessError(t *testing.T) {
 		ctx.ProtocolClient.Protocol.MaxOperationsPerBatch = 2
 		ctx.OpQueue = q
 
-		writer, err := New("test2", ctx, WithBatchTimeout(10*time.Millisecond))
+		writer, err := New(namespace, ctx, WithBatchTimeout(10*time.Millisecond))
 		require.NoError(t, err)
 
 		writer.Start()
@@ -477,6 +1106,193 @@ func generateOperation(num int) (*batch.OperationInfo, error) {
 	return op, nil
 }
 
+// droppingBlockchainClient drops the given number of anchors that are written (e.g. as if the transactions were
+// dropped from the mempool) and fails the given number of subsequent writes
+type droppingBlockchainClient struct {
+	*mocks.MockBlockchainClient
+	mutex  sync.Mutex
+	drop   int
+	errs   int
+	writes int
+}
+
+// WriteAnchor writes the anchor unless it's dropped
+func (c *droppingBlockchainClient) WriteAnchor(anchor string) error {
+	c.mutex.Lock()
+	c.writes++
+
+	if c.drop > 0 {
+		c.drop--
+		c.mutex.Unlock()
+
+		return nil
+	}
+
+	if c.errs > 0 {
+		c.errs--
+		c.mutex.Unlock()
+
+		return errors.New("blockchain error")
+	}
+
+	c.mutex.Unlock()
+
+	return c.MockBlockchainClient.WriteAnchor(anchor)
+}
+
+// Writes returns the number of times that an anchor was written
+func (c *droppingBlockchainClient) Writes() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.writes
+}
+
+// readingBlockchainClient records the positions from which transactions are read
+type readingBlockchainClient struct {
+	*mocks.MockBlockchainClient
+	mutex sync.Mutex
+	reads []int
+}
+
+// Read ledger transaction
+func (c *readingBlockchainClient) Read(sinceTransactionNumber int) (bool, *txn.SidetreeTxn) {
+	c.mutex.Lock()
+	c.reads = append(c.reads, sinceTransactionNumber)
+	c.mutex.Unlock()
+
+	return c.MockBlockchainClient.Read(sinceTransactionNumber)
+}
+
+// Reads returns the positions from which transactions were read
+func (c *readingBlockchainClient) Reads() []int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.reads
+}
+
+// manifestStore stores the batches of a write manifest in memory
+type manifestStore struct {
+	mutex   sync.Mutex
+	batches map[string][]string
+}
+
+func newManifestStore() *manifestStore {
+	return &manifestStore{batches: make(map[string][]string)}
+}
+
+// Get returns a copy of the stored batches
+func (s *manifestStore) Get() (map[string][]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	batches := make(map[string][]string, len(s.batches))
+	for anchorString, addresses := range s.batches {
+		batches[anchorString] = addresses
+	}
+
+	return batches, nil
+}
+
+// Put stores a copy of the given batches
+func (s *manifestStore) Put(batches map[string][]string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.batches = make(map[string][]string, len(batches))
+	for anchorString, addresses := range batches {
+		s.batches[anchorString] = addresses
+	}
+
+	return nil
+}
+
+// pendingAnchorStore stores copies of the pending anchors (as if they were loaded from storage)
+type pendingAnchorStore struct {
+	mutex   sync.Mutex
+	anchors map[string][]byte
+	err     error
+}
+
+func newPendingAnchorStore() *pendingAnchorStore {
+	return &pendingAnchorStore{anchors: make(map[string][]byte)}
+}
+
+// Put stores the pending anchor
+func (s *pendingAnchorStore) Put(anchor *PendingAnchor) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	content, err := json.Marshal(anchor)
+	if err != nil {
+		return err
+	}
+
+	s.anchors[anchor.Namespace+"/"+anchor.AnchorString] = content
+
+	return nil
+}
+
+// List returns the pending anchors of the given namespace
+func (s *pendingAnchorStore) List(ns string) ([]*PendingAnchor, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var anchors []*PendingAnchor
+
+	for _, content := range s.anchors {
+		anchor := &PendingAnchor{}
+		if err := json.Unmarshal(content, anchor); err != nil {
+			return nil, err
+		}
+
+		if anchor.Namespace == ns {
+			anchors = append(anchors, anchor)
+		}
+	}
+
+	return anchors, nil
+}
+
+// Delete deletes the pending anchor
+func (s *pendingAnchorStore) Delete(ns, anchorString string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.anchors, ns+"/"+anchorString)
+
+	return nil
+}
+
+// blockchainContext is a mock batch writer context with the given blockchain client
+type blockchainContext struct {
+	*mockContext
+	blockchain BlockchainClient
+}
+
+// Blockchain returns the blockchain client
+func (m *blockchainContext) Blockchain() BlockchainClient {
+	return m.blockchain
+}
+
+func getBatchOperations(t *testing.T, ops ...*batch.OperationInfo) []*batch.Operation {
+	var batchOps []*batch.Operation
+
+	for _, op := range ops {
+		batchOp, err := operation.ParseOperation(op.Namespace, op.Data, mocks.NewMockProtocolClient().Current())
+		require.NoError(t, err)
+
+		batchOps = append(batchOps, batchOp)
+	}
+
+	return batchOps
+}
+
 // mockContext implements mock batch writer context
 type mockContext struct {
 	ProtocolClient   *mocks.MockProtocolClient
@@ -524,3 +1340,24 @@ type mockOpsHandler struct{}
 func (h *mockOpsHandler) PrepareTxnFiles(ops []*batch.Operation) (string, error) {
 	return "", nil
 }
+
+// excludingOpsHandler excludes the given operation from the batch files the first time it's included in a batch
+type excludingOpsHandler struct {
+	TxnHandler
+	exclude []byte
+}
+
+// PrepareTxnFiles prepares batch files from operations
+func (h *excludingOpsHandler) PrepareTxnFiles(ops []*batch.Operation) (string, error) {
+	for _, op := range ops {
+		if h.exclude != nil && bytes.Equal(op.OperationBuffer, h.exclude) {
+			h.exclude = nil
+
+			return "", &txnhandler.ExcludedOperationsError{
+				Operations: []*batch.RejectedOperation{{Operation: op, Reason: "excluded"}},
+			}
+		}
+	}
+
+	return h.TxnHandler.PrepareTxnFiles(ops)
+}
@@ -0,0 +1,191 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dochandler
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/document"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+)
+
+// CacheStats contains resolution cache statistics
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Size          int
+}
+
+// ResolutionCache is an LRU cache of the documents resolved by the operation processor. The cache is keyed
+// by ID (namespace and unique suffix) so it may be shared by the document handlers of multiple namespaces.
+// Cached documents must be invalidated (e.g. by the observer) when new operations are stored for the suffix.
+type ResolutionCache struct {
+	mutex    sync.Mutex
+	capacity int
+	lru      *list.List
+	entries  map[string]*list.Element
+	stats    CacheStats
+
+	// generation is incremented on each invalidation
+	generation uint64
+
+	// loads contains the resolutions that are in progress
+	loads map[string]*load
+}
+
+type cacheEntry struct {
+	id     string
+	result *document.ResolutionResult
+}
+
+type load struct {
+	count int
+	// invalidated is the generation at which the ID was last invalidated while loading
+	invalidated uint64
+}
+
+// NewResolutionCache returns a new resolution cache that holds up to the given number of documents
+func NewResolutionCache(capacity int) *ResolutionCache {
+	return &ResolutionCache{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		loads:    make(map[string]*load),
+	}
+}
+
+// Resolve returns the cached document for the given ID. If the document isn't cached then it is resolved using
+// the given function and added to the cache, unless it was invalidated while it was being resolved.
+func (c *ResolutionCache) Resolve(id string, resolve func() (*document.ResolutionResult, error)) (*document.ResolutionResult, error) {
+	result, generation, ok := c.get(id)
+	if ok {
+		return result, nil
+	}
+
+	result, err := resolve()
+
+	c.put(id, result, err, generation)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return copyResult(result), nil
+}
+
+// Invalidate removes the document with the given namespace and unique suffix from the cache
+func (c *ResolutionCache) Invalidate(namespace, uniqueSuffix string) {
+	id := namespace + docutil.NamespaceDelimiter + uniqueSuffix
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+
+	if l, ok := c.loads[id]; ok {
+		l.invalidated = c.generation
+	}
+
+	if elem, ok := c.entries[id]; ok {
+		c.remove(elem)
+		c.stats.Invalidations++
+	}
+}
+
+// Stats returns the cache statistics
+func (c *ResolutionCache) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+
+	return stats
+}
+
+func (c *ResolutionCache) get(id string) (*document.ResolutionResult, uint64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.stats.Hits++
+		c.lru.MoveToFront(elem)
+
+		return copyResult(elem.Value.(*cacheEntry).result), 0, true
+	}
+
+	c.stats.Misses++
+
+	l, ok := c.loads[id]
+	if !ok {
+		l = &load{}
+		c.loads[id] = l
+	}
+
+	l.count++
+
+	return nil, c.generation, false
+}
+
+func (c *ResolutionCache) put(id string, result *document.ResolutionResult, err error, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	l := c.loads[id]
+
+	l.count--
+	if l.count == 0 {
+		delete(c.loads, id)
+	}
+
+	if err != nil || c.capacity <= 0 {
+		return
+	}
+
+	if l.invalidated > generation {
+		logger.Debugf("Not caching document [%s] since it was invalidated while it was being resolved", id)
+
+		return
+	}
+
+	if elem, ok := c.entries[id]; ok {
+		elem.Value.(*cacheEntry).result = result
+		c.lru.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[id] = c.lru.PushFront(&cacheEntry{id: id, result: result})
+
+	if c.lru.Len() > c.capacity {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *ResolutionCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).id)
+}
+
+// copyResult returns a copy of the result so that callers may modify the top-level document fields
+// (e.g. the ID) without modifying the cached document
+func copyResult(result *document.ResolutionResult) *document.ResolutionResult {
+	resultCopy := *result
+
+	if result.Document != nil {
+		resultCopy.Document = make(document.Document, len(result.Document))
+		for k, v := range result.Document {
+			resultCopy.Document[k] = v
+		}
+	}
+
+	return &resultCopy
+}
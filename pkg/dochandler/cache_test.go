@@ -0,0 +1,169 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dochandler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/document"
+)
+
+func TestResolutionCache(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		cache := NewResolutionCache(2)
+
+		result, err := cache.Resolve("did:sidetree:abc", resolveFunc("abc"))
+		require.NoError(t, err)
+		require.Equal(t, "abc", result.Document.ID())
+
+		// the returned document may be modified without modifying the cached document
+		result.Document["id"] = "did:sidetree:abc"
+
+		result, err = cache.Resolve("did:sidetree:abc", resolveErrFunc(t))
+		require.NoError(t, err)
+		require.Equal(t, "abc", result.Document.ID())
+
+		require.Equal(t, CacheStats{Hits: 1, Misses: 1, Size: 1}, cache.Stats())
+	})
+
+	t.Run("least recently used document is evicted", func(t *testing.T) {
+		cache := NewResolutionCache(2)
+
+		for _, suffix := range []string{"abc", "def"} {
+			_, err := cache.Resolve("did:sidetree:"+suffix, resolveFunc(suffix))
+			require.NoError(t, err)
+		}
+
+		// abc is now the most recently used
+		_, err := cache.Resolve("did:sidetree:abc", resolveErrFunc(t))
+		require.NoError(t, err)
+
+		_, err = cache.Resolve("did:sidetree:xyz", resolveFunc("xyz"))
+		require.NoError(t, err)
+
+		require.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 2}, cache.Stats())
+
+		_, err = cache.Resolve("did:sidetree:abc", resolveErrFunc(t))
+		require.NoError(t, err)
+
+		_, err = cache.Resolve("did:sidetree:def", resolveFunc("def"))
+		require.NoError(t, err)
+
+		require.Equal(t, CacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2}, cache.Stats())
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		cache := NewResolutionCache(2)
+
+		_, err := cache.Resolve("did:sidetree:abc", resolveFunc("abc"))
+		require.NoError(t, err)
+
+		// other namespace
+		cache.Invalidate("did:other", "abc")
+		require.Equal(t, CacheStats{Misses: 1, Size: 1}, cache.Stats())
+
+		cache.Invalidate("did:sidetree", "abc")
+		require.Equal(t, CacheStats{Misses: 1, Invalidations: 1}, cache.Stats())
+
+		result, err := cache.Resolve("did:sidetree:abc", resolveFunc("abc2"))
+		require.NoError(t, err)
+		require.Equal(t, "abc2", result.Document.ID())
+	})
+
+	t.Run("document invalidated while resolving is not cached", func(t *testing.T) {
+		cache := NewResolutionCache(2)
+
+		result, err := cache.Resolve("did:sidetree:abc", func() (*document.ResolutionResult, error) {
+			// new operations are stored after the operations were loaded by the processor
+			cache.Invalidate("did:sidetree", "abc")
+
+			return newResult("abc"), nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "abc", result.Document.ID())
+		require.Equal(t, CacheStats{Misses: 1}, cache.Stats())
+
+		_, err = cache.Resolve("did:sidetree:abc", resolveFunc("abc"))
+		require.NoError(t, err)
+		require.Equal(t, CacheStats{Misses: 2, Size: 1}, cache.Stats())
+	})
+
+	t.Run("error is not cached", func(t *testing.T) {
+		cache := NewResolutionCache(2)
+
+		result, err := cache.Resolve("did:sidetree:abc", func() (*document.ResolutionResult, error) {
+			return nil, errors.New("not found")
+		})
+		require.EqualError(t, err, "not found")
+		require.Nil(t, result)
+		require.Equal(t, CacheStats{Misses: 1}, cache.Stats())
+	})
+
+	t.Run("zero capacity", func(t *testing.T) {
+		cache := NewResolutionCache(0)
+
+		_, err := cache.Resolve("did:sidetree:abc", resolveFunc("abc"))
+		require.NoError(t, err)
+
+		_, err = cache.Resolve("did:sidetree:abc", resolveFunc("abc"))
+		require.NoError(t, err)
+
+		require.Equal(t, CacheStats{Misses: 2}, cache.Stats())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		cache := NewResolutionCache(5)
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				suffix := fmt.Sprintf("suffix%d", i%10)
+
+				_, err := cache.Resolve("did:sidetree:"+suffix, resolveFunc(suffix))
+				require.NoError(t, err)
+
+				cache.Invalidate("did:sidetree", suffix)
+			}(i)
+		}
+
+		wg.Wait()
+
+		stats := cache.Stats()
+		require.Equal(t, uint64(20), stats.Hits+stats.Misses)
+		require.Empty(t, cache.loads)
+	})
+}
+
+func resolveFunc(id string) func() (*document.ResolutionResult, error) {
+	return func() (*document.ResolutionResult, error) {
+		return newResult(id), nil
+	}
+}
+
+func resolveErrFunc(t *testing.T) func() (*document.ResolutionResult, error) {
+	return func() (*document.ResolutionResult, error) {
+		require.FailNow(t, "document should have been resolved from the cache")
+
+		return nil, nil
+	}
+}
+
+func newResult(id string) *document.ResolutionResult {
+	return &document.ResolutionResult{
+		Document: document.Document{"id": id},
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	internaljws "github.com/trustbloc/sidetree-core-go/pkg/internal/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/jws"
 )
@@ -54,19 +55,19 @@ func (v *Validator) IsValidPayload(payload []byte) error {
 		return err
 	}
 
-	didSuffix := doc.GetStringValue(didSuffix)
-	if didSuffix == "" {
-		return errors.New("missing did unique suffix")
+	uniqueSuffix := doc.GetStringValue(didSuffix)
+	if uniqueSuffix == "" {
+		return errs.NewField(errs.MissingField, didSuffix, "missing did unique suffix")
 	}
 
 	// did document has to exist in the store for all operations except for create
-	docs, err := v.store.Get(didSuffix)
+	docs, err := v.store.Get(uniqueSuffix)
 	if err != nil {
 		return err
 	}
 
 	if len(docs) == 0 {
-		return errors.New("missing did document operations")
+		return errs.New(errs.NotFound, "missing did document operations")
 	}
 
 	return nil
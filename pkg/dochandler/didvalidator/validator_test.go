@@ -21,6 +21,7 @@ import (
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
 	"github.com/trustbloc/sidetree-core-go/pkg/util/pubkey"
 )
@@ -81,7 +82,7 @@ func TestIsValidPayload(t *testing.T) {
 	store := mocks.NewMockOperationStore(nil)
 	v := New(store)
 
-	store.Put(&batch.Operation{UniqueSuffix: "abc"})
+	store.Put([]*batch.Operation{{UniqueSuffix: "abc"}})
 
 	err := v.IsValidPayload(validUpdate)
 	require.Nil(t, err)
@@ -93,6 +94,8 @@ func TestIsValidPayloadError(t *testing.T) {
 	err := v.IsValidPayload(invalidUpdate)
 	require.NotNil(t, err)
 	require.Contains(t, err.Error(), "missing did unique suffix")
+	require.Equal(t, errs.MissingField, errs.CodeOf(err))
+	require.Equal(t, "did_suffix", errs.FieldOf(err))
 }
 
 func TestIsValidPayload_StoreErrors(t *testing.T) {
@@ -105,7 +108,7 @@ func TestIsValidPayload_StoreErrors(t *testing.T) {
 	require.Contains(t, err.Error(), "not found")
 
 	// scenario: found in the store and is valid
-	store.Put(&batch.Operation{UniqueSuffix: "abc"})
+	store.Put([]*batch.Operation{{UniqueSuffix: "abc"}})
 	err = v.IsValidPayload(validUpdate)
 	require.Nil(t, err)
 
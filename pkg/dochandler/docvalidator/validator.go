@@ -12,6 +12,7 @@ import (
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
 const didSuffix = "did_suffix"
@@ -45,7 +46,7 @@ func (v *Validator) IsValidPayload(payload []byte) error {
 
 	uniqueSuffix := doc.GetStringValue(didSuffix)
 	if uniqueSuffix == "" {
-		return errors.New("missing unique suffix")
+		return errs.NewField(errs.MissingField, didSuffix, "missing unique suffix")
 	}
 
 	// document has to exist in the store for all operations except for create
@@ -55,7 +56,7 @@ func (v *Validator) IsValidPayload(payload []byte) error {
 	}
 
 	if len(docs) == 0 {
-		return errors.New("missing document operations")
+		return errs.New(errs.NotFound, "missing document operations")
 	}
 
 	return nil
@@ -49,7 +49,7 @@ func TestValidatorIsValidPayload(t *testing.T) {
 	store := mocks.NewMockOperationStore(nil)
 	v := New(store)
 
-	store.Put(&batch.Operation{UniqueSuffix: "abc"})
+	store.Put([]*batch.Operation{{UniqueSuffix: "abc"}})
 
 	err := v.IsValidPayload(validUpdate)
 	require.Nil(t, err)
@@ -88,7 +88,7 @@ func TestIsValidPayload_StoreErrors(t *testing.T) {
 	require.Contains(t, err.Error(), "not found")
 
 	// scenario: found in the store and is valid
-	store.Put(&batch.Operation{UniqueSuffix: "abc"})
+	store.Put([]*batch.Operation{{UniqueSuffix: "abc"}})
 	err = v.IsValidPayload(validUpdate)
 	require.Nil(t, err)
 
@@ -27,10 +27,12 @@ import (
 	"github.com/trustbloc/edge-core/pkg/log"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/composer"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/request"
 	"github.com/trustbloc/sidetree-core-go/pkg/operation"
 	"github.com/trustbloc/sidetree-core-go/pkg/patch"
@@ -52,11 +54,39 @@ type DocumentHandler struct {
 	writer    BatchWriter
 	validator DocumentValidator
 	namespace string
+	cache     *ResolutionCache
+	status    opstatus.Store
+}
+
+// Option is a document handler option
+type Option func(opts *DocumentHandler)
+
+// WithResolutionCache caches the documents resolved by the operation processor. The cache must be invalidated
+// when new operations are stored (see observer.Providers.CacheInvalidator).
+func WithResolutionCache(cache *ResolutionCache) Option {
+	return func(opts *DocumentHandler) {
+		opts.cache = cache
+	}
+}
+
+// WithOperationStatusStore sets the store that tracks the status of the submitted operations. The status is
+// subsequently updated by the batch writer and the observer (which should be configured with the same store).
+// An operation that is rejected since another operation for the same DID is pending doesn't get a status.
+func WithOperationStatusStore(store opstatus.Store) Option {
+	return func(opts *DocumentHandler) {
+		opts.status = store
+	}
 }
 
 // OperationProcessor is an interface which resolves the document based on the ID
 type OperationProcessor interface {
-	Resolve(uniqueSuffix string) (*document.ResolutionResult, error)
+	Resolve(uniqueSuffix string, opts ...document.ResolutionOption) (*document.ResolutionResult, error)
+}
+
+// OperationValidator is implemented by an operation processor that is able to validate an operation against
+// the current state of the document (e.g. processor.OperationProcessor)
+type OperationValidator interface {
+	ValidateOperation(operation *batch.Operation) error
 }
 
 // BatchWriter is an interface to add an operation to the batch
@@ -64,6 +94,18 @@ type BatchWriter interface {
 	Add(operation *batch.OperationInfo) error
 }
 
+// PendingOperationChecker is implemented by a batch writer that is able to tell whether an operation for a unique
+// suffix is pending (e.g. batch.Writer)
+type PendingOperationChecker interface {
+	IsPending(namespace, uniqueSuffix string) (bool, error)
+}
+
+// AnchoredOperationChecker is implemented by an operation status store that is able to tell whether an operation
+// for a unique suffix was anchored but not processed by the observer yet (e.g. opstore.StatusStore)
+type AnchoredOperationChecker interface {
+	IsAnchored(namespace, uniqueSuffix string) (bool, error)
+}
+
 // DocumentValidator is an interface for validating document operations
 type DocumentValidator interface {
 	IsValidOriginalDocument(payload []byte) error
@@ -72,14 +114,20 @@ type DocumentValidator interface {
 }
 
 // New creates a new requestHandler with the context
-func New(namespace string, protocol protocol.Client, validator DocumentValidator, writer BatchWriter, processor OperationProcessor) *DocumentHandler {
-	return &DocumentHandler{
+func New(namespace string, protocol protocol.Client, validator DocumentValidator, writer BatchWriter, processor OperationProcessor, opts ...Option) *DocumentHandler {
+	dh := &DocumentHandler{
 		protocol:  protocol,
 		processor: processor,
 		writer:    writer,
 		validator: validator,
 		namespace: namespace,
 	}
+
+	for _, opt := range opts {
+		opt(dh)
+	}
+
+	return dh
 }
 
 // Namespace returns the namespace of the document handler
@@ -92,8 +140,9 @@ func (r *DocumentHandler) Protocol() protocol.Client {
 	return r.protocol
 }
 
-//ProcessOperation validates operation and adds it to the batch
-func (r *DocumentHandler) ProcessOperation(operation *batch.Operation) (*document.ResolutionResult, error) {
+//ProcessOperation validates operation and adds it to the batch. The options (e.g. batch.WithPriority) are applied
+// when the operation is added to the batch.
+func (r *DocumentHandler) ProcessOperation(operation *batch.Operation, opts ...batch.ProcessOption) (*document.ResolutionResult, error) {
 	// perform validation for operation request
 	if err := r.validateOperation(operation); err != nil {
 		logger.Warnf("Failed to validate operation: %s", err.Error())
@@ -101,11 +150,21 @@ func (r *DocumentHandler) ProcessOperation(operation *batch.Operation) (*documen
 	}
 
 	// validated operation will be added to the batch
-	if err := r.addToBatch(operation); err != nil {
+	if err := r.addToBatch(operation, batch.GetProcessOptions(opts...)); err != nil {
+		if errors.Is(err, errs.ErrConflict) {
+			// the status isn't updated since the pending operation may be the same operation (i.e. a retry)
+			logger.Warnf("Rejecting operation: %s", err.Error())
+			return nil, err
+		}
+
 		logger.Errorf("Failed to add operation to batch: %s", err.Error())
+		r.updateStatus(operation, opstatus.Rejected, err.Error())
 		return nil, err
 	}
 
+	// the status store ignores this update if the batch writer has already moved the operation forward
+	r.updateStatus(operation, opstatus.Queued, "")
+
 	logger.Infof("[%s] operation added to the batch", operation.ID)
 
 	// create operation will also return document
@@ -144,44 +203,69 @@ func (r *DocumentHandler) getCreateResponse(operation *batch.Operation) (*docume
 // Standard resolution is performed if the DID is found to be registered on the blockchain.
 // If the DID Document cannot be found, the encoded DID Document given in the initial-values DID parameter is used
 // to generate and return as the resolved DID Document, in which case the supplied encoded DID Document is subject to
-// the same validation as an original DID Document in a create operation
-func (r *DocumentHandler) ResolveDocument(idOrInitialDoc string) (*document.ResolutionResult, error) {
+// the same validation as an original DID Document in a create operation.
+//
+// If the DID with initial-values DID parameter (long-form DID) is resolved then the short-form DID is returned as
+// the equivalent ID of the document and, if the document is published, also as the canonical ID.
+//
+// A historical version of the document may be resolved by providing the version ID or version time option.
+func (r *DocumentHandler) ResolveDocument(idOrInitialDoc string, opts ...document.ResolutionOption) (*document.ResolutionResult, error) {
 	if !strings.HasPrefix(idOrInitialDoc, r.namespace+docutil.NamespaceDelimiter) {
-		return nil, errors.New("must start with configured namespace")
+		return nil, errs.New(errs.BadRequest, "must start with configured namespace")
 	}
 
 	// extract did and optional initial document value
 	id, initial, err := request.GetParts(r.namespace, idOrInitialDoc)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s", badRequest, err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, badRequest)
 	}
 
 	uniquePortion, err := getSuffix(r.namespace, id)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s", badRequest, err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, badRequest)
 	}
 
 	// resolve document from the blockchain
-	doc, err := r.resolveRequestWithID(uniquePortion)
+	doc, err := r.resolveRequestWithID(uniquePortion, opts...)
 	if err == nil {
+		if initial != nil {
+			doc.DocumentMetadata.CanonicalID = id
+			doc.DocumentMetadata.EquivalentID = []string{id}
+		}
+
 		return doc, nil
 	}
 
 	// if document was not found on the blockchain and initial value has been provided resolve using initial value
-	if initial != nil && strings.Contains(err.Error(), "not found") {
-		return r.resolveRequestWithDocument(id, initial)
+	if initial != nil && errors.Is(err, errs.ErrNotFound) {
+		doc, err = r.resolveRequestWithDocument(id, initial)
+		if err != nil {
+			return nil, err
+		}
+
+		doc.DocumentMetadata.EquivalentID = []string{id}
+
+		return doc, nil
 	}
 
 	return nil, err
 }
 
-func (r *DocumentHandler) resolveRequestWithID(uniquePortion string) (*document.ResolutionResult, error) {
-	internalResult, err := r.processor.Resolve(uniquePortion)
+func (r *DocumentHandler) resolveRequestWithID(uniquePortion string, opts ...document.ResolutionOption) (*document.ResolutionResult, error) {
+	internalResult, err := r.resolve(uniquePortion, opts...)
 	if err != nil {
 		logger.Errorf("Failed to resolve uniquePortion[%s]: %s", uniquePortion, err.Error())
 		return nil, err
 	}
 
+	if internalResult.DocumentMetadata.Deactivated {
+		return &document.ResolutionResult{
+			Document:         make(document.Document),
+			DocumentMetadata: internalResult.DocumentMetadata,
+			MethodMetadata:   document.MethodMetadata{Published: true},
+		}, nil
+	}
+
 	externalResult, err := r.transformToExternalDoc(internalResult.Document, r.namespace+docutil.NamespaceDelimiter+uniquePortion)
 	if err != nil {
 		return nil, err
@@ -190,6 +274,7 @@ func (r *DocumentHandler) resolveRequestWithID(uniquePortion string) (*document.
 	externalResult.MethodMetadata.Published = true
 	externalResult.MethodMetadata.RecoveryCommitment = internalResult.MethodMetadata.RecoveryCommitment
 	externalResult.MethodMetadata.UpdateCommitment = internalResult.MethodMetadata.UpdateCommitment
+	externalResult.DocumentMetadata = internalResult.DocumentMetadata
 
 	return externalResult, nil
 }
@@ -197,31 +282,43 @@ func (r *DocumentHandler) resolveRequestWithID(uniquePortion string) (*document.
 func (r *DocumentHandler) resolveRequestWithDocument(id string, initial *model.CreateRequest) (*document.ResolutionResult, error) {
 	// verify size of each delta does not exceed the maximum allowed limit
 	if len(initial.Delta) > int(r.protocol.Current().MaxDeltaByteSize) {
-		return nil, fmt.Errorf("%s: delta byte size exceeds protocol max delta byte size", badRequest)
+		return nil, errs.New(errs.BadRequest, "%s: delta byte size exceeds protocol max delta byte size", badRequest)
 	}
 
 	initialBytes, err := json.Marshal(initial)
 	if err != nil {
-		return nil, fmt.Errorf("%s: marshal initial state: %s", badRequest, err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, "%s: marshal initial state", badRequest)
 	}
 
 	op, err := operation.ParseCreateOperation(initialBytes, r.protocol.Current())
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s", badRequest, err.Error())
+		return nil, wrapBadRequest(err, badRequest)
 	}
 
 	op.ID = r.namespace + docutil.NamespaceDelimiter + op.UniqueSuffix
 	if id != op.ID {
-		return nil, fmt.Errorf("%s: provided did doesn't match did created from initial state", badRequest)
+		return nil, errs.New(errs.BadRequest, "%s: provided did doesn't match did created from initial state", badRequest)
 	}
 
 	if err := r.validateInitialDocument(op.Delta.Patches); err != nil {
-		return nil, fmt.Errorf("%s: validate initial document: %s", badRequest, err.Error())
+		return nil, wrapBadRequest(err, badRequest+": validate initial document")
 	}
 
 	return r.getCreateResponse(op)
 }
 
+// resolve resolves the document using the operation processor. If a resolution cache is configured
+// then the latest version of the document is resolved from the cache.
+func (r *DocumentHandler) resolve(uniquePortion string, opts ...document.ResolutionOption) (*document.ResolutionResult, error) {
+	if r.cache == nil || document.GetResolutionOptions(opts...).IsHistorical() {
+		return r.processor.Resolve(uniquePortion, opts...)
+	}
+
+	return r.cache.Resolve(r.namespace+docutil.NamespaceDelimiter+uniquePortion, func() (*document.ResolutionResult, error) {
+		return r.processor.Resolve(uniquePortion)
+	})
+}
+
 // helper function to transform internal into external document and return resolution result
 func (r *DocumentHandler) transformToExternalDoc(internal document.Document, id string) (*document.ResolutionResult, error) {
 	if internal == nil {
@@ -235,32 +332,100 @@ func (r *DocumentHandler) transformToExternalDoc(internal document.Document, id
 }
 
 // helper namespace for adding operations to the batch
-func (r *DocumentHandler) addToBatch(operation *batch.Operation) error {
+func (r *DocumentHandler) addToBatch(operation *batch.Operation, opts batch.ProcessOptions) error {
 	return r.writer.Add(&batch.OperationInfo{
 		Namespace:    r.namespace,
 		UniqueSuffix: operation.UniqueSuffix,
 		Data:         operation.OperationBuffer,
+		Priority:     opts.Priority,
 	})
 }
 
+// updateStatus updates the status of the operation if an operation status store was provided
+func (r *DocumentHandler) updateStatus(operation *batch.Operation, status opstatus.Status, reason string) {
+	if r.status == nil {
+		return
+	}
+
+	err := r.status.Put(&opstatus.OperationStatus{
+		ID:           opstatus.ID(operation.OperationBuffer),
+		Namespace:    r.namespace,
+		UniqueSuffix: operation.UniqueSuffix,
+		Type:         operation.Type,
+		Status:       status,
+		Reason:       reason,
+	})
+	if err != nil {
+		logger.Warnf("Failed to update status of operation [%s] to [%s]: %s", operation.ID, status, err)
+	}
+}
+
 // validateOperation validates the operation
 func (r *DocumentHandler) validateOperation(operation *batch.Operation) error {
 	// check maximum operation size against protocol
 	if len(operation.EncodedDelta) > int(r.protocol.Current().MaxDeltaByteSize) {
-		return errors.New("delta byte size exceeds protocol max delta byte size")
+		return errs.NewField(errs.InvalidField, "delta", "delta byte size exceeds protocol max delta byte size")
 	}
 
 	if operation.Type == batch.OperationTypeCreate {
 		return r.validateInitialDocument(operation.Delta.Patches)
 	}
 
-	return r.validator.IsValidPayload(operation.OperationBuffer)
+	if err := r.validator.IsValidPayload(operation.OperationBuffer); err != nil {
+		return err
+	}
+
+	// an operation that is chained to a pending operation would fail validation against the current document state,
+	// so the conflict is reported first
+	if err := r.checkPending(operation); err != nil {
+		return err
+	}
+
+	// reject operations with an invalid commitment or signature before they're anchored
+	// (they would be discarded by the observer anyway)
+	if validator, ok := r.processor.(OperationValidator); ok {
+		if err := validator.ValidateOperation(operation); err != nil {
+			return wrapBadRequest(err, "validate operation against current document state")
+		}
+	}
+
+	return nil
+}
+
+// checkPending returns an error with code errs.Conflict if an operation for the same unique suffix is pending.
+// An operation is pending until the observer has stored it, i.e. an operation whose anchor was confirmed by the
+// batch writer is still pending (see AnchoredOperationChecker) since the operations that are chained to it would
+// fail validation against the current document state.
+func (r *DocumentHandler) checkPending(operation *batch.Operation) error {
+	if checker, ok := r.writer.(PendingOperationChecker); ok {
+		pending, err := checker.IsPending(r.namespace, operation.UniqueSuffix)
+		if err != nil {
+			return err
+		}
+
+		if pending {
+			return errs.New(errs.Conflict, "an operation for unique suffix [%s] is already pending", operation.UniqueSuffix)
+		}
+	}
+
+	if checker, ok := r.status.(AnchoredOperationChecker); ok {
+		anchored, err := checker.IsAnchored(r.namespace, operation.UniqueSuffix)
+		if err != nil {
+			return err
+		}
+
+		if anchored {
+			return errs.New(errs.Conflict, "an operation for unique suffix [%s] was anchored but it wasn't processed yet", operation.UniqueSuffix)
+		}
+	}
+
+	return nil
 }
 
 func (r *DocumentHandler) validateInitialDocument(patches []patch.Patch) error {
 	doc, err := getInitialDocument(patches)
 	if err != nil {
-		return err
+		return errs.WrapField(errs.InvalidDocument, "delta.patches", err, "apply patches to initial document")
 	}
 
 	docBytes, err := json.Marshal(doc)
@@ -268,7 +433,20 @@ func (r *DocumentHandler) validateInitialDocument(patches []patch.Patch) error {
 		return err
 	}
 
-	return r.validator.IsValidOriginalDocument(docBytes)
+	if err := r.validator.IsValidOriginalDocument(docBytes); err != nil {
+		return errs.WrapField(errs.InvalidDocument, "delta.patches", err, "invalid original document")
+	}
+
+	return nil
+}
+
+// wrapBadRequest returns the given error as a bad request unless the error already has a (more specific) code
+func wrapBadRequest(err error, msg string) error {
+	if errs.CodeOf(err) == errs.Internal {
+		return errs.Wrap(errs.BadRequest, err, msg)
+	}
+
+	return fmt.Errorf("%s: %w", msg, err)
 }
 
 // getSuffix fetches unique portion of ID which is string after namespace
@@ -8,12 +8,14 @@ package dochandler
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	batchapi "github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/cas"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/batch/cutter"
@@ -23,6 +25,7 @@ import (
 	"github.com/trustbloc/sidetree-core-go/pkg/dochandler/docvalidator"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/canonicalizer"
 	"github.com/trustbloc/sidetree-core-go/pkg/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
@@ -60,6 +63,93 @@ func TestDocumentHandler_ProcessOperation_Create(t *testing.T) {
 	require.NotNil(t, doc)
 }
 
+func TestDocumentHandler_ProcessOperation_Priority(t *testing.T) {
+	store := mocks.NewMockOperationStore(nil)
+	pc := mocks.NewMockProtocolClient()
+
+	createOp := getCreateOperation()
+
+	writer := &mockBatchWriter{}
+	dochandler := New(namespace, pc, docvalidator.New(store), writer, processor.New("test", store, pc))
+
+	_, err := dochandler.ProcessOperation(createOp)
+	require.NoError(t, err)
+
+	_, err = dochandler.ProcessOperation(createOp, batchapi.WithPriority(batchapi.PriorityHigh))
+	require.NoError(t, err)
+
+	require.Len(t, writer.ops, 2)
+	require.Equal(t, batchapi.PriorityNormal, writer.ops[0].Priority)
+	require.Equal(t, batchapi.PriorityHigh, writer.ops[1].Priority)
+	require.Equal(t, createOp.UniqueSuffix, writer.ops[1].UniqueSuffix)
+}
+
+func TestDocumentHandler_ProcessOperation_Status(t *testing.T) {
+	store := mocks.NewMockOperationStore(nil)
+	pc := mocks.NewMockProtocolClient()
+
+	createOp := getCreateOperation()
+
+	t.Run("queued", func(t *testing.T) {
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		dochandler := New(namespace, pc, docvalidator.New(store), &mockBatchWriter{},
+			processor.New("test", store, pc), WithOperationStatusStore(statusStore))
+
+		_, err := dochandler.ProcessOperation(createOp)
+		require.NoError(t, err)
+
+		status, err := statusStore.Get(opstatus.ID(createOp.OperationBuffer))
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Queued, status.Status)
+		require.Equal(t, namespace, status.Namespace)
+		require.Equal(t, createOp.UniqueSuffix, status.UniqueSuffix)
+		require.Equal(t, batchapi.OperationTypeCreate, status.Type)
+	})
+
+	t.Run("batch writer error", func(t *testing.T) {
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		dochandler := New(namespace, pc, docvalidator.New(store), &mockBatchWriter{err: errors.New("writer is stopped")},
+			processor.New("test", store, pc), WithOperationStatusStore(statusStore))
+
+		_, err := dochandler.ProcessOperation(createOp)
+		require.EqualError(t, err, "writer is stopped")
+
+		status, err := statusStore.Get(opstatus.ID(createOp.OperationBuffer))
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Rejected, status.Status)
+		require.Equal(t, "writer is stopped", status.Reason)
+	})
+
+	t.Run("pending operation conflict", func(t *testing.T) {
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		dochandler := New(namespace, pc, docvalidator.New(store),
+			&mockBatchWriter{err: errs.New(errs.Conflict, "an operation for unique suffix is already pending")},
+			processor.New("test", store, pc), WithOperationStatusStore(statusStore))
+
+		_, err := dochandler.ProcessOperation(createOp)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, errs.ErrConflict))
+
+		_, err = statusStore.Get(opstatus.ID(createOp.OperationBuffer))
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+	})
+
+	t.Run("status store error", func(t *testing.T) {
+		statusStore := mocks.NewMockOperationStatusStore()
+		statusStore.Err = errors.New("status store error")
+
+		dochandler := New(namespace, pc, docvalidator.New(store), &mockBatchWriter{},
+			processor.New("test", store, pc), WithOperationStatusStore(statusStore))
+
+		// the operation is processed regardless
+		_, err := dochandler.ProcessOperation(createOp)
+		require.NoError(t, err)
+	})
+}
+
 func TestDocumentHandler_ProcessOperation_InitialDocumentError(t *testing.T) {
 	dochandler := getDocumentHandler(mocks.NewMockOperationStore(nil))
 	require.NotNil(t, dochandler)
@@ -78,6 +168,8 @@ func TestDocumentHandler_ProcessOperation_InitialDocumentError(t *testing.T) {
 	require.NotNil(t, err)
 	require.Nil(t, doc)
 	require.Contains(t, err.Error(), "expected array of interfaces")
+	require.Equal(t, errs.InvalidDocument, errs.CodeOf(err))
+	require.Equal(t, "delta.patches", errs.FieldOf(err))
 }
 
 func TestDocumentHandler_ProcessOperation_MaxDeltaSizeError(t *testing.T) {
@@ -95,6 +187,8 @@ func TestDocumentHandler_ProcessOperation_MaxDeltaSizeError(t *testing.T) {
 	require.NotNil(t, err)
 	require.Nil(t, doc)
 	require.Contains(t, err.Error(), "delta byte size exceeds protocol max delta byte size")
+	require.Equal(t, errs.InvalidField, errs.CodeOf(err))
+	require.Equal(t, "delta", errs.FieldOf(err))
 }
 
 func TestDocumentHandler_ResolveDocument_DID(t *testing.T) {
@@ -111,7 +205,7 @@ func TestDocumentHandler_ResolveDocument_DID(t *testing.T) {
 	require.Contains(t, err.Error(), "not found")
 
 	// insert document in the store
-	err = store.Put(getCreateOperation())
+	err = store.Put([]*batchapi.Operation{getCreateOperation()})
 	require.Nil(t, err)
 
 	// scenario: resolved document (success)
@@ -125,6 +219,7 @@ func TestDocumentHandler_ResolveDocument_DID(t *testing.T) {
 	require.NotNil(t, err)
 	require.Nil(t, result)
 	require.Contains(t, err.Error(), "must start with configured namespace")
+	require.True(t, errors.Is(err, errs.ErrBadRequest))
 
 	// scenario: invalid id
 	result, err = dochandler.ResolveDocument(namespace + docutil.NamespaceDelimiter)
@@ -133,6 +228,147 @@ func TestDocumentHandler_ResolveDocument_DID(t *testing.T) {
 	require.Contains(t, err.Error(), "did suffix is empty")
 }
 
+func TestDocumentHandler_ResolveDocument_Cache(t *testing.T) {
+	store := mocks.NewMockOperationStore(nil)
+	cache := NewResolutionCache(10)
+	dochandler := getDocumentHandler(store, WithResolutionCache(cache))
+	require.NotNil(t, dochandler)
+
+	createOp := getCreateOperation()
+	docID := createOp.ID
+
+	// scenario: not found in the store (not cached)
+	result, err := dochandler.ResolveDocument(docID)
+	require.Error(t, err)
+	require.Nil(t, result)
+	require.Contains(t, err.Error(), "not found")
+	require.Equal(t, CacheStats{Misses: 1}, cache.Stats())
+
+	err = store.Put([]*batchapi.Operation{createOp})
+	require.NoError(t, err)
+
+	result, err = dochandler.ResolveDocument(docID)
+	require.NoError(t, err)
+	require.Equal(t, docID, result.Document.ID())
+	require.Equal(t, CacheStats{Misses: 2, Size: 1}, cache.Stats())
+
+	cachedResult, err := dochandler.ResolveDocument(docID)
+	require.NoError(t, err)
+	require.Equal(t, result, cachedResult)
+	require.Equal(t, CacheStats{Hits: 1, Misses: 2, Size: 1}, cache.Stats())
+
+	cache.Invalidate(namespace, createOp.UniqueSuffix)
+	require.Equal(t, CacheStats{Hits: 1, Misses: 2, Invalidations: 1}, cache.Stats())
+
+	result, err = dochandler.ResolveDocument(docID)
+	require.NoError(t, err)
+	require.Equal(t, cachedResult, result)
+	require.Equal(t, CacheStats{Hits: 1, Misses: 3, Invalidations: 1, Size: 1}, cache.Stats())
+}
+
+func TestDocumentHandler_ResolveDocument_Version(t *testing.T) {
+	store := mocks.NewMockOperationStore(nil)
+	cache := NewResolutionCache(10)
+	dochandler := getDocumentHandler(store, WithResolutionCache(cache))
+	require.NotNil(t, dochandler)
+
+	createOp := getCreateOperation()
+	createOp.TransactionTime = 5
+	createOp.TransactionNumber = 2
+
+	err := store.Put([]*batchapi.Operation{createOp})
+	require.NoError(t, err)
+
+	result, err := dochandler.ResolveDocument(createOp.ID, document.WithVersionID(document.VersionID{TransactionNumber: 2}))
+	require.NoError(t, err)
+	require.Equal(t, createOp.ID, result.Document.ID())
+	require.Equal(t, "2-0", result.DocumentMetadata.VersionID)
+	require.Equal(t, uint64(5), result.DocumentMetadata.Updated)
+	require.True(t, result.MethodMetadata.Published)
+
+	result, err = dochandler.ResolveDocument(createOp.ID, document.WithVersionTime(5))
+	require.NoError(t, err)
+	require.Equal(t, "2-0", result.DocumentMetadata.VersionID)
+
+	// historical versions are not cached
+	require.Equal(t, CacheStats{}, cache.Stats())
+
+	result, err = dochandler.ResolveDocument(createOp.ID, document.WithVersionTime(4))
+	require.Error(t, err)
+	require.Nil(t, result)
+	require.Contains(t, err.Error(), "not found")
+
+	result, err = dochandler.ResolveDocument(createOp.ID)
+	require.NoError(t, err)
+	require.Equal(t, "2-0", result.DocumentMetadata.VersionID)
+	require.Equal(t, CacheStats{Misses: 1, Size: 1}, cache.Stats())
+}
+
+func TestDocumentHandler_ResolveDocument_Metadata(t *testing.T) {
+	store := mocks.NewMockOperationStore(nil)
+	dochandler := getDocumentHandler(store)
+	require.NotNil(t, dochandler)
+
+	createReq, err := getCreateRequest()
+	require.NoError(t, err)
+
+	createOp := getCreateOperation()
+	createOp.TransactionTime = 5
+	createOp.TransactionNumber = 2
+
+	require.NoError(t, store.Put([]*batchapi.Operation{createOp}))
+
+	t.Run("short-form DID", func(t *testing.T) {
+		result, err := dochandler.ResolveDocument(createOp.ID)
+		require.NoError(t, err)
+		require.Equal(t, document.DocumentMetadata{
+			Created:        5,
+			Updated:        5,
+			VersionID:      "2-0",
+			OperationCount: 1,
+		}, result.DocumentMetadata)
+	})
+
+	t.Run("published long-form DID", func(t *testing.T) {
+		result, err := dochandler.ResolveDocument(createOp.ID + initialStateParam + createReq.SuffixData + "." + createReq.Delta)
+		require.NoError(t, err)
+		require.True(t, result.MethodMetadata.Published)
+		require.Equal(t, createOp.ID, result.Document.ID())
+		require.Equal(t, document.DocumentMetadata{
+			Created:        5,
+			Updated:        5,
+			VersionID:      "2-0",
+			OperationCount: 1,
+			CanonicalID:    createOp.ID,
+			EquivalentID:   []string{createOp.ID},
+		}, result.DocumentMetadata)
+	})
+}
+
+func TestDocumentHandler_ResolveDocument_Deactivated(t *testing.T) {
+	dochandler := getDocumentHandler(mocks.NewMockOperationStore(nil))
+	require.NotNil(t, dochandler)
+
+	metadata := document.DocumentMetadata{
+		Created:        5,
+		Updated:        10,
+		VersionID:      "3-0",
+		OperationCount: 2,
+		Deactivated:    true,
+	}
+
+	dochandler.processor = &mockProcessor{result: &document.ResolutionResult{
+		Document:         make(document.Document),
+		DocumentMetadata: metadata,
+	}}
+
+	result, err := dochandler.ResolveDocument(getCreateOperation().ID)
+	require.NoError(t, err)
+	require.Empty(t, result.Document)
+	require.Equal(t, metadata, result.DocumentMetadata)
+	require.True(t, result.MethodMetadata.Published)
+}
+
 func TestDocumentHandler_ResolveDocument_InitialValue(t *testing.T) {
 	dochandler := getDocumentHandler(mocks.NewMockOperationStore(nil))
 	require.NotNil(t, dochandler)
@@ -148,6 +384,8 @@ func TestDocumentHandler_ResolveDocument_InitialValue(t *testing.T) {
 	result, err := dochandler.ResolveDocument(docID + initialStateParam + initialState)
 	require.NotNil(t, result)
 	require.Equal(t, false, result.MethodMetadata.Published)
+	require.Equal(t, []string{docID}, result.DocumentMetadata.EquivalentID)
+	require.Empty(t, result.DocumentMetadata.CanonicalID)
 
 	result, err = dochandler.ResolveDocument(docID + initialStateParam)
 	require.NotNil(t, err)
@@ -260,16 +498,121 @@ func TestProcessOperation_Update(t *testing.T) {
 	require.NotNil(t, dochandler)
 
 	// insert document in the store
-	err := store.Put(getCreateOperation())
+	err := store.Put([]*batchapi.Operation{getCreateOperation()})
 	require.Nil(t, err)
 
 	// modify default validator to did validator since update payload is did document update
 	validator := didvalidator.New(store)
 	dochandler.validator = validator
 
-	doc, err := dochandler.ProcessOperation(getUpdateOperation())
-	require.Nil(t, err)
-	require.Nil(t, doc)
+	t.Run("success", func(t *testing.T) {
+		// the mock processor doesn't validate the operation against the current document state
+		processor := dochandler.processor
+		dochandler.processor = &mockProcessor{}
+		defer func() { dochandler.processor = processor }()
+
+		// the operation isn't added to the batch writer so that it doesn't conflict with subsequent operations
+		writer := &mockBatchWriter{}
+		batchWriter := dochandler.writer
+		dochandler.writer = writer
+		defer func() { dochandler.writer = batchWriter }()
+
+		doc, err := dochandler.ProcessOperation(getUpdateOperation())
+		require.Nil(t, err)
+		require.Nil(t, doc)
+		require.Len(t, writer.ops, 1)
+	})
+
+	t.Run("error - operation is not valid against the current document state", func(t *testing.T) {
+		// the update operation isn't signed
+		doc, err := dochandler.ProcessOperation(getUpdateOperation())
+		require.Error(t, err)
+		require.Nil(t, doc)
+		require.Contains(t, err.Error(), "validate operation against current document state: missing signed data")
+		require.True(t, errors.Is(err, errs.ErrInvalidSignature))
+	})
+
+	t.Run("error - chained update while the previous update is pending", func(t *testing.T) {
+		// the batch writer isn't started, i.e. the first update remains pending
+		writer, err := batch.New(namespace, &BatchContext{
+			ProtocolClient:   mocks.NewMockProtocolClient(),
+			CasClient:        mocks.NewMockCasClient(nil),
+			BlockchainClient: mocks.NewMockBlockchainClient(nil),
+			OpQueue:          &opqueue.MemQueue{},
+		})
+		require.NoError(t, err)
+
+		processor := &mockProcessor{}
+		handler := New(namespace, dochandler.protocol, validator, writer, processor)
+
+		_, err = handler.ProcessOperation(getUpdateOperation())
+		require.NoError(t, err)
+
+		// the second update reveals the commitment of the pending update, i.e. it doesn't match the commitment
+		// of the current document state
+		processor.err = errs.New(errs.CommitmentMismatch, "update commitment doesn't match")
+
+		doc, err := handler.ProcessOperation(getUpdateOperation())
+		require.Error(t, err)
+		require.Nil(t, doc)
+		require.True(t, errors.Is(err, errs.ErrConflict))
+		require.Contains(t, err.Error(), "is already pending")
+	})
+
+	t.Run("error - chained update while the previous update is anchored but not observed", func(t *testing.T) {
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		// the anchor of the previous update was confirmed (i.e. it's no longer pending in the batch writer) but the
+		// observer hasn't stored the update yet, i.e. the chained update doesn't match the current commitment
+		processor := &mockProcessor{err: errs.New(errs.CommitmentMismatch, "update commitment doesn't match")}
+		handler := New(namespace, dochandler.protocol, validator, &mockBatchWriter{}, processor, WithOperationStatusStore(statusStore))
+
+		previous := getUpdateOperation()
+		require.NoError(t, statusStore.Put(&opstatus.OperationStatus{
+			ID:           opstatus.ID([]byte("previous")),
+			Namespace:    namespace,
+			UniqueSuffix: previous.UniqueSuffix,
+			Status:       opstatus.Anchored,
+			AnchorString: "anchor",
+		}))
+
+		doc, err := handler.ProcessOperation(getUpdateOperation())
+		require.Error(t, err)
+		require.Nil(t, doc)
+		require.True(t, errors.Is(err, errs.ErrConflict))
+		require.Contains(t, err.Error(), "was anchored but it wasn't processed yet")
+
+		// the observer stored the previous update
+		require.NoError(t, statusStore.Put(&opstatus.OperationStatus{
+			ID:           opstatus.ID([]byte("previous")),
+			Namespace:    namespace,
+			UniqueSuffix: previous.UniqueSuffix,
+			Status:       opstatus.Confirmed,
+			AnchorString: "anchor",
+		}))
+
+		processor.err = nil
+
+		_, err = handler.ProcessOperation(getUpdateOperation())
+		require.NoError(t, err)
+
+		// status store error
+		statusStore.Err = errors.New("injected status store error")
+
+		_, err = handler.ProcessOperation(getUpdateOperation())
+		require.EqualError(t, err, "injected status store error")
+	})
+
+	t.Run("error - validation error", func(t *testing.T) {
+		processor := dochandler.processor
+		dochandler.processor = &mockProcessor{err: errors.New("update delta doesn't match delta hash")}
+		defer func() { dochandler.processor = processor }()
+
+		doc, err := dochandler.ProcessOperation(getUpdateOperation())
+		require.Error(t, err)
+		require.Nil(t, doc)
+		require.True(t, errors.Is(err, errs.ErrBadRequest))
+	})
 }
 
 // BatchContext implements batch writer context
@@ -300,7 +643,35 @@ func (m *BatchContext) OperationQueue() cutter.OperationQueue {
 	return m.OpQueue
 }
 
-func getDocumentHandler(store processor.OperationStoreClient) *DocumentHandler {
+type mockProcessor struct {
+	result *document.ResolutionResult
+	err    error
+}
+
+func (m *mockProcessor) Resolve(string, ...document.ResolutionOption) (*document.ResolutionResult, error) {
+	return m.result, nil
+}
+
+func (m *mockProcessor) ValidateOperation(*batchapi.Operation) error {
+	return m.err
+}
+
+type mockBatchWriter struct {
+	err error
+	ops []*batchapi.OperationInfo
+}
+
+func (m *mockBatchWriter) Add(op *batchapi.OperationInfo) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.ops = append(m.ops, op)
+
+	return nil
+}
+
+func getDocumentHandler(store processor.OperationStoreClient, opts ...Option) *DocumentHandler {
 	protocol := mocks.NewMockProtocolClient()
 
 	validator := docvalidator.New(store)
@@ -320,7 +691,7 @@ func getDocumentHandler(store processor.OperationStoreClient) *DocumentHandler {
 	// start go routine for cutting batches
 	writer.Start()
 
-	return New(namespace, protocol, validator, writer, processor)
+	return New(namespace, protocol, validator, writer, processor, opts...)
 }
 
 func getCreateOperation() *batchapi.Operation {
@@ -6,11 +6,21 @@ SPDX-License-Identifier: Apache-2.0
 
 package document
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// versionIDSeparator separates the transaction number and the operation index of a version ID
+const versionIDSeparator = "-"
+
 // ResolutionResult describes resolution result
 type ResolutionResult struct {
-	Context        string         `json:"@context"`
-	Document       Document       `json:"didDocument"`
-	MethodMetadata MethodMetadata `json:"methodMetadata"`
+	Context          string           `json:"@context"`
+	Document         Document         `json:"didDocument"`
+	DocumentMetadata DocumentMetadata `json:"didDocumentMetadata"`
+	MethodMetadata   MethodMetadata   `json:"methodMetadata"`
 }
 
 // MethodMetadata contains document metadata
@@ -19,3 +29,98 @@ type MethodMetadata struct {
 	RecoveryCommitment string `json:"recoveryCommitment"`
 	Published          bool   `json:"published"`
 }
+
+// DocumentMetadata contains metadata about the document that is derived from the operations that were applied
+type DocumentMetadata struct {
+	// Created is the transaction time of the create operation
+	Created uint64 `json:"created,omitempty"`
+	// Updated is the transaction time of the last operation that was applied to the document
+	Updated uint64 `json:"updated,omitempty"`
+	// VersionID is the version ID of the last operation that was applied to the document (see VersionID)
+	VersionID string `json:"versionId,omitempty"`
+	// OperationCount is the number of operations that were applied to the document
+	OperationCount int `json:"operationCount,omitempty"`
+	// Deactivated is true if the document was deactivated
+	Deactivated bool `json:"deactivated,omitempty"`
+
+	// CanonicalID is the short-form ID of a published document that was resolved using its long-form ID
+	CanonicalID string `json:"canonicalId,omitempty"`
+	// EquivalentID contains the short-form ID of a document that was resolved using its long-form ID
+	EquivalentID []string `json:"equivalentId,omitempty"`
+}
+
+// ResolutionOption is an option for document resolution
+type ResolutionOption func(opts *ResolutionOptions)
+
+// ResolutionOptions contains the options for resolving a historical version of a document. If no version is
+// specified then the latest version of the document is resolved.
+type ResolutionOptions struct {
+	// VersionID is the version ID of the last operation that is applied
+	VersionID *VersionID
+	// VersionTime is the transaction time after which operations are not applied
+	VersionTime *uint64
+}
+
+// VersionID identifies a version of a document by the position of the last operation that was applied to the
+// document, i.e. the number of the transaction in which the operation was anchored and the index of the operation
+// in the transaction (a transaction may contain multiple operations for the same document). The string form of a
+// version ID is "<transaction number>-<operation index>".
+type VersionID struct {
+	TransactionNumber uint64
+	OperationIndex    uint
+}
+
+// String returns the string form of the version ID
+func (v VersionID) String() string {
+	return fmt.Sprintf("%d%s%d", v.TransactionNumber, versionIDSeparator, v.OperationIndex)
+}
+
+// ParseVersionID parses the string form of a version ID
+func ParseVersionID(value string) (VersionID, error) {
+	parts := strings.Split(value, versionIDSeparator)
+	if len(parts) != 2 {
+		return VersionID{}, fmt.Errorf("version ID must be of the form <transaction number>%s<operation index>", versionIDSeparator)
+	}
+
+	txnNumber, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return VersionID{}, fmt.Errorf("invalid transaction number: %s", parts[0])
+	}
+
+	opIndex, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return VersionID{}, fmt.Errorf("invalid operation index: %s", parts[1])
+	}
+
+	return VersionID{TransactionNumber: txnNumber, OperationIndex: uint(opIndex)}, nil
+}
+
+// WithVersionID resolves the document as it was after the operation with the given version ID was applied
+func WithVersionID(versionID VersionID) ResolutionOption {
+	return func(opts *ResolutionOptions) {
+		opts.VersionID = &versionID
+	}
+}
+
+// WithVersionTime resolves the document as it was at the given transaction time
+func WithVersionTime(versionTime uint64) ResolutionOption {
+	return func(opts *ResolutionOptions) {
+		opts.VersionTime = &versionTime
+	}
+}
+
+// GetResolutionOptions returns the resolution options populated from the given options
+func GetResolutionOptions(opts ...ResolutionOption) ResolutionOptions {
+	options := ResolutionOptions{}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// IsHistorical returns true if a version of the document was requested
+func (o ResolutionOptions) IsHistorical() bool {
+	return o.VersionID != nil || o.VersionTime != nil
+}
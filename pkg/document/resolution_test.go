@@ -0,0 +1,45 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package document
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionID(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		versionID := VersionID{TransactionNumber: 12, OperationIndex: 3}
+		require.Equal(t, "12-3", versionID.String())
+
+		parsed, err := ParseVersionID(versionID.String())
+		require.NoError(t, err)
+		require.Equal(t, versionID, parsed)
+	})
+
+	t.Run("error - invalid format", func(t *testing.T) {
+		for _, value := range []string{"12", "12-3-4", ""} {
+			_, err := ParseVersionID(value)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "version ID must be of the form")
+		}
+	})
+
+	t.Run("error - invalid transaction number", func(t *testing.T) {
+		_, err := ParseVersionID("abc-3")
+		require.EqualError(t, err, "invalid transaction number: abc")
+	})
+
+	t.Run("error - invalid operation index", func(t *testing.T) {
+		_, err := ParseVersionID("12--1")
+		require.Error(t, err)
+
+		_, err = ParseVersionID("12-abc")
+		require.EqualError(t, err, "invalid operation index: abc")
+	})
+}
@@ -0,0 +1,137 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code classifies an error. Codes are stable so they may be returned to clients.
+type Code string
+
+const (
+	// Internal is the code of an error that isn't classified
+	Internal Code = "internal_error"
+	// NotFound indicates that the document (or another resource) doesn't exist
+	NotFound Code = "not_found"
+	// BadRequest indicates that the request or operation is invalid
+	BadRequest Code = "bad_request"
+	// Deactivated indicates that the document was deactivated
+	Deactivated Code = "deactivated"
+	// InvalidSignature indicates that the signed data of an operation is missing or can't be verified
+	InvalidSignature Code = "invalid_signature"
+	// CommitmentMismatch indicates that the key of an operation doesn't match the commitment of the document
+	CommitmentMismatch Code = "commitment_mismatch"
+	// MissingField indicates that a required field of the request is missing
+	MissingField Code = "missing_field"
+	// InvalidField indicates that a field of the request can't be decoded or has an invalid value
+	InvalidField Code = "invalid_field"
+	// InvalidDocument indicates that the document in the request violates the document rules
+	InvalidDocument Code = "invalid_document"
+	// UnsupportedOperation indicates that the operation type of the request isn't supported
+	UnsupportedOperation Code = "unsupported_operation"
+	// Conflict indicates that the request conflicts with an operation that is already pending
+	Conflict Code = "conflict"
+	// RequestTooLarge indicates that the request exceeds the maximum allowed size
+	RequestTooLarge Code = "request_too_large"
+)
+
+// Sentinel errors for use with errors.Is. An error matches a sentinel if it has the same code.
+var (
+	ErrNotFound           = &Error{code: NotFound, msg: "not found"}
+	ErrBadRequest         = &Error{code: BadRequest, msg: "bad request"}
+	ErrDeactivated        = &Error{code: Deactivated, msg: "document was deactivated"}
+	ErrInvalidSignature   = &Error{code: InvalidSignature, msg: "invalid signature"}
+	ErrCommitmentMismatch = &Error{code: CommitmentMismatch, msg: "commitment mismatch"}
+	ErrConflict           = &Error{code: Conflict, msg: "conflict"}
+)
+
+// Error is an error with a code and, optionally, the (JSON) path of the request field that caused the error
+type Error struct {
+	code  Code
+	field string
+	msg   string
+	cause error
+}
+
+// New returns a new error with the given code and formatted message
+func New(code Code, format string, args ...interface{}) error {
+	return &Error{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns a new error with the given code that wraps the given error. The message of the wrapped
+// error is appended to the formatted message.
+func Wrap(code Code, err error, format string, args ...interface{}) error {
+	return &Error{code: code, msg: fmt.Sprintf(format, args...), cause: err}
+}
+
+// NewField returns a new error with the given code and formatted message for the given request field
+func NewField(code Code, field, format string, args ...interface{}) error {
+	return &Error{code: code, field: field, msg: fmt.Sprintf(format, args...)}
+}
+
+// WrapField returns a new error with the given code for the given request field that wraps the given error
+func WrapField(code Code, field string, err error, format string, args ...interface{}) error {
+	return &Error{code: code, field: field, msg: fmt.Sprintf(format, args...), cause: err}
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.msg
+	}
+
+	return e.msg + ": " + e.cause.Error()
+}
+
+// Code returns the error code
+func (e *Error) Code() Code {
+	return e.code
+}
+
+// Field returns the request field that caused the error (if known)
+func (e *Error) Field() string {
+	return e.field
+}
+
+// Unwrap returns the wrapped error (if any)
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is returns true if the target is an Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+
+	return ok && t.code == e.code
+}
+
+// CodeOf returns the code of the first Error in the chain of the given error or Internal if there's none
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.code
+	}
+
+	return Internal
+}
+
+// FieldOf returns the first request field in the chain of the given error or an empty string if there's none
+func FieldOf(err error) string {
+	var e *Error
+
+	for errors.As(err, &e) {
+		if e.field != "" {
+			return e.field
+		}
+
+		err = e.cause
+	}
+
+	return ""
+}
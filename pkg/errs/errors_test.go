@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package errs
+
+import (
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	err := New(NotFound, "unique suffix not found: %s", "abc")
+	require.EqualError(t, err, "unique suffix not found: abc")
+	require.Equal(t, NotFound, CodeOf(err))
+	require.True(t, errors.Is(err, ErrNotFound))
+	require.False(t, errors.Is(err, ErrBadRequest))
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("invalid JWS")
+
+	err := Wrap(InvalidSignature, cause, "failed to check signature")
+	require.EqualError(t, err, "failed to check signature: invalid JWS")
+	require.Equal(t, InvalidSignature, CodeOf(err))
+	require.True(t, errors.Is(err, ErrInvalidSignature))
+	require.True(t, errors.Is(err, cause))
+
+	t.Run("wrapped by another package", func(t *testing.T) {
+		wrapped := pkgerrors.Wrap(New(CommitmentMismatch, "commitments don't match"), "apply operation")
+		require.Equal(t, CommitmentMismatch, CodeOf(wrapped))
+		require.True(t, errors.Is(wrapped, ErrCommitmentMismatch))
+	})
+
+	t.Run("outermost code", func(t *testing.T) {
+		wrapped := Wrap(BadRequest, ErrNotFound, "invalid request")
+		require.Equal(t, BadRequest, CodeOf(wrapped))
+		require.True(t, errors.Is(wrapped, ErrBadRequest))
+		require.True(t, errors.Is(wrapped, ErrNotFound))
+	})
+}
+
+func TestCodeOf(t *testing.T) {
+	require.Equal(t, Internal, CodeOf(errors.New("some error")))
+	require.Equal(t, Internal, CodeOf(nil))
+	require.Equal(t, Deactivated, CodeOf(ErrDeactivated))
+
+	var e *Error
+	require.True(t, errors.As(pkgerrors.Wrap(ErrBadRequest, "wrapped"), &e))
+	require.Equal(t, BadRequest, e.Code())
+}
+
+func TestField(t *testing.T) {
+	err := NewField(MissingField, "did_suffix", "missing did suffix")
+	require.EqualError(t, err, "missing did suffix")
+	require.Equal(t, MissingField, CodeOf(err))
+	require.Equal(t, "did_suffix", FieldOf(err))
+
+	err = WrapField(InvalidField, "delta", errors.New("illegal base64 data"), "decode delta")
+	require.EqualError(t, err, "decode delta: illegal base64 data")
+	require.Equal(t, InvalidField, CodeOf(err))
+	require.Equal(t, "delta", FieldOf(err))
+
+	t.Run("nested field", func(t *testing.T) {
+		wrapped := Wrap(BadRequest, pkgerrors.Wrap(err, "parse operation"), "bad request")
+		require.Equal(t, BadRequest, CodeOf(wrapped))
+		require.Equal(t, "delta", FieldOf(wrapped))
+	})
+
+	t.Run("no field", func(t *testing.T) {
+		require.Empty(t, FieldOf(New(BadRequest, "bad request")))
+		require.Empty(t, FieldOf(errors.New("some error")))
+		require.Empty(t, FieldOf(nil))
+	})
+}
@@ -0,0 +1,297 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package filecas
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/multiformats/go-multihash"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
+var logger = log.New("sidetree-core-filecas")
+
+const (
+	// DefaultMaxContentSize is the default maximum size (in bytes) of the content that is stored
+	DefaultMaxContentSize = 10 << 20
+
+	// DefaultHashAlgorithm is the default multihash code of the hash algorithm that is used to compute addresses
+	DefaultHashAlgorithm = 18 // SHA2-256
+
+	tempFileExt = ".tmp"
+
+	dirPerm = 0700
+)
+
+// Client implements content addressable storage on the local file system. The address of the content is
+// the (base64url encoded) multihash of the content. Content is stored in a file that is named after
+// its address in a shard directory that is named after the first byte of the hash (in hex), so that
+// the number of files per directory stays manageable. Content is written to a temp file which is then
+// renamed, i.e. a file in a shard directory is always complete. The hash of the content is verified when
+// the content is read.
+type Client struct {
+	dir            string
+	maxContentSize int
+	hashAlgorithm  uint
+}
+
+// Option defines file CAS options such as maximum content size
+type Option func(c *Client)
+
+// WithMaxContentSize sets the maximum size (in bytes) of the content that is stored.
+// Larger content is rejected by Write and Read.
+func WithMaxContentSize(size int) Option {
+	return func(c *Client) {
+		c.maxContentSize = size
+	}
+}
+
+// WithHashAlgorithm sets the multihash code of the hash algorithm that is used to compute addresses
+func WithHashAlgorithm(multihashCode uint) Option {
+	return func(c *Client) {
+		c.hashAlgorithm = multihashCode
+	}
+}
+
+// New opens (or creates) the content addressable storage in the given directory
+func New(dir string, opts ...Option) (*Client, error) {
+	c := &Client{
+		dir:            dir,
+		maxContentSize: DefaultMaxContentSize,
+		hashAlgorithm:  DefaultHashAlgorithm,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if _, err := docutil.GetHash(c.hashAlgorithm); err != nil {
+		return nil, errors.Wrapf(err, "hash algorithm [%d]", c.hashAlgorithm)
+	}
+
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create CAS directory [%s]", dir)
+	}
+
+	return c, nil
+}
+
+// Write writes the given content to CAS.
+// returns the multihash of the content in base64url encoding which represents the address of the content.
+// Writing content that is already stored doesn't modify the stored file.
+func (c *Client) Write(content []byte) (string, error) {
+	if len(content) > c.maxContentSize {
+		return "", errs.New(errs.RequestTooLarge, "content size %d exceeds maximum size %d", len(content), c.maxContentSize)
+	}
+
+	hash, err := docutil.ComputeMultihash(c.hashAlgorithm, content)
+	if err != nil {
+		return "", errors.Wrap(err, "compute content hash")
+	}
+
+	address := docutil.EncodeToString(hash)
+
+	path, err := c.path(address)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		logger.Debugf("Content[%s] is already stored", address)
+
+		return address, nil
+	}
+
+	if err := c.writeFile(path, content); err != nil {
+		return "", errors.WithMessagef(err, "write content[%s]", address)
+	}
+
+	return address, nil
+}
+
+// Read reads the content of the given address in CAS.
+// returns the content of the given address.
+func (c *Client) Read(address string) ([]byte, error) {
+	path, err := c.path(address)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, errs.New(errs.NotFound, "content[%s] not found", address)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "stat content[%s]", address)
+	}
+
+	if info.Size() > int64(c.maxContentSize) {
+		return nil, fmt.Errorf("content[%s] size %d exceeds maximum size %d", address, info.Size(), c.maxContentSize)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "read content[%s]", address)
+	}
+
+	if err := verifyHash(address, content); err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// List returns the addresses of all of the content in CAS.
+func (c *Client) List() ([]string, error) {
+	shards, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "list CAS directory")
+	}
+
+	var addresses []string
+
+	for _, shard := range shards {
+		if !shard.IsDir() {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(c.dir, shard.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "list shard directory [%s]", shard.Name())
+		}
+
+		for _, file := range files {
+			address := file.Name()
+
+			// ignore temp files (which aren't named after an address) and files that don't belong to the shard
+			if path, err := c.path(address); err != nil || filepath.Base(filepath.Dir(path)) != shard.Name() {
+				logger.Debugf("Ignoring file [%s] in shard directory [%s]", address, shard.Name())
+				continue
+			}
+
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses, nil
+}
+
+// Delete deletes the content of the given address from CAS. Deleting content that isn't stored isn't an error.
+func (c *Client) Delete(address string) error {
+	path, err := c.path(address)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "delete content[%s]", address)
+	}
+
+	return nil
+}
+
+// writeFile atomically writes the file (write to temp file, sync and rename)
+func (c *Client) writeFile(path string, content []byte) error {
+	shardDir := filepath.Dir(path)
+
+	if err := os.MkdirAll(shardDir, dirPerm); err != nil {
+		return errors.Wrap(err, "create shard directory")
+	}
+
+	// the temp file name is unique so that the same content may be written concurrently (the temp file
+	// is created with mode 0600)
+	f, err := ioutil.TempFile(shardDir, filepath.Base(path)+".*"+tempFileExt)
+	if err != nil {
+		return errors.Wrap(err, "create temp file")
+	}
+
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		if e := os.Remove(f.Name()); e != nil {
+			logger.Warnf("Failed to remove temp file [%s]: %s", f.Name(), e)
+		}
+
+		return errors.Wrap(err, "write temp file")
+	}
+
+	return syncDir(shardDir)
+}
+
+// path returns the path of the file for the given address. An error is returned if the address isn't
+// a (canonically encoded) multihash, which also ensures that the path is within the CAS directory.
+func (c *Client) path(address string) (string, error) {
+	decoded, err := docutil.DecodeString(address)
+	if err != nil || docutil.EncodeToString(decoded) != address {
+		return "", errs.New(errs.BadRequest, "invalid address [%s]", address)
+	}
+
+	mh, err := multihash.Decode(decoded)
+	if err != nil || len(mh.Digest) == 0 {
+		return "", errs.New(errs.BadRequest, "address [%s] is not a multihash", address)
+	}
+
+	return filepath.Join(c.dir, fmt.Sprintf("%02x", mh.Digest[0]), address), nil
+}
+
+// verifyHash verifies that the multihash of the given content matches the address
+func verifyHash(address string, content []byte) error {
+	code, err := docutil.GetMultihashCode(address)
+	if err != nil {
+		return errors.Wrapf(err, "content[%s] hash", address)
+	}
+
+	hash, err := docutil.ComputeMultihash(uint(code), content)
+	if err != nil {
+		return errors.Wrapf(err, "content[%s] hash", address)
+	}
+
+	if docutil.EncodeToString(hash) != address {
+		return fmt.Errorf("content[%s] hash doesn't match address", address)
+	}
+
+	return nil
+}
+
+// syncDir syncs the given directory so that created and renamed files are persisted
+func syncDir(dir string) error {
+	d, err := os.Open(filepath.Clean(dir))
+	if err != nil {
+		return errors.Wrap(err, "open shard directory")
+	}
+
+	err = d.Sync()
+
+	if e := d.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "sync shard directory")
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package filecas
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/cas"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
+var _ cas.DeletableClient = (*Client)(nil)
+
+func TestClient(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	c, err := New(dir)
+	require.NoError(t, err)
+
+	address, err := c.Write([]byte("content"))
+	require.NoError(t, err)
+
+	// the address is the multihash of the content
+	hash, err := docutil.ComputeMultihash(DefaultHashAlgorithm, []byte("content"))
+	require.NoError(t, err)
+	require.Equal(t, docutil.EncodeToString(hash), address)
+
+	// the content is stored in the shard directory of the hash
+	_, err = os.Stat(filepath.Join(dir, fmt.Sprintf("%02x", hash[2]), address))
+	require.NoError(t, err)
+
+	content, err := c.Read(address)
+	require.NoError(t, err)
+	require.Equal(t, []byte("content"), content)
+
+	// writing the same content again returns the same address
+	address2, err := c.Write([]byte("content"))
+	require.NoError(t, err)
+	require.Equal(t, address, address2)
+
+	address2, err = c.Write([]byte("content2"))
+	require.NoError(t, err)
+
+	addresses, err := c.List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{address, address2}, addresses)
+
+	require.NoError(t, c.Delete(address))
+	require.NoError(t, c.Delete(address))
+
+	_, err = c.Read(address)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, errs.ErrNotFound))
+
+	// the content is still there after reopening
+	c, err = New(dir)
+	require.NoError(t, err)
+
+	addresses, err = c.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{address2}, addresses)
+
+	content, err = c.Read(address2)
+	require.NoError(t, err)
+	require.Equal(t, []byte("content2"), content)
+}
+
+func TestClient_ConcurrentWrites(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	c, err := New(dir)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	errCh := make(chan error, 20)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			_, e := c.Write([]byte(fmt.Sprintf("content%d", i%5)))
+			errCh <- e
+		}(i)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	for e := range errCh {
+		require.NoError(t, e)
+	}
+
+	addresses, err := c.List()
+	require.NoError(t, err)
+	require.Len(t, addresses, 5)
+
+	// no temp files are left behind
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"+tempFileExt))
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestClient_List(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	c, err := New(dir)
+	require.NoError(t, err)
+
+	address, err := c.Write([]byte("content"))
+	require.NoError(t, err)
+
+	path, err := c.path(address)
+	require.NoError(t, err)
+
+	// temp files, files in the wrong shard and files outside of shards are ignored
+	require.NoError(t, ioutil.WriteFile(path+".123"+tempFileExt, []byte("content"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "xx"), dirPerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "xx", address), []byte("content"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, address), []byte("content"), 0600))
+
+	addresses, err := c.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{address}, addresses)
+
+	t.Run("error - CAS directory was removed", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(dir))
+
+		_, err := c.List()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "list CAS directory")
+	})
+}
+
+func TestClient_Errors(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	t.Run("error - unsupported hash algorithm", func(t *testing.T) {
+		_, err := New(dir, WithHashAlgorithm(100))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "hash algorithm [100]")
+	})
+
+	t.Run("error - CAS directory can't be created", func(t *testing.T) {
+		file := filepath.Join(dir, "file")
+		require.NoError(t, ioutil.WriteFile(file, []byte("content"), 0600))
+
+		_, err := New(filepath.Join(file, "cas"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create CAS directory")
+	})
+
+	t.Run("error - invalid address", func(t *testing.T) {
+		c, err := New(dir)
+		require.NoError(t, err)
+
+		for _, address := range []string{"../file", "", "YWJj", "YWJjZA=="} {
+			_, err = c.Read(address)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, errs.ErrBadRequest))
+
+			err = c.Delete(address)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, errs.ErrBadRequest))
+		}
+	})
+
+	t.Run("error - content size exceeds maximum size", func(t *testing.T) {
+		c, err := New(dir, WithMaxContentSize(10))
+		require.NoError(t, err)
+
+		_, err = c.Write([]byte("content is too large"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "content size 20 exceeds maximum size 10")
+
+		c2, err := New(dir)
+		require.NoError(t, err)
+
+		address, err := c2.Write([]byte("content is too large"))
+		require.NoError(t, err)
+
+		_, err = c.Read(address)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "size 20 exceeds maximum size 10")
+	})
+
+	t.Run("error - hash doesn't match address", func(t *testing.T) {
+		c, err := New(dir)
+		require.NoError(t, err)
+
+		address, err := c.Write([]byte("content"))
+		require.NoError(t, err)
+
+		path, err := c.path(address)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, []byte("tampered"), 0600))
+
+		_, err = c.Read(address)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "hash doesn't match address")
+	})
+
+	t.Run("error - shard directory can't be created", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		c, err := New(dir)
+		require.NoError(t, err)
+
+		hash, err := docutil.ComputeMultihash(DefaultHashAlgorithm, []byte("content"))
+		require.NoError(t, err)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%02x", hash[2])), []byte("content"), 0600))
+
+		_, err = c.Write([]byte("content"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create shard directory")
+	})
+}
+
+func newTestDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "filecas")
+	require.NoError(t, err)
+
+	return dir, func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
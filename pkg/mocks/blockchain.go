@@ -15,9 +15,10 @@ import (
 // MockBlockchainClient mocks blockchain client for testing purposes.
 type MockBlockchainClient struct {
 	sync.RWMutex
-	namespace string
-	anchors   []string
-	err       error
+	namespace  string
+	anchors    []string
+	namespaces []string
+	err        error
 }
 
 // NewMockBlockchainClient creates mock client
@@ -35,6 +36,22 @@ func (m *MockBlockchainClient) WriteAnchor(anchorFileHash string) error {
 	defer m.Unlock()
 
 	m.anchors = append(m.anchors, anchorFileHash)
+	m.namespaces = append(m.namespaces, m.namespace)
+
+	return nil
+}
+
+// WriteNamespaceAnchor writes the anchor file hash of the given namespace as a transaction to blockchain.
+func (m *MockBlockchainClient) WriteNamespaceAnchor(namespace, anchorFileHash string) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.anchors = append(m.anchors, anchorFileHash)
+	m.namespaces = append(m.namespaces, namespace)
 
 	return nil
 }
@@ -51,7 +68,7 @@ func (m *MockBlockchainClient) Read(sinceTransactionNumber int) (bool, *txn.Side
 		hashIndex := sinceTransactionNumber + 1
 
 		txn := &txn.SidetreeTxn{
-			Namespace:         m.namespace,
+			Namespace:         m.namespaces[hashIndex],
 			TransactionTime:   uint64(hashIndex),
 			TransactionNumber: uint64(hashIndex),
 			AnchorString:      m.anchors[hashIndex],
@@ -63,6 +80,14 @@ func (m *MockBlockchainClient) Read(sinceTransactionNumber int) (bool, *txn.Side
 	return moreTransactions, nil
 }
 
+// LastTransactionNumber returns the number of the last transaction (-1 if there are no transactions)
+func (m *MockBlockchainClient) LastTransactionNumber() (int, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return len(m.anchors) - 1, nil
+}
+
 // GetAnchors returns anchors
 func (m *MockBlockchainClient) GetAnchors() []string {
 	m.RLock()
@@ -70,3 +95,18 @@ func (m *MockBlockchainClient) GetAnchors() []string {
 
 	return m.anchors
 }
+
+// GetNamespaceAnchors returns the anchors of the given namespace
+func (m *MockBlockchainClient) GetNamespaceAnchors(namespace string) []string {
+	m.RLock()
+	defer m.RUnlock()
+
+	var anchors []string
+	for i, anchor := range m.anchors {
+		if m.namespaces[i] == namespace {
+			anchors = append(anchors, anchor)
+		}
+	}
+
+	return anchors
+}
@@ -86,6 +86,39 @@ func (m *MockCasClient) Read(address string) ([]byte, error) {
 	return value, nil
 }
 
+// List returns the addresses of all of the content in CAS.
+func (m *MockCasClient) List() ([]string, error) {
+	err := m.GetError()
+	if err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	addresses := make([]string, 0, len(m.m))
+	for address := range m.m {
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+// Delete deletes the content of the given address from CAS.
+func (m *MockCasClient) Delete(address string) error {
+	err := m.GetError()
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.m, address)
+
+	return nil
+}
+
 // SetError injects an error into the mock client
 func (m *MockCasClient) SetError(err error) {
 	m.Lock()
@@ -8,8 +8,6 @@ package mocks
 
 import (
 	"encoding/json"
-	"errors"
-	"fmt"
 	"strings"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
@@ -17,6 +15,7 @@ import (
 	"github.com/trustbloc/sidetree-core-go/pkg/composer"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/request"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 )
@@ -66,7 +65,7 @@ func (m *MockDocumentHandler) Protocol() protocol.Client {
 }
 
 // ProcessOperation mocks process operation
-func (m *MockDocumentHandler) ProcessOperation(operation *batch.Operation) (*document.ResolutionResult, error) {
+func (m *MockDocumentHandler) ProcessOperation(operation *batch.Operation, _ ...batch.ProcessOption) (*document.ResolutionResult, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -96,7 +95,7 @@ func (m *MockDocumentHandler) ProcessOperation(operation *batch.Operation) (*doc
 }
 
 //ResolveDocument mocks resolve document
-func (m *MockDocumentHandler) ResolveDocument(idOrDocument string) (*document.ResolutionResult, error) {
+func (m *MockDocumentHandler) ResolveDocument(idOrDocument string, _ ...document.ResolutionOption) (*document.ResolutionResult, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -106,11 +105,15 @@ func (m *MockDocumentHandler) ResolveDocument(idOrDocument string) (*document.Re
 	}
 
 	if _, ok := m.store[idOrDocument]; !ok {
-		return nil, errors.New("not found")
+		return nil, errs.New(errs.NotFound, "not found")
 	}
 
 	if m.store[idOrDocument] == nil {
-		return nil, errors.New("was deactivated")
+		return &document.ResolutionResult{
+			Document:         make(document.Document),
+			DocumentMetadata: document.DocumentMetadata{Deactivated: true},
+			MethodMetadata:   document.MethodMetadata{Published: true},
+		}, nil
 	}
 
 	return &document.ResolutionResult{
@@ -126,10 +129,9 @@ func applyID(doc document.Document, id string) document.Document {
 }
 
 func (m *MockDocumentHandler) resolveWithInitialState(idOrDocument string) (*document.ResolutionResult, error) {
-	const badRequest = "bad request"
 	id, initialState, err := request.GetParts(m.namespace, idOrDocument)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s", badRequest, err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, "bad request")
 	}
 
 	decodedDelta, err := docutil.DecodeString(initialState.Delta)
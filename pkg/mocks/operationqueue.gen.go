@@ -21,10 +21,10 @@ type OperationQueue struct {
 		result1 uint
 		result2 error
 	}
-	RemoveStub        func(num uint) (uint, uint, error)
+	RemoveStub        func(ops []*batch.OperationInfo) (uint, uint, error)
 	removeMutex       sync.RWMutex
 	removeArgsForCall []struct {
-		num uint
+		ops []*batch.OperationInfo
 	}
 	removeReturns struct {
 		result1 uint
@@ -113,16 +113,21 @@ func (fake *OperationQueue) AddReturnsOnCall(i int, result1 uint, result2 error)
 	}{result1, result2}
 }
 
-func (fake *OperationQueue) Remove(num uint) (uint, uint, error) {
+func (fake *OperationQueue) Remove(ops []*batch.OperationInfo) (uint, uint, error) {
+	var opsCopy []*batch.OperationInfo
+	if ops != nil {
+		opsCopy = make([]*batch.OperationInfo, len(ops))
+		copy(opsCopy, ops)
+	}
 	fake.removeMutex.Lock()
 	ret, specificReturn := fake.removeReturnsOnCall[len(fake.removeArgsForCall)]
 	fake.removeArgsForCall = append(fake.removeArgsForCall, struct {
-		num uint
-	}{num})
-	fake.recordInvocation("Remove", []interface{}{num})
+		ops []*batch.OperationInfo
+	}{opsCopy})
+	fake.recordInvocation("Remove", []interface{}{opsCopy})
 	fake.removeMutex.Unlock()
 	if fake.RemoveStub != nil {
-		return fake.RemoveStub(num)
+		return fake.RemoveStub(ops)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3
@@ -136,10 +141,10 @@ func (fake *OperationQueue) RemoveCallCount() int {
 	return len(fake.removeArgsForCall)
 }
 
-func (fake *OperationQueue) RemoveArgsForCall(i int) uint {
+func (fake *OperationQueue) RemoveArgsForCall(i int) []*batch.OperationInfo {
 	fake.removeMutex.RLock()
 	defer fake.removeMutex.RUnlock()
-	return fake.removeArgsForCall[i].num
+	return fake.removeArgsForCall[i].ops
 }
 
 func (fake *OperationQueue) RemoveReturns(result1 uint, result2 uint, result3 error) {
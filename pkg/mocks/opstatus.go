@@ -0,0 +1,98 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
+// MockOperationStatusStore mocks the operation status store for testing purposes.
+type MockOperationStatusStore struct {
+	sync.RWMutex
+	statuses map[string]*opstatus.OperationStatus
+	Err      error
+}
+
+// NewMockOperationStatusStore creates a mock operation status store
+func NewMockOperationStatusStore() *MockOperationStatusStore {
+	return &MockOperationStatusStore{statuses: make(map[string]*opstatus.OperationStatus)}
+}
+
+// Put mocks storing the status of an operation
+func (m *MockOperationStatusStore) Put(status *opstatus.OperationStatus) error {
+	if m.Err != nil {
+		return m.Err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	st := *status
+	m.statuses[status.ID] = &st
+
+	return nil
+}
+
+// Get mocks retrieving the status of an operation
+func (m *MockOperationStatusStore) Get(id string) (*opstatus.OperationStatus, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	status, ok := m.statuses[id]
+	if !ok {
+		return nil, errs.New(errs.NotFound, "operation status not found")
+	}
+
+	st := *status
+
+	return &st, nil
+}
+
+// GetByAnchor mocks retrieving the status of an operation by anchor string and unique suffix
+func (m *MockOperationStatusStore) GetByAnchor(anchorString, uniqueSuffix string) (*opstatus.OperationStatus, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, status := range m.statuses {
+		if status.AnchorString == anchorString && status.UniqueSuffix == uniqueSuffix {
+			st := *status
+
+			return &st, nil
+		}
+	}
+
+	return nil, errs.New(errs.NotFound, "operation status not found")
+}
+
+// IsAnchored mocks checking whether an operation for the given unique suffix was anchored but not confirmed yet
+func (m *MockOperationStatusStore) IsAnchored(namespace, uniqueSuffix string) (bool, error) {
+	if m.Err != nil {
+		return false, m.Err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, status := range m.statuses {
+		if status.Namespace == namespace && status.UniqueSuffix == uniqueSuffix && status.Status == opstatus.Anchored {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -20,12 +20,13 @@ const maxBatchFileSize = 20000
 
 // MockProtocolClient mocks protocol for testing purposes.
 type MockProtocolClient struct {
-	Protocol protocol.Protocol
+	Protocol protocol.Protocol    // current version (separated for easier testing)
+	Versions []*protocol.Protocol // all versions (sorted by starting blockchain time)
 }
 
 // NewMockProtocolClient creates mocks protocol client
 func NewMockProtocolClient() *MockProtocolClient {
-	return &MockProtocolClient{
+	m := &MockProtocolClient{
 		//nolint:gomnd // mock values are defined below.
 		Protocol: protocol.Protocol{
 			StartingBlockChainTime:       0,
@@ -38,6 +39,11 @@ func NewMockProtocolClient() *MockProtocolClient {
 			MaxAnchorFileSize:            maxBatchFileSize,
 		},
 	}
+
+	// current version is the only version by default
+	m.Versions = []*protocol.Protocol{&m.Protocol}
+
+	return m
 }
 
 // Current mocks getting last protocol version
@@ -45,6 +51,17 @@ func (m *MockProtocolClient) Current() protocol.Protocol {
 	return m.Protocol
 }
 
+// Get mocks getting protocol version based on blockchain(transaction) time
+func (m *MockProtocolClient) Get(transactionTime uint64) (protocol.Protocol, error) {
+	for i := len(m.Versions) - 1; i >= 0; i-- {
+		if transactionTime >= uint64(m.Versions[i].StartingBlockChainTime) {
+			return *m.Versions[i], nil
+		}
+	}
+
+	return protocol.Protocol{}, errors.Errorf("protocol parameters are not defined for blockchain time: %d", transactionTime)
+}
+
 // NewMockProtocolClientProvider creates new mock protocol client provider
 func NewMockProtocolClientProvider() *MockProtocolClientProvider {
 	m := make(map[string]protocol.Client)
@@ -7,10 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package mocks
 
 import (
-	"errors"
 	"sync"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
 // MockOperationStore mocks store for testing purposes.
@@ -26,26 +26,23 @@ func NewMockOperationStore(err error) *MockOperationStore {
 	return &MockOperationStore{operations: make(map[string][]*batch.Operation), Err: err, Validate: true}
 }
 
-//Put mocks storing operation
-func (m *MockOperationStore) Put(op *batch.Operation) error {
+//Put mocks storing operations
+func (m *MockOperationStore) Put(ops []*batch.Operation) error {
 	if m.Err != nil {
 		return m.Err
 	}
 
-	var opsSize int
-	m.RLock()
-	opsSize = len(m.operations[op.UniqueSuffix])
-	m.RUnlock()
-
-	if m.Validate && op.Type == batch.OperationTypeCreate && opsSize > 0 {
-		// Nothing to do; already created
-		return nil
-	}
-
 	m.Lock()
 	defer m.Unlock()
 
-	m.operations[op.UniqueSuffix] = append(m.operations[op.UniqueSuffix], op)
+	for _, op := range ops {
+		if m.Validate && op.Type == batch.OperationTypeCreate && len(m.operations[op.UniqueSuffix]) > 0 {
+			// Nothing to do; already created
+			continue
+		}
+
+		m.operations[op.UniqueSuffix] = append(m.operations[op.UniqueSuffix], op)
+	}
 
 	return nil
 }
@@ -63,5 +60,5 @@ func (m *MockOperationStore) Get(uniqueSuffix string) ([]*batch.Operation, error
 		return ops, nil
 	}
 
-	return nil, errors.New("uniqueSuffix not found in the store")
+	return nil, errs.New(errs.NotFound, "uniqueSuffix not found in the store")
 }
@@ -26,3 +26,8 @@ type noopOperationFilter struct {
 func (m *noopOperationFilter) Filter(_ string, ops []*batch.Operation) ([]*batch.Operation, error) {
 	return ops, nil
 }
+
+// Validate simply returns the provided operations without validating them
+func (m *noopOperationFilter) Validate(_ string, ops []*batch.Operation) ([]*batch.Operation, error) {
+	return ops, nil
+}
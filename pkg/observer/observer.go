@@ -8,13 +8,16 @@ package observer
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/trustbloc/edge-core/pkg/log"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
 var logger = log.New("sidetree-core-observer")
@@ -24,6 +27,31 @@ type Ledger interface {
 	RegisterForSidetreeTxn() <-chan []txn.SidetreeTxn
 }
 
+// ReplayableLedger is a Ledger that is able to replay the Sidetree transactions that were anchored after a given checkpoint
+type ReplayableLedger interface {
+	Ledger
+
+	// RegisterForSidetreeTxnAfter registers for Sidetree transactions. Transactions that were anchored after the
+	// given checkpoints (keyed by namespace) are delivered first, followed by new transactions. Transactions for
+	// namespaces that don't have a checkpoint are delivered from the beginning of the ledger.
+	RegisterForSidetreeTxnAfter(checkpoints map[string]Checkpoint) <-chan []txn.SidetreeTxn
+}
+
+// Checkpoint is the position of the last processed Sidetree transaction
+type Checkpoint struct {
+	TransactionTime   uint64 `json:"transactionTime"`
+	TransactionNumber uint64 `json:"transactionNumber"`
+}
+
+// CheckpointStore persists the last processed Sidetree transaction per namespace
+type CheckpointStore interface {
+	// Checkpoints returns the checkpoints of all namespaces
+	Checkpoints() (map[string]Checkpoint, error)
+
+	// Put stores the checkpoint for the given namespace
+	Put(namespace string, checkpoint Checkpoint) error
+}
+
 // TxnOpsProvider defines an interface for retrieving(assembling) operations from batch files(chunk, map, anchor)
 type TxnOpsProvider interface {
 	// GetTxnOperations will read batch files(chunk, map, anchor) and assemble batch operations from those files
@@ -51,11 +79,29 @@ type OperationFilter interface {
 	Filter(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, error)
 }
 
+// RejectingOperationFilter is implemented by operation filters that are able to report the reason why operations
+// were filtered out
+type RejectingOperationFilter interface {
+	FilterWithRejections(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, []*batch.RejectedOperation, error)
+}
+
+// OperationValidator is implemented by operation filters that are able to validate all of the operations
+// for a unique suffix (regardless of the operations in the store)
+type OperationValidator interface {
+	Validate(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, error)
+}
+
 // OperationFilterProvider returns an operation filter for the given namespace
 type OperationFilterProvider interface {
 	Get(namespace string) (OperationFilter, error)
 }
 
+// CacheInvalidator is notified when the operations for a unique suffix have changed so that
+// the cached document for the suffix may be invalidated
+type CacheInvalidator interface {
+	Invalidate(namespace, uniqueSuffix string)
+}
+
 // Providers contains all of the providers required by the TxnProcessor
 type Providers struct {
 	Ledger                Ledger
@@ -63,28 +109,58 @@ type Providers struct {
 	OpStoreProvider       OperationStoreProvider
 	OpFilterProvider      OperationFilterProvider
 	DecompressionProvider DecompressionProvider
+
+	// CheckpointStore is optional. If set then the last processed transaction is recorded so that
+	// the observer may resume from that transaction after restart.
+	CheckpointStore CheckpointStore
+
+	// DeadLetterStore is optional. If set then transactions that could not be processed after the maximum
+	// number of attempts are stored so that they may be redriven.
+	DeadLetterStore DeadLetterStore
+
+	// CacheInvalidator is optional. If set then it is notified when operations are stored for a unique suffix.
+	CacheInvalidator CacheInvalidator
+
+	// OperationStatusStore is optional. If set then the status of the operations that were submitted to this node
+	// is updated to confirmed or rejected (see RejectingOperationFilter) when the transaction is processed.
+	OperationStatusStore opstatus.Store
 }
 
 // Observer receives transactions over a channel and processes them by storing them to an operation store
 type Observer struct {
 	*Providers
 
-	processor *TxnProcessor
-	stopCh    chan struct{}
+	processor   *TxnProcessor
+	stopCh      chan struct{}
+	opts        Options
+	retries     *retryQueue
+	checkpoints map[string]Checkpoint
+	// heldBack contains the transactions (by namespace) that are held back until the failed transaction
+	// of the namespace is processed (or its dead letter is redriven or discarded)
+	heldBack map[string][]txn.SidetreeTxn
+	// deadLettered contains the dead-lettered transactions (by namespace and anchor string)
+	deadLettered map[string]map[string]txn.SidetreeTxn
 }
 
 // New returns a new observer
-func New(providers *Providers) *Observer {
+func New(providers *Providers, opts ...Option) *Observer {
 	return &Observer{
-		Providers: providers,
-		stopCh:    make(chan struct{}, 1),
-		processor: NewTxnProcessor(providers),
+		Providers:    providers,
+		stopCh:       make(chan struct{}, 1),
+		processor:    NewTxnProcessor(providers),
+		opts:         prepareOptsFromOptions(opts...),
+		retries:      &retryQueue{},
+		checkpoints:  make(map[string]Checkpoint),
+		heldBack:     make(map[string][]txn.SidetreeTxn),
+		deadLettered: make(map[string]map[string]txn.SidetreeTxn),
 	}
 }
 
 // Start starts observer routines
 func (o *Observer) Start() {
-	go o.listen(o.Ledger.RegisterForSidetreeTxn())
+	o.loadDeadLetters()
+
+	go o.listen(o.register(), o.registerForRollback())
 }
 
 // Stop stops the observer
@@ -92,13 +168,33 @@ func (o *Observer) Stop() {
 	o.stopCh <- struct{}{}
 }
 
-func (o *Observer) listen(txnsCh <-chan []txn.SidetreeTxn) {
+func (o *Observer) listen(txnsCh <-chan []txn.SidetreeTxn, rollbackCh <-chan Rollback) {
+	ticker := time.NewTicker(o.opts.RetryInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-o.stopCh:
 			logger.Infof("The observer has been stopped. Exiting.")
 			return
 
+		case now := <-ticker.C:
+			o.processRetries(now)
+
+		case r, ok := <-rollbackCh:
+			if !ok {
+				logger.Warnf("Rollback notification channel was closed.")
+
+				rollbackCh = nil
+
+				continue
+			}
+
+			if err := o.rollback(r); err != nil {
+				logger.Errorf("Failed to roll back namespace [%s] from transaction time [%d]: %s",
+					r.Namespace, r.TransactionTime, err)
+			}
+
 		case txns, ok := <-txnsCh:
 			if !ok {
 				logger.Warnf("Notification channel was closed. Exiting.")
@@ -110,17 +206,126 @@ func (o *Observer) listen(txnsCh <-chan []txn.SidetreeTxn) {
 	}
 }
 
+// register registers for Sidetree transactions. If a checkpoint store is configured and the ledger is able to
+// replay transactions then only the transactions after the last processed transactions are requested.
+func (o *Observer) register() <-chan []txn.SidetreeTxn {
+	if o.CheckpointStore == nil {
+		return o.Ledger.RegisterForSidetreeTxn()
+	}
+
+	checkpoints, err := o.CheckpointStore.Checkpoints()
+	if err != nil {
+		// transactions are processed idempotently so it's safe to process them again
+		logger.Errorf("Failed to load checkpoints - all transactions will be processed: %s", err)
+	}
+
+	for namespace, checkpoint := range checkpoints {
+		logger.Infof("Resuming namespace [%s] after transaction time [%d], number [%d]",
+			namespace, checkpoint.TransactionTime, checkpoint.TransactionNumber)
+
+		o.checkpoints[namespace] = checkpoint
+	}
+
+	ledger, ok := o.Ledger.(ReplayableLedger)
+	if !ok {
+		logger.Debugf("Ledger is not able to replay transactions")
+
+		return o.Ledger.RegisterForSidetreeTxn()
+	}
+
+	return ledger.RegisterForSidetreeTxnAfter(o.checkpoints)
+}
+
 func (o *Observer) process(txns []txn.SidetreeTxn) {
 	for _, txn := range txns {
-		err := o.processor.Process(txn)
-		if err != nil {
-			logger.Warnf("Failed to process anchor[%s]: %s", txn.AnchorString, err.Error())
-			continue
-		}
-		logger.Debugf("Successfully processed anchor[%s]", txn.AnchorString)
+		o.processTxn(txn)
+	}
+}
+
+// processTxn processes the given transaction. The transactions of a namespace are processed in order, so the
+// transactions that follow a failed transaction are held back until the failed transaction is processed (or its
+// dead letter is redriven or discarded). Otherwise the operations of the subsequent transactions would be validated
+// against a document state that is missing the operations of the failed transaction. (The held back transactions
+// aren't persisted but the checkpoint isn't saved either, so they're processed again after restart.)
+func (o *Observer) processTxn(sidetreeTxn txn.SidetreeTxn) {
+	if o.isProcessed(sidetreeTxn) {
+		logger.Debugf("Skipping anchor[%s] since it was already processed", sidetreeTxn.AnchorString)
+		return
+	}
+
+	if o.isHeldBack(sidetreeTxn.Namespace) {
+		logger.Debugf("Holding back anchor[%s] until the failed transaction of namespace [%s] is processed",
+			sidetreeTxn.AnchorString, sidetreeTxn.Namespace)
+
+		o.heldBack[sidetreeTxn.Namespace] = append(o.heldBack[sidetreeTxn.Namespace], sidetreeTxn)
+
+		return
+	}
+
+	err := o.processor.Process(sidetreeTxn)
+	if err != nil {
+		logger.Warnf("Failed to process anchor[%s]: %s", sidetreeTxn.AnchorString, err.Error())
+
+		o.handleFailure(&retryEntry{txn: sidetreeTxn, attempts: 1}, err)
+
+		return
+	}
+	logger.Debugf("Successfully processed anchor[%s]", sidetreeTxn.AnchorString)
+
+	o.saveCheckpoint(sidetreeTxn)
+}
+
+// continueAfter saves the checkpoint of the given failed transaction (which was either processed on retry or its
+// dead letter was resolved) and processes the transactions of the namespace that were held back. (The checkpoint
+// isn't saved if the namespace is still held back or if the checkpoint is past the given transaction already.)
+func (o *Observer) continueAfter(sidetreeTxn txn.SidetreeTxn) {
+	if !o.isHeldBack(sidetreeTxn.Namespace) && !o.isProcessed(sidetreeTxn) {
+		o.saveCheckpoint(sidetreeTxn)
+	}
+
+	heldBack := o.heldBack[sidetreeTxn.Namespace]
+	delete(o.heldBack, sidetreeTxn.Namespace)
+
+	for _, t := range heldBack {
+		o.processTxn(t)
 	}
 }
 
+// isProcessed returns true if the given transaction is at or before the checkpoint of its namespace
+func (o *Observer) isProcessed(sidetreeTxn txn.SidetreeTxn) bool {
+	checkpoint, ok := o.checkpoints[sidetreeTxn.Namespace]
+	if !ok {
+		return false
+	}
+
+	if sidetreeTxn.TransactionTime != checkpoint.TransactionTime {
+		return sidetreeTxn.TransactionTime < checkpoint.TransactionTime
+	}
+
+	return sidetreeTxn.TransactionNumber <= checkpoint.TransactionNumber
+}
+
+func (o *Observer) saveCheckpoint(sidetreeTxn txn.SidetreeTxn) {
+	if o.CheckpointStore == nil {
+		return
+	}
+
+	o.putCheckpoint(sidetreeTxn.Namespace, Checkpoint{
+		TransactionTime:   sidetreeTxn.TransactionTime,
+		TransactionNumber: sidetreeTxn.TransactionNumber,
+	})
+}
+
+func (o *Observer) putCheckpoint(namespace string, checkpoint Checkpoint) {
+	// if the checkpoint isn't saved then the transaction will be processed again after restart,
+	// which is safe since transactions are processed idempotently
+	if err := o.CheckpointStore.Put(namespace, checkpoint); err != nil {
+		logger.Warnf("Failed to save checkpoint for namespace [%s]: %s", namespace, err)
+	}
+
+	o.checkpoints[namespace] = checkpoint
+}
+
 // TxnProcessor processes Sidetree transactions by persisting them to an operation store
 type TxnProcessor struct {
 	*Providers
@@ -174,7 +379,7 @@ func (p *TxnProcessor) processTxnOperations(txnOps []*batch.Operation, sidetreeT
 			return errors.Wrapf(err, "error getting operation filter for namespace [%s]", mapping.namespace)
 		}
 
-		validOps, err := opFilter.Filter(suffix, mapping.operations)
+		validOps, rejectedOps, err := filter(opFilter, suffix, mapping.operations)
 		if err != nil {
 			return errors.Wrap(err, "error filtering invalid operations")
 		}
@@ -188,11 +393,69 @@ func (p *TxnProcessor) processTxnOperations(txnOps []*batch.Operation, sidetreeT
 		if err != nil {
 			return errors.Wrapf(err, "failed to store operation from anchor string[%s]", sidetreeTxn.AnchorString)
 		}
+
+		if len(validOps) > 0 {
+			p.invalidate(mapping.namespace, suffix)
+		}
+
+		p.updateStatus(sidetreeTxn, validOps, rejectedOps)
 	}
 
 	return nil
 }
 
+func filter(opFilter OperationFilter, suffix string, ops []*batch.Operation) ([]*batch.Operation, []*batch.RejectedOperation, error) {
+	if f, ok := opFilter.(RejectingOperationFilter); ok {
+		return f.FilterWithRejections(suffix, ops)
+	}
+
+	validOps, err := opFilter.Filter(suffix, ops)
+
+	return validOps, nil, err
+}
+
+// updateStatus updates the status of the operations that were batched by this node (i.e. the operations
+// for which a status is found by anchor string)
+func (p *TxnProcessor) updateStatus(sidetreeTxn txn.SidetreeTxn, validOps []*batch.Operation, rejectedOps []*batch.RejectedOperation) {
+	if p.OperationStatusStore == nil {
+		return
+	}
+
+	for _, op := range validOps {
+		p.putStatus(sidetreeTxn, op.UniqueSuffix, opstatus.Confirmed, "")
+	}
+
+	for _, rejected := range rejectedOps {
+		p.putStatus(sidetreeTxn, rejected.Operation.UniqueSuffix, opstatus.Rejected, rejected.Reason)
+	}
+}
+
+func (p *TxnProcessor) putStatus(sidetreeTxn txn.SidetreeTxn, uniqueSuffix string, status opstatus.Status, reason string) {
+	st, err := p.OperationStatusStore.GetByAnchor(sidetreeTxn.AnchorString, uniqueSuffix)
+	if err != nil {
+		if !errors.Is(err, errs.ErrNotFound) {
+			logger.Warnf("Failed to get status of operation for suffix [%s] in anchor [%s]: %s", uniqueSuffix, sidetreeTxn.AnchorString, err)
+		}
+
+		return
+	}
+
+	st.Status = status
+	st.Reason = reason
+	st.TransactionTime = sidetreeTxn.TransactionTime
+	st.TransactionNumber = sidetreeTxn.TransactionNumber
+
+	if err := p.OperationStatusStore.Put(st); err != nil {
+		logger.Warnf("Failed to update status of operation [%s] to [%s]: %s", st.ID, status, err)
+	}
+}
+
+func (p *TxnProcessor) invalidate(namespace, uniqueSuffix string) {
+	if p.CacheInvalidator != nil {
+		p.CacheInvalidator.Invalidate(namespace, uniqueSuffix)
+	}
+}
+
 func updateOperation(op *batch.Operation, index uint, sidetreeTxn txn.SidetreeTxn) *batch.Operation {
 	//  The logical blockchain time that this operation was anchored on the blockchain
 	op.TransactionTime = sidetreeTxn.TransactionTime
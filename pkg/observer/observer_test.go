@@ -16,14 +16,18 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
 	"github.com/trustbloc/sidetree-core-go/pkg/compression"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
 	"github.com/trustbloc/sidetree-core-go/pkg/txnhandler"
 )
 
 const anchorString = "1.anchorAddress"
 
+var _ OperationStore = (*mocks.MockOperationStore)(nil)
+
 func TestStartObserver(t *testing.T) {
 	t.Run("test error from ProcessSidetreeTxn", func(t *testing.T) {
 		sidetreeTxnCh := make(chan []txn.SidetreeTxn, 100)
@@ -185,6 +189,206 @@ func TestProcessTxnOperations(t *testing.T) {
 		err = p.processTxnOperations(batchOps, txn.SidetreeTxn{AnchorString: anchorString})
 		require.NoError(t, err)
 	})
+
+	t.Run("success - cache invalidated", func(t *testing.T) {
+		cache := &mockCacheInvalidator{}
+
+		providers := &Providers{
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+			CacheInvalidator: cache,
+		}
+
+		ops := []*batch.Operation{
+			{ID: "did:sidetree:abc", UniqueSuffix: "abc"},
+			{ID: "did:other:xyz", UniqueSuffix: "xyz"},
+		}
+
+		p := NewTxnProcessor(providers)
+		err := p.processTxnOperations(ops, txn.SidetreeTxn{AnchorString: anchorString})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"did:sidetree:abc", "did:other:xyz"}, cache.invalidated)
+	})
+
+	t.Run("success - operation status updated", func(t *testing.T) {
+		statusStore := mocks.NewMockOperationStatusStore()
+
+		for _, suffix := range []string{"abc", "xyz"} {
+			require.NoError(t, statusStore.Put(&opstatus.OperationStatus{
+				ID:           "op-" + suffix,
+				UniqueSuffix: suffix,
+				Status:       opstatus.Anchored,
+				AnchorString: anchorString,
+			}))
+		}
+
+		providers := &Providers{
+			OpStoreProvider:      &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+			OpFilterProvider:     &mockRejectingFilterProvider{rejectedSuffix: "xyz"},
+			OperationStatusStore: statusStore,
+		}
+
+		ops := []*batch.Operation{
+			{ID: "did:sidetree:abc", UniqueSuffix: "abc"},
+			{ID: "did:sidetree:xyz", UniqueSuffix: "xyz"},
+			{ID: "did:sidetree:123", UniqueSuffix: "123"},
+		}
+
+		p := NewTxnProcessor(providers)
+		err := p.processTxnOperations(ops, txn.SidetreeTxn{AnchorString: anchorString, TransactionTime: 10, TransactionNumber: 2})
+		require.NoError(t, err)
+
+		status, err := statusStore.Get("op-abc")
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Confirmed, status.Status)
+		require.Equal(t, uint64(10), status.TransactionTime)
+		require.Equal(t, uint64(2), status.TransactionNumber)
+		require.Empty(t, status.Reason)
+
+		status, err = statusStore.Get("op-xyz")
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Rejected, status.Status)
+		require.Equal(t, "invalid operation", status.Reason)
+	})
+
+	t.Run("operation status store error", func(t *testing.T) {
+		statusStore := mocks.NewMockOperationStatusStore()
+		statusStore.Err = errors.New("injected status store error")
+
+		providers := &Providers{
+			OpStoreProvider:      &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+			OpFilterProvider:     &NoopOperationFilterProvider{},
+			OperationStatusStore: statusStore,
+		}
+
+		// the operations are processed regardless
+		p := NewTxnProcessor(providers)
+		err := p.processTxnOperations([]*batch.Operation{{ID: "did:sidetree:abc", UniqueSuffix: "abc"}}, txn.SidetreeTxn{AnchorString: anchorString})
+		require.NoError(t, err)
+	})
+}
+
+func TestObserver_Checkpoints(t *testing.T) {
+	t.Run("no checkpoint store", func(t *testing.T) {
+		sidetreeTxnCh := make(chan []txn.SidetreeTxn, 100)
+		ledger := &mockReplayableLedger{mockLedger: mockLedger{registerForSidetreeTxnValue: sidetreeTxnCh}}
+		txnOpsProvider := &mockTxnOpsProvider{}
+
+		o := New(&Providers{
+			Ledger:           ledger,
+			TxnOpsProvider:   txnOpsProvider,
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+		})
+
+		require.True(t, o.register() == sidetreeTxnCh)
+		require.Nil(t, ledger.checkpoints)
+
+		txns := []txn.SidetreeTxn{{Namespace: ns, TransactionTime: 20, TransactionNumber: 2}}
+
+		o.process(txns)
+		o.process(txns)
+		require.Len(t, txnOpsProvider.txns, 2)
+	})
+
+	t.Run("resume after checkpoint", func(t *testing.T) {
+		checkpointStore := &mockCheckpointStore{checkpoints: map[string]Checkpoint{
+			ns: {TransactionTime: 20, TransactionNumber: 2},
+		}}
+		txnOpsProvider := &mockTxnOpsProvider{}
+
+		o := New(&Providers{
+			Ledger:           mockLedger{registerForSidetreeTxnValue: make(chan []txn.SidetreeTxn)},
+			TxnOpsProvider:   txnOpsProvider,
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+			CheckpointStore:  checkpointStore,
+		})
+
+		require.NotNil(t, o.register())
+
+		o.process([]txn.SidetreeTxn{
+			{Namespace: ns, TransactionTime: 19, TransactionNumber: 5},
+			{Namespace: ns, TransactionTime: 20, TransactionNumber: 1},
+			{Namespace: ns, TransactionTime: 20, TransactionNumber: 2},
+			{Namespace: ns, TransactionTime: 20, TransactionNumber: 3},
+			{Namespace: "did:other", TransactionTime: 1, TransactionNumber: 0},
+		})
+
+		require.Len(t, txnOpsProvider.txns, 2)
+		require.Equal(t, uint64(3), txnOpsProvider.txns[0].TransactionNumber)
+		require.Equal(t, "did:other", txnOpsProvider.txns[1].Namespace)
+
+		require.Equal(t, map[string]Checkpoint{
+			ns:          {TransactionTime: 20, TransactionNumber: 3},
+			"did:other": {TransactionTime: 1, TransactionNumber: 0},
+		}, checkpointStore.checkpoints)
+
+		// transactions that were already processed are skipped
+		o.process([]txn.SidetreeTxn{{Namespace: ns, TransactionTime: 20, TransactionNumber: 3}})
+		require.Len(t, txnOpsProvider.txns, 2)
+	})
+
+	t.Run("replayable ledger", func(t *testing.T) {
+		sidetreeTxnCh := make(chan []txn.SidetreeTxn)
+		ledger := &mockReplayableLedger{replayCh: sidetreeTxnCh}
+
+		checkpoint := Checkpoint{TransactionTime: 20, TransactionNumber: 2}
+
+		o := New(&Providers{
+			Ledger:          ledger,
+			CheckpointStore: &mockCheckpointStore{checkpoints: map[string]Checkpoint{ns: checkpoint}},
+		})
+
+		require.True(t, o.register() == sidetreeTxnCh)
+		require.Equal(t, map[string]Checkpoint{ns: checkpoint}, ledger.checkpoints)
+	})
+
+	t.Run("error loading checkpoints", func(t *testing.T) {
+		ledger := &mockReplayableLedger{replayCh: make(chan []txn.SidetreeTxn)}
+
+		o := New(&Providers{
+			Ledger:          ledger,
+			CheckpointStore: &mockCheckpointStore{err: errors.New("injected checkpoint store error")},
+		})
+
+		// all transactions are replayed
+		require.NotNil(t, o.register())
+		require.NotNil(t, ledger.checkpoints)
+		require.Empty(t, ledger.checkpoints)
+	})
+
+	t.Run("error saving checkpoint", func(t *testing.T) {
+		checkpointStore := &mockCheckpointStore{putErr: errors.New("injected checkpoint store error")}
+		txnOpsProvider := &mockTxnOpsProvider{}
+
+		o := New(&Providers{
+			TxnOpsProvider:   txnOpsProvider,
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+			CheckpointStore:  checkpointStore,
+		})
+
+		txns := []txn.SidetreeTxn{{Namespace: ns, TransactionTime: 20, TransactionNumber: 2}}
+
+		o.process(txns)
+		o.process(txns)
+		require.Len(t, txnOpsProvider.txns, 1)
+		require.Empty(t, checkpointStore.checkpoints)
+	})
+
+	t.Run("checkpoint not saved for failed transaction", func(t *testing.T) {
+		checkpointStore := &mockCheckpointStore{}
+
+		o := New(&Providers{
+			TxnOpsProvider:   &mockTxnOpsProvider{err: errors.New("txn operations provider error")},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+			CheckpointStore:  checkpointStore,
+		})
+
+		o.process([]txn.SidetreeTxn{{Namespace: ns, TransactionTime: 20, TransactionNumber: 2}})
+		require.Empty(t, checkpointStore.checkpoints)
+	})
 }
 
 func TestUpdateOperation(t *testing.T) {
@@ -252,11 +456,55 @@ func (m *mockOperationStoreProvider) ForNamespace(string) (OperationStore, error
 	return m.opStore, nil
 }
 
+type mockReplayableLedger struct {
+	mockLedger
+
+	replayCh    chan []txn.SidetreeTxn
+	checkpoints map[string]Checkpoint
+}
+
+func (m *mockReplayableLedger) RegisterForSidetreeTxnAfter(checkpoints map[string]Checkpoint) <-chan []txn.SidetreeTxn {
+	m.checkpoints = checkpoints
+
+	return m.replayCh
+}
+
+type mockCheckpointStore struct {
+	checkpoints map[string]Checkpoint
+	err         error
+	putErr      error
+}
+
+func (m *mockCheckpointStore) Checkpoints() (map[string]Checkpoint, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return m.checkpoints, nil
+}
+
+func (m *mockCheckpointStore) Put(namespace string, checkpoint Checkpoint) error {
+	if m.putErr != nil {
+		return m.putErr
+	}
+
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]Checkpoint)
+	}
+
+	m.checkpoints[namespace] = checkpoint
+
+	return nil
+}
+
 type mockTxnOpsProvider struct {
-	err error
+	err  error
+	txns []*txn.SidetreeTxn
 }
 
 func (m *mockTxnOpsProvider) GetTxnOperations(txn *txn.SidetreeTxn) ([]*batch.Operation, error) {
+	m.txns = append(m.txns, txn)
+
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -267,3 +515,42 @@ func (m *mockTxnOpsProvider) GetTxnOperations(txn *txn.SidetreeTxn) ([]*batch.Op
 
 	return []*batch.Operation{op}, nil
 }
+
+type mockRejectingFilterProvider struct {
+	rejectedSuffix string
+}
+
+func (m *mockRejectingFilterProvider) Get(string) (OperationFilter, error) {
+	return m, nil
+}
+
+func (m *mockRejectingFilterProvider) Filter(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, error) {
+	validOps, _, err := m.FilterWithRejections(uniqueSuffix, ops)
+
+	return validOps, err
+}
+
+func (m *mockRejectingFilterProvider) FilterWithRejections(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, []*batch.RejectedOperation, error) {
+	if uniqueSuffix != m.rejectedSuffix {
+		return ops, nil, nil
+	}
+
+	var rejected []*batch.RejectedOperation
+	for _, op := range ops {
+		rejected = append(rejected, &batch.RejectedOperation{Operation: op, Reason: "invalid operation"})
+	}
+
+	return nil, rejected, nil
+}
+
+type mockCacheInvalidator struct {
+	mutex       sync.Mutex
+	invalidated []string
+}
+
+func (m *mockCacheInvalidator) Invalidate(namespace, uniqueSuffix string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.invalidated = append(m.invalidated, namespace+docutil.NamespaceDelimiter+uniqueSuffix)
+}
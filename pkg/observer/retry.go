@@ -0,0 +1,388 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package observer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+)
+
+const (
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultRetryInterval  = time.Second
+)
+
+// DeadLetter contains a Sidetree transaction that could not be processed after the maximum number of attempts
+type DeadLetter struct {
+	Txn      txn.SidetreeTxn `json:"txn"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+}
+
+// DeadLetterStore stores the Sidetree transactions that could not be processed. Dead letters are keyed by anchor string.
+type DeadLetterStore interface {
+	// Put stores (or replaces) the dead letter
+	Put(deadLetter *DeadLetter) error
+
+	// Get returns the dead letter for the given anchor string
+	Get(anchorString string) (*DeadLetter, error)
+
+	// List returns all of the dead letters
+	List() ([]*DeadLetter, error)
+
+	// Delete deletes the dead letter for the given anchor string
+	Delete(anchorString string) error
+}
+
+// Option defines observer options such as retry backoff
+type Option func(opts *Options)
+
+// Options allows the user to specify more advanced options
+type Options struct {
+	// MaxAttempts is the maximum number of times a transaction is processed before it's moved to the dead-letter store
+	MaxAttempts int
+	// InitialBackoff is the time to wait before a failed transaction is processed again. The backoff is doubled
+	// on each subsequent failure up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryInterval is the interval at which the retry queue is checked for transactions that are due
+	RetryInterval time.Duration
+}
+
+// WithMaxAttempts sets the maximum number of times a transaction is processed before it's moved to the dead-letter store
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *Options) {
+		o.MaxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff sets the initial and maximum backoff between attempts to process a failed transaction
+func WithBackoff(initial, max time.Duration) Option {
+	return func(o *Options) {
+		o.InitialBackoff = initial
+		o.MaxBackoff = max
+	}
+}
+
+// WithRetryInterval sets the interval at which the retry queue is checked for transactions that are due
+func WithRetryInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.RetryInterval = interval
+	}
+}
+
+func prepareOptsFromOptions(options ...Option) Options {
+	rOpts := Options{
+		MaxAttempts:    defaultMaxAttempts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+		RetryInterval:  defaultRetryInterval,
+	}
+
+	for _, option := range options {
+		option(&rOpts)
+	}
+
+	return rOpts
+}
+
+type retryEntry struct {
+	txn         txn.SidetreeTxn
+	attempts    int
+	nextAttempt time.Time
+	// redriven is true if the transaction was redriven from the dead-letter store
+	redriven bool
+	// discarded is true if the dead-lettered transaction was discarded (i.e. it's skipped rather than processed)
+	discarded bool
+}
+
+// retryQueue holds the failed transactions that are waiting to be processed again
+type retryQueue struct {
+	mutex   sync.Mutex
+	entries []*retryEntry
+}
+
+func (q *retryQueue) add(entry *retryEntry) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.entries = append(q.entries, entry)
+}
+
+// removeDue removes and returns the entries that are due at the given time
+func (q *retryQueue) removeDue(now time.Time) []*retryEntry {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var due, remaining []*retryEntry
+
+	for _, entry := range q.entries {
+		if entry.nextAttempt.After(now) {
+			remaining = append(remaining, entry)
+		} else {
+			due = append(due, entry)
+		}
+	}
+
+	q.entries = remaining
+
+	return due
+}
+
+// hasPending returns true if there are transactions for the given namespace that were received from the
+// ledger (i.e. not redriven from the dead-letter store) and are waiting to be processed again
+func (q *retryQueue) hasPending(namespace string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, entry := range q.entries {
+		if !entry.redriven && entry.txn.Namespace == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeFrom removes the entries for the given namespace that were anchored at or after the given transaction time
+func (q *retryQueue) removeFrom(namespace string, transactionTime uint64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var remaining []*retryEntry
+
+	for _, entry := range q.entries {
+		if entry.txn.Namespace == namespace && entry.txn.TransactionTime >= transactionTime {
+			logger.Infof("Removing anchor[%s] from retry queue since it was rolled back", entry.txn.AnchorString)
+			continue
+		}
+
+		remaining = append(remaining, entry)
+	}
+
+	q.entries = remaining
+}
+
+func (q *retryQueue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.entries)
+}
+
+// DeadLetters returns the transactions that could not be processed after the maximum number of attempts
+func (o *Observer) DeadLetters() ([]*DeadLetter, error) {
+	if o.DeadLetterStore == nil {
+		return nil, errors.New("dead-letter store is not configured")
+	}
+
+	return o.DeadLetterStore.List()
+}
+
+// Redrive schedules the dead-lettered transaction with the given anchor string to be processed again. The
+// transaction is removed from the dead-letter store once it's processed successfully, after which the transactions
+// of the namespace that were held back are processed. If it fails again then it's retried up to the maximum number
+// of attempts and then stored in the dead-letter store again.
+func (o *Observer) Redrive(anchorString string) error {
+	if o.DeadLetterStore == nil {
+		return errors.New("dead-letter store is not configured")
+	}
+
+	deadLetter, err := o.DeadLetterStore.Get(anchorString)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to get dead letter for anchor[%s]", anchorString)
+	}
+
+	logger.Infof("Redriving anchor[%s]", anchorString)
+
+	o.retries.add(&retryEntry{
+		txn:         deadLetter.Txn,
+		nextAttempt: time.Now(),
+		redriven:    true,
+	})
+
+	return nil
+}
+
+// Discard discards the dead-lettered transaction with the given anchor string, i.e. the operations of the transaction
+// are skipped and the transactions of the namespace that were held back are processed.
+func (o *Observer) Discard(anchorString string) error {
+	if o.DeadLetterStore == nil {
+		return errors.New("dead-letter store is not configured")
+	}
+
+	deadLetter, err := o.DeadLetterStore.Get(anchorString)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to get dead letter for anchor[%s]", anchorString)
+	}
+
+	logger.Warnf("Discarding anchor[%s]", anchorString)
+
+	// the transaction is discarded by the observer routine (along with the retries)
+	o.retries.add(&retryEntry{
+		txn:         deadLetter.Txn,
+		nextAttempt: time.Now(),
+		redriven:    true,
+		discarded:   true,
+	})
+
+	return nil
+}
+
+// loadDeadLetters loads the dead-lettered transactions so that the transactions of their namespaces are held back
+// until the dead letters are redriven or discarded
+func (o *Observer) loadDeadLetters() {
+	if o.DeadLetterStore == nil {
+		return
+	}
+
+	deadLetters, err := o.DeadLetterStore.List()
+	if err != nil {
+		logger.Errorf("Failed to load dead letters: %s", err)
+
+		return
+	}
+
+	for _, deadLetter := range deadLetters {
+		logger.Warnf("Holding back the transactions of namespace [%s] until anchor[%s] is redriven or discarded",
+			deadLetter.Txn.Namespace, deadLetter.Txn.AnchorString)
+
+		o.addDeadLettered(deadLetter.Txn)
+	}
+}
+
+// processRetries processes the failed transactions that are due at the given time
+func (o *Observer) processRetries(now time.Time) {
+	for _, entry := range o.retries.removeDue(now) {
+		if entry.discarded {
+			o.resolveDeadLetter(entry.txn)
+
+			continue
+		}
+
+		entry.attempts++
+
+		logger.Debugf("Retrying anchor[%s] - attempt %d", entry.txn.AnchorString, entry.attempts)
+
+		err := o.processor.Process(entry.txn)
+		if err != nil {
+			logger.Warnf("Failed to process anchor[%s] on attempt %d: %s", entry.txn.AnchorString, entry.attempts, err)
+
+			o.handleFailure(entry, err)
+
+			continue
+		}
+
+		logger.Infof("Successfully processed anchor[%s] on attempt %d", entry.txn.AnchorString, entry.attempts)
+
+		if !entry.redriven {
+			o.continueAfter(entry.txn)
+
+			continue
+		}
+
+		o.resolveDeadLetter(entry.txn)
+	}
+}
+
+// resolveDeadLetter deletes the dead letter of the given transaction (which was either redriven successfully or
+// discarded) and processes the transactions of the namespace that were held back
+func (o *Observer) resolveDeadLetter(sidetreeTxn txn.SidetreeTxn) {
+	if err := o.DeadLetterStore.Delete(sidetreeTxn.AnchorString); err != nil {
+		logger.Warnf("Failed to delete dead letter for anchor[%s]: %s", sidetreeTxn.AnchorString, err)
+	}
+
+	o.removeDeadLettered(sidetreeTxn.Namespace, sidetreeTxn.AnchorString)
+	o.continueAfter(sidetreeTxn)
+}
+
+// handleFailure schedules the failed transaction to be processed again after a backoff (the subsequent transactions
+// of the namespace are held back in the meantime). If the maximum number of attempts was reached then the transaction
+// is moved to the dead-letter store and the subsequent transactions remain held back until the dead letter is
+// redriven successfully or discarded (since the operations of the subsequent transactions would be validated against
+// a document state that is missing the operations of the dead-lettered transaction). If the transaction can't be
+// moved to the dead-letter store then the subsequent transactions are processed.
+func (o *Observer) handleFailure(entry *retryEntry, err error) {
+	if entry.attempts < o.opts.MaxAttempts {
+		entry.nextAttempt = time.Now().Add(o.backoff(entry.attempts))
+		o.retries.add(entry)
+
+		return
+	}
+
+	if o.DeadLetterStore == nil {
+		logger.Errorf("Giving up on anchor[%s] after %d attempts: %s", entry.txn.AnchorString, entry.attempts, err)
+
+		o.continueAfter(entry.txn)
+
+		return
+	}
+
+	logger.Errorf("Moving anchor[%s] to dead-letter store after %d attempts: %s",
+		entry.txn.AnchorString, entry.attempts, err)
+
+	deadLetter := &DeadLetter{
+		Txn:      entry.txn,
+		Attempts: entry.attempts,
+		Error:    err.Error(),
+	}
+
+	if e := o.DeadLetterStore.Put(deadLetter); e != nil {
+		logger.Errorf("Failed to store dead letter for anchor[%s]: %s", entry.txn.AnchorString, e)
+
+		if !entry.redriven {
+			o.continueAfter(entry.txn)
+		}
+
+		return
+	}
+
+	o.addDeadLettered(entry.txn)
+}
+
+func (o *Observer) addDeadLettered(sidetreeTxn txn.SidetreeTxn) {
+	if o.deadLettered[sidetreeTxn.Namespace] == nil {
+		o.deadLettered[sidetreeTxn.Namespace] = make(map[string]txn.SidetreeTxn)
+	}
+
+	o.deadLettered[sidetreeTxn.Namespace][sidetreeTxn.AnchorString] = sidetreeTxn
+}
+
+func (o *Observer) removeDeadLettered(namespace, anchorString string) {
+	delete(o.deadLettered[namespace], anchorString)
+
+	if len(o.deadLettered[namespace]) == 0 {
+		delete(o.deadLettered, namespace)
+	}
+}
+
+// isHeldBack returns true if the transactions of the given namespace are held back, i.e. a transaction of the
+// namespace failed and it's waiting to be processed again or it was moved to the dead-letter store
+func (o *Observer) isHeldBack(namespace string) bool {
+	return o.retries.hasPending(namespace) || len(o.deadLettered[namespace]) > 0
+}
+
+// backoff returns the time to wait after the given number of failed attempts
+func (o *Observer) backoff(attempts int) time.Duration {
+	backoff := o.opts.InitialBackoff
+
+	for i := 1; i < attempts && backoff < o.opts.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > o.opts.MaxBackoff {
+		return o.opts.MaxBackoff
+	}
+
+	return backoff
+}
@@ -0,0 +1,518 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package observer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+)
+
+const ns = "did:sidetree"
+
+func TestOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		o := New(&Providers{})
+		require.Equal(t, defaultMaxAttempts, o.opts.MaxAttempts)
+		require.Equal(t, defaultInitialBackoff, o.opts.InitialBackoff)
+		require.Equal(t, defaultMaxBackoff, o.opts.MaxBackoff)
+		require.Equal(t, defaultRetryInterval, o.opts.RetryInterval)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		o := New(&Providers{},
+			WithMaxAttempts(3),
+			WithBackoff(time.Millisecond, time.Second),
+			WithRetryInterval(10*time.Millisecond),
+		)
+		require.Equal(t, 3, o.opts.MaxAttempts)
+		require.Equal(t, time.Millisecond, o.opts.InitialBackoff)
+		require.Equal(t, time.Second, o.opts.MaxBackoff)
+		require.Equal(t, 10*time.Millisecond, o.opts.RetryInterval)
+	})
+}
+
+func TestObserver_Backoff(t *testing.T) {
+	o := New(&Providers{}, WithBackoff(time.Second, 10*time.Second))
+
+	require.Equal(t, time.Second, o.backoff(1))
+	require.Equal(t, 2*time.Second, o.backoff(2))
+	require.Equal(t, 4*time.Second, o.backoff(3))
+	require.Equal(t, 8*time.Second, o.backoff(4))
+	require.Equal(t, 10*time.Second, o.backoff(5))
+	require.Equal(t, 10*time.Second, o.backoff(100))
+}
+
+func TestObserver_Retry(t *testing.T) {
+	txn1 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 20, TransactionNumber: 1, AnchorString: "1.anchor1"}
+	txn2 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 20, TransactionNumber: 2, AnchorString: "1.anchor2"}
+
+	later := func() time.Time {
+		return time.Now().Add(time.Hour)
+	}
+
+	t.Run("success after retry", func(t *testing.T) {
+		txnOpsProvider := &mockFailingTxnOpsProvider{failures: map[string]int{txn1.AnchorString: 2}}
+		checkpointStore := &mockCheckpointStore{}
+
+		o := newObserver(txnOpsProvider, checkpointStore, nil, WithMaxAttempts(3))
+
+		o.process([]txn.SidetreeTxn{txn1, txn2})
+		require.Equal(t, 1, o.retries.len())
+
+		// subsequent transaction and checkpoint are held back until the failed transaction is processed
+		require.Zero(t, txnOpsProvider.attempts(txn2.AnchorString))
+		require.Empty(t, checkpointStore.checkpoints)
+
+		// retry is not due yet
+		o.processRetries(time.Now())
+		require.Equal(t, 1, o.retries.len())
+		require.Equal(t, 1, txnOpsProvider.attempts(txn1.AnchorString))
+
+		o.processRetries(later())
+		require.Equal(t, 1, o.retries.len())
+		require.Equal(t, 2, txnOpsProvider.attempts(txn1.AnchorString))
+		require.Empty(t, checkpointStore.checkpoints)
+
+		o.processRetries(later())
+		require.Zero(t, o.retries.len())
+		require.Equal(t, 3, txnOpsProvider.attempts(txn1.AnchorString))
+		require.Equal(t, 1, txnOpsProvider.attempts(txn2.AnchorString))
+		require.Empty(t, o.heldBack)
+		require.Equal(t, map[string]Checkpoint{ns: {TransactionTime: 20, TransactionNumber: 2}}, checkpointStore.checkpoints)
+	})
+
+	t.Run("moved to dead-letter store after max attempts", func(t *testing.T) {
+		txnOpsProvider := &mockFailingTxnOpsProvider{failures: map[string]int{txn1.AnchorString: 10}}
+		checkpointStore := &mockCheckpointStore{}
+		deadLetterStore := &mockDeadLetterStore{}
+
+		o := newObserver(txnOpsProvider, checkpointStore, deadLetterStore, WithMaxAttempts(2))
+
+		o.process([]txn.SidetreeTxn{txn1, txn2})
+		require.Equal(t, 1, o.retries.len())
+		require.Zero(t, txnOpsProvider.attempts(txn2.AnchorString))
+		require.Empty(t, checkpointStore.checkpoints)
+
+		o.processRetries(later())
+		require.Zero(t, o.retries.len())
+		require.Equal(t, 2, txnOpsProvider.attempts(txn1.AnchorString))
+
+		// subsequent transaction and checkpoint are held back until the dead letter is redriven or discarded
+		require.Zero(t, txnOpsProvider.attempts(txn2.AnchorString))
+		require.Equal(t, []txn.SidetreeTxn{txn2}, o.heldBack[ns])
+		require.Empty(t, checkpointStore.checkpoints)
+
+		deadLetters, err := o.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, deadLetters, 1)
+		require.Equal(t, txn1, deadLetters[0].Txn)
+		require.Equal(t, 2, deadLetters[0].Attempts)
+		require.Contains(t, deadLetters[0].Error, "injected txn operations provider error")
+	})
+
+	t.Run("subsequent transaction updates the same document", func(t *testing.T) {
+		createOp := &batch.Operation{ID: "did:sidetree:abc", UniqueSuffix: "abc", Type: batch.OperationTypeCreate}
+		updateOp := &batch.Operation{ID: "did:sidetree:abc", UniqueSuffix: "abc", Type: batch.OperationTypeUpdate}
+
+		txnOpsProvider := &mockFailingTxnOpsProvider{
+			failures: map[string]int{txn1.AnchorString: 1},
+			ops: map[string][]*batch.Operation{
+				txn1.AnchorString: {createOp},
+				txn2.AnchorString: {updateOp},
+			},
+		}
+
+		opStore := mocks.NewMockOperationStore(nil)
+
+		o := New(&Providers{
+			Ledger:           mockLedger{},
+			TxnOpsProvider:   txnOpsProvider,
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: opStore},
+			OpFilterProvider: &mockOperationFilterProvider{opFilter: &mockStoreFilter{opStore: opStore}},
+		})
+
+		o.process([]txn.SidetreeTxn{txn1, txn2})
+		require.Equal(t, 1, o.retries.len())
+
+		// the update isn't filtered (and rejected) before the create is processed
+		require.Zero(t, txnOpsProvider.attempts(txn2.AnchorString))
+		_, err := opStore.Get("abc")
+		require.Error(t, err)
+
+		o.processRetries(later())
+		require.Zero(t, o.retries.len())
+
+		ops, err := opStore.Get("abc")
+		require.NoError(t, err)
+		require.Len(t, ops, 2)
+		require.Equal(t, batch.OperationTypeCreate, ops[0].Type)
+		require.Equal(t, batch.OperationTypeUpdate, ops[1].Type)
+	})
+
+	t.Run("no dead-letter store", func(t *testing.T) {
+		txnOpsProvider := &mockFailingTxnOpsProvider{failures: map[string]int{txn1.AnchorString: 10}}
+
+		o := newObserver(txnOpsProvider, nil, nil, WithMaxAttempts(1))
+
+		o.process([]txn.SidetreeTxn{txn1})
+		require.Zero(t, o.retries.len())
+		require.Equal(t, 1, txnOpsProvider.attempts(txn1.AnchorString))
+
+		deadLetters, err := o.DeadLetters()
+		require.EqualError(t, err, "dead-letter store is not configured")
+		require.Nil(t, deadLetters)
+
+		err = o.Redrive(txn1.AnchorString)
+		require.EqualError(t, err, "dead-letter store is not configured")
+	})
+
+	t.Run("dead-letter store error", func(t *testing.T) {
+		txnOpsProvider := &mockFailingTxnOpsProvider{failures: map[string]int{txn1.AnchorString: 10}}
+		deadLetterStore := &mockDeadLetterStore{putErr: errors.New("injected dead-letter store error")}
+
+		o := newObserver(txnOpsProvider, nil, deadLetterStore, WithMaxAttempts(1))
+
+		o.process([]txn.SidetreeTxn{txn1})
+		require.Zero(t, o.retries.len())
+		require.Empty(t, deadLetterStore.deadLetters)
+	})
+}
+
+func TestObserver_Redrive(t *testing.T) {
+	txn1 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 20, TransactionNumber: 1, AnchorString: "1.anchor1"}
+
+	deadLetter := &DeadLetter{Txn: txn1, Attempts: 5, Error: "CAS error"}
+
+	t.Run("success", func(t *testing.T) {
+		txnOpsProvider := &mockFailingTxnOpsProvider{}
+		checkpointStore := &mockCheckpointStore{checkpoints: map[string]Checkpoint{ns: {TransactionTime: 10}}}
+		deadLetterStore := &mockDeadLetterStore{deadLetters: map[string]*DeadLetter{txn1.AnchorString: deadLetter}}
+
+		o := newObserver(txnOpsProvider, checkpointStore, deadLetterStore)
+		require.NotNil(t, o.register())
+		o.loadDeadLetters()
+
+		// the dead-lettered transaction and the subsequent transactions are held back after restart
+		txn2 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 31, AnchorString: "1.anchor2"}
+		o.process([]txn.SidetreeTxn{txn1, txn2})
+		require.Zero(t, txnOpsProvider.attempts(txn1.AnchorString))
+		require.Zero(t, txnOpsProvider.attempts(txn2.AnchorString))
+		require.Equal(t, map[string]Checkpoint{ns: {TransactionTime: 10}}, checkpointStore.checkpoints)
+
+		require.NoError(t, o.Redrive(txn1.AnchorString))
+		require.Equal(t, 1, o.retries.len())
+
+		o.processRetries(time.Now())
+		require.Zero(t, o.retries.len())
+		require.Equal(t, 1, txnOpsProvider.attempts(txn1.AnchorString))
+		require.Equal(t, 1, txnOpsProvider.attempts(txn2.AnchorString))
+		require.Empty(t, deadLetterStore.deadLetters)
+		require.Empty(t, o.heldBack)
+		require.Empty(t, o.deadLettered)
+		require.Equal(t, map[string]Checkpoint{ns: {TransactionTime: 31}}, checkpointStore.checkpoints)
+	})
+
+	t.Run("redrive after later transactions", func(t *testing.T) {
+		txn2 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 20, TransactionNumber: 2, AnchorString: "1.anchor2"}
+
+		createOp := &batch.Operation{ID: "did:sidetree:abc", UniqueSuffix: "abc", Type: batch.OperationTypeCreate}
+		updateOp := &batch.Operation{ID: "did:sidetree:abc", UniqueSuffix: "abc", Type: batch.OperationTypeUpdate}
+
+		txnOpsProvider := &mockFailingTxnOpsProvider{
+			failures: map[string]int{txn1.AnchorString: 1},
+			ops: map[string][]*batch.Operation{
+				txn1.AnchorString: {createOp},
+				txn2.AnchorString: {updateOp},
+			},
+		}
+
+		opStore := mocks.NewMockOperationStore(nil)
+		checkpointStore := &mockCheckpointStore{}
+		deadLetterStore := &mockDeadLetterStore{}
+
+		o := New(&Providers{
+			Ledger:           mockLedger{},
+			TxnOpsProvider:   txnOpsProvider,
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: opStore},
+			OpFilterProvider: &mockOperationFilterProvider{opFilter: &mockStoreFilter{opStore: opStore}},
+			CheckpointStore:  checkpointStore,
+			DeadLetterStore:  deadLetterStore,
+		}, WithMaxAttempts(1))
+
+		o.process([]txn.SidetreeTxn{txn1, txn2})
+		require.Len(t, deadLetterStore.deadLetters, 1)
+
+		// the later transaction isn't applied (i.e. the update isn't rejected) while the create is dead-lettered
+		o.process([]txn.SidetreeTxn{{Namespace: ns, TransactionTime: 21, AnchorString: "1.anchor3"}})
+		require.Zero(t, txnOpsProvider.attempts(txn2.AnchorString))
+		_, err := opStore.Get("abc")
+		require.Error(t, err)
+		require.Empty(t, checkpointStore.checkpoints)
+
+		require.NoError(t, o.Redrive(txn1.AnchorString))
+
+		o.processRetries(time.Now())
+		require.Empty(t, deadLetterStore.deadLetters)
+
+		// the later transactions are applied after the redriven transaction
+		ops, err := opStore.Get("abc")
+		require.NoError(t, err)
+		require.Len(t, ops, 2)
+		require.Equal(t, batch.OperationTypeCreate, ops[0].Type)
+		require.Equal(t, batch.OperationTypeUpdate, ops[1].Type)
+		require.Equal(t, map[string]Checkpoint{ns: {TransactionTime: 21}}, checkpointStore.checkpoints)
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		txn2 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 20, TransactionNumber: 2, AnchorString: "1.anchor2"}
+
+		txnOpsProvider := &mockFailingTxnOpsProvider{failures: map[string]int{txn1.AnchorString: 10}}
+		checkpointStore := &mockCheckpointStore{}
+		deadLetterStore := &mockDeadLetterStore{}
+
+		o := newObserver(txnOpsProvider, checkpointStore, deadLetterStore, WithMaxAttempts(1))
+
+		o.process([]txn.SidetreeTxn{txn1, txn2})
+		require.Len(t, deadLetterStore.deadLetters, 1)
+		require.Zero(t, txnOpsProvider.attempts(txn2.AnchorString))
+
+		require.NoError(t, o.Discard(txn1.AnchorString))
+
+		o.processRetries(time.Now())
+
+		// the discarded transaction isn't processed again
+		require.Equal(t, 1, txnOpsProvider.attempts(txn1.AnchorString))
+		require.Equal(t, 1, txnOpsProvider.attempts(txn2.AnchorString))
+		require.Empty(t, deadLetterStore.deadLetters)
+		require.Empty(t, o.heldBack)
+		require.Equal(t, map[string]Checkpoint{ns: {TransactionTime: 20, TransactionNumber: 2}}, checkpointStore.checkpoints)
+
+		err := o.Discard(txn1.AnchorString)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to get dead letter for anchor[1.anchor1]")
+
+		o = newObserver(txnOpsProvider, nil, nil)
+
+		err = o.Discard(txn1.AnchorString)
+		require.EqualError(t, err, "dead-letter store is not configured")
+	})
+
+	t.Run("load error", func(t *testing.T) {
+		deadLetterStore := &mockDeadLetterStore{listErr: errors.New("injected dead-letter store error")}
+
+		o := newObserver(&mockFailingTxnOpsProvider{}, nil, deadLetterStore)
+		o.loadDeadLetters()
+		require.Empty(t, o.deadLettered)
+	})
+
+	t.Run("fails again", func(t *testing.T) {
+		txnOpsProvider := &mockFailingTxnOpsProvider{failures: map[string]int{txn1.AnchorString: 10}}
+		deadLetterStore := &mockDeadLetterStore{deadLetters: map[string]*DeadLetter{txn1.AnchorString: deadLetter}}
+
+		o := newObserver(txnOpsProvider, nil, deadLetterStore, WithMaxAttempts(2), WithBackoff(0, 0))
+
+		require.NoError(t, o.Redrive(txn1.AnchorString))
+
+		o.processRetries(time.Now())
+		require.Equal(t, 1, o.retries.len())
+
+		o.processRetries(time.Now())
+		require.Zero(t, o.retries.len())
+		require.Equal(t, 2, txnOpsProvider.attempts(txn1.AnchorString))
+
+		dl, err := deadLetterStore.Get(txn1.AnchorString)
+		require.NoError(t, err)
+		require.Equal(t, 2, dl.Attempts)
+		require.Contains(t, dl.Error, "injected txn operations provider error")
+	})
+
+	t.Run("delete error", func(t *testing.T) {
+		deadLetterStore := &mockDeadLetterStore{
+			deadLetters: map[string]*DeadLetter{txn1.AnchorString: deadLetter},
+			deleteErr:   errors.New("injected dead-letter store error"),
+		}
+
+		o := newObserver(&mockFailingTxnOpsProvider{}, nil, deadLetterStore)
+
+		require.NoError(t, o.Redrive(txn1.AnchorString))
+
+		o.processRetries(time.Now())
+		require.Zero(t, o.retries.len())
+		require.Len(t, deadLetterStore.deadLetters, 1)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		o := newObserver(&mockFailingTxnOpsProvider{}, nil, &mockDeadLetterStore{})
+
+		err := o.Redrive(txn1.AnchorString)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to get dead letter for anchor[1.anchor1]")
+		require.Zero(t, o.retries.len())
+	})
+}
+
+func TestObserver_RetryOnStart(t *testing.T) {
+	sidetreeTxnCh := make(chan []txn.SidetreeTxn, 100)
+
+	sidetreeTxn := txn.SidetreeTxn{Namespace: ns, TransactionTime: 20, TransactionNumber: 1, AnchorString: "1.anchor1"}
+
+	txnOpsProvider := &mockFailingTxnOpsProvider{failures: map[string]int{sidetreeTxn.AnchorString: 2}}
+
+	o := New(&Providers{
+		Ledger:           mockLedger{registerForSidetreeTxnValue: sidetreeTxnCh},
+		TxnOpsProvider:   txnOpsProvider,
+		OpStoreProvider:  &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+		OpFilterProvider: &NoopOperationFilterProvider{},
+	}, WithBackoff(time.Millisecond, time.Millisecond), WithRetryInterval(10*time.Millisecond))
+
+	o.Start()
+	defer o.Stop()
+
+	sidetreeTxnCh <- []txn.SidetreeTxn{sidetreeTxn}
+
+	time.Sleep(200 * time.Millisecond)
+
+	require.Equal(t, 3, txnOpsProvider.attempts(sidetreeTxn.AnchorString))
+	require.Zero(t, o.retries.len())
+}
+
+func newObserver(txnOpsProvider TxnOpsProvider, checkpointStore CheckpointStore,
+	deadLetterStore DeadLetterStore, opts ...Option) *Observer {
+	providers := &Providers{
+		Ledger:           mockLedger{registerForSidetreeTxnValue: make(chan []txn.SidetreeTxn)},
+		TxnOpsProvider:   txnOpsProvider,
+		OpStoreProvider:  &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+		OpFilterProvider: &NoopOperationFilterProvider{},
+	}
+
+	if checkpointStore != nil {
+		providers.CheckpointStore = checkpointStore
+	}
+
+	if deadLetterStore != nil {
+		providers.DeadLetterStore = deadLetterStore
+	}
+
+	return New(providers, opts...)
+}
+
+// mockFailingTxnOpsProvider fails the given number of times for an anchor string
+type mockFailingTxnOpsProvider struct {
+	mutex    sync.Mutex
+	failures map[string]int
+	ops      map[string][]*batch.Operation
+	calls    map[string]int
+}
+
+func (m *mockFailingTxnOpsProvider) GetTxnOperations(sidetreeTxn *txn.SidetreeTxn) ([]*batch.Operation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.calls == nil {
+		m.calls = make(map[string]int)
+	}
+
+	m.calls[sidetreeTxn.AnchorString]++
+
+	if m.calls[sidetreeTxn.AnchorString] <= m.failures[sidetreeTxn.AnchorString] {
+		return nil, errors.New("injected txn operations provider error")
+	}
+
+	if ops, ok := m.ops[sidetreeTxn.AnchorString]; ok {
+		return ops, nil
+	}
+
+	return []*batch.Operation{{ID: "did:sidetree:abc"}}, nil
+}
+
+// mockStoreFilter rejects the operations (other than create) of a document that isn't in the operation store
+type mockStoreFilter struct {
+	opStore *mocks.MockOperationStore
+}
+
+func (m *mockStoreFilter) Filter(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, error) {
+	_, err := m.opStore.Get(uniqueSuffix)
+	created := err == nil
+
+	var validOps []*batch.Operation
+
+	for _, op := range ops {
+		if op.Type == batch.OperationTypeCreate || created {
+			validOps = append(validOps, op)
+		}
+	}
+
+	return validOps, nil
+}
+
+func (m *mockFailingTxnOpsProvider) attempts(anchorString string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.calls[anchorString]
+}
+
+type mockDeadLetterStore struct {
+	deadLetters map[string]*DeadLetter
+	putErr      error
+	listErr     error
+	deleteErr   error
+}
+
+func (m *mockDeadLetterStore) Put(deadLetter *DeadLetter) error {
+	if m.putErr != nil {
+		return m.putErr
+	}
+
+	if m.deadLetters == nil {
+		m.deadLetters = make(map[string]*DeadLetter)
+	}
+
+	m.deadLetters[deadLetter.Txn.AnchorString] = deadLetter
+
+	return nil
+}
+
+func (m *mockDeadLetterStore) Get(anchorString string) (*DeadLetter, error) {
+	deadLetter, ok := m.deadLetters[anchorString]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return deadLetter, nil
+}
+
+func (m *mockDeadLetterStore) List() ([]*DeadLetter, error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+
+	var deadLetters []*DeadLetter
+	for _, deadLetter := range m.deadLetters {
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, nil
+}
+
+func (m *mockDeadLetterStore) Delete(anchorString string) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+
+	delete(m.deadLetters, anchorString)
+
+	return nil
+}
@@ -0,0 +1,224 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package observer
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+)
+
+// Rollback notifies the observer that the ledger was reorganized. All of the transactions in the given namespace
+// that were anchored at or after the given transaction time are no longer valid.
+type Rollback struct {
+	Namespace       string
+	TransactionTime uint64
+}
+
+// RollbackLedger is a Ledger that notifies the observer when previously delivered transactions are rolled back
+// (e.g. on ledgers with probabilistic finality). The transactions of the new fork are expected to be delivered
+// over the Sidetree transaction channel after the rollback notification.
+type RollbackLedger interface {
+	Ledger
+
+	RegisterForRollback() <-chan Rollback
+}
+
+// RollbackOperationStore is an operation store that is able to delete operations that were rolled back
+type RollbackOperationStore interface {
+	OperationStore
+
+	// Get retrieves all operations related to document
+	Get(uniqueSuffix string) ([]*batch.Operation, error)
+
+	// Rollback deletes all of the operations that were anchored at or after the given transaction time
+	// and returns the unique suffixes of the deleted operations
+	Rollback(transactionTime uint64) ([]string, error)
+
+	// Delete deletes the given operations
+	Delete(ops []*batch.Operation) error
+}
+
+func (o *Observer) registerForRollback() <-chan Rollback {
+	ledger, ok := o.Ledger.(RollbackLedger)
+	if !ok {
+		logger.Debugf("Ledger doesn't provide rollback notifications")
+
+		// receiving from a nil channel blocks forever
+		return nil
+	}
+
+	return ledger.RegisterForRollback()
+}
+
+// rollback deletes the operations that were rolled back, re-validates the remaining operations of the
+// affected suffixes and resets the checkpoint so that the transactions of the new fork are processed
+func (o *Observer) rollback(r Rollback) error {
+	logger.Infof("Rolling back namespace [%s] from transaction time [%d]", r.Namespace, r.TransactionTime)
+
+	if r.TransactionTime == 0 {
+		return errors.New("rollback to transaction time 0 is not supported")
+	}
+
+	o.retries.removeFrom(r.Namespace, r.TransactionTime)
+	o.removeHeldBackFrom(r.Namespace, r.TransactionTime)
+	o.removeDeadLetteredFrom(r.Namespace, r.TransactionTime)
+
+	if err := o.rollbackOperations(r); err != nil {
+		return err
+	}
+
+	o.rollbackCheckpoint(r)
+
+	return nil
+}
+
+// removeHeldBackFrom removes the held back transactions of the given namespace that were anchored at or after
+// the given transaction time
+func (o *Observer) removeHeldBackFrom(namespace string, transactionTime uint64) {
+	var remaining []txn.SidetreeTxn
+
+	for _, sidetreeTxn := range o.heldBack[namespace] {
+		if sidetreeTxn.TransactionTime >= transactionTime {
+			logger.Infof("Removing held back anchor[%s] since it was rolled back", sidetreeTxn.AnchorString)
+			continue
+		}
+
+		remaining = append(remaining, sidetreeTxn)
+	}
+
+	if len(remaining) == 0 {
+		delete(o.heldBack, namespace)
+
+		return
+	}
+
+	o.heldBack[namespace] = remaining
+}
+
+// removeDeadLetteredFrom deletes the dead letters of the given namespace that were anchored at or after the given
+// transaction time, since the transactions are no longer on the ledger
+func (o *Observer) removeDeadLetteredFrom(namespace string, transactionTime uint64) {
+	for anchorString, sidetreeTxn := range o.deadLettered[namespace] {
+		if sidetreeTxn.TransactionTime < transactionTime {
+			continue
+		}
+
+		logger.Infof("Deleting dead letter for anchor[%s] since it was rolled back", anchorString)
+
+		if err := o.DeadLetterStore.Delete(anchorString); err != nil {
+			logger.Warnf("Failed to delete dead letter for anchor[%s]: %s", anchorString, err)
+		}
+
+		o.removeDeadLettered(namespace, anchorString)
+	}
+}
+
+func (o *Observer) rollbackOperations(r Rollback) error {
+	opStore, err := o.OpStoreProvider.ForNamespace(r.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "error getting operation store for namespace [%s]", r.Namespace)
+	}
+
+	store, ok := opStore.(RollbackOperationStore)
+	if !ok {
+		return errors.Errorf("operation store for namespace [%s] doesn't support rollback", r.Namespace)
+	}
+
+	opFilter, err := o.OpFilterProvider.Get(r.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "error getting operation filter for namespace [%s]", r.Namespace)
+	}
+
+	suffixes, err := store.Rollback(r.TransactionTime)
+	if err != nil {
+		return errors.Wrapf(err, "failed to roll back operations for namespace [%s]", r.Namespace)
+	}
+
+	for _, suffix := range suffixes {
+		err := revalidate(suffix, store, opFilter)
+
+		// the operations of the suffix have changed even if re-validation failed
+		o.processor.invalidate(r.Namespace, suffix)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revalidate validates the remaining operations of the given suffix and deletes the operations
+// that are no longer valid
+func revalidate(suffix string, store RollbackOperationStore, opFilter OperationFilter) error {
+	validator, ok := opFilter.(OperationValidator)
+	if !ok {
+		logger.Warnf("Operation filter is not able to re-validate operations for suffix [%s]", suffix)
+
+		return nil
+	}
+
+	ops, err := store.Get(suffix)
+	if err != nil {
+		// all of the operations for the suffix were rolled back
+		logger.Debugf("No operations remaining for suffix [%s]: %s", suffix, err)
+
+		return nil
+	}
+
+	validOps, err := validator.Validate(suffix, ops)
+	if err != nil {
+		return errors.Wrapf(err, "error validating operations for suffix [%s]", suffix)
+	}
+
+	var invalidOps []*batch.Operation
+
+	for _, op := range ops {
+		if !contains(validOps, op) {
+			invalidOps = append(invalidOps, op)
+		}
+	}
+
+	if len(invalidOps) == 0 {
+		return nil
+	}
+
+	logger.Infof("Deleting %d operations for suffix [%s] that are no longer valid", len(invalidOps), suffix)
+
+	return errors.Wrapf(store.Delete(invalidOps), "failed to delete invalid operations for suffix [%s]", suffix)
+}
+
+// rollbackCheckpoint resets the checkpoint to the last transaction before the rollback
+func (o *Observer) rollbackCheckpoint(r Rollback) {
+	if o.CheckpointStore == nil {
+		return
+	}
+
+	checkpoint, ok := o.checkpoints[r.Namespace]
+	if !ok || checkpoint.TransactionTime < r.TransactionTime {
+		return
+	}
+
+	o.putCheckpoint(r.Namespace, Checkpoint{
+		TransactionTime:   r.TransactionTime - 1,
+		TransactionNumber: math.MaxUint64,
+	})
+}
+
+func contains(ops []*batch.Operation, op *batch.Operation) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
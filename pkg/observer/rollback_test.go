@@ -0,0 +1,396 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package observer
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+)
+
+func TestObserver_Rollback(t *testing.T) {
+	createOp := &batch.Operation{Type: batch.OperationTypeCreate, UniqueSuffix: "abc", TransactionTime: 10}
+	updateOp1 := &batch.Operation{Type: batch.OperationTypeUpdate, UniqueSuffix: "abc", TransactionTime: 15}
+	updateOp2 := &batch.Operation{Type: batch.OperationTypeUpdate, UniqueSuffix: "abc", TransactionTime: 20}
+	createOp2 := &batch.Operation{Type: batch.OperationTypeCreate, UniqueSuffix: "xyz", TransactionTime: 25}
+
+	t.Run("success", func(t *testing.T) {
+		opStore := newMockRollbackOperationStore(createOp, updateOp1, updateOp2, createOp2)
+		checkpointStore := &mockCheckpointStore{checkpoints: map[string]Checkpoint{ns: {TransactionTime: 25}}}
+
+		cache := &mockCacheInvalidator{}
+
+		// update 1 is no longer valid
+		opFilter := &mockOperationValidator{invalid: []*batch.Operation{updateOp1}}
+
+		o := New(&Providers{
+			Ledger:           mockLedger{},
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: opStore},
+			OpFilterProvider: &mockOperationFilterProvider{opFilter: opFilter},
+			CheckpointStore:  checkpointStore,
+			CacheInvalidator: cache,
+		})
+
+		o.register()
+
+		o.retries.add(&retryEntry{txn: txn.SidetreeTxn{Namespace: ns, TransactionTime: 21}})
+		o.retries.add(&retryEntry{txn: txn.SidetreeTxn{Namespace: ns, TransactionTime: 19}})
+		o.heldBack[ns] = []txn.SidetreeTxn{
+			{Namespace: ns, TransactionTime: 19, AnchorString: "1.anchor1"},
+			{Namespace: ns, TransactionTime: 22, AnchorString: "1.anchor2"},
+		}
+
+		require.NoError(t, o.rollback(Rollback{Namespace: ns, TransactionTime: 20}))
+
+		ops, err := opStore.Get("abc")
+		require.NoError(t, err)
+		require.Equal(t, []*batch.Operation{createOp}, ops)
+
+		_, err = opStore.Get("xyz")
+		require.Error(t, err)
+
+		require.Equal(t, []string{"abc"}, opFilter.validated)
+		require.ElementsMatch(t, []string{"did:sidetree:abc", "did:sidetree:xyz"}, cache.invalidated)
+
+		require.Equal(t, 1, o.retries.len())
+		require.Equal(t, []txn.SidetreeTxn{{Namespace: ns, TransactionTime: 19, AnchorString: "1.anchor1"}}, o.heldBack[ns])
+
+		expected := Checkpoint{TransactionTime: 19, TransactionNumber: math.MaxUint64}
+		require.Equal(t, map[string]Checkpoint{ns: expected}, checkpointStore.checkpoints)
+
+		// transactions of the new fork are processed
+		require.True(t, o.isProcessed(txn.SidetreeTxn{Namespace: ns, TransactionTime: 19, TransactionNumber: 100}))
+		require.False(t, o.isProcessed(txn.SidetreeTxn{Namespace: ns, TransactionTime: 20}))
+	})
+
+	t.Run("dead letters are rolled back", func(t *testing.T) {
+		txn1 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 19, AnchorString: "1.anchor1"}
+		txn2 := txn.SidetreeTxn{Namespace: ns, TransactionTime: 21, AnchorString: "1.anchor2"}
+
+		deadLetterStore := &mockDeadLetterStore{
+			deadLetters: map[string]*DeadLetter{
+				txn1.AnchorString: {Txn: txn1},
+				txn2.AnchorString: {Txn: txn2},
+			},
+		}
+
+		o := New(&Providers{
+			Ledger:           mockLedger{},
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: newMockRollbackOperationStore(createOp)},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+			DeadLetterStore:  deadLetterStore,
+		})
+
+		o.loadDeadLetters()
+
+		require.NoError(t, o.rollback(Rollback{Namespace: ns, TransactionTime: 20}))
+		require.Equal(t, map[string]*DeadLetter{txn1.AnchorString: {Txn: txn1}}, deadLetterStore.deadLetters)
+		require.True(t, o.isHeldBack(ns))
+
+		require.NoError(t, o.rollback(Rollback{Namespace: ns, TransactionTime: 10}))
+		require.Empty(t, deadLetterStore.deadLetters)
+		require.False(t, o.isHeldBack(ns))
+	})
+
+	t.Run("checkpoint before rollback", func(t *testing.T) {
+		opStore := newMockRollbackOperationStore(createOp, updateOp1)
+		checkpointStore := &mockCheckpointStore{checkpoints: map[string]Checkpoint{ns: {TransactionTime: 15}}}
+
+		o := New(&Providers{
+			Ledger:           mockLedger{},
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: opStore},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+			CheckpointStore:  checkpointStore,
+		})
+
+		o.register()
+
+		require.NoError(t, o.rollback(Rollback{Namespace: ns, TransactionTime: 20}))
+		require.Equal(t, map[string]Checkpoint{ns: {TransactionTime: 15}}, checkpointStore.checkpoints)
+
+		// no checkpoint for namespace
+		require.NoError(t, o.rollback(Rollback{Namespace: "did:other", TransactionTime: 20}))
+		require.Len(t, checkpointStore.checkpoints, 1)
+	})
+
+	t.Run("operation filter is not able to re-validate operations", func(t *testing.T) {
+		opStore := newMockRollbackOperationStore(createOp, updateOp1, updateOp2)
+
+		o := New(&Providers{
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: opStore},
+			OpFilterProvider: &mockOperationFilterProvider{opFilter: &mockOperationFilter{}},
+		})
+
+		require.NoError(t, o.rollback(Rollback{Namespace: ns, TransactionTime: 20}))
+
+		ops, err := opStore.Get("abc")
+		require.NoError(t, err)
+		require.Equal(t, []*batch.Operation{createOp, updateOp1}, ops)
+	})
+
+	t.Run("error - transaction time 0", func(t *testing.T) {
+		o := New(&Providers{})
+
+		err := o.rollback(Rollback{Namespace: ns})
+		require.EqualError(t, err, "rollback to transaction time 0 is not supported")
+	})
+
+	t.Run("error - operation store provider", func(t *testing.T) {
+		o := New(&Providers{
+			OpStoreProvider: &mockOperationStoreProvider{err: errors.New("injected store provider error")},
+		})
+
+		err := o.rollback(Rollback{Namespace: ns, TransactionTime: 20})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "injected store provider error")
+	})
+
+	t.Run("error - operation store doesn't support rollback", func(t *testing.T) {
+		o := New(&Providers{
+			OpStoreProvider: &mockOperationStoreProvider{opStore: &mockOperationStore{}},
+		})
+
+		err := o.rollback(Rollback{Namespace: ns, TransactionTime: 20})
+		require.EqualError(t, err, "operation store for namespace [did:sidetree] doesn't support rollback")
+	})
+
+	t.Run("error - operation store rollback", func(t *testing.T) {
+		opStore := newMockRollbackOperationStore()
+		opStore.rollbackErr = errors.New("injected rollback error")
+
+		o := New(&Providers{
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: opStore},
+			OpFilterProvider: &NoopOperationFilterProvider{},
+		})
+
+		err := o.rollback(Rollback{Namespace: ns, TransactionTime: 20})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "injected rollback error")
+	})
+
+	t.Run("error - operation filter provider", func(t *testing.T) {
+		o := New(&Providers{
+			OpStoreProvider:  &mockOperationStoreProvider{opStore: newMockRollbackOperationStore()},
+			OpFilterProvider: &mockOperationFilterProvider{err: errors.New("injected filter provider error")},
+		})
+
+		err := o.rollback(Rollback{Namespace: ns, TransactionTime: 20})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "injected filter provider error")
+	})
+
+	t.Run("error - validate", func(t *testing.T) {
+		o := New(&Providers{
+			OpStoreProvider: &mockOperationStoreProvider{opStore: newMockRollbackOperationStore(createOp, updateOp2)},
+			OpFilterProvider: &mockOperationFilterProvider{
+				opFilter: &mockOperationValidator{err: errors.New("injected validate error")},
+			},
+		})
+
+		err := o.rollback(Rollback{Namespace: ns, TransactionTime: 20})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "injected validate error")
+	})
+
+	t.Run("error - delete", func(t *testing.T) {
+		opStore := newMockRollbackOperationStore(createOp, updateOp1, updateOp2)
+		opStore.deleteErr = errors.New("injected delete error")
+
+		o := New(&Providers{
+			OpStoreProvider: &mockOperationStoreProvider{opStore: opStore},
+			OpFilterProvider: &mockOperationFilterProvider{
+				opFilter: &mockOperationValidator{invalid: []*batch.Operation{updateOp1}},
+			},
+		})
+
+		err := o.rollback(Rollback{Namespace: ns, TransactionTime: 20})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "injected delete error")
+	})
+}
+
+func TestObserver_RollbackNotification(t *testing.T) {
+	opStore := newMockRollbackOperationStore(
+		&batch.Operation{Type: batch.OperationTypeCreate, UniqueSuffix: "abc", TransactionTime: 10},
+		&batch.Operation{Type: batch.OperationTypeUpdate, UniqueSuffix: "abc", TransactionTime: 20},
+	)
+
+	rollbackCh := make(chan Rollback, 10)
+
+	o := New(&Providers{
+		Ledger: &mockRollbackLedger{
+			mockLedger: mockLedger{registerForSidetreeTxnValue: make(chan []txn.SidetreeTxn)},
+			rollbackCh: rollbackCh,
+		},
+		OpStoreProvider:  &mockOperationStoreProvider{opStore: opStore},
+		OpFilterProvider: &NoopOperationFilterProvider{},
+	})
+
+	o.Start()
+	defer o.Stop()
+
+	// error is logged
+	rollbackCh <- Rollback{Namespace: ns}
+	rollbackCh <- Rollback{Namespace: ns, TransactionTime: 20}
+	close(rollbackCh)
+
+	time.Sleep(200 * time.Millisecond)
+
+	ops, err := opStore.Get("abc")
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+}
+
+type mockRollbackLedger struct {
+	mockLedger
+
+	rollbackCh chan Rollback
+}
+
+func (m *mockRollbackLedger) RegisterForRollback() <-chan Rollback {
+	return m.rollbackCh
+}
+
+type mockRollbackOperationStore struct {
+	mockOperationStore
+
+	mutex       sync.RWMutex
+	ops         []*batch.Operation
+	rollbackErr error
+	deleteErr   error
+}
+
+func newMockRollbackOperationStore(ops ...*batch.Operation) *mockRollbackOperationStore {
+	return &mockRollbackOperationStore{ops: ops}
+}
+
+func (m *mockRollbackOperationStore) Get(suffix string) ([]*batch.Operation, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var ops []*batch.Operation
+
+	for _, op := range m.ops {
+		if op.UniqueSuffix == suffix {
+			ops = append(ops, op)
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil, errors.New("not found")
+	}
+
+	return ops, nil
+}
+
+func (m *mockRollbackOperationStore) Rollback(transactionTime uint64) ([]string, error) {
+	if m.rollbackErr != nil {
+		return nil, m.rollbackErr
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var remaining []*batch.Operation
+
+	var suffixes []string
+
+	for _, op := range m.ops {
+		if op.TransactionTime < transactionTime {
+			remaining = append(remaining, op)
+		} else if !containsSuffix(suffixes, op.UniqueSuffix) {
+			suffixes = append(suffixes, op.UniqueSuffix)
+		}
+	}
+
+	m.ops = remaining
+
+	return suffixes, nil
+}
+
+func (m *mockRollbackOperationStore) Delete(ops []*batch.Operation) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var remaining []*batch.Operation
+
+	for _, op := range m.ops {
+		if !contains(ops, op) {
+			remaining = append(remaining, op)
+		}
+	}
+
+	m.ops = remaining
+
+	return nil
+}
+
+func containsSuffix(suffixes []string, suffix string) bool {
+	for _, s := range suffixes {
+		if s == suffix {
+			return true
+		}
+	}
+
+	return false
+}
+
+type mockOperationFilterProvider struct {
+	opFilter OperationFilter
+	err      error
+}
+
+func (m *mockOperationFilterProvider) Get(string) (OperationFilter, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return m.opFilter, nil
+}
+
+type mockOperationFilter struct{}
+
+func (m *mockOperationFilter) Filter(_ string, ops []*batch.Operation) ([]*batch.Operation, error) {
+	return ops, nil
+}
+
+type mockOperationValidator struct {
+	mockOperationFilter
+
+	invalid   []*batch.Operation
+	err       error
+	validated []string
+}
+
+func (m *mockOperationValidator) Validate(suffix string, ops []*batch.Operation) ([]*batch.Operation, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.validated = append(m.validated, suffix)
+
+	var validOps []*batch.Operation
+
+	for _, op := range ops {
+		if !contains(m.invalid, op) {
+			validOps = append(validOps, op)
+		}
+	}
+
+	return validOps, nil
+}
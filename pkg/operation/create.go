@@ -8,13 +8,11 @@ package operation
 
 import (
 	"encoding/json"
-	"fmt"
-
-	"github.com/pkg/errors"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 )
 
@@ -40,7 +38,7 @@ func ParseCreateOperation(request []byte, protocol protocol.Protocol) (*batch.Op
 	// verify actual delta hash matches expected delta hash
 	err = docutil.IsValidHash(schema.Delta, suffixData.DeltaHash)
 	if err != nil {
-		return nil, fmt.Errorf("parse create operation: delta doesn't match suffix data delta hash: %s", err.Error())
+		return nil, errs.WrapField(errs.InvalidField, "delta", err, "parse create operation: delta doesn't match suffix data delta hash")
 	}
 
 	uniqueSuffix, err := docutil.CalculateUniqueSuffix(schema.SuffixData, code)
@@ -63,7 +61,7 @@ func parseCreateRequest(payload []byte) (*model.CreateRequest, error) {
 	schema := &model.CreateRequest{}
 	err := json.Unmarshal(payload, schema)
 	if err != nil {
-		return nil, err
+		return nil, errs.Wrap(errs.BadRequest, err, "failed to unmarshal create request")
 	}
 
 	if err := validateCreateRequest(schema); err != nil {
@@ -77,13 +75,13 @@ func parseCreateRequest(payload []byte) (*model.CreateRequest, error) {
 func ParseDelta(encoded string, code uint) (*model.DeltaModel, error) {
 	bytes, err := docutil.DecodeString(encoded)
 	if err != nil {
-		return nil, err
+		return nil, errs.WrapField(errs.InvalidField, "delta", err, "decode delta")
 	}
 
 	schema := &model.DeltaModel{}
 	err = json.Unmarshal(bytes, schema)
 	if err != nil {
-		return nil, err
+		return nil, errs.WrapField(errs.InvalidField, "delta", err, "unmarshal delta")
 	}
 
 	if err := validateDelta(schema, code); err != nil {
@@ -97,13 +95,13 @@ func ParseDelta(encoded string, code uint) (*model.DeltaModel, error) {
 func ParseSuffixData(encoded string, code uint) (*model.SuffixDataModel, error) {
 	bytes, err := docutil.DecodeString(encoded)
 	if err != nil {
-		return nil, err
+		return nil, errs.WrapField(errs.InvalidField, "suffix_data", err, "decode suffix data")
 	}
 
 	schema := &model.SuffixDataModel{}
 	err = json.Unmarshal(bytes, schema)
 	if err != nil {
-		return nil, err
+		return nil, errs.WrapField(errs.InvalidField, "suffix_data", err, "unmarshal suffix data")
 	}
 
 	if err := validateSuffixData(schema, code); err != nil {
@@ -115,17 +113,17 @@ func ParseSuffixData(encoded string, code uint) (*model.SuffixDataModel, error)
 
 func validateDelta(delta *model.DeltaModel, code uint) error {
 	if len(delta.Patches) == 0 {
-		return errors.New("missing patches")
+		return errs.NewField(errs.MissingField, "delta.patches", "missing patches")
 	}
 
 	for _, p := range delta.Patches {
 		if err := p.Validate(); err != nil {
-			return err
+			return errs.WrapField(errs.InvalidField, "delta.patches", err, "invalid patch")
 		}
 	}
 
 	if !docutil.IsComputedUsingHashAlgorithm(delta.UpdateCommitment, uint64(code)) {
-		return errors.New("next update commitment hash is not computed with the latest supported hash algorithm")
+		return errs.NewField(errs.InvalidField, "delta.update_commitment", "next update commitment hash is not computed with the latest supported hash algorithm")
 	}
 
 	return nil
@@ -133,11 +131,11 @@ func validateDelta(delta *model.DeltaModel, code uint) error {
 
 func validateSuffixData(suffixData *model.SuffixDataModel, code uint) error {
 	if !docutil.IsComputedUsingHashAlgorithm(suffixData.RecoveryCommitment, uint64(code)) {
-		return errors.New("next recovery commitment hash is not computed with the latest supported hash algorithm")
+		return errs.NewField(errs.InvalidField, "suffix_data.recovery_commitment", "next recovery commitment hash is not computed with the latest supported hash algorithm")
 	}
 
 	if !docutil.IsComputedUsingHashAlgorithm(suffixData.DeltaHash, uint64(code)) {
-		return errors.New("patch data hash is not computed with the latest supported hash algorithm")
+		return errs.NewField(errs.InvalidField, "suffix_data.delta_hash", "patch data hash is not computed with the latest supported hash algorithm")
 	}
 
 	return nil
@@ -145,11 +143,11 @@ func validateSuffixData(suffixData *model.SuffixDataModel, code uint) error {
 
 func validateCreateRequest(create *model.CreateRequest) error {
 	if create.Delta == "" {
-		return errors.New("missing delta")
+		return errs.NewField(errs.MissingField, "delta", "missing delta")
 	}
 
 	if create.SuffixData == "" {
-		return errors.New("missing suffix data")
+		return errs.NewField(errs.MissingField, "suffix_data", "missing suffix data")
 	}
 
 	return nil
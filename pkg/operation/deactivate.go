@@ -8,11 +8,11 @@ package operation
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 )
 
@@ -40,7 +40,7 @@ func parseDeactivateRequest(payload []byte) (*model.DeactivateRequest, error) {
 	schema := &model.DeactivateRequest{}
 	err := json.Unmarshal(payload, schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal deactivate request: %s", err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, "failed to unmarshal deactivate request")
 	}
 
 	if err := validateDeactivateRequest(schema); err != nil {
@@ -52,11 +52,11 @@ func parseDeactivateRequest(payload []byte) (*model.DeactivateRequest, error) {
 
 func validateDeactivateRequest(req *model.DeactivateRequest) error {
 	if req.DidSuffix == "" {
-		return errors.New("missing unique suffix")
+		return errs.NewField(errs.MissingField, "did_suffix", "missing unique suffix")
 	}
 
 	if req.SignedData == "" {
-		return errors.New("missing signed data")
+		return errs.NewField(errs.MissingField, "signed_data", "missing signed data")
 	}
 
 	return nil
@@ -65,17 +65,17 @@ func validateDeactivateRequest(req *model.DeactivateRequest) error {
 func parseSignedDataForDeactivate(req *model.DeactivateRequest) (*model.DeactivateSignedDataModel, error) {
 	jws, err := parseSignedData(req.SignedData)
 	if err != nil {
-		return nil, fmt.Errorf("deactivate: %s", err.Error())
+		return nil, fmt.Errorf("deactivate: %w", err)
 	}
 
 	signedData := &model.DeactivateSignedDataModel{}
 	err = json.Unmarshal(jws.Payload, signedData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal signed data model for deactivate: %s", err.Error())
+		return nil, errs.WrapField(errs.InvalidField, "signed_data", err, "failed to unmarshal signed data model for deactivate")
 	}
 
 	if signedData.DidSuffix != req.DidSuffix {
-		return nil, errors.New("signed did suffix mismatch for deactivate")
+		return nil, errs.NewField(errs.InvalidField, "signed_data.did_suffix", "signed did suffix mismatch for deactivate")
 	}
 
 	return signedData, nil
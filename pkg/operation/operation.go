@@ -8,11 +8,11 @@ package operation
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 )
 
@@ -21,7 +21,7 @@ func ParseOperation(namespace string, operationBuffer []byte, protocol protocol.
 	schema := &operationSchema{}
 	err := json.Unmarshal(operationBuffer, schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal operation buffer into operation schema: %s", err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, "failed to unmarshal operation buffer into operation schema")
 	}
 
 	var op *batch.Operation
@@ -36,7 +36,7 @@ func ParseOperation(namespace string, operationBuffer []byte, protocol protocol.
 	case model.OperationTypeRecover:
 		op, parseErr = ParseRecoverOperation(operationBuffer, protocol)
 	default:
-		return nil, fmt.Errorf("operation type [%s] not implemented", schema.Operation)
+		return nil, errs.NewField(errs.UnsupportedOperation, "type", "operation type [%s] not implemented", schema.Operation)
 	}
 
 	if parseErr != nil {
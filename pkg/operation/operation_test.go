@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
 const namespace = "did:sidetree"
@@ -73,6 +74,8 @@ func TestGetOperation(t *testing.T) {
 		op, err := ParseOperation(namespace, operation, p)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "not implemented")
+		require.Equal(t, errs.UnsupportedOperation, errs.CodeOf(err))
+		require.Equal(t, "type", errs.FieldOf(err))
 		require.Nil(t, op)
 	})
 	t.Run("unmarshal request error - not JSON", func(t *testing.T) {
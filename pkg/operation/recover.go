@@ -15,6 +15,7 @@ import (
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	internal "github.com/trustbloc/sidetree-core-go/pkg/internal/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
@@ -53,7 +54,7 @@ func parseRecoverRequest(payload []byte) (*model.RecoverRequest, error) {
 	schema := &model.RecoverRequest{}
 	err := json.Unmarshal(payload, schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal recover request: %s", err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, "failed to unmarshal recover request")
 	}
 
 	if err := validateRecoverRequest(schema); err != nil {
@@ -66,13 +67,13 @@ func parseRecoverRequest(payload []byte) (*model.RecoverRequest, error) {
 func parseSignedDataForRecovery(compactJWS string, code uint) (*model.RecoverSignedDataModel, error) {
 	jws, err := parseSignedData(compactJWS)
 	if err != nil {
-		return nil, fmt.Errorf("recover: %s", err.Error())
+		return nil, fmt.Errorf("recover: %w", err)
 	}
 
 	schema := &model.RecoverSignedDataModel{}
 	err = json.Unmarshal(jws.Payload, schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal signed data model for recover: %s", err.Error())
+		return nil, errs.WrapField(errs.InvalidField, "signed_data", err, "failed to unmarshal signed data model for recover")
 	}
 
 	if err := validateSignedDataForRecovery(schema, code); err != nil {
@@ -84,15 +85,15 @@ func parseSignedDataForRecovery(compactJWS string, code uint) (*model.RecoverSig
 
 func validateSignedDataForRecovery(signedData *model.RecoverSignedDataModel, code uint) error {
 	if err := validateKey(signedData.RecoveryKey); err != nil {
-		return fmt.Errorf("signed data for recovery: %s", err.Error())
+		return errs.WrapField(errs.InvalidField, "signed_data.recovery_key", err, "signed data for recovery")
 	}
 
 	if !docutil.IsComputedUsingHashAlgorithm(signedData.RecoveryCommitment, uint64(code)) {
-		return errors.New("next recovery commitment hash is not computed with the latest supported hash algorithm")
+		return errs.NewField(errs.InvalidField, "signed_data.recovery_commitment", "next recovery commitment hash is not computed with the latest supported hash algorithm")
 	}
 
 	if !docutil.IsComputedUsingHashAlgorithm(signedData.DeltaHash, uint64(code)) {
-		return errors.New("patch data hash is not computed with the latest supported hash algorithm")
+		return errs.NewField(errs.InvalidField, "signed_data.delta_hash", "patch data hash is not computed with the latest supported hash algorithm")
 	}
 
 	return nil
@@ -101,7 +102,7 @@ func validateSignedDataForRecovery(signedData *model.RecoverSignedDataModel, cod
 func parseSignedData(compactJWS string) (*internal.JSONWebSignature, error) {
 	jws, err := internal.ParseJWS(compactJWS)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse signed data: %s", err.Error())
+		return nil, errs.WrapField(errs.InvalidSignature, "signed_data", err, "failed to parse signed data")
 	}
 
 	return jws, nil
@@ -109,15 +110,15 @@ func parseSignedData(compactJWS string) (*internal.JSONWebSignature, error) {
 
 func validateRecoverRequest(recover *model.RecoverRequest) error {
 	if recover.DidSuffix == "" {
-		return errors.New("missing did suffix")
+		return errs.NewField(errs.MissingField, "did_suffix", "missing did suffix")
 	}
 
 	if recover.Delta == "" {
-		return errors.New("missing delta")
+		return errs.NewField(errs.MissingField, "delta", "missing delta")
 	}
 
 	if recover.SignedData == "" {
-		return errors.New("missing signed data")
+		return errs.NewField(errs.MissingField, "signed_data", "missing signed data")
 	}
 
 	return nil
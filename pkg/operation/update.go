@@ -8,12 +8,12 @@ package operation
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 )
 
@@ -48,7 +48,7 @@ func parseUpdateRequest(payload []byte) (*model.UpdateRequest, error) {
 	schema := &model.UpdateRequest{}
 	err := json.Unmarshal(payload, schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal update request: %s", err.Error())
+		return nil, errs.Wrap(errs.BadRequest, err, "failed to unmarshal update request")
 	}
 
 	if err := validateUpdateRequest(schema); err != nil {
@@ -61,13 +61,13 @@ func parseUpdateRequest(payload []byte) (*model.UpdateRequest, error) {
 func parseSignedDataForUpdate(compactJWS string, code uint) (*model.UpdateSignedDataModel, error) {
 	jws, err := parseSignedData(compactJWS)
 	if err != nil {
-		return nil, fmt.Errorf("update: %s", err.Error())
+		return nil, fmt.Errorf("update: %w", err)
 	}
 
 	schema := &model.UpdateSignedDataModel{}
 	err = json.Unmarshal(jws.Payload, schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal signed data model for update: %s", err.Error())
+		return nil, errs.WrapField(errs.InvalidField, "signed_data", err, "failed to unmarshal signed data model for update")
 	}
 
 	if err := validateSignedDataForUpdate(schema, code); err != nil {
@@ -79,15 +79,15 @@ func parseSignedDataForUpdate(compactJWS string, code uint) (*model.UpdateSigned
 
 func validateUpdateRequest(update *model.UpdateRequest) error {
 	if update.DidSuffix == "" {
-		return errors.New("missing did suffix")
+		return errs.NewField(errs.MissingField, "did_suffix", "missing did suffix")
 	}
 
 	if update.Delta == "" {
-		return errors.New("missing delta")
+		return errs.NewField(errs.MissingField, "delta", "missing delta")
 	}
 
 	if update.SignedData == "" {
-		return errors.New("missing signed data")
+		return errs.NewField(errs.MissingField, "signed_data", "missing signed data")
 	}
 
 	return nil
@@ -95,11 +95,11 @@ func validateUpdateRequest(update *model.UpdateRequest) error {
 
 func validateSignedDataForUpdate(signedData *model.UpdateSignedDataModel, code uint) error {
 	if err := validateKey(signedData.UpdateKey); err != nil {
-		return fmt.Errorf("signed data for update: %s", err.Error())
+		return errs.WrapField(errs.InvalidField, "signed_data.update_key", err, "signed data for update")
 	}
 
 	if !docutil.IsComputedUsingHashAlgorithm(signedData.DeltaHash, uint64(code)) {
-		return errors.New("delta hash is not computed with the latest supported hash algorithm")
+		return errs.NewField(errs.InvalidField, "signed_data.delta_hash", "delta hash is not computed with the latest supported hash algorithm")
 	}
 
 	return nil
@@ -15,6 +15,7 @@ import (
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/canonicalizer"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/signutil"
 	"github.com/trustbloc/sidetree-core-go/pkg/jws"
@@ -174,6 +175,8 @@ func TestValidateUpdateRequest(t *testing.T) {
 		err = validateUpdateRequest(update)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "missing signed data")
+		require.Equal(t, errs.MissingField, errs.CodeOf(err))
+		require.Equal(t, "signed_data", errs.FieldOf(err))
 	})
 	t.Run("missing did suffix", func(t *testing.T) {
 		update, err := getDefaultUpdateRequest()
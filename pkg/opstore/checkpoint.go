@@ -0,0 +1,118 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/observer"
+)
+
+const tempFileExt = ".tmp"
+
+// CheckpointStore stores the observer checkpoints (last processed transaction per namespace). All of the
+// checkpoints are kept in a single file which is replaced atomically whenever a checkpoint is updated.
+type CheckpointStore struct {
+	mutex       sync.RWMutex
+	path        string
+	checkpoints map[string]observer.Checkpoint
+}
+
+// NewCheckpointStore opens (or creates) the checkpoint store backed by the given file.
+func NewCheckpointStore(path string) (*CheckpointStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create checkpoint store directory for [%s]", path)
+	}
+
+	checkpoints := make(map[string]observer.Checkpoint)
+
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "read checkpoint store [%s]", path)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(content, &checkpoints); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal checkpoint store [%s]", path)
+		}
+	}
+
+	return &CheckpointStore{
+		path:        path,
+		checkpoints: checkpoints,
+	}, nil
+}
+
+// Checkpoints returns the checkpoints of all namespaces
+func (s *CheckpointStore) Checkpoints() (map[string]observer.Checkpoint, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	checkpoints := make(map[string]observer.Checkpoint, len(s.checkpoints))
+	for namespace, checkpoint := range s.checkpoints {
+		checkpoints[namespace] = checkpoint
+	}
+
+	return checkpoints, nil
+}
+
+// Put stores the checkpoint for the given namespace. The checkpoint is synced to disk before Put returns.
+func (s *CheckpointStore) Put(namespace string, checkpoint observer.Checkpoint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	checkpoints := make(map[string]observer.Checkpoint, len(s.checkpoints)+1)
+	for ns, cp := range s.checkpoints {
+		checkpoints[ns] = cp
+	}
+
+	checkpoints[namespace] = checkpoint
+
+	content, err := json.Marshal(checkpoints)
+	if err != nil {
+		return errors.Wrap(err, "marshal checkpoints")
+	}
+
+	if err := writeFileAtomic(s.path, content); err != nil {
+		return err
+	}
+
+	s.checkpoints = checkpoints
+
+	return nil
+}
+
+// writeFileAtomic replaces the given file (write to temp file, sync and rename)
+func writeFileAtomic(path string, content []byte) error {
+	tmpPath := path + tempFileExt
+
+	f, err := os.OpenFile(filepath.Clean(tmpPath), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return errors.Wrapf(err, "create file [%s]", tmpPath)
+	}
+
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "write file [%s]", tmpPath)
+	}
+
+	return errors.Wrapf(os.Rename(tmpPath, path), "rename file [%s]", tmpPath)
+}
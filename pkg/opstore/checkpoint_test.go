@@ -0,0 +1,102 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/observer"
+)
+
+var _ observer.CheckpointStore = (*CheckpointStore)(nil)
+
+func TestCheckpointStore(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "checkpoints")
+
+		s, err := NewCheckpointStore(path)
+		require.NoError(t, err)
+
+		checkpoints, err := s.Checkpoints()
+		require.NoError(t, err)
+		require.Empty(t, checkpoints)
+
+		cp1 := observer.Checkpoint{TransactionTime: 10, TransactionNumber: 1}
+		cp2 := observer.Checkpoint{TransactionTime: 20, TransactionNumber: 2}
+
+		require.NoError(t, s.Put(namespace, cp1))
+		require.NoError(t, s.Put("did:other", cp1))
+		require.NoError(t, s.Put(namespace, cp2))
+
+		checkpoints, err = s.Checkpoints()
+		require.NoError(t, err)
+		require.Equal(t, map[string]observer.Checkpoint{namespace: cp2, "did:other": cp1}, checkpoints)
+
+		// returned checkpoints are a copy
+		checkpoints[namespace] = cp1
+
+		s, err = NewCheckpointStore(path)
+		require.NoError(t, err)
+
+		checkpoints, err = s.Checkpoints()
+		require.NoError(t, err)
+		require.Equal(t, map[string]observer.Checkpoint{namespace: cp2, "did:other": cp1}, checkpoints)
+	})
+
+	t.Run("error - invalid content", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "checkpoints")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), filePerm))
+
+		s, err := NewCheckpointStore(path)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "unmarshal checkpoint store")
+	})
+
+	t.Run("error - read", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewCheckpointStore(dir)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "read checkpoint store")
+	})
+
+	t.Run("error - write", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "checkpoints")
+
+		s, err := NewCheckpointStore(path)
+		require.NoError(t, err)
+
+		// the temporary file can't be created if a directory with the same name exists
+		require.NoError(t, ioutil.WriteFile(path, []byte("{}"), filePerm))
+		require.NoError(t, os.Mkdir(path+tempFileExt, dirPerm))
+
+		err = s.Put(namespace, observer.Checkpoint{TransactionTime: 10})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create file")
+
+		checkpoints, err := s.Checkpoints()
+		require.NoError(t, err)
+		require.Empty(t, checkpoints)
+	})
+}
@@ -0,0 +1,138 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/observer"
+)
+
+// DeadLetterStore stores the Sidetree transactions that the observer could not process. All of the dead letters
+// are kept in a single file which is replaced atomically whenever a dead letter is added or deleted.
+type DeadLetterStore struct {
+	mutex       sync.RWMutex
+	path        string
+	deadLetters map[string]*observer.DeadLetter
+}
+
+// NewDeadLetterStore opens (or creates) the dead-letter store backed by the given file.
+func NewDeadLetterStore(path string) (*DeadLetterStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create dead-letter store directory for [%s]", path)
+	}
+
+	deadLetters := make(map[string]*observer.DeadLetter)
+
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "read dead-letter store [%s]", path)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(content, &deadLetters); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal dead-letter store [%s]", path)
+		}
+	}
+
+	return &DeadLetterStore{
+		path:        path,
+		deadLetters: deadLetters,
+	}, nil
+}
+
+// Put stores (or replaces) the dead letter. The dead letter is synced to disk before Put returns.
+func (s *DeadLetterStore) Put(deadLetter *observer.DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deadLetters := s.copy()
+	deadLetters[deadLetter.Txn.AnchorString] = deadLetter
+
+	return s.save(deadLetters)
+}
+
+// Get returns the dead letter for the given anchor string
+func (s *DeadLetterStore) Get(anchorString string) (*observer.DeadLetter, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	deadLetter, ok := s.deadLetters[anchorString]
+	if !ok {
+		return nil, errs.New(errs.NotFound, "dead letter not found for anchor string: %s", anchorString)
+	}
+
+	return deadLetter, nil
+}
+
+// List returns all of the dead letters ordered by transaction time and transaction number
+func (s *DeadLetterStore) List() ([]*observer.DeadLetter, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	deadLetters := make([]*observer.DeadLetter, 0, len(s.deadLetters))
+	for _, deadLetter := range s.deadLetters {
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	sort.Slice(deadLetters, func(i, j int) bool {
+		if deadLetters[i].Txn.TransactionTime != deadLetters[j].Txn.TransactionTime {
+			return deadLetters[i].Txn.TransactionTime < deadLetters[j].Txn.TransactionTime
+		}
+
+		return deadLetters[i].Txn.TransactionNumber < deadLetters[j].Txn.TransactionNumber
+	})
+
+	return deadLetters, nil
+}
+
+// Delete deletes the dead letter for the given anchor string
+func (s *DeadLetterStore) Delete(anchorString string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.deadLetters[anchorString]; !ok {
+		return nil
+	}
+
+	deadLetters := s.copy()
+	delete(deadLetters, anchorString)
+
+	return s.save(deadLetters)
+}
+
+func (s *DeadLetterStore) copy() map[string]*observer.DeadLetter {
+	deadLetters := make(map[string]*observer.DeadLetter, len(s.deadLetters)+1)
+	for anchorString, deadLetter := range s.deadLetters {
+		deadLetters[anchorString] = deadLetter
+	}
+
+	return deadLetters
+}
+
+func (s *DeadLetterStore) save(deadLetters map[string]*observer.DeadLetter) error {
+	content, err := json.Marshal(deadLetters)
+	if err != nil {
+		return errors.Wrap(err, "marshal dead letters")
+	}
+
+	if err := writeFileAtomic(s.path, content); err != nil {
+		return err
+	}
+
+	s.deadLetters = deadLetters
+
+	return nil
+}
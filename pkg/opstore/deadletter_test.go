@@ -0,0 +1,128 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+	"github.com/trustbloc/sidetree-core-go/pkg/observer"
+)
+
+var _ observer.DeadLetterStore = (*DeadLetterStore)(nil)
+
+func TestDeadLetterStore(t *testing.T) {
+	dl1 := &observer.DeadLetter{
+		Txn:      txn.SidetreeTxn{Namespace: namespace, TransactionTime: 20, TransactionNumber: 1, AnchorString: "1.anchor1"},
+		Attempts: 5,
+		Error:    "CAS error",
+	}
+
+	dl2 := &observer.DeadLetter{
+		Txn:      txn.SidetreeTxn{Namespace: namespace, TransactionTime: 10, TransactionNumber: 3, AnchorString: "1.anchor2"},
+		Attempts: 5,
+		Error:    "CAS error",
+	}
+
+	t.Run("success", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "deadletters")
+
+		s, err := NewDeadLetterStore(path)
+		require.NoError(t, err)
+
+		deadLetters, err := s.List()
+		require.NoError(t, err)
+		require.Empty(t, deadLetters)
+
+		dl, err := s.Get(dl1.Txn.AnchorString)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "not found")
+		require.Nil(t, dl)
+
+		require.NoError(t, s.Put(dl1))
+		require.NoError(t, s.Put(dl2))
+
+		dl, err = s.Get(dl1.Txn.AnchorString)
+		require.NoError(t, err)
+		require.Equal(t, dl1, dl)
+
+		s, err = NewDeadLetterStore(path)
+		require.NoError(t, err)
+
+		deadLetters, err = s.List()
+		require.NoError(t, err)
+		require.Equal(t, []*observer.DeadLetter{dl2, dl1}, deadLetters)
+
+		require.NoError(t, s.Delete(dl2.Txn.AnchorString))
+		require.NoError(t, s.Delete(dl2.Txn.AnchorString))
+
+		s, err = NewDeadLetterStore(path)
+		require.NoError(t, err)
+
+		deadLetters, err = s.List()
+		require.NoError(t, err)
+		require.Equal(t, []*observer.DeadLetter{dl1}, deadLetters)
+	})
+
+	t.Run("error - invalid content", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "deadletters")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), filePerm))
+
+		s, err := NewDeadLetterStore(path)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "unmarshal dead-letter store")
+	})
+
+	t.Run("error - read", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewDeadLetterStore(dir)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "read dead-letter store")
+	})
+
+	t.Run("error - write", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "deadletters")
+
+		s, err := NewDeadLetterStore(path)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(dl1))
+
+		// the temporary file can't be created if a directory with the same name exists
+		require.NoError(t, os.Mkdir(path+tempFileExt, dirPerm))
+
+		err = s.Put(dl2)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create file")
+
+		err = s.Delete(dl1.Txn.AnchorString)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create file")
+
+		deadLetters, err := s.List()
+		require.NoError(t, err)
+		require.Equal(t, []*observer.DeadLetter{dl1}, deadLetters)
+	})
+}
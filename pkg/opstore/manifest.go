@@ -0,0 +1,88 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ManifestStore stores the prepared batches of a write manifest (see txnhandler.NewPersistentWriteManifest), i.e.
+// the addresses of the batch files keyed by anchor string. The batches are kept in a single file which is replaced
+// atomically whenever the batches are stored.
+type ManifestStore struct {
+	mutex   sync.RWMutex
+	path    string
+	batches map[string][]string
+}
+
+// NewManifestStore opens (or creates) the manifest store backed by the given file.
+func NewManifestStore(path string) (*ManifestStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create manifest store directory for [%s]", path)
+	}
+
+	batches := make(map[string][]string)
+
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "read manifest store [%s]", path)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(content, &batches); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal manifest store [%s]", path)
+		}
+	}
+
+	return &ManifestStore{
+		path:    path,
+		batches: batches,
+	}, nil
+}
+
+// Get returns the addresses of the files of the prepared batches keyed by anchor string
+func (s *ManifestStore) Get() (map[string][]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	batches := make(map[string][]string, len(s.batches))
+	for anchorString, addresses := range s.batches {
+		batches[anchorString] = addresses
+	}
+
+	return batches, nil
+}
+
+// Put stores (or replaces) the prepared batches. The batches are synced to disk before Put returns.
+func (s *ManifestStore) Put(batches map[string][]string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	content, err := json.Marshal(batches)
+	if err != nil {
+		return errors.Wrap(err, "marshal manifest")
+	}
+
+	if err := writeFileAtomic(s.path, content); err != nil {
+		return err
+	}
+
+	copied := make(map[string][]string, len(batches))
+	for anchorString, addresses := range batches {
+		copied[anchorString] = addresses
+	}
+
+	s.batches = copied
+
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/txnhandler"
+)
+
+var _ txnhandler.WriteManifestStore = (*ManifestStore)(nil)
+
+func TestManifestStore(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "manifest")
+
+		s, err := NewManifestStore(path)
+		require.NoError(t, err)
+
+		batches, err := s.Get()
+		require.NoError(t, err)
+		require.Empty(t, batches)
+
+		batches = map[string][]string{
+			"anchor1": {"address1", "address2"},
+			"anchor2": {"address3"},
+		}
+
+		require.NoError(t, s.Put(batches))
+
+		s, err = NewManifestStore(path)
+		require.NoError(t, err)
+
+		stored, err := s.Get()
+		require.NoError(t, err)
+		require.Equal(t, batches, stored)
+
+		require.NoError(t, s.Put(map[string][]string{}))
+
+		s, err = NewManifestStore(path)
+		require.NoError(t, err)
+
+		stored, err = s.Get()
+		require.NoError(t, err)
+		require.Empty(t, stored)
+	})
+
+	t.Run("success - with write manifest", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "manifest")
+
+		s, err := NewManifestStore(path)
+		require.NoError(t, err)
+
+		manifest, err := txnhandler.NewPersistentWriteManifest(s)
+		require.NoError(t, err)
+		require.NoError(t, manifest.Prepared("anchor1", []string{"address1", "address2"}))
+
+		s, err = NewManifestStore(path)
+		require.NoError(t, err)
+
+		manifest, err = txnhandler.NewPersistentWriteManifest(s)
+		require.NoError(t, err)
+		require.Equal(t, []string{"anchor1"}, manifest.Batches())
+		require.ElementsMatch(t, []string{"address1", "address2"}, manifest.Addresses())
+	})
+
+	t.Run("error - invalid content", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "manifest")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), filePerm))
+
+		s, err := NewManifestStore(path)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "unmarshal manifest store")
+	})
+
+	t.Run("error - read", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewManifestStore(dir)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "read manifest store")
+	})
+
+	t.Run("error - write", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "manifest")
+
+		s, err := NewManifestStore(path)
+		require.NoError(t, err)
+
+		batches := map[string][]string{"anchor1": {"address1"}}
+		require.NoError(t, s.Put(batches))
+
+		// the temporary file can't be created if a directory with the same name exists
+		require.NoError(t, os.Mkdir(path+tempFileExt, dirPerm))
+
+		err = s.Put(map[string][]string{"anchor2": {"address2"}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create file")
+
+		stored, err := s.Get()
+		require.NoError(t, err)
+		require.Equal(t, batches, stored)
+	})
+}
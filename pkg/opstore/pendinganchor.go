@@ -0,0 +1,133 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/batch"
+)
+
+// PendingAnchorStore stores the anchors that were written by the batch writer but that weren't confirmed yet.
+// All of the pending anchors are kept in a single file which is replaced atomically whenever a pending anchor
+// is stored or deleted.
+type PendingAnchorStore struct {
+	mutex   sync.RWMutex
+	path    string
+	anchors map[string]map[string]*batch.PendingAnchor
+}
+
+// NewPendingAnchorStore opens (or creates) the pending-anchor store backed by the given file.
+func NewPendingAnchorStore(path string) (*PendingAnchorStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create pending-anchor store directory for [%s]", path)
+	}
+
+	anchors := make(map[string]map[string]*batch.PendingAnchor)
+
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "read pending-anchor store [%s]", path)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(content, &anchors); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal pending-anchor store [%s]", path)
+		}
+	}
+
+	return &PendingAnchorStore{
+		path:    path,
+		anchors: anchors,
+	}, nil
+}
+
+// Put stores (or replaces) the pending anchor. The pending anchor is synced to disk before Put returns.
+func (s *PendingAnchorStore) Put(anchor *batch.PendingAnchor) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	anchors := s.copy(anchor.Namespace)
+	anchors[anchor.Namespace][anchor.AnchorString] = anchor
+
+	return s.save(anchors)
+}
+
+// List returns the pending anchors of the given namespace ordered by the time at which they were written
+func (s *PendingAnchorStore) List(namespace string) ([]*batch.PendingAnchor, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	anchors := make([]*batch.PendingAnchor, 0, len(s.anchors[namespace]))
+	for _, anchor := range s.anchors[namespace] {
+		anchors = append(anchors, anchor)
+	}
+
+	sort.Slice(anchors, func(i, j int) bool {
+		return anchors[i].Written.Before(anchors[j].Written)
+	})
+
+	return anchors, nil
+}
+
+// Delete deletes the pending anchor for the given namespace and anchor string
+func (s *PendingAnchorStore) Delete(namespace, anchorString string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.anchors[namespace][anchorString]; !ok {
+		return nil
+	}
+
+	anchors := s.copy(namespace)
+	delete(anchors[namespace], anchorString)
+
+	if len(anchors[namespace]) == 0 {
+		delete(anchors, namespace)
+	}
+
+	return s.save(anchors)
+}
+
+// copy returns a copy of the pending anchors (the pending anchors of the given namespace are copied as well)
+func (s *PendingAnchorStore) copy(namespace string) map[string]map[string]*batch.PendingAnchor {
+	anchors := make(map[string]map[string]*batch.PendingAnchor, len(s.anchors)+1)
+	for ns, nsAnchors := range s.anchors {
+		anchors[ns] = nsAnchors
+	}
+
+	nsAnchors := make(map[string]*batch.PendingAnchor, len(s.anchors[namespace])+1)
+	for anchorString, anchor := range s.anchors[namespace] {
+		nsAnchors[anchorString] = anchor
+	}
+
+	anchors[namespace] = nsAnchors
+
+	return anchors
+}
+
+func (s *PendingAnchorStore) save(anchors map[string]map[string]*batch.PendingAnchor) error {
+	content, err := json.Marshal(anchors)
+	if err != nil {
+		return errors.Wrap(err, "marshal pending anchors")
+	}
+
+	if err := writeFileAtomic(s.path, content); err != nil {
+		return err
+	}
+
+	s.anchors = anchors
+
+	return nil
+}
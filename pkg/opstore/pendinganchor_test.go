@@ -0,0 +1,145 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	batchapi "github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/batch"
+)
+
+var _ batch.PendingAnchorStore = (*PendingAnchorStore)(nil)
+
+func TestPendingAnchorStore(t *testing.T) {
+	const otherNamespace = "did:other"
+
+	written := time.Unix(1600000000, 0).UTC()
+
+	a1 := &batch.PendingAnchor{
+		Namespace:         namespace,
+		AnchorString:      "1.anchor1",
+		Operations:        []*batchapi.OperationInfo{{Namespace: namespace, UniqueSuffix: "suffix1", Data: []byte("op1")}},
+		TransactionNumber: 10,
+		Written:           written.Add(time.Minute),
+	}
+
+	a2 := &batch.PendingAnchor{
+		Namespace:         namespace,
+		AnchorString:      "1.anchor2",
+		Operations:        []*batchapi.OperationInfo{{Namespace: namespace, UniqueSuffix: "suffix2", Data: []byte("op2")}},
+		TransactionNumber: 5,
+		Written:           written,
+		Resubmissions:     1,
+	}
+
+	a3 := &batch.PendingAnchor{
+		Namespace:    otherNamespace,
+		AnchorString: "1.anchor1",
+		Written:      written,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "pendinganchors")
+
+		s, err := NewPendingAnchorStore(path)
+		require.NoError(t, err)
+
+		anchors, err := s.List(namespace)
+		require.NoError(t, err)
+		require.Empty(t, anchors)
+
+		require.NoError(t, s.Put(a1))
+		require.NoError(t, s.Put(a2))
+		require.NoError(t, s.Put(a3))
+
+		s, err = NewPendingAnchorStore(path)
+		require.NoError(t, err)
+
+		anchors, err = s.List(namespace)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.PendingAnchor{a2, a1}, anchors)
+
+		anchors, err = s.List(otherNamespace)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.PendingAnchor{a3}, anchors)
+
+		require.NoError(t, s.Delete(namespace, a2.AnchorString))
+		require.NoError(t, s.Delete(namespace, a2.AnchorString))
+		require.NoError(t, s.Delete(otherNamespace, a3.AnchorString))
+
+		s, err = NewPendingAnchorStore(path)
+		require.NoError(t, err)
+
+		anchors, err = s.List(namespace)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.PendingAnchor{a1}, anchors)
+
+		anchors, err = s.List(otherNamespace)
+		require.NoError(t, err)
+		require.Empty(t, anchors)
+	})
+
+	t.Run("error - invalid content", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "pendinganchors")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), filePerm))
+
+		s, err := NewPendingAnchorStore(path)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "unmarshal pending-anchor store")
+	})
+
+	t.Run("error - read", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewPendingAnchorStore(dir)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "read pending-anchor store")
+	})
+
+	t.Run("error - write", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "pendinganchors")
+
+		s, err := NewPendingAnchorStore(path)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(a1))
+
+		// the temporary file can't be created if a directory with the same name exists
+		require.NoError(t, os.Mkdir(path+tempFileExt, dirPerm))
+
+		err = s.Put(a2)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create file")
+
+		err = s.Delete(namespace, a1.AnchorString)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create file")
+
+		anchors, err := s.List(namespace)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.PendingAnchor{a1}, anchors)
+	})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/observer"
+)
+
+const storeFileExt = ".ops"
+
+// Provider provides an operation store per namespace. The stores are kept in the given directory.
+type Provider struct {
+	mutex  sync.Mutex
+	dir    string
+	opts   []Option
+	stores map[string]*Store
+}
+
+// NewProvider returns a new operation store provider. The given options are applied to each of the stores.
+func NewProvider(dir string, opts ...Option) *Provider {
+	return &Provider{
+		dir:    dir,
+		opts:   opts,
+		stores: make(map[string]*Store),
+	}
+}
+
+// ForNamespace returns the operation store for the given namespace
+func (p *Provider) ForNamespace(namespace string) (observer.OperationStore, error) {
+	s, err := p.Get(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get returns the operation store for the given namespace. The store is opened (or created) on first access.
+func (p *Provider) Get(namespace string) (*Store, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	s, ok := p.stores[namespace]
+	if ok {
+		return s, nil
+	}
+
+	// namespace may contain characters that are not allowed in file names
+	s, err := New(filepath.Join(p.dir, docutil.EncodeToString([]byte(namespace))+storeFileExt), p.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	p.stores[namespace] = s
+
+	return s, nil
+}
+
+// Close closes all of the operation stores
+func (p *Provider) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var err error
+
+	for namespace, s := range p.stores {
+		if e := s.Close(); e != nil {
+			logger.Warnf("Failed to close operation store for namespace [%s]: %s", namespace, e)
+			err = e
+		}
+
+		delete(p.stores, namespace)
+	}
+
+	return err
+}
@@ -0,0 +1,85 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+)
+
+func TestProvider(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		p := NewProvider(dir)
+
+		s1, err := p.ForNamespace(namespace)
+		require.NoError(t, err)
+		require.NotNil(t, s1)
+
+		s2, err := p.Get(namespace)
+		require.NoError(t, err)
+		require.True(t, s1 == s2)
+
+		s3, err := p.Get("did:other")
+		require.NoError(t, err)
+		require.False(t, s2 == s3)
+
+		op := newOperation(suffix1, 1, 1, 0)
+		require.NoError(t, s1.Put([]*batch.Operation{op}))
+
+		_, err = s3.Get(suffix1)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "not found")
+
+		require.NoError(t, p.Close())
+
+		err = s2.Put([]*batch.Operation{op})
+		require.EqualError(t, err, "operation store is closed")
+
+		// stores are re-opened after close
+		s4, err := p.Get(namespace)
+		require.NoError(t, err)
+		defer closeStore(t, s4)
+
+		requireOps(t, s4, suffix1, op)
+	})
+
+	t.Run("success - store options", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		p := NewProvider(dir, WithLockTimeout(time.Second))
+		defer func() {
+			require.NoError(t, p.Close())
+		}()
+
+		s, err := p.Get(namespace)
+		require.NoError(t, err)
+		require.Equal(t, time.Second, s.lockTimeout)
+	})
+
+	t.Run("error - invalid directory", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		file := filepath.Join(dir, "file")
+		require.NoError(t, ioutil.WriteFile(file, []byte("file"), filePerm))
+
+		s, err := NewProvider(file).ForNamespace(namespace)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "create operation store directory")
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/processor"
+)
+
+const snapshotFileExt = ".snapshot"
+
+// SnapshotStore stores document snapshots for the operation processor. Each snapshot is kept in its own file
+// (in the given directory) which is replaced atomically whenever the snapshot is updated.
+type SnapshotStore struct {
+	// mutex serializes writes since concurrent writes of the same snapshot would use the same temp file
+	mutex sync.Mutex
+	dir   string
+}
+
+// NewSnapshotStore opens (or creates) the snapshot store backed by the given directory.
+func NewSnapshotStore(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create snapshot store directory [%s]", dir)
+	}
+
+	return &SnapshotStore{dir: dir}, nil
+}
+
+// Get returns the snapshot for the given unique suffix
+func (s *SnapshotStore) Get(uniqueSuffix string) (*processor.Snapshot, error) {
+	path := s.path(uniqueSuffix)
+
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errs.New(errs.NotFound, "snapshot not found for unique suffix: %s", uniqueSuffix)
+		}
+
+		return nil, errors.Wrapf(err, "read snapshot [%s]", path)
+	}
+
+	snapshot := &processor.Snapshot{}
+	if err := json.Unmarshal(content, snapshot); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal snapshot [%s]", path)
+	}
+
+	return snapshot, nil
+}
+
+// Put stores (or replaces) the snapshot for the given unique suffix. The snapshot is synced to disk
+// before Put returns.
+func (s *SnapshotStore) Put(uniqueSuffix string, snapshot *processor.Snapshot) error {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "marshal snapshot")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return writeFileAtomic(s.path(uniqueSuffix), content)
+}
+
+// path returns the snapshot file for the given unique suffix. The suffix is encoded since it's
+// supplied by the client.
+func (s *SnapshotStore) path(uniqueSuffix string) string {
+	return filepath.Join(s.dir, docutil.EncodeToString([]byte(uniqueSuffix))+snapshotFileExt)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/document"
+	"github.com/trustbloc/sidetree-core-go/pkg/processor"
+)
+
+var _ processor.SnapshotStore = (*SnapshotStore)(nil)
+
+func TestSnapshotStore(t *testing.T) {
+	snapshot := &processor.Snapshot{
+		Document:           document.Document{"test": "value"},
+		UpdateCommitment:   "update",
+		RecoveryCommitment: "recovery",
+		TransactionTime:    20,
+		TransactionNumber:  2,
+		CreatedTime:        10,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewSnapshotStore(filepath.Join(dir, "snapshots"))
+		require.NoError(t, err)
+
+		result, err := s.Get("abc")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "not found")
+		require.Nil(t, result)
+
+		require.NoError(t, s.Put("abc", snapshot))
+
+		result, err = s.Get("abc")
+		require.NoError(t, err)
+		require.Equal(t, snapshot, result)
+
+		// the document isn't shared
+		result.Document["test"] = "modified"
+
+		s, err = NewSnapshotStore(filepath.Join(dir, "snapshots"))
+		require.NoError(t, err)
+
+		result, err = s.Get("abc")
+		require.NoError(t, err)
+		require.Equal(t, snapshot, result)
+
+		// the suffix is encoded
+		result, err = s.Get("../abc")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "not found")
+		require.Nil(t, result)
+	})
+
+	t.Run("error - create directory", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "snapshots")
+		require.NoError(t, ioutil.WriteFile(path, []byte{}, filePerm))
+
+		s, err := NewSnapshotStore(path)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "create snapshot store directory")
+	})
+
+	t.Run("error - invalid content", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewSnapshotStore(dir)
+		require.NoError(t, err)
+
+		require.NoError(t, ioutil.WriteFile(s.path("abc"), []byte("{"), filePerm))
+
+		result, err := s.Get("abc")
+		require.Error(t, err)
+		require.Nil(t, result)
+		require.Contains(t, err.Error(), "unmarshal snapshot")
+	})
+
+	t.Run("error - read", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewSnapshotStore(dir)
+		require.NoError(t, err)
+
+		require.NoError(t, os.Mkdir(s.path("abc"), dirPerm))
+
+		result, err := s.Get("abc")
+		require.Error(t, err)
+		require.Nil(t, result)
+		require.Contains(t, err.Error(), "read snapshot")
+	})
+
+	t.Run("error - write", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewSnapshotStore(dir)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("abc", snapshot))
+
+		// the temporary file can't be created if a directory with the same name exists
+		require.NoError(t, os.Mkdir(s.path("abc")+tempFileExt, dirPerm))
+
+		err = s.Put("abc", &processor.Snapshot{TransactionTime: 30})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create file")
+
+		result, err := s.Get("abc")
+		require.NoError(t, err)
+		require.Equal(t, snapshot, result)
+	})
+
+	t.Run("error - marshal", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewSnapshotStore(dir)
+		require.NoError(t, err)
+
+		err = s.Put("abc", &processor.Snapshot{Document: document.Document{"test": make(chan int)}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "marshal snapshot")
+	})
+}
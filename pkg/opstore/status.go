@@ -0,0 +1,418 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
+// statusOrder is the order of the operation statuses. The status of an operation may only move forward.
+var statusOrder = map[opstatus.Status]int{
+	opstatus.Queued:    0,
+	opstatus.Batched:   1,
+	opstatus.Anchored:  2,
+	opstatus.Failed:    2,
+	opstatus.Confirmed: 3,
+	opstatus.Rejected:  3,
+}
+
+const (
+	// DefaultStatusRetention is the default period for which the status of an operation is retained after its
+	// last update
+	DefaultStatusRetention = 24 * time.Hour
+
+	// DefaultMaxStatuses is the default maximum number of operation statuses in the store
+	DefaultMaxStatuses = 100000
+)
+
+// statusesBucket contains the persisted statuses keyed by operation ID
+var statusesBucket = []byte("statuses")
+
+type anchorKey struct {
+	anchorString string
+	uniqueSuffix string
+}
+
+type suffixKey struct {
+	namespace    string
+	uniqueSuffix string
+}
+
+// StatusStore keeps the status of operations in memory. A store that is created with NewStatusStore isn't durable,
+// i.e. the statuses are lost when the node is restarted (the status of an operation that was submitted before is
+// not found). A store that is created with NewPersistentStatusStore also persists the statuses to a bbolt database
+// (every update is synced to disk before Put returns) from which the statuses are loaded when the store is opened.
+// The store is meant for tracking the progress of recently submitted operations, so the status of an operation is
+// evicted once it wasn't updated for the retention period (see WithStatusRetention) or, if the store is full, when
+// the status of another operation is added (the least recently updated status is evicted first, see WithMaxStatuses).
+//
+// Since the status is updated by different components (document handler, batch writer and observer), possibly
+// out of order, updates that would move the status of an operation backwards are ignored. The only exceptions
+// are an operation that was rejected and is then submitted again and an operation that is batched again after
+// the anchor of its batch failed.
+type StatusStore struct {
+	mutex    sync.Mutex
+	statuses map[string]*list.Element
+	anchored map[anchorKey]string
+	// unobserved counts the anchored operations (by unique suffix) that weren't confirmed (or rejected) yet
+	unobserved map[suffixKey]int
+	// updates orders the statuses by the time of their last update (least recently updated first)
+	updates     *list.List
+	retention   time.Duration
+	maxStatuses int
+	now         func() time.Time
+	// db persists the statuses (nil if the store isn't persistent)
+	db *bolt.DB
+	// evicted are the IDs of the statuses that were removed from memory but not yet from the database
+	evicted []string
+	closed  bool
+}
+
+// statusEntry is an entry of the update list
+type statusEntry struct {
+	status  *opstatus.OperationStatus
+	updated time.Time
+}
+
+// persistedStatus is the persisted form of a status entry
+type persistedStatus struct {
+	Status  *opstatus.OperationStatus `json:"status"`
+	Updated time.Time                 `json:"updated"`
+}
+
+// StatusStoreOption is an option for the operation status store
+type StatusStoreOption func(s *StatusStore)
+
+// WithStatusRetention sets the period for which the status of an operation is retained after its last update
+func WithStatusRetention(retention time.Duration) StatusStoreOption {
+	return func(s *StatusStore) {
+		s.retention = retention
+	}
+}
+
+// WithMaxStatuses sets the maximum number of operation statuses in the store
+func WithMaxStatuses(maxStatuses int) StatusStoreOption {
+	return func(s *StatusStore) {
+		s.maxStatuses = maxStatuses
+	}
+}
+
+// NewStatusStore returns a new operation status store
+func NewStatusStore(opts ...StatusStoreOption) *StatusStore {
+	s := &StatusStore{
+		statuses:    make(map[string]*list.Element),
+		anchored:    make(map[anchorKey]string),
+		unobserved:  make(map[suffixKey]int),
+		updates:     list.New(),
+		retention:   DefaultStatusRetention,
+		maxStatuses: DefaultMaxStatuses,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// NewPersistentStatusStore opens (or creates) the operation status store that is persisted to the given file.
+// The statuses that were persisted before (and that weren't evicted in the meantime) are loaded into memory.
+func NewPersistentStatusStore(path string, opts ...StatusStoreOption) (*StatusStore, error) {
+	s := NewStatusStore(opts...)
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create status store directory for [%s]", path)
+	}
+
+	db, err := bolt.Open(filepath.Clean(path), filePerm, &bolt.Options{Timeout: DefaultLockTimeout})
+	if err != nil {
+		return nil, errors.Wrapf(err, "open status store [%s]", path)
+	}
+
+	s.db = db
+
+	if err := s.load(); err != nil {
+		if e := db.Close(); e != nil {
+			logger.Warnf("Failed to close status store [%s]: %s", path, e)
+		}
+
+		return nil, errors.WithMessagef(err, "load status store [%s]", path)
+	}
+
+	return s, nil
+}
+
+// Put stores the status of an operation
+func (s *StatusStore) Put(status *opstatus.OperationStatus) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return errors.New("status store is closed")
+	}
+
+	now := s.now()
+
+	s.evictExpired(now)
+
+	if elem, ok := s.statuses[status.ID]; ok {
+		current := elem.Value.(*statusEntry).status
+
+		if !isValidTransition(current.Status, status.Status) {
+			logger.Debugf("Ignoring status [%s] for operation [%s] since the current status is [%s]",
+				status.Status, status.ID, current.Status)
+
+			return nil
+		}
+
+		s.remove(elem)
+	}
+
+	st := *status
+	s.add(&statusEntry{status: &st, updated: now})
+	s.evictOverflow()
+
+	return s.persist(st.ID)
+}
+
+// Close closes the database of a persistent store
+func (s *StatusStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+
+	err := s.db.Close()
+	s.db = nil
+	s.closed = true
+
+	return err
+}
+
+// Get returns the status of the operation with the given ID
+func (s *StatusStore) Get(id string) (*opstatus.OperationStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.get(id)
+}
+
+// GetByAnchor returns the status of the operation for the given unique suffix that was batched
+// with the given anchor string
+func (s *StatusStore) GetByAnchor(anchorString, uniqueSuffix string) (*opstatus.OperationStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id, ok := s.anchored[anchorKey{anchorString, uniqueSuffix}]
+	if !ok {
+		return nil, errs.New(errs.NotFound, "status not found for unique suffix [%s] in anchor [%s]", uniqueSuffix, anchorString)
+	}
+
+	return s.get(id)
+}
+
+// IsAnchored returns true if an operation for the given unique suffix of the given namespace was anchored but the
+// observer hasn't processed its transaction yet (i.e. the operation is neither confirmed nor rejected)
+func (s *StatusStore) IsAnchored(namespace, uniqueSuffix string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.evictExpired(s.now())
+
+	return s.unobserved[suffixKey{namespace, uniqueSuffix}] > 0, nil
+}
+
+func (s *StatusStore) get(id string) (*opstatus.OperationStatus, error) {
+	s.evictExpired(s.now())
+
+	elem, ok := s.statuses[id]
+	if !ok {
+		return nil, errs.New(errs.NotFound, "status not found for operation: %s", id)
+	}
+
+	st := *elem.Value.(*statusEntry).status
+
+	return &st, nil
+}
+
+// add adds the given entry as the most recently updated status
+func (s *StatusStore) add(entry *statusEntry) {
+	st := entry.status
+
+	s.statuses[st.ID] = s.updates.PushBack(entry)
+
+	if st.AnchorString != "" {
+		s.anchored[anchorKey{st.AnchorString, st.UniqueSuffix}] = st.ID
+	}
+
+	if st.Status == opstatus.Anchored {
+		s.unobserved[suffixKey{st.Namespace, st.UniqueSuffix}]++
+	}
+}
+
+// evictOverflow evicts the least recently updated statuses if the store is full
+func (s *StatusStore) evictOverflow() {
+	for s.updates.Len() > s.maxStatuses {
+		evicted := s.updates.Front()
+
+		logger.Debugf("Evicting status of operation [%s] since the store is full", evicted.Value.(*statusEntry).status.ID)
+
+		s.remove(evicted)
+	}
+}
+
+// persist stores the status with the given ID (unless it was evicted already) and deletes the evicted statuses
+// from the database of a persistent store. The status is kept in memory even if it can't be persisted.
+func (s *StatusStore) persist(id string) error {
+	if s.db == nil {
+		return nil
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(statusesBucket)
+
+		for _, evicted := range s.evicted {
+			if err := b.Delete([]byte(evicted)); err != nil {
+				return errors.Wrapf(err, "delete status of operation [%s]", evicted)
+			}
+		}
+
+		elem, ok := s.statuses[id]
+		if !ok {
+			return nil
+		}
+
+		entry := elem.Value.(*statusEntry)
+
+		value, err := json.Marshal(&persistedStatus{Status: entry.status, Updated: entry.updated})
+		if err != nil {
+			return errors.Wrap(err, "marshal status")
+		}
+
+		return errors.Wrapf(b.Put([]byte(id), value), "store status of operation [%s]", id)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.evicted = nil
+
+	return nil
+}
+
+// load loads the persisted statuses in the order of their last update. The statuses that expired (or that don't
+// fit into the store) since they were persisted are evicted.
+func (s *StatusStore) load() error {
+	var entries []*statusEntry
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(statusesBucket)
+		if err != nil {
+			return errors.Wrap(err, "create statuses bucket")
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			persisted := &persistedStatus{}
+			if err := json.Unmarshal(v, persisted); err != nil {
+				return errors.Wrapf(err, "unmarshal status of operation [%s]", k)
+			}
+
+			entries = append(entries, &statusEntry{status: persisted.Status, updated: persisted.Updated})
+
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].updated.Before(entries[j].updated)
+	})
+
+	for _, entry := range entries {
+		s.add(entry)
+	}
+
+	s.evictExpired(s.now())
+	s.evictOverflow()
+
+	logger.Infof("Loaded %d operation statuses (%d evicted)", s.updates.Len(), len(s.evicted))
+
+	return s.persist("")
+}
+
+// evictExpired evicts the statuses that weren't updated for the retention period
+func (s *StatusStore) evictExpired(now time.Time) {
+	for elem := s.updates.Front(); elem != nil; elem = s.updates.Front() {
+		entry := elem.Value.(*statusEntry)
+		if now.Sub(entry.updated) < s.retention {
+			return
+		}
+
+		logger.Debugf("Evicting status of operation [%s] since it wasn't updated since %s", entry.status.ID, entry.updated)
+
+		s.remove(elem)
+	}
+}
+
+// remove removes the status of the given element from the store
+func (s *StatusStore) remove(elem *list.Element) {
+	status := s.updates.Remove(elem).(*statusEntry).status
+
+	delete(s.statuses, status.ID)
+
+	if s.db != nil {
+		s.evicted = append(s.evicted, status.ID)
+	}
+
+	key := anchorKey{status.AnchorString, status.UniqueSuffix}
+	if s.anchored[key] == status.ID {
+		delete(s.anchored, key)
+	}
+
+	if status.Status == opstatus.Anchored {
+		sk := suffixKey{status.Namespace, status.UniqueSuffix}
+
+		if s.unobserved[sk]--; s.unobserved[sk] <= 0 {
+			delete(s.unobserved, sk)
+		}
+	}
+}
+
+func isValidTransition(current, next opstatus.Status) bool {
+	if current == opstatus.Rejected {
+		// the operation was submitted again
+		return next == opstatus.Queued
+	}
+
+	if current == opstatus.Failed {
+		// the operation is batched again
+		return next != opstatus.Queued
+	}
+
+	if current == opstatus.Confirmed {
+		return false
+	}
+
+	return statusOrder[next] >= statusOrder[current]
+}
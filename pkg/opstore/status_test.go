@@ -0,0 +1,354 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
+var _ opstatus.Store = (*StatusStore)(nil)
+
+func TestStatusStore(t *testing.T) {
+	const (
+		id     = "op1"
+		suffix = "suffix1"
+	)
+
+	t.Run("success", func(t *testing.T) {
+		s := NewStatusStore()
+
+		status, err := s.Get(id)
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+		require.Nil(t, status)
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, UniqueSuffix: suffix, Status: opstatus.Queued}))
+
+		status, err = s.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Queued, status.Status)
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, UniqueSuffix: suffix, Status: opstatus.Batched, AnchorString: "anchor1"}))
+
+		status, err = s.GetByAnchor("anchor1", suffix)
+		require.NoError(t, err)
+		require.Equal(t, id, status.ID)
+		require.Equal(t, opstatus.Batched, status.Status)
+
+		// the batch is cut again (e.g. after a ledger error) so the anchor string changes
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, UniqueSuffix: suffix, Status: opstatus.Batched, AnchorString: "anchor2"}))
+
+		_, err = s.GetByAnchor("anchor1", suffix)
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, UniqueSuffix: suffix, Status: opstatus.Confirmed, AnchorString: "anchor2", TransactionTime: 10}))
+
+		status, err = s.GetByAnchor("anchor2", suffix)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Confirmed, status.Status)
+		require.Equal(t, uint64(10), status.TransactionTime)
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		s := NewStatusStore()
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Confirmed, AnchorString: "anchor1"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Anchored, AnchorString: "anchor1"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Rejected, Reason: "invalid"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Queued}))
+
+		status, err := s.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Confirmed, status.Status)
+	})
+
+	t.Run("submitted again after rejection", func(t *testing.T) {
+		s := NewStatusStore()
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Rejected, Reason: "invalid"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Anchored}))
+
+		status, err := s.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Rejected, status.Status)
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Queued}))
+
+		status, err = s.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Queued, status.Status)
+		require.Empty(t, status.Reason)
+	})
+
+	t.Run("batched again after failure", func(t *testing.T) {
+		s := NewStatusStore()
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Anchored, AnchorString: "anchor1"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Failed, AnchorString: "anchor1", Reason: "not confirmed"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Queued}))
+
+		status, err := s.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Failed, status.Status)
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Batched, AnchorString: "anchor2"}))
+
+		status, err = s.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Batched, status.Status)
+		require.Equal(t, "anchor2", status.AnchorString)
+		require.Empty(t, status.Reason)
+	})
+
+	t.Run("anchored but not observed", func(t *testing.T) {
+		s := NewStatusStore()
+
+		anchored, err := s.IsAnchored(namespace, suffix)
+		require.NoError(t, err)
+		require.False(t, anchored)
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Namespace: namespace, UniqueSuffix: suffix, Status: opstatus.Batched, AnchorString: "anchor1"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Namespace: namespace, UniqueSuffix: suffix, Status: opstatus.Anchored, AnchorString: "anchor1"}))
+
+		anchored, err = s.IsAnchored(namespace, suffix)
+		require.NoError(t, err)
+		require.True(t, anchored)
+
+		anchored, err = s.IsAnchored("did:other", suffix)
+		require.NoError(t, err)
+		require.False(t, anchored)
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Namespace: namespace, UniqueSuffix: suffix, Status: opstatus.Confirmed, AnchorString: "anchor1"}))
+
+		anchored, err = s.IsAnchored(namespace, suffix)
+		require.NoError(t, err)
+		require.False(t, anchored)
+		require.Empty(t, s.unobserved)
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		s := NewStatusStore(WithStatusRetention(time.Hour))
+
+		now := time.Now()
+		s.now = func() time.Time { return now }
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, UniqueSuffix: suffix, Status: opstatus.Queued}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op2", UniqueSuffix: "suffix2", Status: opstatus.Queued}))
+
+		now = now.Add(30 * time.Minute)
+
+		// the status of an operation is retained after its last update
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, UniqueSuffix: suffix, Status: opstatus.Batched, AnchorString: "anchor1"}))
+
+		now = now.Add(30 * time.Minute)
+
+		_, err := s.Get("op2")
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+
+		status, err := s.GetByAnchor("anchor1", suffix)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Batched, status.Status)
+
+		now = now.Add(30 * time.Minute)
+
+		_, err = s.GetByAnchor("anchor1", suffix)
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+
+		_, err = s.Get(id)
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+		require.Empty(t, s.anchored)
+	})
+
+	t.Run("maximum number of statuses", func(t *testing.T) {
+		s := NewStatusStore(WithMaxStatuses(2))
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op1", UniqueSuffix: "suffix1", Status: opstatus.Batched, AnchorString: "anchor1"}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op2", UniqueSuffix: "suffix2", Status: opstatus.Queued}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op1", UniqueSuffix: "suffix1", Status: opstatus.Anchored, AnchorString: "anchor1"}))
+
+		// the least recently updated status is evicted
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op3", UniqueSuffix: "suffix3", Status: opstatus.Queued}))
+
+		_, err := s.Get("op2")
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op4", UniqueSuffix: "suffix4", Status: opstatus.Queued}))
+
+		_, err = s.GetByAnchor("anchor1", "suffix1")
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+
+		for _, opID := range []string{"op3", "op4"} {
+			_, err = s.Get(opID)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("returns a copy", func(t *testing.T) {
+		s := NewStatusStore()
+
+		st := &opstatus.OperationStatus{ID: id, Status: opstatus.Queued}
+		require.NoError(t, s.Put(st))
+
+		st.Status = opstatus.Confirmed
+
+		status, err := s.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Queued, status.Status)
+	})
+}
+
+func TestPersistentStatusStore(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "statuses")
+
+		s, err := NewPersistentStatusStore(path)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op1", Namespace: namespace, UniqueSuffix: "suffix1", Status: opstatus.Queued}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op2", Namespace: namespace, UniqueSuffix: "suffix2", Status: opstatus.Queued}))
+		require.NoError(t, s.Put(&opstatus.OperationStatus{ID: "op2", Namespace: namespace, UniqueSuffix: "suffix2", Status: opstatus.Anchored, AnchorString: "anchor1"}))
+		require.NoError(t, s.Close())
+		require.NoError(t, s.Close())
+
+		err = s.Put(&opstatus.OperationStatus{ID: "op3", Status: opstatus.Queued})
+		require.EqualError(t, err, "status store is closed")
+
+		s, err = NewPersistentStatusStore(path)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, s.Close())
+		}()
+
+		status, err := s.Get("op1")
+		require.NoError(t, err)
+		require.Equal(t, opstatus.Queued, status.Status)
+
+		status, err = s.GetByAnchor("anchor1", "suffix2")
+		require.NoError(t, err)
+		require.Equal(t, "op2", status.ID)
+		require.Equal(t, opstatus.Anchored, status.Status)
+
+		anchored, err := s.IsAnchored(namespace, "suffix2")
+		require.NoError(t, err)
+		require.True(t, anchored)
+
+		// the order of the updates is restored, i.e. op1 is the least recently updated status
+		require.Equal(t, "op1", s.updates.Front().Value.(*statusEntry).status.ID)
+
+		_, err = s.Get("op3")
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+	})
+
+	t.Run("evicted statuses are deleted", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "statuses")
+
+		s, err := NewPersistentStatusStore(path, WithMaxStatuses(2))
+		require.NoError(t, err)
+
+		for _, id := range []string{"op1", "op2", "op3"} {
+			require.NoError(t, s.Put(&opstatus.OperationStatus{ID: id, Status: opstatus.Queued}))
+		}
+
+		require.NoError(t, s.Close())
+
+		// the statuses that don't fit into the store are evicted when the store is loaded
+		s, err = NewPersistentStatusStore(path, WithMaxStatuses(1))
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+
+		s, err = NewPersistentStatusStore(path)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, s.Close())
+		}()
+
+		for _, id := range []string{"op1", "op2"} {
+			_, err = s.Get(id)
+			require.True(t, errors.Is(err, errs.ErrNotFound))
+		}
+
+		_, err = s.Get("op3")
+		require.NoError(t, err)
+	})
+
+	t.Run("error - invalid content", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "statuses")
+
+		db, err := bolt.Open(path, filePerm, nil)
+		require.NoError(t, err)
+		require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket(statusesBucket)
+			require.NoError(t, err)
+
+			return b.Put([]byte("op1"), []byte("{"))
+		}))
+		require.NoError(t, db.Close())
+
+		s, err := NewPersistentStatusStore(path)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "unmarshal status of operation [op1]")
+	})
+
+	t.Run("error - open", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewPersistentStatusStore(dir)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "open status store")
+
+		file := filepath.Join(dir, "file")
+		require.NoError(t, ioutil.WriteFile(file, []byte("file"), filePerm))
+
+		s, err = NewPersistentStatusStore(filepath.Join(file, "statuses"))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "create status store directory")
+	})
+
+	t.Run("error - persist", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := NewPersistentStatusStore(filepath.Join(dir, "statuses"))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, s.Close())
+		}()
+
+		// the database was closed (e.g. the file was closed by the OS)
+		require.NoError(t, s.db.Close())
+
+		err = s.Put(&opstatus.OperationStatus{ID: "op1", Status: opstatus.Queued})
+		require.Error(t, err)
+
+		// the status is kept in memory
+		_, err = s.Get("op1")
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,332 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/edge-core/pkg/log"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
+var logger = log.New("sidetree-core-opstore")
+
+const (
+	dirPerm  = 0700
+	filePerm = 0600
+
+	// DefaultLockTimeout is the default time to wait for the lock on the database file of the operation store
+	DefaultLockTimeout = 5 * time.Second
+
+	// positionLen is the length of the key of an operation (transaction time, transaction number and operation index)
+	positionLen = 24
+)
+
+var (
+	// operationsBucket contains a bucket per unique suffix. The operations of a unique suffix are keyed by position.
+	operationsBucket = []byte("operations")
+	// positionsBucket indexes the operations by position (followed by the unique suffix) across all unique suffixes
+	positionsBucket = []byte("positions")
+)
+
+// Store is an embedded operation store backed by a bbolt database (i.e. a B+tree in a single file). Each unique
+// suffix has its own bucket in which the operations are keyed by their position on the ledger (transaction time,
+// transaction number and operation index), so the operations of a unique suffix are read in order by a single
+// range scan and operations are not kept in memory. A second index (keyed by position and unique suffix) allows
+// the operations that were anchored at or after a transaction time to be rolled back without scanning all of the
+// unique suffixes. Every update (Put, Delete, Rollback) is a single transaction which is synced to disk before the
+// update returns.
+//
+// An operation is identified by its position on the ledger (transaction time, transaction number and
+// operation index), so storing an operation that is already in the store is a no-op. This allows
+// transactions to be re-processed (e.g. after a restart) without duplicating operations.
+type Store struct {
+	// mutex guards the database against being closed while it's used
+	mutex       sync.RWMutex
+	path        string
+	db          *bolt.DB
+	lockTimeout time.Duration
+}
+
+// Option is an option for the operation store
+type Option func(s *Store)
+
+// WithLockTimeout sets how long New waits for the lock on the database file, which is held while the database
+// is open (e.g. by another process)
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(s *Store) {
+		s.lockTimeout = timeout
+	}
+}
+
+// New opens (or creates) the operation store backed by the given file.
+func New(path string, opts ...Option) (*Store, error) {
+	s := &Store{
+		path:        path,
+		lockTimeout: DefaultLockTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "create operation store directory for [%s]", path)
+	}
+
+	db, err := bolt.Open(filepath.Clean(path), filePerm, &bolt.Options{Timeout: s.lockTimeout})
+	if err != nil {
+		return nil, errors.Wrapf(err, "open operation store [%s]", path)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{operationsBucket, positionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return errors.Wrapf(err, "create bucket [%s]", name)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if e := db.Close(); e != nil {
+			logger.Warnf("Failed to close operation store [%s]: %s", path, e)
+		}
+
+		return nil, errors.WithMessagef(err, "initialize operation store [%s]", path)
+	}
+
+	s.db = db
+
+	return s, nil
+}
+
+// Put stores the given operations. Operations that are already in the store are ignored.
+func (s *Store) Put(ops []*batch.Operation) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, op := range ops {
+			if err := put(tx, op); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Delete deletes the given operations from the store. Operations that are not in the store are ignored.
+func (s *Store) Delete(ops []*batch.Operation) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, op := range ops {
+			if err := remove(tx, op.UniqueSuffix, positionOf(op)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Rollback deletes all of the operations that were anchored at or after the given transaction time
+// (e.g. due to a ledger reorganization) and returns the unique suffixes of the deleted operations.
+func (s *Store) Rollback(transactionTime uint64) ([]string, error) {
+	var suffixes []string
+
+	err := s.update(func(tx *bolt.Tx) error {
+		var keys [][]byte
+
+		c := tx.Bucket(positionsBucket).Cursor()
+
+		// the keys are copied since they're only valid while the cursor isn't moved or modified
+		for k, _ := c.Seek(encodePosition(transactionTime, 0, 0)); k != nil; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+
+		rolledBack := make(map[string]bool)
+
+		for _, k := range keys {
+			suffix := string(k[positionLen:])
+
+			if err := remove(tx, suffix, k[:positionLen]); err != nil {
+				return err
+			}
+
+			rolledBack[suffix] = true
+		}
+
+		for suffix := range rolledBack {
+			suffixes = append(suffixes, suffix)
+		}
+
+		if len(keys) > 0 {
+			logger.Infof("Rolling back %d operations for %d suffixes from transaction time [%d] in store [%s]",
+				len(keys), len(suffixes), transactionTime, s.path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(suffixes)
+
+	return suffixes, nil
+}
+
+// Get returns all of the operations for the given unique suffix ordered by
+// transaction time, transaction number and operation index.
+func (s *Store) Get(uniqueSuffix string) ([]*batch.Operation, error) {
+	var ops []*batch.Operation
+
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(operationsBucket).Bucket([]byte(uniqueSuffix))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			op := &batch.Operation{}
+			if err := json.Unmarshal(v, op); err != nil {
+				return errors.Wrapf(err, "unmarshal operation for suffix [%s]", uniqueSuffix)
+			}
+
+			ops = append(ops, op)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ops) == 0 {
+		return nil, errs.New(errs.NotFound, "uniqueSuffix not found in the store: %s", uniqueSuffix)
+	}
+
+	return ops, nil
+}
+
+// Close closes the store. The store may be re-opened using New.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+
+	err := s.db.Close()
+	s.db = nil
+
+	return err
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.db == nil {
+		return errors.New("operation store is closed")
+	}
+
+	return s.db.Update(fn)
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.db == nil {
+		return errors.New("operation store is closed")
+	}
+
+	return s.db.View(fn)
+}
+
+// put stores the operation unless an operation at the same position is already stored for the unique suffix
+func put(tx *bolt.Tx, op *batch.Operation) error {
+	b, err := tx.Bucket(operationsBucket).CreateBucketIfNotExists([]byte(op.UniqueSuffix))
+	if err != nil {
+		return errors.Wrapf(err, "create bucket for suffix [%s]", op.UniqueSuffix)
+	}
+
+	pos := positionOf(op)
+
+	if b.Get(pos) != nil {
+		logger.Debugf("Ignoring duplicate operation for suffix [%s] at time [%d], number [%d], index [%d]",
+			op.UniqueSuffix, op.TransactionTime, op.TransactionNumber, op.OperationIndex)
+
+		return nil
+	}
+
+	value, err := json.Marshal(op)
+	if err != nil {
+		return errors.Wrap(err, "marshal operation")
+	}
+
+	if err := b.Put(pos, value); err != nil {
+		return errors.Wrapf(err, "store operation for suffix [%s]", op.UniqueSuffix)
+	}
+
+	return errors.Wrap(tx.Bucket(positionsBucket).Put(indexKey(pos, op.UniqueSuffix), []byte{}), "index operation")
+}
+
+// remove deletes the operation at the given position for the unique suffix (if any). The bucket of the unique
+// suffix is deleted once the last operation of the unique suffix is deleted.
+func remove(tx *bolt.Tx, uniqueSuffix string, pos []byte) error {
+	operations := tx.Bucket(operationsBucket)
+
+	b := operations.Bucket([]byte(uniqueSuffix))
+	if b == nil || b.Get(pos) == nil {
+		return nil
+	}
+
+	if err := b.Delete(pos); err != nil {
+		return errors.Wrapf(err, "delete operation for suffix [%s]", uniqueSuffix)
+	}
+
+	if err := tx.Bucket(positionsBucket).Delete(indexKey(pos, uniqueSuffix)); err != nil {
+		return errors.Wrap(err, "delete operation from index")
+	}
+
+	if k, _ := b.Cursor().First(); k != nil {
+		return nil
+	}
+
+	return errors.Wrapf(operations.DeleteBucket([]byte(uniqueSuffix)), "delete bucket for suffix [%s]", uniqueSuffix)
+}
+
+// positionOf returns the key of the operation. Keys are big-endian so that operations are ordered by position.
+func positionOf(op *batch.Operation) []byte {
+	return encodePosition(op.TransactionTime, op.TransactionNumber, uint64(op.OperationIndex))
+}
+
+func encodePosition(txnTime, txnNumber, opIndex uint64) []byte {
+	pos := make([]byte, positionLen)
+
+	binary.BigEndian.PutUint64(pos, txnTime)
+	binary.BigEndian.PutUint64(pos[8:], txnNumber)
+	binary.BigEndian.PutUint64(pos[16:], opIndex)
+
+	return pos
+}
+
+// indexKey returns the key of the operation at the given position for the given unique suffix in the positions index
+func indexKey(pos []byte, uniqueSuffix string) []byte {
+	return append(append(make([]byte, 0, len(pos)+len(uniqueSuffix)), pos...), uniqueSuffix...)
+}
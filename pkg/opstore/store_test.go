@@ -0,0 +1,408 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+	"github.com/trustbloc/sidetree-core-go/pkg/observer"
+	"github.com/trustbloc/sidetree-core-go/pkg/operation"
+	"github.com/trustbloc/sidetree-core-go/pkg/processor"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/helper"
+)
+
+const (
+	namespace = "did:sidetree"
+	suffix1   = "suffix1"
+	suffix2   = "suffix2"
+
+	validDoc = `{"publicKey": [{"id": "key1", "type": "JwsVerificationKey2020", "purpose": ["ops", "general"],
+		"jwk": {"kty": "EC", "crv": "P-256K", "x": "PUymIqdtF_qxaAqPABSw-C-owT1KYYQbsMKFM-L9fJA",
+		"y": "nM84jDHCMOTGTh_ZdHq4dBBdo4Z5PkEOW9jA8z8IsGc"}}]}`
+
+	sha2_256 = 18
+)
+
+var (
+	_ processor.OperationStoreClient  = (*Store)(nil)
+	_ observer.RollbackOperationStore = (*Store)(nil)
+	_ observer.OperationStoreProvider = (*Provider)(nil)
+)
+
+func TestStore(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	s, err := New(filepath.Join(dir, "ops"))
+	require.NoError(t, err)
+	defer closeStore(t, s)
+
+	ops, err := s.Get(suffix1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+	require.Nil(t, ops)
+
+	op1 := newOperation(suffix1, 1, 1, 0)
+	op2 := newOperation(suffix1, 1, 1, 1)
+	op3 := newOperation(suffix1, 2, 0, 0)
+	op4 := newOperation(suffix1, 2, 2, 0)
+	op5 := newOperation(suffix2, 1, 1, 2)
+
+	require.NoError(t, s.Put([]*batch.Operation{op4, op2, op5}))
+	require.NoError(t, s.Put([]*batch.Operation{op3, op1}))
+
+	ops, err = s.Get(suffix1)
+	require.NoError(t, err)
+	require.Equal(t, []*batch.Operation{op1, op2, op3, op4}, ops)
+
+	ops, err = s.Get(suffix2)
+	require.NoError(t, err)
+	require.Equal(t, []*batch.Operation{op5}, ops)
+
+	t.Run("returned operations are a copy", func(t *testing.T) {
+		ops, err := s.Get(suffix1)
+		require.NoError(t, err)
+
+		ops[0] = op5
+		_ = append(ops[:1], op5)
+
+		ops, err = s.Get(suffix1)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.Operation{op1, op2, op3, op4}, ops)
+	})
+
+	t.Run("duplicate operations", func(t *testing.T) {
+		require.NoError(t, s.Put([]*batch.Operation{op1, op3}))
+
+		// the operation that was stored first is kept
+		duplicate := newOperation(suffix1, 2, 2, 0)
+		duplicate.OperationBuffer = []byte("duplicate")
+		require.NoError(t, s.Put([]*batch.Operation{duplicate}))
+
+		ops, err := s.Get(suffix1)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.Operation{op1, op2, op3, op4}, ops)
+
+		op6 := newOperation(suffix1, 3, 0, 0)
+		require.NoError(t, s.Put([]*batch.Operation{op6, op6, newOperation(suffix1, 3, 0, 0)}))
+
+		ops, err = s.Get(suffix1)
+		require.NoError(t, err)
+		require.Equal(t, []*batch.Operation{op1, op2, op3, op4, op6}, ops)
+	})
+}
+
+func TestStore_Reopen(t *testing.T) {
+	op1 := newOperation(suffix1, 1, 1, 0)
+	op2 := newOperation(suffix1, 2, 1, 1)
+	op3 := newOperation(suffix2, 2, 1, 2)
+
+	t.Run("success", func(t *testing.T) {
+		path, cleanup := newStoreWithOps(t, op2, op3, op1)
+		defer cleanup()
+
+		s, err := New(path)
+		require.NoError(t, err)
+
+		requireOps(t, s, suffix1, op1, op2)
+		requireOps(t, s, suffix2, op3)
+
+		// operations that were stored before the store was re-opened are still deduplicated
+		require.NoError(t, s.Put([]*batch.Operation{op1, op2, op3}))
+		require.NoError(t, s.Close())
+
+		s, err = New(path)
+		require.NoError(t, err)
+		defer closeStore(t, s)
+
+		requireOps(t, s, suffix1, op1, op2)
+		requireOps(t, s, suffix2, op3)
+	})
+
+	t.Run("error - invalid database file", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		path := filepath.Join(dir, "ops")
+		require.NoError(t, ioutil.WriteFile(path, bytes.Repeat([]byte("x"), 2*os.Getpagesize()), filePerm))
+
+		s, err := New(path)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "open operation store")
+	})
+
+	t.Run("error - database file is locked", func(t *testing.T) {
+		path, cleanup := newStoreWithOps(t, op1)
+		defer cleanup()
+
+		s, err := New(path)
+		require.NoError(t, err)
+		defer closeStore(t, s)
+
+		s2, err := New(path, WithLockTimeout(10*time.Millisecond))
+		require.Error(t, err)
+		require.Nil(t, s2)
+		require.Contains(t, err.Error(), "timeout")
+	})
+
+	t.Run("error - invalid path", func(t *testing.T) {
+		dir, cleanup := newTestDir(t)
+		defer cleanup()
+
+		s, err := New(dir)
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "open operation store")
+	})
+}
+
+func TestStore_Delete(t *testing.T) {
+	op1 := newOperation(suffix1, 1, 1, 0)
+	op2 := newOperation(suffix1, 2, 1, 1)
+	op3 := newOperation(suffix2, 2, 1, 2)
+
+	path, cleanup := newStoreWithOps(t, op1, op2, op3)
+	defer cleanup()
+
+	s, err := New(path)
+	require.NoError(t, err)
+
+	// operations that are not in the store are ignored
+	require.NoError(t, s.Delete([]*batch.Operation{newOperation(suffix1, 3, 0, 0)}))
+
+	require.NoError(t, s.Delete([]*batch.Operation{newOperation(suffix1, 2, 1, 1), op3}))
+
+	requireOps(t, s, suffix1, op1)
+
+	_, err = s.Get(suffix2)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+
+	// the deleted operations were removed from the index
+	suffixes, err := s.Rollback(2)
+	require.NoError(t, err)
+	require.Empty(t, suffixes)
+
+	require.NoError(t, s.Close())
+
+	s, err = New(path)
+	require.NoError(t, err)
+	defer closeStore(t, s)
+
+	requireOps(t, s, suffix1, op1)
+
+	_, err = s.Get(suffix2)
+	require.Error(t, err)
+
+	// deleted operation may be stored again
+	require.NoError(t, s.Put([]*batch.Operation{op3}))
+	requireOps(t, s, suffix2, op3)
+}
+
+func TestStore_Rollback(t *testing.T) {
+	op1 := newOperation(suffix1, 1, 1, 0)
+	op2 := newOperation(suffix1, 2, 1, 1)
+	op3 := newOperation(suffix1, 3, 0, 0)
+	op4 := newOperation(suffix2, 2, 2, 0)
+	op5 := newOperation("suffix3", 1, 2, 0)
+
+	path, cleanup := newStoreWithOps(t, op1, op2, op3, op4, op5)
+	defer cleanup()
+
+	s, err := New(path)
+	require.NoError(t, err)
+
+	suffixes, err := s.Rollback(4)
+	require.NoError(t, err)
+	require.Empty(t, suffixes)
+
+	suffixes, err = s.Rollback(2)
+	require.NoError(t, err)
+	require.Equal(t, []string{suffix1, suffix2}, suffixes)
+
+	// the rolled back operations were removed from the index
+	suffixes, err = s.Rollback(2)
+	require.NoError(t, err)
+	require.Empty(t, suffixes)
+
+	requireOps(t, s, suffix1, op1)
+	requireOps(t, s, "suffix3", op5)
+
+	_, err = s.Get(suffix2)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+
+	require.NoError(t, s.Close())
+
+	s, err = New(path)
+	require.NoError(t, err)
+	defer closeStore(t, s)
+
+	requireOps(t, s, suffix1, op1)
+	requireOps(t, s, "suffix3", op5)
+
+	_, err = s.Get(suffix2)
+	require.Error(t, err)
+}
+
+func TestStore_Close(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	s, err := New(filepath.Join(dir, "ops"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+
+	err = s.Put([]*batch.Operation{newOperation(suffix1, 1, 1, 0)})
+	require.EqualError(t, err, "operation store is closed")
+
+	err = s.Delete([]*batch.Operation{newOperation(suffix1, 1, 1, 0)})
+	require.EqualError(t, err, "operation store is closed")
+
+	suffixes, err := s.Rollback(1)
+	require.EqualError(t, err, "operation store is closed")
+	require.Empty(t, suffixes)
+
+	ops, err := s.Get(suffix1)
+	require.EqualError(t, err, "operation store is closed")
+	require.Empty(t, ops)
+}
+
+func TestStore_ObserverAndProcessor(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+
+	pc := mocks.NewMockProtocolClient()
+
+	createOp := newCreateOperation(t, pc)
+
+	provider := NewProvider(dir)
+
+	txnProcessor := observer.NewTxnProcessor(&observer.Providers{
+		TxnOpsProvider:   &mockTxnOpsProvider{ops: []*batch.Operation{createOp}},
+		OpStoreProvider:  provider,
+		OpFilterProvider: &observer.NoopOperationFilterProvider{},
+	})
+
+	sidetreeTxn := txn.SidetreeTxn{Namespace: namespace, TransactionTime: 10, TransactionNumber: 2}
+
+	require.NoError(t, txnProcessor.Process(sidetreeTxn))
+
+	// process the same transaction again (e.g. after restart)
+	require.NoError(t, txnProcessor.Process(sidetreeTxn))
+	require.NoError(t, provider.Close())
+
+	s, err := NewProvider(dir).Get(namespace)
+	require.NoError(t, err)
+	defer closeStore(t, s)
+
+	ops, err := s.Get(createOp.UniqueSuffix)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	require.Equal(t, uint64(10), ops[0].TransactionTime)
+	require.Equal(t, uint64(2), ops[0].TransactionNumber)
+
+	result, err := processor.New("test", s, pc).Resolve(createOp.UniqueSuffix)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	require.Len(t, result.Document.PublicKeys(), 1)
+}
+
+func newOperation(uniqueSuffix string, txnTime, txnNumber uint64, index uint) *batch.Operation {
+	return &batch.Operation{
+		Type:              batch.OperationTypeUpdate,
+		Namespace:         namespace,
+		ID:                namespace + docutil.NamespaceDelimiter + uniqueSuffix,
+		UniqueSuffix:      uniqueSuffix,
+		OperationBuffer:   []byte(uniqueSuffix),
+		TransactionTime:   txnTime,
+		TransactionNumber: txnNumber,
+		OperationIndex:    index,
+	}
+}
+
+func newCreateOperation(t *testing.T, pc *mocks.MockProtocolClient) *batch.Operation {
+	recoveryCommitment, err := docutil.ComputeMultihash(sha2_256, []byte("recoveryCommitment"))
+	require.NoError(t, err)
+
+	updateCommitment, err := docutil.ComputeMultihash(sha2_256, []byte("updateCommitment"))
+	require.NoError(t, err)
+
+	request, err := helper.NewCreateRequest(&helper.CreateRequestInfo{
+		OpaqueDocument:     validDoc,
+		RecoveryCommitment: docutil.EncodeToString(recoveryCommitment),
+		UpdateCommitment:   docutil.EncodeToString(updateCommitment),
+		MultihashCode:      sha2_256,
+	})
+	require.NoError(t, err)
+
+	op, err := operation.ParseCreateOperation(request, pc.Current())
+	require.NoError(t, err)
+
+	op.Namespace = namespace
+	op.ID = namespace + docutil.NamespaceDelimiter + op.UniqueSuffix
+
+	return op
+}
+
+func newTestDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "opstore")
+	require.NoError(t, err)
+
+	return dir, func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func newStoreWithOps(t *testing.T, ops ...*batch.Operation) (string, func()) {
+	dir, cleanup := newTestDir(t)
+
+	path := filepath.Join(dir, "ops")
+
+	s, err := New(path)
+	require.NoError(t, err)
+
+	for _, op := range ops {
+		require.NoError(t, s.Put([]*batch.Operation{op}))
+	}
+
+	require.NoError(t, s.Close())
+
+	return path, cleanup
+}
+
+func closeStore(t *testing.T, s *Store) {
+	require.NoError(t, s.Close())
+}
+
+func requireOps(t *testing.T, s *Store, uniqueSuffix string, expected ...*batch.Operation) {
+	ops, err := s.Get(uniqueSuffix)
+	require.NoError(t, err)
+	require.Equal(t, expected, ops)
+}
+
+type mockTxnOpsProvider struct {
+	ops []*batch.Operation
+}
+
+func (m *mockTxnOpsProvider) GetTxnOperations(*txn.SidetreeTxn) ([]*batch.Operation, error) {
+	return m.ops, nil
+}
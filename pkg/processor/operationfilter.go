@@ -7,12 +7,12 @@ SPDX-License-Identifier: Apache-2.0
 package processor
 
 import (
-	"strings"
-
-	"github.com/pkg/errors"
+	"errors"
+	"fmt"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
 // OperationValidationFilter filters out invalid operations.
@@ -29,21 +29,73 @@ func NewOperationFilter(name string, store OperationStoreClient, pc protocol.Cli
 
 // Filter filters out the invalid operations and returns only the valid ones
 func (s *OperationValidationFilter) Filter(uniqueSuffix string, newOps []*batch.Operation) ([]*batch.Operation, error) {
-	logger.Debugf("[%s] Validating operations for unique suffix [%s]...", s.name, uniqueSuffix)
+	validOps, _, err := s.FilterWithRejections(uniqueSuffix, newOps)
 
-	newOps = s.filterInvalidSuffix(uniqueSuffix, newOps)
+	return validOps, err
+}
+
+// FilterWithRejections filters out the invalid operations and returns the valid ones as well as the rejected ones
+// along with the reason why they were rejected
+func (s *OperationValidationFilter) FilterWithRejections(uniqueSuffix string, newOps []*batch.Operation) ([]*batch.Operation, []*batch.RejectedOperation, error) {
+	logger.Debugf("[%s] Validating operations for unique suffix [%s]...", s.name, uniqueSuffix)
 
 	ops, err := s.store.Get(uniqueSuffix)
 	if err != nil {
-		if !strings.Contains(err.Error(), "not found") {
-			return nil, err
+		if !errors.Is(err, errs.ErrNotFound) {
+			return nil, nil, err
 		}
 
 		logger.Debugf("[%s] Unique suffix not found in the store [%s]", s.name, uniqueSuffix)
 	}
 
+	// operations may be filtered again if a transaction is re-processed (e.g. after restart) or if the same anchor
+	// was included in the ledger more than once (e.g. an anchor that was written again by the batch writer)
+	newOps = s.filterStoredOperations(ops, newOps)
+
 	// Combine the existing (persistet) operations with the new operations
-	ops = append(ops, newOps...)
+	validOps, rejected, err := s.validate(uniqueSuffix, append(ops, newOps...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var validNewOps []*batch.Operation
+	for _, op := range validOps {
+		if contains(newOps, op) {
+			validNewOps = append(validNewOps, op)
+		}
+	}
+
+	var rejectedNewOps []*batch.RejectedOperation
+	for _, op := range newOps {
+		if reason, ok := rejected[op]; ok {
+			rejectedNewOps = append(rejectedNewOps, &batch.RejectedOperation{Operation: op, Reason: reason})
+		}
+	}
+
+	return validNewOps, rejectedNewOps, nil
+}
+
+// Validate validates the given operations (regardless of the operations in the store) and returns only the valid ones.
+// The given operations must include the create operation.
+func (s *OperationValidationFilter) Validate(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, error) {
+	validOps, _, err := s.validate(uniqueSuffix, ops)
+
+	return validOps, err
+}
+
+// rejections contains the reason why each of the rejected operations was rejected
+type rejections map[*batch.Operation]string
+
+func (r rejections) add(ops []*batch.Operation, reason string) {
+	for _, op := range ops {
+		r[op] = reason
+	}
+}
+
+func (s *OperationValidationFilter) validate(uniqueSuffix string, ops []*batch.Operation) ([]*batch.Operation, rejections, error) {
+	rejected := make(rejections)
+
+	ops = s.filterInvalidSuffix(uniqueSuffix, ops, rejected)
 
 	// Sort the operations by transaction time/number
 	sortOperations(ops)
@@ -53,36 +105,39 @@ func (s *OperationValidationFilter) Filter(uniqueSuffix string, newOps []*batch.
 	// split operations info 'full' and 'update' operations
 	createOps, updateOps, fullOps := splitOperations(ops)
 	if len(createOps) == 0 {
-		return nil, errors.New("missing create operation")
+		return nil, nil, errors.New("missing create operation")
 	}
 
 	// apply 'full' operations first
-	validFullOps, rm := s.getValidOperations(append(createOps, fullOps...), &resolutionModel{})
+	validFullOps, rm := s.getValidOperations(append(createOps, fullOps...), &resolutionModel{}, rejected)
 
-	var validUpdateOps []*batch.Operation
 	if rm.Doc == nil {
 		logger.Debugf("[%s] Document was deactivated [%s]", s.name, uniqueSuffix)
-	} else {
-		// next apply update ops since last 'full' transaction
-		validUpdateOps, _ = s.getValidOperations(getOpsWithTxnGreaterThan(updateOps, rm.LastOperationTransactionTime, rm.LastOperationTransactionNumber), rm)
-	}
 
-	var validNewOps []*batch.Operation
-	for _, op := range append(validFullOps, validUpdateOps...) {
-		if contains(newOps, op) {
-			validNewOps = append(validNewOps, op)
-		}
+		rejected.add(updateOps, "document was deactivated")
+
+		return validFullOps, rejected, nil
 	}
 
-	return validNewOps, nil
+	// next apply update ops since last 'full' transaction
+	pendingUpdateOps := getOpsWithTxnGreaterThan(updateOps, rm.LastOperationTransactionTime, rm.LastOperationTransactionNumber)
+
+	rejected.add(updateOps[:len(updateOps)-len(pendingUpdateOps)], "operation was anchored before the last create or recover operation")
+
+	validUpdateOps, _ := s.getValidOperations(pendingUpdateOps, rm, rejected)
+
+	return append(validFullOps, validUpdateOps...), rejected, nil
 }
 
-func (s *OperationValidationFilter) getValidOperations(ops []*batch.Operation, rm *resolutionModel) ([]*batch.Operation, *resolutionModel) {
+func (s *OperationValidationFilter) getValidOperations(ops []*batch.Operation, rm *resolutionModel, rejected rejections) ([]*batch.Operation, *resolutionModel) {
 	var validOps []*batch.Operation
 	for _, op := range ops {
 		m, err := s.applyOperation(op, rm)
 		if err != nil {
 			logger.Infof("[%s] Rejecting invalid operation {ID: %s, UniqueSuffix: %s, Type: %s, TransactionTime: %d, TransactionNumber: %d}. Reason: %s", s.name, op.ID, op.UniqueSuffix, op.Type, op.TransactionTime, op.TransactionNumber, err)
+
+			rejected[op] = err.Error()
+
 			continue
 		}
 
@@ -95,11 +150,37 @@ func (s *OperationValidationFilter) getValidOperations(ops []*batch.Operation, r
 	return validOps, rm
 }
 
-func (s *OperationValidationFilter) filterInvalidSuffix(uniqueSuffix string, ops []*batch.Operation) []*batch.Operation {
+func (s *OperationValidationFilter) filterInvalidSuffix(uniqueSuffix string, ops []*batch.Operation, rejected rejections) []*batch.Operation {
 	var filtered []*batch.Operation
 	for _, op := range ops {
 		if op.UniqueSuffix != uniqueSuffix {
 			logger.Infof("[%s] Rejecting invalid operation {ID: %s, UniqueSuffix: %s Type: %s, TransactionTime: %d, TransactionNumber: %d}. Reason: operation's unique suffix is not set to [%s]", s.name, op.ID, op.UniqueSuffix, op.Type, op.TransactionTime, op.TransactionNumber, uniqueSuffix)
+
+			rejected[op] = fmt.Sprintf("operation's unique suffix is not set to [%s]", uniqueSuffix)
+
+			continue
+		}
+
+		filtered = append(filtered, op)
+	}
+
+	return filtered
+}
+
+// filterStoredOperations filters out the operations that are already stored, i.e. operations at the position of
+// a stored operation and operations with the same operation request as a stored operation. (An operation request
+// can only be applied once, so an operation that is anchored again is ignored rather than rejected, which would
+// override the status of the operation that was applied since the status is looked up by anchor string.)
+func (s *OperationValidationFilter) filterStoredOperations(storedOps, ops []*batch.Operation) []*batch.Operation {
+	var filtered []*batch.Operation
+	for _, op := range ops {
+		if containsPosition(storedOps, op) {
+			logger.Debugf("[%s] Ignoring operation that is already stored {ID: %s, UniqueSuffix: %s Type: %s, TransactionTime: %d, TransactionNumber: %d}", s.name, op.ID, op.UniqueSuffix, op.Type, op.TransactionTime, op.TransactionNumber)
+			continue
+		}
+
+		if containsRequest(storedOps, op) {
+			logger.Infof("[%s] Ignoring operation that was already anchored by another transaction {ID: %s, UniqueSuffix: %s Type: %s, TransactionTime: %d, TransactionNumber: %d}", s.name, op.ID, op.UniqueSuffix, op.Type, op.TransactionTime, op.TransactionNumber)
 			continue
 		}
 
@@ -109,6 +190,36 @@ func (s *OperationValidationFilter) filterInvalidSuffix(uniqueSuffix string, ops
 	return filtered
 }
 
+// containsPosition returns true if the given operations contain an operation at the same position on the ledger
+func containsPosition(ops []*batch.Operation, op *batch.Operation) bool {
+	for _, o := range ops {
+		if o.TransactionTime == op.TransactionTime &&
+			o.TransactionNumber == op.TransactionNumber &&
+			o.OperationIndex == op.OperationIndex {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsRequest returns true if the given operations contain an operation with the same operation request, i.e.
+// the same type, signed data, delta and suffix data (the operation buffer isn't set for the operations that are
+// read from the batch files)
+func containsRequest(ops []*batch.Operation, op *batch.Operation) bool {
+	for _, o := range ops {
+		if o.Type == op.Type &&
+			o.UniqueSuffix == op.UniqueSuffix &&
+			o.SignedData == op.SignedData &&
+			o.EncodedDelta == op.EncodedDelta &&
+			o.EncodedSuffixData == op.EncodedSuffixData {
+			return true
+		}
+	}
+
+	return false
+}
+
 func contains(ops []*batch.Operation, op *batch.Operation) bool {
 	for _, o := range ops {
 		if o == op {
@@ -86,7 +86,7 @@ func TestOperationFilter_Filter(t *testing.T) {
 
 		createOp1, err := getCreateOperation(recoveryKey, updateKey)
 		require.NoError(t, err)
-		err = store.Put(createOp1)
+		err = store.Put([]*batch.Operation{createOp1})
 		require.Nil(t, err)
 
 		createOp2, err := getCreateOperation(recoveryKey, updateKey)
@@ -113,7 +113,7 @@ func TestOperationFilter_Filter(t *testing.T) {
 		createOp1, err := getCreateOperation(recoveryKey, updateKey)
 		require.NoError(t, err)
 
-		err = store.Put(createOp1)
+		err = store.Put([]*batch.Operation{createOp1})
 		require.Nil(t, err)
 
 		createOp2, err := getCreateOperation(recoveryKey, updateKey)
@@ -130,4 +130,176 @@ func TestOperationFilter_Filter(t *testing.T) {
 		require.Len(t, validOps, 1)
 		require.True(t, validOps[0] == deactivateOp)
 	})
+	t.Run("Operations already in store", func(t *testing.T) {
+		store := mocks.NewMockOperationStore(nil)
+		store.Validate = false
+
+		createOp, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+		updateOp, _, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 1)
+		require.NoError(t, err)
+
+		require.NoError(t, store.Put([]*batch.Operation{createOp}))
+		require.NoError(t, store.Put([]*batch.Operation{updateOp}))
+
+		createOp2, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+		updateOp2, _, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 1)
+		require.NoError(t, err)
+
+		// The same operations are filtered again (e.g. transaction is re-processed after restart)
+		filter := NewOperationFilter("test", store, pc)
+		validOps, err := filter.Filter(createOp.UniqueSuffix, []*batch.Operation{createOp2, updateOp2})
+		require.NoError(t, err)
+		require.Empty(t, validOps)
+	})
+}
+
+func TestOperationFilter_FilterWithRejections(t *testing.T) {
+	recoveryKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	updateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pc := mocks.NewMockProtocolClient()
+
+	t.Run("success", func(t *testing.T) {
+		store := mocks.NewMockOperationStore(nil)
+		store.Validate = false
+
+		createOp, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{createOp}))
+
+		updateOp1, _, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 1)
+		require.NoError(t, err)
+		updateOp2, _, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 3)
+		require.NoError(t, err)
+		updateOp3, _, err := getUpdateOperation(updateKey, "123456", 2)
+		require.NoError(t, err)
+
+		filter := NewOperationFilter("test", store, pc)
+		validOps, rejectedOps, err := filter.FilterWithRejections(createOp.UniqueSuffix, []*batch.Operation{updateOp1, updateOp2, updateOp3})
+		require.NoError(t, err)
+		require.Len(t, validOps, 1)
+		require.True(t, validOps[0] == updateOp1)
+
+		require.Len(t, rejectedOps, 2)
+		require.True(t, rejectedOps[0].Operation == updateOp2)
+		require.NotEmpty(t, rejectedOps[0].Reason)
+		require.True(t, rejectedOps[1].Operation == updateOp3)
+		require.Contains(t, rejectedOps[1].Reason, "operation's unique suffix is not set to")
+	})
+
+	t.Run("With deactivate operation", func(t *testing.T) {
+		store := mocks.NewMockOperationStore(nil)
+		store.Validate = false
+
+		createOp, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{createOp}))
+
+		deactivateOp, err := getDeactivateOperation(recoveryKey, createOp.UniqueSuffix, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{deactivateOp}))
+
+		updateOp, _, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 2)
+		require.NoError(t, err)
+
+		filter := NewOperationFilter("test", store, pc)
+		validOps, rejectedOps, err := filter.FilterWithRejections(createOp.UniqueSuffix, []*batch.Operation{updateOp})
+		require.NoError(t, err)
+		require.Empty(t, validOps)
+		require.Len(t, rejectedOps, 1)
+		require.True(t, rejectedOps[0].Operation == updateOp)
+		require.Equal(t, "document was deactivated", rejectedOps[0].Reason)
+	})
+
+	t.Run("Operations already in store", func(t *testing.T) {
+		store := mocks.NewMockOperationStore(nil)
+		store.Validate = false
+
+		createOp, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{createOp}))
+
+		createOp2, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+
+		// operations that were already stored are neither valid nor rejected
+		filter := NewOperationFilter("test", store, pc)
+		validOps, rejectedOps, err := filter.FilterWithRejections(createOp.UniqueSuffix, []*batch.Operation{createOp2})
+		require.NoError(t, err)
+		require.Empty(t, validOps)
+		require.Empty(t, rejectedOps)
+	})
+
+	t.Run("Operations anchored again", func(t *testing.T) {
+		store := mocks.NewMockOperationStore(nil)
+		store.Validate = false
+
+		createOp, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+
+		updateOp, nextUpdateKey, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{createOp, updateOp}))
+
+		// the same anchor was included in the ledger again (at a later position)
+		updateOpCopy := *updateOp
+		updateOpCopy.TransactionNumber += 10
+
+		updateOp2, _, err := getUpdateOperation(nextUpdateKey, createOp.UniqueSuffix, 2)
+		require.NoError(t, err)
+
+		filter := NewOperationFilter("test", store, pc)
+		validOps, rejectedOps, err := filter.FilterWithRejections(createOp.UniqueSuffix, []*batch.Operation{&updateOpCopy, updateOp2})
+		require.NoError(t, err)
+		require.Equal(t, []*batch.Operation{updateOp2}, validOps)
+		require.Empty(t, rejectedOps)
+	})
+}
+
+func TestOperationFilter_Validate(t *testing.T) {
+	recoveryKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	updateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pc := mocks.NewMockProtocolClient()
+
+	createOp, err := getCreateOperation(recoveryKey, updateKey)
+	require.NoError(t, err)
+	updateOp1, _, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 1)
+	require.NoError(t, err)
+	updateOp2, _, err := getUpdateOperation(updateKey, createOp.UniqueSuffix, 3)
+	require.NoError(t, err)
+	updateOp3, _, err := getUpdateOperation(updateKey, "123456", 2)
+	require.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		store := mocks.NewMockOperationStore(nil)
+
+		// operations in the store are not considered
+		require.NoError(t, store.Put([]*batch.Operation{createOp}))
+		require.NoError(t, store.Put([]*batch.Operation{updateOp1}))
+
+		// The second update should be discarded because same update key has been used and
+		// the third update should be discarded since it's for a different suffix
+		filter := NewOperationFilter("test", store, pc)
+		validOps, err := filter.Validate(createOp.UniqueSuffix, []*batch.Operation{updateOp2, updateOp3, updateOp1, createOp})
+		require.NoError(t, err)
+		require.Len(t, validOps, 2)
+		require.True(t, validOps[0] == createOp)
+		require.True(t, validOps[1] == updateOp1)
+	})
+
+	t.Run("No create operation error", func(t *testing.T) {
+		filter := NewOperationFilter("test", mocks.NewMockOperationStore(nil), pc)
+		validOps, err := filter.Validate(createOp.UniqueSuffix, []*batch.Operation{updateOp1})
+		require.EqualError(t, err, "missing create operation")
+		require.Empty(t, validOps)
+	})
 }
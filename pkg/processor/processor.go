@@ -20,6 +20,7 @@ import (
 	"github.com/trustbloc/sidetree-core-go/pkg/composer"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	internal "github.com/trustbloc/sidetree-core-go/pkg/internal/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 )
@@ -32,6 +33,11 @@ type OperationProcessor struct {
 	name  string
 	store OperationStoreClient
 	pc    protocol.Client
+
+	snapshots                SnapshotStore
+	snapshotSigner           SnapshotSigner
+	consistencyCheckInterval uint64
+	resolutions              uint64
 }
 
 // OperationStoreClient defines interface for retrieving all operations related to document
@@ -41,14 +47,112 @@ type OperationStoreClient interface {
 }
 
 // New returns new operation processor with the given name. (Note that name is only used for logging.)
-func New(name string, store OperationStoreClient, pc protocol.Client) *OperationProcessor {
-	return &OperationProcessor{name: name, store: store, pc: pc}
+func New(name string, store OperationStoreClient, pc protocol.Client, opts ...Option) *OperationProcessor {
+	s := &OperationProcessor{
+		name:                     name,
+		store:                    store,
+		pc:                       pc,
+		consistencyCheckInterval: defaultConsistencyCheckInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // Resolve document based on the given unique suffix
 // Parameters:
 // uniqueSuffix - unique portion of ID to resolve. for example "abc123" in "did:sidetree:abc123"
-func (s *OperationProcessor) Resolve(uniqueSuffix string) (*document.ResolutionResult, error) {
+// opts - optional version of the document to resolve (the latest version is resolved by default)
+func (s *OperationProcessor) Resolve(uniqueSuffix string, opts ...document.ResolutionOption) (*document.ResolutionResult, error) {
+	resolutionOpts := document.GetResolutionOptions(opts...)
+
+	ops, err := s.getOperations(uniqueSuffix, resolutionOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rm *resolutionModel
+	if resolutionOpts.IsHistorical() {
+		rm, err = s.replay(ops)
+	} else {
+		rm, err = s.resolveLatest(uniqueSuffix, ops)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc := rm.Doc
+	if rm.Deactivated {
+		// the document of a deactivated DID is empty
+		doc = make(document.Document)
+	}
+
+	return &document.ResolutionResult{
+		Document: doc,
+		DocumentMetadata: document.DocumentMetadata{
+			Created:        rm.CreatedTime,
+			Updated:        rm.LastOperationTransactionTime,
+			VersionID:      rm.versionID().String(),
+			OperationCount: countAppliedOperations(ops),
+			Deactivated:    rm.Deactivated,
+		},
+		MethodMetadata: document.MethodMetadata{
+			RecoveryCommitment: rm.RecoveryCommitment,
+			UpdateCommitment:   rm.UpdateCommitment,
+		},
+	}, nil
+}
+
+// ValidateOperation validates the given operation, which hasn't been anchored yet, against the latest state of the
+// document. The same checks (i.e. commitment, delta hash and signature) are performed as when the operation is applied,
+// however, the operation is validated with the current protocol version.
+func (s *OperationProcessor) ValidateOperation(operation *batch.Operation) error {
+	if operation.Type == batch.OperationTypeCreate {
+		// the create operation doesn't depend on the state of the document
+		return nil
+	}
+
+	ops, err := s.getOperations(operation.UniqueSuffix, document.ResolutionOptions{})
+	if err != nil {
+		return err
+	}
+
+	rm, err := s.resolveLatest(operation.UniqueSuffix, ops)
+	if err != nil {
+		return err
+	}
+
+	p := s.pc.Current()
+
+	switch operation.Type {
+	case batch.OperationTypeUpdate:
+		return s.verifyUpdateOperation(operation, rm, p)
+	case batch.OperationTypeDeactivate:
+		return s.verifyDeactivateOperation(operation, rm, p)
+	case batch.OperationTypeRecover:
+		_, err = s.verifyRecoverOperation(operation, rm, p)
+		return err
+	default:
+		return errs.New(errs.UnsupportedOperation, "operation type [%s] not supported", operation.Type)
+	}
+}
+
+// resolveLatest resolves the latest version of the document from the given (sorted) operations
+func (s *OperationProcessor) resolveLatest(uniqueSuffix string, ops []*batch.Operation) (*resolutionModel, error) {
+	// snapshots contain the latest version of the document
+	if s.snapshots != nil {
+		return s.resolveFromSnapshot(uniqueSuffix, ops)
+	}
+
+	return s.replay(ops)
+}
+
+// getOperations returns the sorted operations for the given suffix up to the requested version
+func (s *OperationProcessor) getOperations(uniqueSuffix string, opts document.ResolutionOptions) ([]*batch.Operation, error) {
 	ops, err := s.store.Get(uniqueSuffix)
 	if err != nil {
 		return nil, err
@@ -58,8 +162,28 @@ func (s *OperationProcessor) Resolve(uniqueSuffix string) (*document.ResolutionR
 
 	logger.Debugf("[%s] Found %d operations for unique suffix [%s]: %+v", s.name, len(ops), uniqueSuffix, ops)
 
-	rm := &resolutionModel{}
+	if opts.VersionTime != nil {
+		ops = getOpsWithTxnTimeLessThanOrEqualTo(ops, *opts.VersionTime)
+
+		if len(ops) == 0 {
+			return nil, errs.New(errs.NotFound, "unique suffix [%s] not found at version time [%d]", uniqueSuffix, *opts.VersionTime)
+		}
+	}
+
+	if opts.VersionID != nil {
+		ops = getOpsUpToVersion(ops, *opts.VersionID)
+
+		if len(ops) == 0 {
+			return nil, errs.New(errs.NotFound, "version [%s] not found for unique suffix [%s]", opts.VersionID, uniqueSuffix)
+		}
+	}
+
+	return ops, nil
+}
 
+// replay applies all of the given operations starting with the create operation
+// pre-condition: operations have to be sorted
+func (s *OperationProcessor) replay(ops []*batch.Operation) (*resolutionModel, error) {
 	// split operations into 'create', 'update' and 'full' operations
 	createOps, updateOps, fullOps := splitOperations(ops)
 	if len(createOps) == 0 {
@@ -67,34 +191,28 @@ func (s *OperationProcessor) Resolve(uniqueSuffix string) (*document.ResolutionR
 	}
 
 	// apply 'create' operations first
-	rm, err = s.applyCreateOperations(createOps, rm)
+	rm, err := s.applyCreateOperations(createOps, &resolutionModel{})
 	if err != nil {
 		return nil, err
 	}
 
+	return s.applyFullAndUpdateOperations(fullOps, updateOps, rm)
+}
+
+func (s *OperationProcessor) applyFullAndUpdateOperations(fullOps, updateOps []*batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
 	// apply 'full' operations first
-	rm, err = s.applyOperations(fullOps, rm)
+	rm, err := s.applyOperations(fullOps, rm)
 	if err != nil {
 		return nil, err
 	}
 
-	if rm.Doc == nil {
-		return nil, errors.New("document was deactivated")
+	// update operations are not applied after the document was deactivated
+	if rm.Deactivated {
+		return rm, nil
 	}
 
 	// next apply update ops since last 'full' transaction
-	rm, err = s.applyOperations(getOpsWithTxnGreaterThan(updateOps, rm.LastOperationTransactionTime, rm.LastOperationTransactionNumber), rm)
-	if err != nil {
-		return nil, err
-	}
-
-	return &document.ResolutionResult{
-		Document: rm.Doc,
-		MethodMetadata: document.MethodMetadata{
-			RecoveryCommitment: rm.RecoveryCommitment,
-			UpdateCommitment:   rm.UpdateCommitment,
-		},
-	}, nil
+	return s.applyOperations(getOpsWithTxnGreaterThan(updateOps, rm.LastOperationTransactionTime, rm.LastOperationTransactionNumber), rm)
 }
 
 func splitOperations(ops []*batch.Operation) (createOps, updateOps, fullOps []*batch.Operation) {
@@ -133,14 +251,54 @@ func getOpsWithTxnGreaterThan(ops []*batch.Operation, txnTime, txnNumber uint64)
 	return nil
 }
 
-func (s *OperationProcessor) applyOperations(ops []*batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
-	var err error
+// countAppliedOperations returns the number of operations that are applied when the document is resolved from the
+// given operations, i.e. the create operation, the 'full' operations and the update operations after the last 'full' operation.
+// pre-condition: operations have to be sorted
+func countAppliedOperations(ops []*batch.Operation) int {
+	_, updateOps, fullOps := splitOperations(ops)
+	if len(fullOps) == 0 {
+		return 1 + len(updateOps)
+	}
 
+	lastFullOp := fullOps[len(fullOps)-1]
+
+	return 1 + len(fullOps) + len(getOpsWithTxnGreaterThan(updateOps, lastFullOp.TransactionTime, lastFullOp.TransactionNumber))
+}
+
+// pre-condition: operations have to be sorted
+func getOpsWithTxnTimeLessThanOrEqualTo(ops []*batch.Operation, txnTime uint64) []*batch.Operation {
+	for index, op := range ops {
+		if op.TransactionTime > txnTime {
+			return ops[:index]
+		}
+	}
+
+	return ops
+}
+
+// getOpsUpToVersion returns the operations up to (and including) the operation with the given version ID, i.e. the
+// operation with the given transaction number and operation index. If there's no such operation then nil is returned.
+// pre-condition: operations have to be sorted
+func getOpsUpToVersion(ops []*batch.Operation, versionID document.VersionID) []*batch.Operation {
+	for index, op := range ops {
+		if op.TransactionNumber == versionID.TransactionNumber && op.OperationIndex == versionID.OperationIndex {
+			return ops[:index+1]
+		}
+	}
+
+	return nil
+}
+
+func (s *OperationProcessor) applyOperations(ops []*batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
 	for _, op := range ops {
-		if rm, err = s.applyOperation(op, rm); err != nil {
+		next, err := s.applyOperation(op, rm)
+		if err != nil {
 			return nil, err
 		}
 
+		next.CreatedTime = rm.CreatedTime
+		rm = next
+
 		logger.Debugf("[%s] After applying op %+v, New doc: %s", s.name, op, rm.Doc)
 	}
 
@@ -156,6 +314,8 @@ func (s *OperationProcessor) applyCreateOperations(ops []*batch.Operation, rm *r
 			continue
 		}
 
+		rm.CreatedTime = op.TransactionTime
+
 		logger.Debugf("[%s] After applying op %+v, New doc: %s", s.name, op, rm.Doc)
 		return rm, nil
 	}
@@ -167,8 +327,19 @@ type resolutionModel struct {
 	Doc                            document.Document
 	LastOperationTransactionTime   uint64
 	LastOperationTransactionNumber uint64
+	LastOperationIndex             uint
 	UpdateCommitment               string
 	RecoveryCommitment             string
+	CreatedTime                    uint64
+	Deactivated                    bool
+}
+
+// versionID returns the version ID of the last operation that was applied
+func (rm *resolutionModel) versionID() document.VersionID {
+	return document.VersionID{
+		TransactionNumber: rm.LastOperationTransactionNumber,
+		OperationIndex:    rm.LastOperationIndex,
+	}
 }
 
 func (s *OperationProcessor) applyOperation(operation *batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
@@ -208,127 +379,200 @@ func (s *OperationProcessor) applyCreateOperation(operation *batch.Operation, rm
 		Doc:                            doc,
 		LastOperationTransactionTime:   operation.TransactionTime,
 		LastOperationTransactionNumber: operation.TransactionNumber,
+		LastOperationIndex:             operation.OperationIndex,
 		UpdateCommitment:               operation.Delta.UpdateCommitment,
 		RecoveryCommitment:             operation.SuffixData.RecoveryCommitment,
 	}, nil
 }
 
-func (s *OperationProcessor) applyUpdateOperation(operation *batch.Operation, rm *resolutionModel) (*resolutionModel, error) { //nolint:dupl
+func (s *OperationProcessor) applyUpdateOperation(operation *batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
 	logger.Debugf("[%s] Applying update operation: %+v", s.name, operation)
 
+	p, err := s.pc.Get(operation.TransactionTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyUpdateOperation(operation, rm, p); err != nil {
+		return nil, err
+	}
+
+	doc, err := composer.ApplyPatches(rm.Doc, operation.Delta.Patches)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resolutionModel{
+		Doc:                            doc,
+		LastOperationTransactionTime:   operation.TransactionTime,
+		LastOperationTransactionNumber: operation.TransactionNumber,
+		LastOperationIndex:             operation.OperationIndex,
+		UpdateCommitment:               operation.Delta.UpdateCommitment,
+		RecoveryCommitment:             rm.RecoveryCommitment}, nil
+}
+
+// verifyUpdateOperation checks whether the update operation may be applied to the given state of the document
+// with the given protocol version, i.e. it verifies the update commitment, the delta hash and the signature
+func (s *OperationProcessor) verifyUpdateOperation(operation *batch.Operation, rm *resolutionModel, p protocol.Protocol) error { //nolint:dupl
+	if rm.Deactivated {
+		return errs.New(errs.Deactivated, "update cannot be applied to a deactivated document")
+	}
+
 	if rm.Doc == nil {
-		return nil, errors.New("update cannot be first operation")
+		return errors.New("update cannot be first operation")
 	}
 
 	jwsParts, err := parseSignedData(operation.SignedData)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	var signedDataModel model.UpdateSignedDataModel
 	err = json.Unmarshal(jwsParts.Payload, &signedDataModel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal signed data model while applying update: %s", err.Error())
+		return fmt.Errorf("failed to unmarshal signed data model while applying update: %s", err.Error())
 	}
 
-	// TODO: protocol should be calculated based on transaction number
-	p := s.pc.Current()
-
 	updateCommitment, err := commitment.Calculate(signedDataModel.UpdateKey, p.HashAlgorithmInMultiHashCode)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// verify that update commitments match
 	if updateCommitment != rm.UpdateCommitment {
-		return nil, fmt.Errorf("commitment generated from update key doesn't match update commitment: [%s][%s]", updateCommitment, rm.UpdateCommitment)
+		return errs.New(errs.CommitmentMismatch, "commitment generated from update key doesn't match update commitment: [%s][%s]", updateCommitment, rm.UpdateCommitment)
 	}
 
 	// verify the delta against the signed delta hash
 	err = docutil.IsValidHash(operation.EncodedDelta, signedDataModel.DeltaHash)
 	if err != nil {
-		return nil, fmt.Errorf("update delta doesn't match delta hash: %s", err.Error())
+		return fmt.Errorf("update delta doesn't match delta hash: %s", err.Error())
 	}
 
 	// verify signature
 	_, err = internal.VerifyJWS(operation.SignedData, signedDataModel.UpdateKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check signature: %s", err.Error())
+		return errs.Wrap(errs.InvalidSignature, err, "failed to check signature")
 	}
 
-	doc, err := composer.ApplyPatches(rm.Doc, operation.Delta.Patches)
-	if err != nil {
-		return nil, err
-	}
-
-	return &resolutionModel{
-		Doc:                            doc,
-		LastOperationTransactionTime:   operation.TransactionTime,
-		LastOperationTransactionNumber: operation.TransactionNumber,
-		UpdateCommitment:               operation.Delta.UpdateCommitment,
-		RecoveryCommitment:             rm.RecoveryCommitment}, nil
+	return nil
 }
 
 func parseSignedData(compactJWS string) (*internal.JSONWebSignature, error) {
 	if compactJWS == "" {
-		return nil, errors.New("missing signed data")
+		return nil, errs.New(errs.InvalidSignature, "missing signed data")
 	}
 
-	return internal.ParseJWS(compactJWS)
+	jws, err := internal.ParseJWS(compactJWS)
+	if err != nil {
+		return nil, errs.Wrap(errs.InvalidSignature, err, "parse signed data")
+	}
+
+	return jws, nil
 }
 
 func (s *OperationProcessor) applyDeactivateOperation(operation *batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
 	logger.Debugf("[%s] Applying deactivate operation: %+v", s.name, operation)
 
+	p, err := s.pc.Get(operation.TransactionTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyDeactivateOperation(operation, rm, p); err != nil {
+		return nil, err
+	}
+
+	return &resolutionModel{
+		Doc:                            nil,
+		LastOperationTransactionTime:   operation.TransactionTime,
+		LastOperationTransactionNumber: operation.TransactionNumber,
+		LastOperationIndex:             operation.OperationIndex,
+		UpdateCommitment:               "",
+		RecoveryCommitment:             "",
+		Deactivated:                    true}, nil
+}
+
+// verifyDeactivateOperation checks whether the deactivate operation may be applied to the given state of the document
+// with the given protocol version, i.e. it verifies the signed DID suffix, the recovery commitment and the signature
+func (s *OperationProcessor) verifyDeactivateOperation(operation *batch.Operation, rm *resolutionModel, p protocol.Protocol) error {
+	if rm.Deactivated {
+		return errs.New(errs.Deactivated, "deactivate cannot be applied to a deactivated document")
+	}
+
 	if rm.Doc == nil {
-		return nil, errors.New("deactivate can only be applied to an existing document")
+		return errors.New("deactivate can only be applied to an existing document")
 	}
 
 	jwsParts, err := parseSignedData(operation.SignedData)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	var signedDataModel model.DeactivateSignedDataModel
 	err = json.Unmarshal(jwsParts.Payload, &signedDataModel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal signed data model while applying deactivate: %s", err.Error())
+		return fmt.Errorf("failed to unmarshal signed data model while applying deactivate: %s", err.Error())
 	}
 
 	// verify signed did suffix against actual did suffix
 	if operation.UniqueSuffix != signedDataModel.DidSuffix {
-		return nil, errors.New("did suffix doesn't match signed value")
+		return errors.New("did suffix doesn't match signed value")
 	}
 
-	// TODO: protocol should be calculated based on transaction number
-	p := s.pc.Current()
-
 	recoveryCommitment, err := commitment.Calculate(signedDataModel.RecoveryKey, p.HashAlgorithmInMultiHashCode)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// verify that recovery commitments match
 	if recoveryCommitment != rm.RecoveryCommitment {
-		return nil, fmt.Errorf("commitment generated from recovery key doesn't match recovery commitment: [%s][%s]", recoveryCommitment, rm.RecoveryCommitment)
+		return errs.New(errs.CommitmentMismatch, "commitment generated from recovery key doesn't match recovery commitment: [%s][%s]", recoveryCommitment, rm.RecoveryCommitment)
 	}
 
 	// verify signature
 	_, err = internal.VerifyJWS(operation.SignedData, signedDataModel.RecoveryKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check signature: %s", err.Error())
+		return errs.Wrap(errs.InvalidSignature, err, "failed to check signature")
+	}
+
+	return nil
+}
+
+func (s *OperationProcessor) applyRecoverOperation(operation *batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
+	logger.Debugf("[%s] Applying recover operation: %+v", s.name, operation)
+
+	p, err := s.pc.Get(operation.TransactionTime)
+	if err != nil {
+		return nil, err
+	}
+
+	signedDataModel, err := s.verifyRecoverOperation(operation, rm, p)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := composer.ApplyPatches(make(document.Document), operation.Delta.Patches)
+	if err != nil {
+		return nil, err
 	}
 
 	return &resolutionModel{
-		Doc:                            nil,
+		Doc:                            doc,
 		LastOperationTransactionTime:   operation.TransactionTime,
 		LastOperationTransactionNumber: operation.TransactionNumber,
-		UpdateCommitment:               "",
-		RecoveryCommitment:             ""}, nil
+		LastOperationIndex:             operation.OperationIndex,
+		UpdateCommitment:               operation.Delta.UpdateCommitment,
+		RecoveryCommitment:             signedDataModel.RecoveryCommitment}, nil
 }
 
-func (s *OperationProcessor) applyRecoverOperation(operation *batch.Operation, rm *resolutionModel) (*resolutionModel, error) { //nolint:dupl
-	logger.Debugf("[%s] Applying recover operation: %+v", s.name, operation)
+// verifyRecoverOperation checks whether the recover operation may be applied to the given state of the document
+// with the given protocol version, i.e. it verifies the recovery commitment, the delta hash and the signature.
+// The signed data is returned.
+func (s *OperationProcessor) verifyRecoverOperation(operation *batch.Operation, rm *resolutionModel, p protocol.Protocol) (*model.RecoverSignedDataModel, error) { //nolint:dupl
+	if rm.Deactivated {
+		return nil, errs.New(errs.Deactivated, "recover cannot be applied to a deactivated document")
+	}
 
 	if rm.Doc == nil {
 		return nil, errors.New("recover can only be applied to an existing document")
@@ -345,9 +589,6 @@ func (s *OperationProcessor) applyRecoverOperation(operation *batch.Operation, r
 		return nil, fmt.Errorf("failed to unmarshal signed data model while applying recover: %s", err.Error())
 	}
 
-	// TODO: protocol should be calculated based on transaction number
-	p := s.pc.Current()
-
 	recoveryCommitment, err := commitment.Calculate(signedDataModel.RecoveryKey, p.HashAlgorithmInMultiHashCode)
 	if err != nil {
 		return nil, err
@@ -355,7 +596,7 @@ func (s *OperationProcessor) applyRecoverOperation(operation *batch.Operation, r
 
 	// verify that recovery commitments match
 	if recoveryCommitment != rm.RecoveryCommitment {
-		return nil, fmt.Errorf("commitment generated from recovery key doesn't match recovery commitment: [%s][%s]", recoveryCommitment, rm.RecoveryCommitment)
+		return nil, errs.New(errs.CommitmentMismatch, "commitment generated from recovery key doesn't match recovery commitment: [%s][%s]", recoveryCommitment, rm.RecoveryCommitment)
 	}
 
 	// verify the delta against the signed delta hash
@@ -367,28 +608,22 @@ func (s *OperationProcessor) applyRecoverOperation(operation *batch.Operation, r
 	// verify signature
 	_, err = internal.VerifyJWS(operation.SignedData, signedDataModel.RecoveryKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check signature: %s", err.Error())
+		return nil, errs.Wrap(errs.InvalidSignature, err, "failed to check signature")
 	}
 
-	doc, err := composer.ApplyPatches(make(document.Document), operation.Delta.Patches)
-	if err != nil {
-		return nil, err
-	}
-
-	return &resolutionModel{
-		Doc:                            doc,
-		LastOperationTransactionTime:   operation.TransactionTime,
-		LastOperationTransactionNumber: operation.TransactionNumber,
-		UpdateCommitment:               operation.Delta.UpdateCommitment,
-		RecoveryCommitment:             signedDataModel.RecoveryCommitment}, nil
+	return &signedDataModel, nil
 }
 
 func sortOperations(ops []*batch.Operation) {
 	sort.Slice(ops, func(i, j int) bool {
-		if ops[i].TransactionTime < ops[j].TransactionTime {
-			return true
+		if ops[i].TransactionTime != ops[j].TransactionTime {
+			return ops[i].TransactionTime < ops[j].TransactionTime
+		}
+
+		if ops[i].TransactionNumber != ops[j].TransactionNumber {
+			return ops[i].TransactionNumber < ops[j].TransactionNumber
 		}
 
-		return ops[i].TransactionNumber < ops[j].TransactionNumber
+		return ops[i].OperationIndex < ops[j].OperationIndex
 	})
 }
@@ -18,9 +18,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/commitment"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/canonicalizer"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/signutil"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
@@ -90,7 +92,7 @@ func TestResolve(t *testing.T) {
 			Patches: []patch.Patch{jsonPatch},
 		}
 
-		err = store.Put(createOp)
+		err = store.Put([]*batch.Operation{createOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -113,7 +115,7 @@ func TestResolve(t *testing.T) {
 
 		createOp.EncodedDelta = docutil.EncodeToString(deltaBytes)
 
-		err = store.Put(createOp)
+		err = store.Put([]*batch.Operation{createOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -140,7 +142,7 @@ func TestUpdateDocument(t *testing.T) {
 
 		updateOp, updateKey, err = getUpdateOperation(updateKey, uniqueSuffix, 1)
 		require.Nil(t, err)
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -154,7 +156,7 @@ func TestUpdateDocument(t *testing.T) {
 		// test consecutive update
 		updateOp, updateKey, err = getUpdateOperation(updateKey, uniqueSuffix, 2)
 		require.Nil(t, err)
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.Nil(t, err)
 
 		result, err = p.Resolve(uniqueSuffix)
@@ -173,7 +175,7 @@ func TestUpdateDocument(t *testing.T) {
 
 		updateOp.SignedData = ""
 
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -181,6 +183,7 @@ func TestUpdateDocument(t *testing.T) {
 		require.Error(t, err)
 		require.Nil(t, doc)
 		require.Contains(t, err.Error(), "missing signed data")
+		require.True(t, errors.Is(err, errs.ErrInvalidSignature))
 	})
 
 	t.Run("unmarshal signed data model error", func(t *testing.T) {
@@ -196,7 +199,7 @@ func TestUpdateDocument(t *testing.T) {
 
 		updateOp.SignedData = compactJWS
 
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -211,16 +214,70 @@ func TestUpdateDocument(t *testing.T) {
 
 		updateOp, _, err := getUpdateOperation(recoveryKey, uniqueSuffix, 77)
 		require.Nil(t, err)
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
 		doc, err := p.Resolve(uniqueSuffix)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "commitment generated from update key doesn't match update commitment")
+		require.True(t, errors.Is(err, errs.ErrCommitmentMismatch))
 		require.Nil(t, doc)
 	})
 
+	t.Run("success - operation validated against protocol version at transaction time", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 1)
+		require.Nil(t, err)
+		updateOp.TransactionTime = 5
+
+		err = store.Put([]*batch.Operation{updateOp})
+		require.Nil(t, err)
+
+		// newer protocol version uses unsupported hash algorithm
+		next := pc.Protocol
+		next.StartingBlockChainTime = 10
+		next.HashAlgorithmInMultiHashCode = 55
+
+		versionedPC := mocks.NewMockProtocolClient()
+		versionedPC.Protocol = next
+		versionedPC.Versions = []*protocol.Protocol{&pc.Protocol, &next}
+
+		p := New("test", store, versionedPC)
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		didDoc := document.DidDocumentFromJSONLDObject(result.Document)
+		require.Equal(t, "special1", didDoc["test"])
+
+		// operation anchored after protocol upgrade is validated against new protocol version
+		updateOp.TransactionTime = 15
+
+		result, err = p.Resolve(uniqueSuffix)
+		require.Error(t, err)
+		require.Nil(t, result)
+		require.Contains(t, err.Error(), "algorithm not supported")
+	})
+
+	t.Run("protocol version not found error", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 1)
+		require.Nil(t, err)
+		err = store.Put([]*batch.Operation{updateOp})
+		require.Nil(t, err)
+
+		versionedPC := mocks.NewMockProtocolClient()
+		versionedPC.Versions = nil
+
+		p := New("test", store, versionedPC)
+		doc, err := p.Resolve(uniqueSuffix)
+		require.Error(t, err)
+		require.Nil(t, doc)
+		require.Contains(t, err.Error(), "protocol parameters are not defined for blockchain time")
+	})
+
 	t.Run("invalid signature error", func(t *testing.T) {
 		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
 
@@ -232,7 +289,7 @@ func TestUpdateDocument(t *testing.T) {
 		updateOp, _, err := getUpdateOperationWithSigner(s, updateKey, uniqueSuffix, 1)
 		require.NoError(t, err)
 
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -250,7 +307,7 @@ func TestUpdateDocument(t *testing.T) {
 
 		updateOp.EncodedDelta = docutil.EncodeToString([]byte("other value"))
 
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -276,7 +333,7 @@ func TestProcessOperation(t *testing.T) {
 		const uniqueSuffix = "uniqueSuffix"
 		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 1)
 		require.Nil(t, err)
-		err = store.Put(updateOp)
+		err = store.Put([]*batch.Operation{updateOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -307,18 +364,19 @@ func TestProcessOperation(t *testing.T) {
 
 		deactivateOp, err := getDeactivateOperation(recoveryKey, uniqueSuffix, 1)
 		require.NoError(t, err)
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.Nil(t, err)
 
 		recoverOp, _, err := getRecoverOperation(recoveryKey, updateKey, uniqueSuffix, 2)
 		require.NoError(t, err)
-		err = store.Put(recoverOp)
+		err = store.Put([]*batch.Operation{recoverOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
 		doc, err := p.Resolve(uniqueSuffix)
 		require.Error(t, err)
-		require.Contains(t, err.Error(), "recover can only be applied to an existing document")
+		require.Contains(t, err.Error(), "recover cannot be applied to a deactivated document")
+		require.True(t, errors.Is(err, errs.ErrDeactivated))
 		require.Nil(t, doc)
 	})
 
@@ -330,7 +388,7 @@ func TestProcessOperation(t *testing.T) {
 
 		deactivateOp.Type = "invalid"
 
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -362,24 +420,40 @@ func TestDeactivate(t *testing.T) {
 		deactivateOp, err := getDeactivateOperation(recoveryKey, uniqueSuffix, 1)
 		require.NoError(t, err)
 
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
 		doc, err := p.Resolve(uniqueSuffix)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "document was deactivated")
-		require.Nil(t, doc)
+		require.NoError(t, err)
+		require.Empty(t, doc.Document)
+		require.True(t, doc.DocumentMetadata.Deactivated)
+		require.Equal(t, "1-0", doc.DocumentMetadata.VersionID)
+		require.Equal(t, 2, doc.DocumentMetadata.OperationCount)
+		require.Empty(t, doc.MethodMetadata.UpdateCommitment)
+		require.Empty(t, doc.MethodMetadata.RecoveryCommitment)
+
+		// update operations after deactivation are not applied
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 2)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{updateOp}))
+
+		doc, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Empty(t, doc.Document)
+		require.True(t, doc.DocumentMetadata.Deactivated)
+		require.Equal(t, "1-0", doc.DocumentMetadata.VersionID)
 
 		// deactivate same document again - error
 		deactivateOp, err = getDeactivateOperation(recoveryKey, uniqueSuffix, 2)
 		require.NoError(t, err)
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.NoError(t, err)
 
 		doc, err = p.Resolve(uniqueSuffix)
 		require.Error(t, err)
-		require.Contains(t, err.Error(), "deactivate can only be applied to an existing document")
+		require.Contains(t, err.Error(), "deactivate cannot be applied to a deactivated document")
+		require.True(t, errors.Is(err, errs.ErrDeactivated))
 		require.Nil(t, doc)
 	})
 
@@ -388,7 +462,7 @@ func TestDeactivate(t *testing.T) {
 
 		deactivateOp, err := getDeactivateOperation(recoveryKey, dummyUniqueSuffix, 0)
 		require.NoError(t, err)
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -406,7 +480,7 @@ func TestDeactivate(t *testing.T) {
 
 		deactivateOp.SignedData = ""
 
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -429,7 +503,7 @@ func TestDeactivate(t *testing.T) {
 
 		deactivateOp.SignedData = compactJWS
 
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -449,7 +523,7 @@ func TestDeactivate(t *testing.T) {
 		signer := ecsigner.New(differentRecoveryKey, "ES256", "")
 		deactivateOp, err := getDeactivateOperationWithSigner(signer, recoveryKey, uniqueSuffix, 1)
 		require.NoError(t, err)
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -475,7 +549,7 @@ func TestDeactivate(t *testing.T) {
 
 		deactivateOp.SignedData = jws
 
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -501,7 +575,7 @@ func TestDeactivate(t *testing.T) {
 
 		deactivateOp.SignedData = jws
 
-		err = store.Put(deactivateOp)
+		err = store.Put([]*batch.Operation{deactivateOp})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -528,7 +602,7 @@ func TestRecover(t *testing.T) {
 
 		recoverOp, recoveryKey, err = getRecoverOperation(recoveryKey, updateKey, uniqueSuffix, 1)
 		require.NoError(t, err)
-		err = store.Put(recoverOp)
+		err = store.Put([]*batch.Operation{recoverOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -543,7 +617,7 @@ func TestRecover(t *testing.T) {
 		// apply recover again - consecutive recoveries are valid
 		recoverOp, _, err = getRecoverOperation(recoveryKey, updateKey, uniqueSuffix, 2)
 		require.NoError(t, err)
-		err = store.Put(recoverOp)
+		err = store.Put([]*batch.Operation{recoverOp})
 		require.Nil(t, err)
 
 		doc, err := p.Resolve(uniqueSuffix)
@@ -559,7 +633,7 @@ func TestRecover(t *testing.T) {
 
 		recoverOp.SignedData = ""
 
-		err = store.Put(recoverOp)
+		err = store.Put([]*batch.Operation{recoverOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -582,7 +656,7 @@ func TestRecover(t *testing.T) {
 
 		recoverOp.SignedData = compactJWS
 
-		err = store.Put(recoverOp)
+		err = store.Put([]*batch.Operation{recoverOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -602,7 +676,7 @@ func TestRecover(t *testing.T) {
 		signer := ecsigner.New(differentRecoveryKey, "ES256", "")
 		recoverOp, _, err := getRecoverOperationWithSigner(signer, recoveryKey, updateKey, uniqueSuffix, 1)
 		require.NoError(t, err)
-		err = store.Put(recoverOp)
+		err = store.Put([]*batch.Operation{recoverOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -628,7 +702,7 @@ func TestRecover(t *testing.T) {
 		}
 		op.SignedData, err = signutil.SignModel(signedModel, ecsigner.New(privateKey, "P-256", ""))
 
-		err = store.Put(op)
+		err = store.Put([]*batch.Operation{op})
 		require.NoError(t, err)
 
 		p := New("test", store, pc)
@@ -645,7 +719,7 @@ func TestRecover(t *testing.T) {
 
 		recoverOp.EncodedDelta = docutil.EncodeToString([]byte("other value"))
 
-		err = store.Put(recoverOp)
+		err = store.Put([]*batch.Operation{recoverOp})
 		require.Nil(t, err)
 
 		p := New("test", store, pc)
@@ -656,6 +730,174 @@ func TestRecover(t *testing.T) {
 	})
 }
 
+func TestValidateOperation(t *testing.T) {
+	recoveryKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	updateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pc := mocks.NewMockProtocolClient()
+
+	t.Run("success - create", func(t *testing.T) {
+		createOp, err := getCreateOperation(recoveryKey, updateKey)
+		require.NoError(t, err)
+
+		p := New("test", mocks.NewMockOperationStore(nil), pc)
+		require.NoError(t, p.ValidateOperation(createOp))
+	})
+
+	t.Run("success - update", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		p := New("test", store, pc)
+		require.NoError(t, p.ValidateOperation(updateOp))
+
+		// the operation isn't applied
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Empty(t, result.Document["test"])
+	})
+
+	t.Run("success - update with snapshots", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer))
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+		require.NoError(t, p.ValidateOperation(updateOp))
+
+		// the snapshot isn't modified
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Empty(t, result.Document["test"])
+	})
+
+	t.Run("success - recover and deactivate", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		recoverOp, _, err := getRecoverOperation(recoveryKey, updateKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		deactivateOp, err := getDeactivateOperation(recoveryKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		p := New("test", store, pc)
+		require.NoError(t, p.ValidateOperation(recoverOp))
+		require.NoError(t, p.ValidateOperation(deactivateOp))
+	})
+
+	t.Run("success - current protocol", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		// the operation hasn't been anchored so the protocol version isn't looked up by transaction time
+		currentOnly := mocks.NewMockProtocolClient()
+		currentOnly.Versions = nil
+
+		expected := *updateOp
+
+		p := New("test", store, currentOnly)
+		require.NoError(t, p.ValidateOperation(updateOp))
+
+		// the operation isn't modified
+		require.Equal(t, expected, *updateOp)
+	})
+
+	t.Run("error - update commitment mismatch", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		updateOp, _, err := getUpdateOperation(otherKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		p := New("test", store, pc)
+		err = p.ValidateOperation(updateOp)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, errs.ErrCommitmentMismatch))
+	})
+
+	t.Run("error - invalid signature", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		// signed with a key other than the update key
+		updateOp, _, err := getUpdateOperationWithSigner(ecsigner.New(otherKey, "ES256", updateKeyID), updateKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		p := New("test", store, pc)
+		err = p.ValidateOperation(updateOp)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, errs.ErrInvalidSignature))
+	})
+
+	t.Run("error - recovery commitment mismatch", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		recoverOp, _, err := getRecoverOperation(otherKey, updateKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		deactivateOp, err := getDeactivateOperation(otherKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		p := New("test", store, pc)
+
+		err = p.ValidateOperation(recoverOp)
+		require.True(t, errors.Is(err, errs.ErrCommitmentMismatch))
+
+		err = p.ValidateOperation(deactivateOp)
+		require.True(t, errors.Is(err, errs.ErrCommitmentMismatch))
+	})
+
+	t.Run("error - document deactivated", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		deactivateOp, err := getDeactivateOperation(recoveryKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{deactivateOp}))
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 2)
+		require.NoError(t, err)
+
+		p := New("test", store, pc)
+		err = p.ValidateOperation(updateOp)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, errs.ErrDeactivated))
+	})
+
+	t.Run("error - document not found", func(t *testing.T) {
+		updateOp, _, err := getUpdateOperation(updateKey, "uniqueSuffix", 1)
+		require.NoError(t, err)
+
+		p := New("test", mocks.NewMockOperationStore(nil), pc)
+		err = p.ValidateOperation(updateOp)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, errs.ErrNotFound))
+	})
+
+	t.Run("error - operation type not supported", func(t *testing.T) {
+		store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+		deactivateOp, err := getDeactivateOperation(recoveryKey, uniqueSuffix, 1)
+		require.NoError(t, err)
+
+		deactivateOp.Type = "invalid"
+
+		p := New("test", store, pc)
+		err = p.ValidateOperation(deactivateOp)
+		require.Error(t, err)
+		require.Equal(t, errs.UnsupportedOperation, errs.CodeOf(err))
+	})
+}
+
 func TestOpsWithTxnGreaterThan(t *testing.T) {
 	op1 := &batch.Operation{
 		TransactionTime:   1,
@@ -679,6 +921,183 @@ func TestOpsWithTxnGreaterThan(t *testing.T) {
 	require.Equal(t, 1, len(txns))
 }
 
+func TestResolve_Version(t *testing.T) {
+	recoveryKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	updateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+	ops, err := store.Get(uniqueSuffix)
+	require.NoError(t, err)
+
+	ops[0].TransactionTime = 2
+
+	for i, txnTime := range []uint64{5, 10} {
+		var updateOp *batch.Operation
+
+		updateOp, updateKey, err = getUpdateOperation(updateKey, uniqueSuffix, uint(i+1))
+		require.NoError(t, err)
+
+		updateOp.TransactionTime = txnTime
+		require.NoError(t, store.Put([]*batch.Operation{updateOp}))
+	}
+
+	snapshots := newMockSnapshotStore()
+
+	p := New("test", store, mocks.NewMockProtocolClient(), WithSnapshotStore(snapshots, snapshots.signer))
+
+	t.Run("latest version", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "special2", result.Document["test"])
+		require.Equal(t, "2-0", result.DocumentMetadata.VersionID)
+		require.Equal(t, uint64(10), result.DocumentMetadata.Updated)
+		require.Equal(t, uint64(2), result.DocumentMetadata.Created)
+		require.Equal(t, 3, result.DocumentMetadata.OperationCount)
+		require.False(t, result.DocumentMetadata.Deactivated)
+
+		// resolved from the snapshot
+		fromSnapshot, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, result.DocumentMetadata, fromSnapshot.DocumentMetadata)
+	})
+
+	t.Run("version ID", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 1}))
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+		require.Equal(t, "1-0", result.DocumentMetadata.VersionID)
+		require.Equal(t, uint64(5), result.DocumentMetadata.Updated)
+		require.Equal(t, uint64(2), result.DocumentMetadata.Created)
+		require.Equal(t, 2, result.DocumentMetadata.OperationCount)
+
+		result, err = p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 0}))
+		require.NoError(t, err)
+		require.Nil(t, result.Document["test"])
+		require.Equal(t, "0-0", result.DocumentMetadata.VersionID)
+		require.Equal(t, uint64(2), result.DocumentMetadata.Updated)
+	})
+
+	t.Run("version time", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix, document.WithVersionTime(7))
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+		require.Equal(t, "1-0", result.DocumentMetadata.VersionID)
+
+		result, err = p.Resolve(uniqueSuffix, document.WithVersionTime(10))
+		require.NoError(t, err)
+		require.Equal(t, "special2", result.Document["test"])
+		require.Equal(t, "2-0", result.DocumentMetadata.VersionID)
+	})
+
+	t.Run("version ID and version time", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 1}), document.WithVersionTime(7))
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+
+		result, err = p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 2}), document.WithVersionTime(7))
+		require.Error(t, err)
+		require.Nil(t, result)
+		require.Contains(t, err.Error(), "version [2-0] not found")
+	})
+
+	t.Run("historical versions are not resolved from snapshot", func(t *testing.T) {
+		snapshot, err := snapshots.Get(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), snapshot.TransactionNumber)
+
+		puts := snapshots.puts
+
+		_, err = p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 1}))
+		require.NoError(t, err)
+		require.Equal(t, puts, snapshots.puts)
+	})
+
+	t.Run("error - version ID not found", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 5}))
+		require.Error(t, err)
+		require.Nil(t, result)
+		require.Contains(t, err.Error(), "version [5-0] not found")
+	})
+
+	t.Run("error - not found at version time", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix, document.WithVersionTime(1))
+		require.Error(t, err)
+		require.Nil(t, result)
+		require.Contains(t, err.Error(), "not found at version time [1]")
+	})
+
+	t.Run("error - store", func(t *testing.T) {
+		result, err := New("test", mocks.NewMockOperationStore(errors.New("injected store error")), mocks.NewMockProtocolClient()).
+			Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 1}))
+		require.EqualError(t, err, "injected store error")
+		require.Nil(t, result)
+	})
+}
+
+func TestResolve_VersionWithinTransaction(t *testing.T) {
+	recoveryKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	updateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+	// two updates for the same suffix are anchored in the same transaction
+	for i := 1; i <= 2; i++ {
+		var updateOp *batch.Operation
+
+		updateOp, updateKey, err = getUpdateOperation(updateKey, uniqueSuffix, uint(i))
+		require.NoError(t, err)
+
+		updateOp.TransactionTime = 5
+		updateOp.TransactionNumber = 1
+		updateOp.OperationIndex = uint(i - 1)
+		require.NoError(t, store.Put([]*batch.Operation{updateOp}))
+	}
+
+	snapshots := newMockSnapshotStore()
+
+	p := New("test", store, mocks.NewMockProtocolClient(), WithSnapshotStore(snapshots, snapshots.signer))
+
+	t.Run("latest version", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "special2", result.Document["test"])
+		require.Equal(t, "1-1", result.DocumentMetadata.VersionID)
+
+		// resolved from the snapshot
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "1-1", result.DocumentMetadata.VersionID)
+	})
+
+	t.Run("version ID", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 1, OperationIndex: 0}))
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+		require.Equal(t, "1-0", result.DocumentMetadata.VersionID)
+		require.Equal(t, 2, result.DocumentMetadata.OperationCount)
+
+		result, err = p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 1, OperationIndex: 1}))
+		require.NoError(t, err)
+		require.Equal(t, "special2", result.Document["test"])
+		require.Equal(t, "1-1", result.DocumentMetadata.VersionID)
+		require.Equal(t, 3, result.DocumentMetadata.OperationCount)
+	})
+
+	t.Run("error - operation index not found", func(t *testing.T) {
+		result, err := p.Resolve(uniqueSuffix, document.WithVersionID(document.VersionID{TransactionNumber: 1, OperationIndex: 2}))
+		require.Error(t, err)
+		require.Nil(t, result)
+		require.Contains(t, err.Error(), "version [1-2] not found")
+	})
+}
+
 func getUpdateOperation(privateKey *ecdsa.PrivateKey, uniqueSuffix string, operationNumber uint) (*batch.Operation, *ecdsa.PrivateKey, error) {
 	s := ecsigner.New(privateKey, "ES256", updateKeyID)
 
@@ -908,7 +1327,7 @@ func getDefaultStore(recoveryKey, updateKey *ecdsa.PrivateKey) (*mocks.MockOpera
 	}
 
 	// store default create operation
-	err = store.Put(createOp)
+	err = store.Put([]*batch.Operation{createOp})
 	if err != nil {
 		panic(err)
 	}
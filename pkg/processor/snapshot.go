@@ -0,0 +1,345 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package processor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/document"
+)
+
+const defaultConsistencyCheckInterval = 100
+
+// Snapshot is the state of a document after all of the operations up to (and including) the operation
+// with the given transaction time and number were applied
+type Snapshot struct {
+	Document           document.Document `json:"document"`
+	UpdateCommitment   string            `json:"updateCommitment"`
+	RecoveryCommitment string            `json:"recoveryCommitment"`
+	TransactionTime    uint64            `json:"transactionTime"`
+	TransactionNumber  uint64            `json:"transactionNumber"`
+	OperationIndex     uint              `json:"operationIndex"`
+	CreatedTime        uint64            `json:"createdTime"`
+	Deactivated        bool              `json:"deactivated"`
+	// OperationsDigest is the digest of the operations that were stored at or before the transaction of the
+	// snapshot when the snapshot was taken (see digestOperations)
+	OperationsDigest string `json:"operationsDigest"`
+	// Signature is the signature of the snapshot (see SnapshotSigner)
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// SnapshotSigner signs the snapshots that are persisted by the operation processor and verifies the signature of
+// a snapshot before the document is resolved from it, i.e. a snapshot that was modified in the store (or that
+// was signed with another key) is discarded.
+type SnapshotSigner interface {
+	// Sign returns the signature of the given content
+	Sign(content []byte) ([]byte, error)
+
+	// Verify returns an error if the given signature is not a valid signature of the given content
+	Verify(content, signature []byte) error
+}
+
+// SnapshotStore persists document snapshots. Implementations must not share the document of a snapshot
+// with the caller (i.e. the document is copied on Put and Get) since documents are modified when operations
+// are applied.
+type SnapshotStore interface {
+	// Get returns the snapshot for the given unique suffix
+	Get(uniqueSuffix string) (*Snapshot, error)
+
+	// Put stores (or replaces) the snapshot for the given unique suffix
+	Put(uniqueSuffix string, snapshot *Snapshot) error
+}
+
+// Option is an operation processor option
+type Option func(opts *OperationProcessor)
+
+// WithSnapshotStore enables incremental resolution. The state of a document is signed with the given signer and
+// persisted to the given store after resolution and subsequent resolutions only apply the operations that are newer
+// than the snapshot.
+func WithSnapshotStore(store SnapshotStore, signer SnapshotSigner) Option {
+	return func(opts *OperationProcessor) {
+		opts.snapshots = store
+		opts.snapshotSigner = signer
+	}
+}
+
+// WithConsistencyCheckInterval sets how often (every n-th resolution from a snapshot) the result of the incremental
+// resolution is checked against a full replay of all operations. The check is disabled if n is zero.
+func WithConsistencyCheckInterval(n uint64) Option {
+	return func(opts *OperationProcessor) {
+		opts.consistencyCheckInterval = n
+	}
+}
+
+// resolveFromSnapshot applies the operations that are newer than the snapshot of the document. All of the
+// operations are replayed if there's no snapshot or if the snapshot is no longer consistent with the store,
+// i.e. operations at or before the transaction of the snapshot were stored or deleted since the snapshot was
+// taken (e.g. the operations were rolled back or an operation of an earlier transaction was stored late).
+func (s *OperationProcessor) resolveFromSnapshot(uniqueSuffix string, ops []*batch.Operation) (*resolutionModel, error) {
+	snapshot, err := s.snapshots.Get(uniqueSuffix)
+	if err != nil {
+		logger.Debugf("[%s] Snapshot not found for unique suffix [%s]: %s", s.name, uniqueSuffix, err)
+
+		return s.replayAndSnapshot(uniqueSuffix, ops)
+	}
+
+	if err := s.verifySnapshot(snapshot); err != nil {
+		logger.Warnf("[%s] Discarding snapshot for unique suffix [%s]: %s", s.name, uniqueSuffix, err)
+
+		return s.replayAndSnapshot(uniqueSuffix, ops)
+	}
+
+	if !containsOperationAt(ops, snapshot.TransactionTime, snapshot.TransactionNumber) {
+		logger.Infof("[%s] Discarding snapshot for unique suffix [%s] since the last operation of the snapshot is not in the store", s.name, uniqueSuffix)
+
+		return s.replayAndSnapshot(uniqueSuffix, ops)
+	}
+
+	if digestOperations(ops, snapshot.TransactionTime, snapshot.TransactionNumber) != snapshot.OperationsDigest {
+		logger.Infof("[%s] Discarding snapshot for unique suffix [%s] since the operations of the snapshot were modified in the store", s.name, uniqueSuffix)
+
+		return s.replayAndSnapshot(uniqueSuffix, ops)
+	}
+
+	newOps := getOpsWithTxnGreaterThan(ops, snapshot.TransactionTime, snapshot.TransactionNumber)
+
+	logger.Debugf("[%s] Applying %d operations newer than snapshot for unique suffix [%s]", s.name, len(newOps), uniqueSuffix)
+
+	_, updateOps, fullOps := splitOperations(newOps)
+
+	rm, err := s.applyFullAndUpdateOperations(fullOps, updateOps, snapshot.resolutionModel())
+	if err != nil {
+		logger.Debugf("[%s] Failed to apply operations to snapshot for unique suffix [%s] - replaying all operations: %s", s.name, uniqueSuffix, err)
+
+		return s.replayAndSnapshot(uniqueSuffix, ops)
+	}
+
+	if s.isConsistencyCheckDue() {
+		return s.checkConsistency(uniqueSuffix, ops, rm)
+	}
+
+	if len(newOps) > 0 {
+		s.saveSnapshot(uniqueSuffix, rm, ops)
+	}
+
+	return rm, nil
+}
+
+func (s *OperationProcessor) replayAndSnapshot(uniqueSuffix string, ops []*batch.Operation) (*resolutionModel, error) {
+	rm, err := s.replay(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	s.saveSnapshot(uniqueSuffix, rm, ops)
+
+	return rm, nil
+}
+
+// checkConsistency replays all of the operations and compares the result with the result of the incremental
+// resolution. The result of the full replay is returned (and persisted) if the results don't match.
+func (s *OperationProcessor) checkConsistency(uniqueSuffix string, ops []*batch.Operation, rm *resolutionModel) (*resolutionModel, error) {
+	logger.Debugf("[%s] Checking snapshot consistency for unique suffix [%s]", s.name, uniqueSuffix)
+
+	replayed, err := s.replay(ops)
+	if err != nil {
+		logger.Errorf("[%s] Full replay failed for unique suffix [%s] but resolution from snapshot succeeded: %s", s.name, uniqueSuffix, err)
+
+		return nil, err
+	}
+
+	if !equal(rm, replayed) {
+		logger.Errorf("[%s] Resolution from snapshot for unique suffix [%s] doesn't match full replay - replacing snapshot", s.name, uniqueSuffix)
+	}
+
+	s.saveSnapshot(uniqueSuffix, replayed, ops)
+
+	return replayed, nil
+}
+
+func (s *OperationProcessor) isConsistencyCheckDue() bool {
+	if s.consistencyCheckInterval == 0 {
+		return false
+	}
+
+	return atomic.AddUint64(&s.resolutions, 1)%s.consistencyCheckInterval == 0
+}
+
+// saveSnapshot saves the snapshot of the given resolution model which was resolved from the given operations
+func (s *OperationProcessor) saveSnapshot(uniqueSuffix string, rm *resolutionModel, ops []*batch.Operation) {
+	snapshot := &Snapshot{
+		Document:           rm.Doc,
+		UpdateCommitment:   rm.UpdateCommitment,
+		RecoveryCommitment: rm.RecoveryCommitment,
+		TransactionTime:    rm.LastOperationTransactionTime,
+		TransactionNumber:  rm.LastOperationTransactionNumber,
+		OperationIndex:     rm.LastOperationIndex,
+		CreatedTime:        rm.CreatedTime,
+		Deactivated:        rm.Deactivated,
+		OperationsDigest:   digestOperations(ops, rm.LastOperationTransactionTime, rm.LastOperationTransactionNumber),
+	}
+
+	// resolution doesn't fail if the snapshot can't be saved
+	if err := s.signSnapshot(snapshot); err != nil {
+		logger.Warnf("[%s] Failed to sign snapshot for unique suffix [%s]: %s", s.name, uniqueSuffix, err)
+
+		return
+	}
+
+	if err := s.snapshots.Put(uniqueSuffix, snapshot); err != nil {
+		logger.Warnf("[%s] Failed to save snapshot for unique suffix [%s]: %s", s.name, uniqueSuffix, err)
+	}
+}
+
+func (s *OperationProcessor) signSnapshot(snapshot *Snapshot) error {
+	content, err := snapshot.signingContent()
+	if err != nil {
+		return err
+	}
+
+	signature, err := s.snapshotSigner.Sign(content)
+	if err != nil {
+		return err
+	}
+
+	snapshot.Signature = signature
+
+	return nil
+}
+
+func (s *OperationProcessor) verifySnapshot(snapshot *Snapshot) error {
+	if len(snapshot.Signature) == 0 {
+		return errors.New("snapshot is not signed")
+	}
+
+	content, err := snapshot.signingContent()
+	if err != nil {
+		return err
+	}
+
+	return s.snapshotSigner.Verify(content, snapshot.Signature)
+}
+
+// signingContent returns the content of the snapshot (without the signature) that is signed. The content is
+// normalized since the document of a stored snapshot was unmarshalled (e.g. numbers are float64).
+func (snapshot *Snapshot) signingContent() ([]byte, error) {
+	unsigned := *snapshot
+	unsigned.Signature = nil
+
+	content, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(content, &normalized); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(normalized)
+}
+
+func (snapshot *Snapshot) resolutionModel() *resolutionModel {
+	return &resolutionModel{
+		Doc:                            snapshot.Document,
+		LastOperationTransactionTime:   snapshot.TransactionTime,
+		LastOperationTransactionNumber: snapshot.TransactionNumber,
+		LastOperationIndex:             snapshot.OperationIndex,
+		UpdateCommitment:               snapshot.UpdateCommitment,
+		RecoveryCommitment:             snapshot.RecoveryCommitment,
+		CreatedTime:                    snapshot.CreatedTime,
+		Deactivated:                    snapshot.Deactivated,
+	}
+}
+
+func containsOperationAt(ops []*batch.Operation, txnTime, txnNumber uint64) bool {
+	for _, op := range ops {
+		if op.TransactionTime == txnTime && op.TransactionNumber == txnNumber {
+			return true
+		}
+	}
+
+	return false
+}
+
+// digestOperations returns the (hex-encoded) SHA-256 digest of the position and content of the given operations that
+// are at or before the given transaction, i.e. the digest changes if any of the operations that were applied to a
+// snapshot are deleted or replaced or if an operation is stored at or before the transaction of the snapshot.
+// pre-condition: operations have to be sorted
+func digestOperations(ops []*batch.Operation, txnTime, txnNumber uint64) string {
+	hash := sha256.New()
+
+	for _, op := range ops[:len(ops)-len(getOpsWithTxnGreaterThan(ops, txnTime, txnNumber))] {
+		// the length of the operation buffer is included so that the digest is unambiguous
+		fmt.Fprintf(hash, "%d.%d.%d.%d.", op.TransactionTime, op.TransactionNumber, op.OperationIndex, len(op.OperationBuffer))
+
+		_, _ = hash.Write(op.OperationBuffer)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// equal compares the JSON representation of the resolution models since the document of a snapshot
+// may have been unmarshalled (e.g. numbers are float64)
+func equal(rm1, rm2 *resolutionModel) bool {
+	bytes1, err := json.Marshal(rm1)
+	if err != nil {
+		return false
+	}
+
+	bytes2, err := json.Marshal(rm2)
+	if err != nil {
+		return false
+	}
+
+	return string(bytes1) == string(bytes2)
+}
+
+// HMACSnapshotSigner signs snapshots with HMAC-SHA256 using a secret key
+type HMACSnapshotSigner struct {
+	key []byte
+}
+
+// NewHMACSnapshotSigner returns a snapshot signer that signs snapshots with HMAC-SHA256 using the given secret key
+func NewHMACSnapshotSigner(key []byte) *HMACSnapshotSigner {
+	return &HMACSnapshotSigner{key: key}
+}
+
+// Sign returns the HMAC of the given content
+func (s *HMACSnapshotSigner) Sign(content []byte) ([]byte, error) {
+	if len(s.key) == 0 {
+		return nil, errors.New("missing snapshot signing key")
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+
+	// Write never returns an error
+	_, _ = mac.Write(content)
+
+	return mac.Sum(nil), nil
+}
+
+// Verify returns an error if the given signature is not the HMAC of the given content
+func (s *HMACSnapshotSigner) Verify(content, signature []byte) error {
+	expected, err := s.Sign(content)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(expected, signature) {
+		return errors.New("invalid snapshot signature")
+	}
+
+	return nil
+}
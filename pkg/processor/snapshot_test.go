@@ -0,0 +1,482 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package processor
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+)
+
+const markerProperty = "fromSnapshot"
+
+var snapshotKey = []byte("snapshot signing key")
+
+func TestResolve_Snapshot(t *testing.T) {
+	recoveryKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pc := mocks.NewMockProtocolClient()
+
+	t.Run("success - operations newer than snapshot are applied", func(t *testing.T) {
+		store, uniqueSuffix, updateKey := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(0))
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+
+		snapshot, err := snapshots.Get(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), snapshot.TransactionNumber)
+		require.Equal(t, result.MethodMetadata.UpdateCommitment, snapshot.UpdateCommitment)
+		require.Equal(t, result.MethodMetadata.RecoveryCommitment, snapshot.RecoveryCommitment)
+
+		// modifying the result doesn't modify the snapshot
+		result.Document["id"] = "did:sidetree:" + uniqueSuffix
+
+		snapshots.mark(uniqueSuffix)
+
+		// no new operations
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, true, result.Document[markerProperty])
+		require.Nil(t, result.Document["id"])
+		require.Equal(t, 1, snapshots.puts)
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 2)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{updateOp}))
+
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "special2", result.Document["test"])
+		require.Equal(t, true, result.Document[markerProperty])
+
+		snapshot, err = snapshots.Get(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), snapshot.TransactionNumber)
+		require.Equal(t, "special2", snapshot.Document["test"])
+		require.Equal(t, 2, snapshots.puts)
+	})
+
+	t.Run("success - recover after snapshot", func(t *testing.T) {
+		store, uniqueSuffix, updateKey := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(0))
+
+		_, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		recoverOp, nextRecoveryKey, err := getRecoverOperation(recoveryKey, updateKey, uniqueSuffix, 2)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{recoverOp}))
+
+		expected, err := New("test", store, pc).Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, 2, expected.DocumentMetadata.OperationCount)
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, expected, result)
+
+		recoveryCommitment, err := getCommitment(nextRecoveryKey)
+		require.NoError(t, err)
+		require.Equal(t, recoveryCommitment, result.MethodMetadata.RecoveryCommitment)
+	})
+
+	t.Run("deactivated after snapshot", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer))
+
+		_, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		deactivateOp, err := getDeactivateOperation(recoveryKey, uniqueSuffix, 2)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{deactivateOp}))
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Empty(t, result.Document)
+		require.True(t, result.DocumentMetadata.Deactivated)
+
+		snapshot, err := snapshots.Get(uniqueSuffix)
+		require.NoError(t, err)
+		require.True(t, snapshot.Deactivated)
+
+		// resolved from the snapshot
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Empty(t, result.Document)
+		require.True(t, result.DocumentMetadata.Deactivated)
+		require.Equal(t, "2-0", result.DocumentMetadata.VersionID)
+	})
+
+	t.Run("snapshot is not consistent with store - all operations are replayed", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(0))
+
+		_, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		// the last operation of the snapshot is not in the store (e.g. it was rolled back)
+		snapshots.mark(uniqueSuffix)
+		snapshots.modify(uniqueSuffix, func(snapshot *Snapshot) { snapshot.TransactionNumber = 5 })
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Nil(t, result.Document[markerProperty])
+		require.Equal(t, "special1", result.Document["test"])
+
+		snapshot, err := snapshots.Get(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), snapshot.TransactionNumber)
+	})
+
+	t.Run("snapshot signature is not valid - all operations are replayed", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(0))
+
+		_, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		snapshot, err := snapshots.Get(uniqueSuffix)
+		require.NoError(t, err)
+		require.NotEmpty(t, snapshot.Signature)
+
+		// the snapshot was modified in the store
+		snapshots.tamper(uniqueSuffix, func(snapshot *Snapshot) {
+			snapshot.Document[markerProperty] = true
+		})
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Nil(t, result.Document[markerProperty])
+		require.Equal(t, "special1", result.Document["test"])
+
+		// the snapshot was signed with another key
+		otherSigner := NewHMACSnapshotSigner([]byte("other key"))
+
+		_, err = New("test", store, pc, WithSnapshotStore(snapshots, otherSigner)).Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		puts := snapshots.puts
+
+		_, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, puts+1, snapshots.puts)
+
+		// the snapshot is not signed
+		snapshots.mark(uniqueSuffix)
+		snapshots.tamper(uniqueSuffix, func(snapshot *Snapshot) { snapshot.Signature = nil })
+
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Nil(t, result.Document[markerProperty])
+	})
+
+	t.Run("snapshot can't be signed - snapshot isn't saved", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		result, err := New("test", store, pc, WithSnapshotStore(snapshots, NewHMACSnapshotSigner(nil))).Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+		require.Zero(t, snapshots.puts)
+	})
+
+	t.Run("operation stored before the snapshot - all operations are replayed", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(0))
+
+		_, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		snapshots.mark(uniqueSuffix)
+
+		ops, err := store.Get(uniqueSuffix)
+		require.NoError(t, err)
+
+		// an operation of the transaction of the create operation is stored after the snapshot was taken
+		lateOp := *ops[1]
+		lateOp.TransactionNumber = ops[0].TransactionNumber
+		lateOp.OperationIndex = 1
+		require.NoError(t, store.Put([]*batch.Operation{&lateOp}))
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Nil(t, result.Document[markerProperty])
+		require.Equal(t, "special1", result.Document["test"])
+		require.Equal(t, 2, snapshots.puts)
+
+		// the snapshot is consistent with the store again
+		snapshots.mark(uniqueSuffix)
+
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, true, result.Document[markerProperty])
+		require.Equal(t, 2, snapshots.puts)
+	})
+
+	t.Run("operations can't be applied to snapshot - all operations are replayed", func(t *testing.T) {
+		store, uniqueSuffix, updateKey := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(0))
+
+		_, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		snapshots.mark(uniqueSuffix)
+		snapshots.modify(uniqueSuffix, func(snapshot *Snapshot) { snapshot.UpdateCommitment = "invalid" })
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 2)
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{updateOp}))
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Nil(t, result.Document[markerProperty])
+		require.Equal(t, "special2", result.Document["test"])
+	})
+
+	t.Run("consistency check", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 2)
+		snapshots := newMockSnapshotStore()
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(2))
+
+		expected, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		// first resolution from snapshot is not checked
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, expected, result)
+
+		// consistent
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, expected, result)
+
+		snapshots.mark(uniqueSuffix)
+
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, true, result.Document[markerProperty])
+
+		// not consistent - result of full replay is returned and the snapshot is replaced
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, expected, result)
+
+		snapshot, err := snapshots.Get(uniqueSuffix)
+		require.NoError(t, err)
+		require.Nil(t, snapshot.Document[markerProperty])
+	})
+
+	t.Run("consistency check - full replay error", func(t *testing.T) {
+		store, uniqueSuffix, updateKey := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+
+		_, err := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer)).Resolve(uniqueSuffix)
+		require.NoError(t, err)
+
+		// store without create operation
+		ops, err := store.Get(uniqueSuffix)
+		require.NoError(t, err)
+
+		storeWithoutCreate := mocks.NewMockOperationStore(nil)
+		require.NoError(t, storeWithoutCreate.Put([]*batch.Operation{ops[1]}))
+
+		updateOp, _, err := getUpdateOperation(updateKey, uniqueSuffix, 2)
+		require.NoError(t, err)
+		require.NoError(t, storeWithoutCreate.Put([]*batch.Operation{updateOp}))
+
+		p := New("test", storeWithoutCreate, pc, WithSnapshotStore(snapshots, snapshots.signer), WithConsistencyCheckInterval(1))
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.EqualError(t, err, "missing create operation")
+		require.Nil(t, result)
+	})
+
+	t.Run("snapshot store errors", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 1)
+		snapshots := newMockSnapshotStore()
+		snapshots.putErr = errors.New("injected put error")
+
+		p := New("test", store, pc, WithSnapshotStore(snapshots, snapshots.signer))
+
+		result, err := p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+
+		_, err = snapshots.Get(uniqueSuffix)
+		require.Error(t, err)
+
+		snapshots.getErr = errors.New("injected get error")
+
+		result, err = p.Resolve(uniqueSuffix)
+		require.NoError(t, err)
+		require.Equal(t, "special1", result.Document["test"])
+	})
+
+	t.Run("error - no create operation", func(t *testing.T) {
+		store, uniqueSuffix, _ := getStoreWithUpdates(t, recoveryKey, 0)
+
+		ops, err := store.Get(uniqueSuffix)
+		require.NoError(t, err)
+
+		ops[0].Type = batch.OperationTypeUpdate
+
+		result, err := New("test", store, pc, WithSnapshotStore(newMockSnapshotStore(), NewHMACSnapshotSigner(snapshotKey))).Resolve(uniqueSuffix)
+		require.EqualError(t, err, "missing create operation")
+		require.Nil(t, result)
+	})
+}
+
+// getStoreWithUpdates returns a store with a create operation followed by the given number of updates
+func getStoreWithUpdates(t *testing.T, recoveryKey *ecdsa.PrivateKey, updates int) (*mocks.MockOperationStore, string, *ecdsa.PrivateKey) {
+	updateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	store, uniqueSuffix := getDefaultStore(recoveryKey, updateKey)
+
+	for i := 1; i <= updates; i++ {
+		var updateOp *batch.Operation
+
+		updateOp, updateKey, err = getUpdateOperation(updateKey, uniqueSuffix, uint(i))
+		require.NoError(t, err)
+		require.NoError(t, store.Put([]*batch.Operation{updateOp}))
+	}
+
+	return store, uniqueSuffix, updateKey
+}
+
+// mockSnapshotStore keeps snapshots in memory (marshalled so that documents aren't shared)
+type mockSnapshotStore struct {
+	mutex     sync.Mutex
+	signer    *HMACSnapshotSigner
+	snapshots map[string][]byte
+	puts      int
+	getErr    error
+	putErr    error
+}
+
+func newMockSnapshotStore() *mockSnapshotStore {
+	return &mockSnapshotStore{
+		signer:    NewHMACSnapshotSigner(snapshotKey),
+		snapshots: make(map[string][]byte),
+	}
+}
+
+func (m *mockSnapshotStore) Get(uniqueSuffix string) (*Snapshot, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+
+	content, ok := m.snapshots[uniqueSuffix]
+	if !ok {
+		return nil, fmt.Errorf("snapshot not found for unique suffix: %s", uniqueSuffix)
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(content, snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (m *mockSnapshotStore) Put(uniqueSuffix string, snapshot *Snapshot) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.putErr != nil {
+		return m.putErr
+	}
+
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	m.snapshots[uniqueSuffix] = content
+	m.puts++
+
+	return nil
+}
+
+// mark adds a property to the document of the snapshot so that the tests can tell whether
+// a document was resolved from the snapshot
+func (m *mockSnapshotStore) mark(uniqueSuffix string) {
+	m.modify(uniqueSuffix, func(snapshot *Snapshot) {
+		snapshot.Document[markerProperty] = true
+	})
+}
+
+// modify modifies the stored snapshot (which is signed again)
+func (m *mockSnapshotStore) modify(uniqueSuffix string, modify func(snapshot *Snapshot)) {
+	m.tamper(uniqueSuffix, func(snapshot *Snapshot) {
+		modify(snapshot)
+
+		content, err := snapshot.signingContent()
+		if err != nil {
+			panic(err)
+		}
+
+		snapshot.Signature, err = m.signer.Sign(content)
+		if err != nil {
+			panic(err)
+		}
+	})
+}
+
+// tamper modifies the stored snapshot without signing it again
+func (m *mockSnapshotStore) tamper(uniqueSuffix string, modify func(snapshot *Snapshot)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(m.snapshots[uniqueSuffix], snapshot); err != nil {
+		panic(err)
+	}
+
+	modify(snapshot)
+
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		panic(err)
+	}
+
+	m.snapshots[uniqueSuffix] = content
+}
@@ -6,6 +6,12 @@ SPDX-License-Identifier: Apache-2.0
 
 package common
 
+import (
+	"net/http"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+)
+
 // HTTPError holds an error and an HTTP status code
 type HTTPError struct {
 	err    error
@@ -29,3 +35,49 @@ func (e *HTTPError) Error() string {
 func (e *HTTPError) Status() int {
 	return e.status
 }
+
+// Unwrap returns the wrapped error
+func (e *HTTPError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode returns the HTTP status code for the given error based on its error code
+func StatusCode(err error) int {
+	switch errs.CodeOf(err) {
+	case errs.NotFound:
+		return http.StatusNotFound
+	case errs.BadRequest, errs.InvalidSignature, errs.CommitmentMismatch,
+		errs.MissingField, errs.InvalidField, errs.InvalidDocument, errs.UnsupportedOperation:
+		return http.StatusBadRequest
+	case errs.Deactivated:
+		return http.StatusGone
+	case errs.Conflict:
+		return http.StatusConflict
+	case errs.RequestTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorCode returns the error code for the response. Errors without a code are classified by the given status code.
+func errorCode(status int, err error) errs.Code {
+	if code := errs.CodeOf(err); code != errs.Internal {
+		return code
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		return errs.NotFound
+	case http.StatusBadRequest:
+		return errs.BadRequest
+	case http.StatusGone:
+		return errs.Deactivated
+	case http.StatusConflict:
+		return errs.Conflict
+	case http.StatusRequestEntityTooLarge:
+		return errs.RequestTooLarge
+	default:
+		return errs.Internal
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
 func TestNewHTTPError(t *testing.T) {
@@ -20,4 +22,20 @@ func TestNewHTTPError(t *testing.T) {
 	require.NotNil(t, err)
 	require.Equal(t, http.StatusBadRequest, err.Status())
 	require.Equal(t, errExpected.Error(), err.Error())
+	require.True(t, errors.Is(err, errExpected))
+}
+
+func TestStatusCode(t *testing.T) {
+	require.Equal(t, http.StatusNotFound, StatusCode(errs.New(errs.NotFound, "not found")))
+	require.Equal(t, http.StatusBadRequest, StatusCode(errs.ErrBadRequest))
+	require.Equal(t, http.StatusBadRequest, StatusCode(errs.ErrInvalidSignature))
+	require.Equal(t, http.StatusBadRequest, StatusCode(errs.ErrCommitmentMismatch))
+	require.Equal(t, http.StatusBadRequest, StatusCode(errs.NewField(errs.MissingField, "delta", "missing delta")))
+	require.Equal(t, http.StatusBadRequest, StatusCode(errs.NewField(errs.InvalidField, "delta", "invalid delta")))
+	require.Equal(t, http.StatusBadRequest, StatusCode(errs.New(errs.InvalidDocument, "invalid document")))
+	require.Equal(t, http.StatusBadRequest, StatusCode(errs.New(errs.UnsupportedOperation, "not implemented")))
+	require.Equal(t, http.StatusGone, StatusCode(errs.ErrDeactivated))
+	require.Equal(t, http.StatusConflict, StatusCode(errs.ErrConflict))
+	require.Equal(t, http.StatusRequestEntityTooLarge, StatusCode(errs.New(errs.RequestTooLarge, "too large")))
+	require.Equal(t, http.StatusInternalServerError, StatusCode(errors.New("some error")))
 }
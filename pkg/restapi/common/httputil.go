@@ -9,8 +9,32 @@ package common
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
+// ErrorResponse is the (problem details style) body of an error response
+type ErrorResponse struct {
+	// Code is the stable error code
+	Code errs.Code `json:"code"`
+	// Message describes the error
+	Message string `json:"message"`
+	// Field is the (JSON) path of the request field that caused the error (if known)
+	Field string `json:"field,omitempty"`
+	// Status is the HTTP status code
+	Status int `json:"status"`
+}
+
+// NewErrorResponse returns the error response (with the code and field of the error) for the given error
+func NewErrorResponse(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Code:    errorCode(status, err),
+		Message: err.Error(),
+		Field:   errs.FieldOf(err),
+		Status:  status,
+	}
+}
+
 // WriteResponse writes a response to the response writer
 func WriteResponse(rw http.ResponseWriter, status int, v interface{}) {
 	rw.Header().Set("Content-Type", "application/did+ld+json")
@@ -21,13 +45,13 @@ func WriteResponse(rw http.ResponseWriter, status int, v interface{}) {
 	}
 }
 
-// WriteError writes an error to the response writer
+// WriteError writes an error (with the code and field of the error) to the response writer as JSON
 func WriteError(rw http.ResponseWriter, status int, err error) {
 	logger.Warnf("returning error status: %d, message: %s", status, err.Error())
 
-	rw.Header().Set("Content-Type", "text/plain")
+	rw.Header().Set("Content-Type", "application/problem+json")
 	rw.WriteHeader(status)
-	_, e := rw.Write([]byte(err.Error()))
+	e := json.NewEncoder(rw).Encode(NewErrorResponse(status, err))
 	if e != nil {
 		logger.Errorf("Unable to write response: %s", e)
 	}
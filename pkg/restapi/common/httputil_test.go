@@ -7,12 +7,15 @@ SPDX-License-Identifier: Apache-2.0
 package common
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 )
 
 func TestWriteResponse(t *testing.T) {
@@ -28,5 +31,50 @@ func TestWriteError(t *testing.T) {
 	errExpected := errors.New("some error")
 	WriteError(rw, http.StatusBadRequest, errExpected)
 	require.Equal(t, http.StatusBadRequest, rw.Code)
-	require.Equal(t, errExpected.Error(), rw.Body.String())
+	require.Equal(t, "application/problem+json", rw.Header().Get("content-type"))
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	require.Equal(t, ErrorResponse{Code: errs.BadRequest, Message: errExpected.Error(), Status: http.StatusBadRequest}, resp)
+
+	t.Run("error code", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		WriteError(rw, http.StatusInternalServerError, errs.New(errs.CommitmentMismatch, "commitments don't match"))
+		require.Equal(t, http.StatusInternalServerError, rw.Code)
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, ErrorResponse{Code: errs.CommitmentMismatch, Message: "commitments don't match", Status: http.StatusInternalServerError}, resp)
+	})
+
+	t.Run("field", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		WriteError(rw, http.StatusBadRequest, errs.NewField(errs.MissingField, "did_suffix", "missing did suffix"))
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, ErrorResponse{
+			Code:    errs.MissingField,
+			Message: "missing did suffix",
+			Field:   "did_suffix",
+			Status:  http.StatusBadRequest,
+		}, resp)
+	})
+
+	t.Run("status code", func(t *testing.T) {
+		for status, code := range map[int]errs.Code{
+			http.StatusNotFound:              errs.NotFound,
+			http.StatusGone:                  errs.Deactivated,
+			http.StatusConflict:              errs.Conflict,
+			http.StatusRequestEntityTooLarge: errs.RequestTooLarge,
+			http.StatusInternalServerError:   errs.Internal,
+		} {
+			rw := httptest.NewRecorder()
+			WriteError(rw, status, errors.New("some error"))
+
+			var resp ErrorResponse
+			require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+			require.Equal(t, code, resp.Code)
+		}
+	})
 }
@@ -0,0 +1,30 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package diddochandler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/dochandler"
+)
+
+// BatchHandler handles the submission of multiple DID operations in one request
+type BatchHandler struct {
+	*handler
+}
+
+// NewBatchHandler returns a new DID document batch handler
+func NewBatchHandler(basePath string, processor dochandler.Processor, opts ...dochandler.BatchOption) *BatchHandler {
+	return &BatchHandler{
+		handler: newHandler(
+			fmt.Sprintf("%s/operations/batch", basePath),
+			http.MethodPost,
+			dochandler.NewBatchHandler(processor, opts...).Update,
+		),
+	}
+}
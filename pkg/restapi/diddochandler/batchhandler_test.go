@@ -0,0 +1,47 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package diddochandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/dochandler"
+)
+
+func TestBatchHandler_Update(t *testing.T) {
+	docHandler := mocks.NewMockDocumentHandler().WithNamespace(namespace)
+	handler := NewBatchHandler(basePath, docHandler, dochandler.WithMaxRequestSize(1024*1024))
+	require.Equal(t, basePath+"/operations/batch", handler.Path())
+	require.Equal(t, http.MethodPost, handler.Method())
+	require.NotNil(t, handler.Handler())
+
+	createRequest, err := getCreateRequest()
+	require.NoError(t, err)
+	request, err := json.Marshal([]interface{}{createRequest})
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/document/operations/batch", bytes.NewReader(request))
+	handler.Handler()(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	id, err := getID(createRequest.SuffixData)
+	require.NoError(t, err)
+
+	var results []*dochandler.BatchResult
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	require.Equal(t, http.StatusOK, results[0].Status)
+	require.Equal(t, id, results[0].Result.Document.ID())
+}
@@ -29,6 +29,22 @@ package diddochandler
 //    default: error
 //        200: response
 
+// swagger:route POST /document/operations/batch submit-did-operations batchRequest
+// Submits multiple DID operations. Each operation is processed independently and
+// the result (or error) of each operation is returned in the same order as the requests.
+// Responses:
+//    default: error
+//        200: batchResponse
+//        413: error
+
+// swagger:route GET /document/operations/{id} get-operation-status operationStatusParams
+// Returns the status (queued, batched, anchored, confirmed or rejected) of an operation. The operation ID is
+// returned in the Sidetree-Operation-Id header when the operation is submitted.
+// Responses:
+//    default: error
+//        200: response
+//        404: error
+
 // Resolve swagger:route GET /document/{id} resolve-did-document resolveDocParams
 // Resolves a DID document by ID or by ID and initial value if provided.
 // Responses:
@@ -57,6 +73,28 @@ type responseWrapper struct {
 	Body string
 }
 
+// Contains the batch request.
+//swagger:parameters batchRequest
+//nolint:deadcode,unused
+type batchRequestWrapper struct {
+	// A JSON array of operation requests.
+	//
+	// required: true
+	// in: body
+	Body []string
+}
+
+// Contains the results of the operations of a batch request.
+//swagger:response batchResponse
+//nolint:deadcode,unused
+type batchResponseWrapper struct {
+	// A JSON array with the result of each operation.
+	//
+	// required: true
+	// in: body
+	Body []string
+}
+
 // Contains the error.
 //swagger:response error
 //nolint:deadcode,unused
@@ -80,3 +118,16 @@ type resolveDocumentParams struct {
 	// required: true
 	ID string `json:"id"`
 }
+
+// operationStatusParams model
+// This is used for getting the status of an operation
+//
+//swagger:parameters operationStatusParams
+//nolint:deadcode,unused
+type operationStatusParams struct {
+	// The operation ID.
+	//
+	// in: path
+	// required: true
+	ID string `json:"id"`
+}
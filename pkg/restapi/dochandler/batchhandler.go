@@ -0,0 +1,130 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dochandler
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
+	"github.com/trustbloc/sidetree-core-go/pkg/document"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/common"
+)
+
+const defaultMaxBatchRequestSize = 10 * 1024 * 1024
+
+// BatchResult is the result of one of the operations of a batch request
+type BatchResult struct {
+	// Index is the index of the operation in the batch request
+	Index int `json:"index"`
+	// OperationID is the ID of the operation which may be used to query the status of the operation
+	// (only returned if the operation was accepted)
+	OperationID string `json:"operationId,omitempty"`
+	// Status is the HTTP status code of the operation
+	Status int `json:"status"`
+	// Result is the resolution result (only returned for create operations)
+	Result *document.ResolutionResult `json:"result,omitempty"`
+	// Error is returned if the operation was rejected
+	Error *common.ErrorResponse `json:"error,omitempty"`
+}
+
+// BatchHandler handles the submission of multiple operations in one request
+type BatchHandler struct {
+	*UpdateHandler
+	maxRequestSize int64
+}
+
+// BatchOption is a batch handler option
+type BatchOption func(opts *BatchHandler)
+
+// WithMaxRequestSize sets the maximum size (in bytes) of a batch request
+func WithMaxRequestSize(size int64) BatchOption {
+	return func(opts *BatchHandler) {
+		opts.maxRequestSize = size
+	}
+}
+
+// NewBatchHandler returns a new batch handler
+func NewBatchHandler(processor Processor, opts ...BatchOption) *BatchHandler {
+	h := &BatchHandler{
+		UpdateHandler:  NewUpdateHandler(processor),
+		maxRequestSize: defaultMaxBatchRequestSize,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Update processes a JSON array of operation requests. Each operation is processed independently (i.e. the
+// operations that are valid are processed even if other operations in the batch are rejected) and the result
+// of each operation is returned in the same order as the requests.
+func (h *BatchHandler) Update(rw http.ResponseWriter, req *http.Request) {
+	requests, err := h.readRequests(req.Body)
+	if err != nil {
+		common.WriteError(rw, common.StatusCode(err), err)
+		return
+	}
+
+	logger.Debugf("Processing batch of %d operations", len(requests))
+
+	results := make([]*BatchResult, len(requests))
+
+	for i, request := range requests {
+		results[i] = h.process(i, request)
+	}
+
+	common.WriteResponse(rw, http.StatusOK, results)
+}
+
+func (h *BatchHandler) readRequests(body io.Reader) ([]json.RawMessage, error) {
+	// read one byte more than the maximum in order to detect requests that are too large
+	content, err := ioutil.ReadAll(io.LimitReader(body, h.maxRequestSize+1))
+	if err != nil {
+		return nil, errs.Wrap(errs.BadRequest, err, "read batch request")
+	}
+
+	if int64(len(content)) > h.maxRequestSize {
+		return nil, errs.New(errs.RequestTooLarge, "batch request exceeds maximum size of %d bytes", h.maxRequestSize)
+	}
+
+	var requests []json.RawMessage
+	if err := json.Unmarshal(content, &requests); err != nil {
+		return nil, errs.Wrap(errs.BadRequest, err, "batch request must be a JSON array of operation requests")
+	}
+
+	if len(requests) == 0 {
+		return nil, errs.New(errs.BadRequest, "batch request doesn't contain any operations")
+	}
+
+	return requests, nil
+}
+
+func (h *BatchHandler) process(index int, request []byte) *BatchResult {
+	result, err := h.doUpdate(request)
+	if err != nil {
+		status := err.(*common.HTTPError).Status()
+
+		return &BatchResult{
+			Index:  index,
+			Status: status,
+			Error:  common.NewErrorResponse(status, err),
+		}
+	}
+
+	return &BatchResult{
+		Index:       index,
+		OperationID: opstatus.ID(request),
+		Status:      http.StatusOK,
+		Result:      result,
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dochandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/common"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/helper"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
+)
+
+func TestBatchHandler_Update(t *testing.T) {
+	req, err := getCreateRequestInfo()
+	require.NoError(t, err)
+
+	create, err := helper.NewCreateRequest(req)
+	require.NoError(t, err)
+
+	var createReq model.CreateRequest
+	require.NoError(t, json.Unmarshal(create, &createReq))
+
+	uniqueSuffix, err := docutil.CalculateUniqueSuffix(createReq.SuffixData, sha2_256)
+	require.NoError(t, err)
+
+	id, err := docutil.CalculateID(namespace, createReq.SuffixData, sha2_256)
+	require.NoError(t, err)
+
+	update, err := helper.NewUpdateRequest(getUpdateRequestInfo(uniqueSuffix))
+	require.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch", newBatchRequest(create, update)))
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		var results []*BatchResult
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &results))
+		require.Len(t, results, 2)
+
+		require.Equal(t, 0, results[0].Index)
+		require.Equal(t, opstatus.ID(create), results[0].OperationID)
+		require.Equal(t, http.StatusOK, results[0].Status)
+		require.Nil(t, results[0].Error)
+		require.NotNil(t, results[0].Result)
+		require.Equal(t, id, results[0].Result.Document.ID())
+
+		require.Equal(t, 1, results[1].Index)
+		require.Equal(t, http.StatusOK, results[1].Status)
+		require.Nil(t, results[1].Error)
+	})
+	t.Run("partial success", func(t *testing.T) {
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch",
+			newBatchRequest(create, getUnsupportedRequest(), []byte(`"invalid"`), update)))
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		var results []*BatchResult
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &results))
+		require.Len(t, results, 4)
+
+		require.Equal(t, http.StatusOK, results[0].Status)
+		require.NotNil(t, results[0].Result)
+
+		require.Equal(t, 1, results[1].Index)
+		require.Equal(t, http.StatusBadRequest, results[1].Status)
+		require.Empty(t, results[1].OperationID)
+		require.Nil(t, results[1].Result)
+		require.Equal(t, errs.UnsupportedOperation, results[1].Error.Code)
+		require.Equal(t, "type", results[1].Error.Field)
+		require.Equal(t, http.StatusBadRequest, results[1].Error.Status)
+
+		require.Equal(t, 2, results[2].Index)
+		require.Equal(t, http.StatusBadRequest, results[2].Status)
+		require.Equal(t, errs.BadRequest, results[2].Error.Code)
+
+		require.Equal(t, 3, results[3].Index)
+		require.Equal(t, http.StatusOK, results[3].Status)
+	})
+	t.Run("processing error", func(t *testing.T) {
+		errExpected := errors.New("create doc error")
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace).WithError(errExpected))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch", newBatchRequest(create)))
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		var results []*BatchResult
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &results))
+		require.Len(t, results, 1)
+		require.Equal(t, http.StatusInternalServerError, results[0].Status)
+		require.Equal(t, common.ErrorResponse{
+			Code:    errs.Internal,
+			Message: errExpected.Error(),
+			Status:  http.StatusInternalServerError,
+		}, *results[0].Error)
+	})
+	t.Run("request too large", func(t *testing.T) {
+		body := newBatchRequest(create, update)
+
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace),
+			WithMaxRequestSize(int64(body.Len()-1)))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch", body))
+		require.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, errs.RequestTooLarge, resp.Code)
+		require.Contains(t, resp.Message, "batch request exceeds maximum size")
+	})
+	t.Run("maximum request size", func(t *testing.T) {
+		body := newBatchRequest(create, update)
+
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace),
+			WithMaxRequestSize(int64(body.Len())))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch", body))
+		require.Equal(t, http.StatusOK, rw.Code)
+	})
+	t.Run("not an array", func(t *testing.T) {
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch", bytes.NewReader(create)))
+		require.Equal(t, http.StatusBadRequest, rw.Code)
+		require.Contains(t, rw.Body.String(), "batch request must be a JSON array of operation requests")
+	})
+	t.Run("empty batch", func(t *testing.T) {
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch", strings.NewReader("[]")))
+		require.Equal(t, http.StatusBadRequest, rw.Code)
+		require.Contains(t, rw.Body.String(), "batch request doesn't contain any operations")
+	})
+	t.Run("read error", func(t *testing.T) {
+		handler := NewBatchHandler(mocks.NewMockDocumentHandler().WithNamespace(namespace))
+
+		rw := httptest.NewRecorder()
+		handler.Update(rw, httptest.NewRequest(http.MethodPost, "/document/batch", &failingReader{}))
+		require.Equal(t, http.StatusBadRequest, rw.Code)
+		require.Contains(t, rw.Body.String(), "read batch request")
+	})
+}
+
+func newBatchRequest(requests ...[]byte) *bytes.Buffer {
+	batch := make([]json.RawMessage, len(requests))
+	for i, r := range requests {
+		batch[i] = r
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		panic(err)
+	}
+
+	return bytes.NewBuffer(body)
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
@@ -7,24 +7,31 @@ SPDX-License-Identifier: Apache-2.0
 package dochandler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
-	"github.com/pkg/errors"
 	"github.com/trustbloc/edge-core/pkg/log"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/request"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/common"
 )
 
 var logger = log.New("sidetree-core-restapi-dochandler")
 
+const (
+	versionIDParam   = "versionId"
+	versionTimeParam = "versionTime"
+)
+
 // Resolver resolves documents
 type Resolver interface {
 	Namespace() string
-	ResolveDocument(idOrDocument string) (*document.ResolutionResult, error)
+	ResolveDocument(idOrDocument string, opts ...document.ResolutionOption) (*document.ResolutionResult, error)
 }
 
 // ResolveHandler resolves generic documents
@@ -43,35 +50,46 @@ func NewResolveHandler(resolver Resolver) *ResolveHandler {
 func (o *ResolveHandler) Resolve(rw http.ResponseWriter, req *http.Request) {
 	id := getID(o.resolver.Namespace(), req)
 	logger.Debugf("Resolving DID document for ID [%s]", id)
-	response, err := o.doResolve(id)
+
+	opts, err := getResolutionOptions(req)
+	if err != nil {
+		common.WriteError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	response, err := o.doResolve(id, opts...)
 	if err != nil {
 		common.WriteError(rw, err.(*common.HTTPError).Status(), err)
 		return
 	}
+	if response.DocumentMetadata.Deactivated {
+		logger.Debugf("... resolved deactivated DID document for ID [%s]", id)
+		common.WriteResponse(rw, http.StatusGone, response)
+		return
+	}
+
 	logger.Debugf("... resolved DID document for ID [%s]: %s", id, response.Document)
 	common.WriteResponse(rw, http.StatusOK, response)
 }
 
-func (o *ResolveHandler) doResolve(id string) (*document.ResolutionResult, error) {
+func (o *ResolveHandler) doResolve(id string, opts ...document.ResolutionOption) (*document.ResolutionResult, error) {
 	if !strings.HasPrefix(id, o.resolver.Namespace()) {
 		logger.Errorf("DID ID [%s] does not start with supported namespace [%s]", id, o.resolver.Namespace())
-		return nil, common.NewHTTPError(http.StatusBadRequest, errors.New("must start with supported namespace"))
+		return nil, common.NewHTTPError(http.StatusBadRequest, errs.New(errs.BadRequest, "must start with supported namespace"))
 	}
 
-	doc, err := o.resolver.ResolveDocument(id)
+	doc, err := o.resolver.ResolveDocument(id, opts...)
 	if err != nil {
-		if strings.Contains(err.Error(), "bad request") {
-			return nil, common.NewHTTPError(http.StatusBadRequest, err)
+		if errors.Is(err, errs.ErrNotFound) {
+			return nil, common.NewHTTPError(http.StatusNotFound, errs.New(errs.NotFound, "document not found"))
 		}
-		if strings.Contains(err.Error(), "not found") {
-			return nil, common.NewHTTPError(http.StatusNotFound, errors.New("document not found"))
-		}
-		if strings.Contains(err.Error(), "was deactivated") {
-			return nil, common.NewHTTPError(http.StatusGone, errors.New("document is no longer available"))
+
+		status := common.StatusCode(err)
+		if status == http.StatusInternalServerError {
+			logger.Errorf("internal server error:  %s", err.Error())
 		}
 
-		logger.Errorf("internal server error:  %s", err.Error())
-		return nil, common.NewHTTPError(http.StatusInternalServerError, err)
+		return nil, common.NewHTTPError(status, err)
 	}
 
 	return doc, nil
@@ -91,3 +109,43 @@ func getInitialState(namespace string, req *http.Request) string {
 
 	return ""
 }
+
+// getResolutionOptions returns the options for resolving a historical version of the document
+// from the versionId and versionTime query parameters
+func getResolutionOptions(req *http.Request) ([]document.ResolutionOption, error) {
+	var opts []document.ResolutionOption
+
+	if value := req.URL.Query().Get(versionIDParam); value != "" {
+		versionID, err := document.ParseVersionID(value)
+		if err != nil {
+			return nil, errs.New(errs.BadRequest, "invalid %s [%s]: %s", versionIDParam, value, err)
+		}
+
+		opts = append(opts, document.WithVersionID(versionID))
+	}
+
+	versionTime, err := getUintParam(req, versionTimeParam)
+	if err != nil {
+		return nil, err
+	}
+
+	if versionTime != nil {
+		opts = append(opts, document.WithVersionTime(*versionTime))
+	}
+
+	return opts, nil
+}
+
+func getUintParam(req *http.Request, name string) (*uint64, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, errs.New(errs.BadRequest, "invalid %s: %s", name, value)
+	}
+
+	return &n, nil
+}
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package dochandler
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -16,12 +17,15 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/canonicalizer"
 	"github.com/trustbloc/sidetree-core-go/pkg/internal/request"
 	"github.com/trustbloc/sidetree-core-go/pkg/jws"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
 	"github.com/trustbloc/sidetree-core-go/pkg/patch"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/common"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 )
 
@@ -120,6 +124,27 @@ func TestResolveHandler_Resolve(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/document", nil)
 		handler.Resolve(rw, req)
 		require.Equal(t, http.StatusNotFound, rw.Code)
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, common.ErrorResponse{Code: errs.NotFound, Message: "document not found", Status: http.StatusNotFound}, resp)
+	})
+	t.Run("Deactivated error", func(t *testing.T) {
+		getID = func(namespace string, req *http.Request) string {
+			return namespace + docutil.NamespaceDelimiter + "someid"
+		}
+		docHandler := mocks.NewMockDocumentHandler().WithNamespace(namespace).
+			WithError(errs.New(errs.Deactivated, "update cannot be applied to a deactivated document"))
+		handler := NewResolveHandler(docHandler)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/document", nil)
+		handler.Resolve(rw, req)
+		require.Equal(t, http.StatusGone, rw.Code)
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, errs.Deactivated, resp.Code)
 	})
 	t.Run("Error", func(t *testing.T) {
 		getID = func(namespace string, req *http.Request) string {
@@ -134,6 +159,7 @@ func TestResolveHandler_Resolve(t *testing.T) {
 		handler.Resolve(rw, req)
 		require.Equal(t, http.StatusInternalServerError, rw.Code)
 		require.Contains(t, rw.Body.String(), errExpected.Error())
+		require.Contains(t, rw.Body.String(), string(errs.Internal))
 	})
 	t.Run("Document is no longer available", func(t *testing.T) {
 		docHandler := mocks.NewMockDocumentHandler().WithNamespace(namespace)
@@ -168,9 +194,65 @@ func TestResolveHandler_Resolve(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/document", nil)
 		handler.Resolve(rw, req)
 		require.Equal(t, http.StatusGone, rw.Code)
+
+		var resp document.ResolutionResult
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Empty(t, resp.Document)
+		require.True(t, resp.DocumentMetadata.Deactivated)
 	})
 }
 
+func TestResolveHandler_ResolveVersion(t *testing.T) {
+	getID = func(namespace string, req *http.Request) string {
+		return namespace + docutil.NamespaceDelimiter + "someid"
+	}
+
+	t.Run("success", func(t *testing.T) {
+		resolver := &mockResolver{}
+		handler := NewResolveHandler(resolver)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/document?versionId=2-1&versionTime=10", nil)
+		handler.Resolve(rw, req)
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		opts := document.GetResolutionOptions(resolver.opts...)
+		require.Equal(t, document.VersionID{TransactionNumber: 2, OperationIndex: 1}, *opts.VersionID)
+		require.Equal(t, uint64(10), *opts.VersionTime)
+	})
+
+	t.Run("invalid version - bad request error", func(t *testing.T) {
+		resolver := &mockResolver{}
+		handler := NewResolveHandler(resolver)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/document?versionId=abc", nil)
+		handler.Resolve(rw, req)
+		require.Equal(t, http.StatusBadRequest, rw.Code)
+		require.Contains(t, rw.Body.String(), "invalid versionId [abc]")
+	})
+}
+
+func TestGetResolutionOptions(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/document", nil)
+	opts, err := getResolutionOptions(req)
+	require.NoError(t, err)
+	require.Empty(t, opts)
+
+	req = httptest.NewRequest(http.MethodGet, "/document?versionTime=10", nil)
+	opts, err = getResolutionOptions(req)
+	require.NoError(t, err)
+
+	resolutionOpts := document.GetResolutionOptions(opts...)
+	require.Nil(t, resolutionOpts.VersionID)
+	require.Equal(t, uint64(10), *resolutionOpts.VersionTime)
+
+	req = httptest.NewRequest(http.MethodGet, "/document?versionTime=-1", nil)
+	opts, err = getResolutionOptions(req)
+	require.EqualError(t, err, "invalid versionTime: -1")
+	require.Nil(t, opts)
+}
+
 func TestGetInitialState(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/document", nil)
 	initialState := getInitialState(namespace, req)
@@ -228,3 +310,17 @@ var testJWK = &jws.JWK{
 	Crv: "crv",
 	X:   "x",
 }
+
+type mockResolver struct {
+	opts []document.ResolutionOption
+}
+
+func (m *mockResolver) Namespace() string {
+	return namespace
+}
+
+func (m *mockResolver) ResolveDocument(id string, opts ...document.ResolutionOption) (*document.ResolutionResult, error) {
+	m.opts = opts
+
+	return &document.ResolutionResult{Document: document.Document{"id": id}}, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dochandler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/common"
+)
+
+// OperationIDHeader is the response header that contains the ID of the submitted operation. The ID may be used
+// to query the status of the operation.
+const OperationIDHeader = "Sidetree-Operation-Id"
+
+// StatusProvider returns the status of operations
+type StatusProvider interface {
+	Get(id string) (*opstatus.OperationStatus, error)
+}
+
+// StatusHandler returns the status of submitted operations
+type StatusHandler struct {
+	provider StatusProvider
+}
+
+// NewStatusHandler returns a new operation status handler
+func NewStatusHandler(provider StatusProvider) *StatusHandler {
+	return &StatusHandler{
+		provider: provider,
+	}
+}
+
+// GetStatus returns the status (queued, batched, anchored, confirmed or rejected) of an operation.
+// Not found is returned if the status of the operation isn't known (e.g. the status store isn't durable).
+func (h *StatusHandler) GetStatus(rw http.ResponseWriter, req *http.Request) {
+	id := getOperationID(req)
+	logger.Debugf("Getting status of operation [%s]", id)
+
+	status, err := h.provider.Get(id)
+	if err != nil {
+		if errors.Is(err, errs.ErrNotFound) {
+			common.WriteError(rw, http.StatusNotFound, errs.New(errs.NotFound, "operation not found"))
+			return
+		}
+
+		common.WriteError(rw, common.StatusCode(err), err)
+
+		return
+	}
+
+	common.WriteResponse(rw, http.StatusOK, status)
+}
+
+var getOperationID = func(req *http.Request) string {
+	return mux.Vars(req)["id"]
+}
@@ -0,0 +1,69 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dochandler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/common"
+)
+
+func TestStatusHandler_GetStatus(t *testing.T) {
+	const id = "op1"
+
+	getOperationID = func(req *http.Request) string { return id }
+
+	t.Run("success", func(t *testing.T) {
+		store := mocks.NewMockOperationStatusStore()
+		require.NoError(t, store.Put(&opstatus.OperationStatus{
+			ID:           id,
+			UniqueSuffix: "abc",
+			Status:       opstatus.Rejected,
+			AnchorString: "1.anchor",
+			Reason:       "document was deactivated",
+		}))
+
+		rw := httptest.NewRecorder()
+		NewStatusHandler(store).GetStatus(rw, httptest.NewRequest(http.MethodGet, "/operations/"+id, nil))
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		var status opstatus.OperationStatus
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+		require.Equal(t, opstatus.Rejected, status.Status)
+		require.Equal(t, "1.anchor", status.AnchorString)
+		require.Equal(t, "document was deactivated", status.Reason)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewStatusHandler(mocks.NewMockOperationStatusStore()).GetStatus(rw, httptest.NewRequest(http.MethodGet, "/operations/"+id, nil))
+		require.Equal(t, http.StatusNotFound, rw.Code)
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, common.ErrorResponse{Code: errs.NotFound, Message: "operation not found", Status: http.StatusNotFound}, resp)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		store := mocks.NewMockOperationStatusStore()
+		store.Err = errors.New("status store error")
+
+		rw := httptest.NewRecorder()
+		NewStatusHandler(store).GetStatus(rw, httptest.NewRequest(http.MethodGet, "/operations/"+id, nil))
+		require.Equal(t, http.StatusInternalServerError, rw.Code)
+		require.Contains(t, rw.Body.String(), "status store error")
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/operation"
@@ -21,7 +22,7 @@ import (
 type Processor interface {
 	Namespace() string
 	Protocol() protocol.Client
-	ProcessOperation(operation *batch.Operation) (*document.ResolutionResult, error)
+	ProcessOperation(operation *batch.Operation, opts ...batch.ProcessOption) (*document.ResolutionResult, error)
 }
 
 // UpdateHandler handles the creation and update of documents
@@ -49,6 +50,8 @@ func (h *UpdateHandler) Update(rw http.ResponseWriter, req *http.Request) {
 		common.WriteError(rw, err.(*common.HTTPError).Status(), err)
 		return
 	}
+
+	rw.Header().Set(OperationIDHeader, opstatus.ID(request))
 	common.WriteResponse(rw, http.StatusOK, response)
 }
 
@@ -62,8 +65,14 @@ func (h *UpdateHandler) doUpdate(request []byte) (*document.ResolutionResult, er
 	// operation has been validated, now process it
 	result, err := h.processor.ProcessOperation(operation)
 	if err != nil {
-		logger.Errorf("internal server error:  %s", err.Error())
-		return nil, common.NewHTTPError(http.StatusInternalServerError, err)
+		status := common.StatusCode(err)
+		if status == http.StatusInternalServerError {
+			logger.Errorf("internal server error:  %s", err.Error())
+		} else {
+			logger.Warnf("operation processing error: %s", err.Error())
+		}
+
+		return nil, common.NewHTTPError(status, err)
 	}
 
 	return result, nil
@@ -20,11 +20,14 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/trustbloc/sidetree-core-go/pkg/api/opstatus"
 	"github.com/trustbloc/sidetree-core-go/pkg/commitment"
 	"github.com/trustbloc/sidetree-core-go/pkg/document"
 	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+	"github.com/trustbloc/sidetree-core-go/pkg/errs"
 	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
 	"github.com/trustbloc/sidetree-core-go/pkg/patch"
+	"github.com/trustbloc/sidetree-core-go/pkg/restapi/common"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/helper"
 	"github.com/trustbloc/sidetree-core-go/pkg/restapi/model"
 	"github.com/trustbloc/sidetree-core-go/pkg/util/ecsigner"
@@ -64,6 +67,7 @@ func TestUpdateHandler_Update(t *testing.T) {
 		handler.Update(rw, req)
 		require.Equal(t, http.StatusOK, rw.Code)
 		require.Equal(t, "application/did+ld+json", rw.Header().Get("content-type"))
+		require.Equal(t, opstatus.ID(create), rw.Header().Get(OperationIDHeader))
 
 		body, err := ioutil.ReadAll(rw.Body)
 		require.NoError(t, err)
@@ -111,6 +115,38 @@ func TestUpdateHandler_Update(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/document", bytes.NewReader(getUnsupportedRequest()))
 		handler.Update(rw, req)
 		require.Equal(t, http.StatusBadRequest, rw.Code)
+		require.Equal(t, "application/problem+json", rw.Header().Get("content-type"))
+		require.Empty(t, rw.Header().Get(OperationIDHeader))
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, errs.UnsupportedOperation, resp.Code)
+		require.Equal(t, "type", resp.Field)
+		require.Equal(t, http.StatusBadRequest, resp.Status)
+	})
+	t.Run("Missing field", func(t *testing.T) {
+		var updateReq model.UpdateRequest
+		update, err := helper.NewUpdateRequest(getUpdateRequestInfo(uniqueSuffix))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(update, &updateReq))
+
+		updateReq.SignedData = ""
+		update, err = json.Marshal(updateReq)
+		require.NoError(t, err)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/document", bytes.NewReader(update))
+		handler.Update(rw, req)
+		require.Equal(t, http.StatusBadRequest, rw.Code)
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, common.ErrorResponse{
+			Code:    errs.MissingField,
+			Message: "missing signed data",
+			Field:   "signed_data",
+			Status:  http.StatusBadRequest,
+		}, resp)
 	})
 	t.Run("Bad Request", func(t *testing.T) {
 		rw := httptest.NewRecorder()
@@ -129,6 +165,36 @@ func TestUpdateHandler_Update(t *testing.T) {
 		require.Equal(t, http.StatusInternalServerError, rw.Code)
 		require.Contains(t, rw.Body.String(), errExpected.Error())
 	})
+	t.Run("Pending operation conflict", func(t *testing.T) {
+		errExpected := errs.New(errs.Conflict, "an operation for unique suffix [%s] is already pending", uniqueSuffix)
+		docHandlerWithErr := mocks.NewMockDocumentHandler().WithNamespace(namespace).WithError(errExpected)
+		handler := NewUpdateHandler(docHandlerWithErr)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/document", bytes.NewReader(create))
+		handler.Update(rw, req)
+		require.Equal(t, http.StatusConflict, rw.Code)
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, errs.Conflict, resp.Code)
+		require.Equal(t, errExpected.Error(), resp.Message)
+	})
+	t.Run("Processing error", func(t *testing.T) {
+		errExpected := errs.NewField(errs.InvalidDocument, "delta.patches", "invalid original document")
+		docHandlerWithErr := mocks.NewMockDocumentHandler().WithNamespace(namespace).WithError(errExpected)
+		handler := NewUpdateHandler(docHandlerWithErr)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/document", bytes.NewReader(create))
+		handler.Update(rw, req)
+		require.Equal(t, http.StatusBadRequest, rw.Code)
+
+		var resp common.ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+		require.Equal(t, errs.InvalidDocument, resp.Code)
+		require.Equal(t, "delta.patches", resp.Field)
+	})
 }
 
 func getCreateRequestInfo() (*helper.CreateRequestInfo, error) {
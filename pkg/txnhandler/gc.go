@@ -0,0 +1,262 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txnhandler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/cas"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+)
+
+// DefaultGracePeriod is the default period for which a file must be unreferenced before it's deleted
+const DefaultGracePeriod = time.Hour
+
+// TxnReader reads the sidetree transactions that were anchored on the ledger
+type TxnReader interface {
+	// Read returns the transaction following the given transaction number (-1 for the first transaction)
+	// and true if there are more transactions
+	Read(sinceTransactionNumber int) (bool, *txn.SidetreeTxn)
+}
+
+// GarbageCollector finds the files in CAS that aren't referenced by any transaction of the namespace,
+// i.e. files that aren't reachable from the anchor file of an anchored transaction (e.g. files that were
+// written by a failed attempt to prepare a batch), and deletes them.
+//
+// The files of a batch that is being written aren't referenced by a transaction until the anchor of the batch is
+// confirmed, so the files of the batch writer should be retained (see WithRetainedFiles). The manifest should be
+// persistent (see NewPersistentWriteManifest) so that the files of an anchor that was written before the batch writer
+// was restarted are retained until the anchor is confirmed. In addition, a file is deleted only if it has been
+// unreferenced for the grace period (see WithGracePeriod), e.g. so that the files that are being written by a batch
+// writer that doesn't share the manifest (such as another process) aren't deleted before the batch is prepared.
+// Note that the time since a file is unreferenced is tracked by the garbage collector (i.e. it's reset on restart).
+//
+// The CAS must not be shared with other namespaces unless the garbage collector is aware of them (see
+// WithSharedNamespaces). An error is returned if an anchor file of another namespace is found in CAS. (A CAS that
+// is shared with parties that don't anchor their files on the ledger, e.g. public IPFS, must not be garbage
+// collected at all.)
+type GarbageCollector struct {
+	mutex       sync.Mutex
+	namespace   string
+	namespaces  map[string]bool
+	cas         cas.DeletableClient
+	ledger      TxnReader
+	provider    *OperationProvider
+	retained    []*WriteManifest
+	gracePeriod time.Duration
+	// unreferenced maps the addresses of the unreferenced files to the time when they were found to be unreferenced
+	unreferenced map[string]time.Time
+	now          func() time.Time
+}
+
+// GarbageCollectorOption is an option for garbage collector
+type GarbageCollectorOption func(gc *GarbageCollector)
+
+// WithRetainedFiles retains the files of the given manifest (i.e. the files of the batches that weren't confirmed
+// yet and the files that may be reused when a batch is prepared again) even though they aren't referenced by any
+// transaction
+func WithRetainedFiles(manifest *WriteManifest) GarbageCollectorOption {
+	return func(gc *GarbageCollector) {
+		gc.retained = append(gc.retained, manifest)
+	}
+}
+
+// WithGracePeriod sets the period for which a file must be unreferenced before it's deleted (default 1 hour)
+func WithGracePeriod(period time.Duration) GarbageCollectorOption {
+	return func(gc *GarbageCollector) {
+		gc.gracePeriod = period
+	}
+}
+
+// WithSharedNamespaces retains the files that are referenced by the transactions of the given namespaces,
+// i.e. the namespaces that share the CAS with the namespace of the garbage collector
+func WithSharedNamespaces(namespaces ...string) GarbageCollectorOption {
+	return func(gc *GarbageCollector) {
+		for _, namespace := range namespaces {
+			gc.namespaces[namespace] = true
+		}
+	}
+}
+
+// NewGarbageCollector returns a new garbage collector for the files of the given namespace
+func NewGarbageCollector(namespace string, cas cas.DeletableClient, ledger TxnReader, pcp protocol.ClientProvider, dp decompressionProvider, opts ...GarbageCollectorOption) *GarbageCollector {
+	gc := &GarbageCollector{
+		namespace:    namespace,
+		namespaces:   map[string]bool{namespace: true},
+		cas:          cas,
+		ledger:       ledger,
+		provider:     NewOperationProvider(cas, pcp, dp),
+		gracePeriod:  DefaultGracePeriod,
+		unreferenced: make(map[string]time.Time),
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(gc)
+	}
+
+	return gc
+}
+
+// Orphans returns the addresses of the files in CAS that aren't referenced by any transaction of the namespace
+// (and the shared namespaces) and that have been unreferenced for the grace period.
+// An error is returned if the files of a transaction can't be read since the files that are referenced by
+// the transaction are unknown in this case.
+func (gc *GarbageCollector) Orphans() ([]string, error) {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+
+	return gc.orphans()
+}
+
+// Collect deletes the files in CAS that aren't referenced by any transaction of the namespace (and the shared
+// namespaces) and that have been unreferenced for the grace period
+// returns the addresses of the deleted files
+func (gc *GarbageCollector) Collect() ([]string, error) {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+
+	orphans, err := gc.orphans()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, address := range orphans {
+		if err := gc.cas.Delete(address); err != nil {
+			return deleted, errors.Wrapf(err, "failed to delete CAS content[%s]", address)
+		}
+
+		delete(gc.unreferenced, address)
+
+		deleted = append(deleted, address)
+	}
+
+	logger.Infof("[%s] Deleted %d orphaned file(s) from CAS", gc.namespace, len(deleted))
+
+	return deleted, nil
+}
+
+func (gc *GarbageCollector) orphans() ([]string, error) {
+	addresses, err := gc.cas.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list CAS content")
+	}
+
+	stored := make(map[string]bool)
+	for _, address := range addresses {
+		stored[address] = true
+	}
+
+	referenced, err := gc.getReferencedFiles(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifest := range gc.retained {
+		for _, address := range manifest.Addresses() {
+			referenced[address] = true
+		}
+	}
+
+	now := gc.now()
+	unreferenced := make(map[string]time.Time)
+
+	var orphans []string
+	for _, address := range addresses {
+		if referenced[address] {
+			continue
+		}
+
+		since, ok := gc.unreferenced[address]
+		if !ok {
+			since = now
+		}
+
+		unreferenced[address] = since
+
+		if now.Sub(since) >= gc.gracePeriod {
+			orphans = append(orphans, address)
+		}
+	}
+
+	// files that are referenced again (e.g. a file that was reused by a batch) are no longer tracked
+	gc.unreferenced = unreferenced
+
+	return orphans, nil
+}
+
+// getReferencedFiles returns the addresses of the files that are referenced by the transactions of the namespaces.
+// An error is returned if the anchor file of a transaction of another namespace is stored in CAS.
+func (gc *GarbageCollector) getReferencedFiles(stored map[string]bool) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+
+	sinceTransactionNumber := -1
+	for {
+		more, sidetreeTxn := gc.ledger.Read(sinceTransactionNumber)
+		if sidetreeTxn == nil {
+			return referenced, nil
+		}
+
+		sinceTransactionNumber = int(sidetreeTxn.TransactionNumber)
+
+		if gc.namespaces[sidetreeTxn.Namespace] {
+			if err := gc.addReferencedFiles(sidetreeTxn, referenced); err != nil {
+				return nil, errors.WithMessagef(err, "failed to get files of transaction[%d]", sidetreeTxn.TransactionNumber)
+			}
+		} else if anchorData, err := ParseAnchorData(sidetreeTxn.AnchorString); err == nil && stored[anchorData.AnchorAddress] {
+			return nil, fmt.Errorf("CAS is shared with namespace [%s] of transaction[%d]", sidetreeTxn.Namespace, sidetreeTxn.TransactionNumber)
+		}
+
+		if !more {
+			return referenced, nil
+		}
+	}
+}
+
+// addReferencedFiles adds the addresses of the anchor, map and chunk files of the given transaction
+func (gc *GarbageCollector) addReferencedFiles(sidetreeTxn *txn.SidetreeTxn, referenced map[string]bool) error {
+	anchorData, err := ParseAnchorData(sidetreeTxn.AnchorString)
+	if err != nil {
+		return err
+	}
+
+	p, err := gc.provider.getProtocol(sidetreeTxn)
+	if err != nil {
+		return err
+	}
+
+	referenced[anchorData.AnchorAddress] = true
+
+	af, err := gc.provider.getAnchorFile(anchorData.AnchorAddress, *p)
+	if err != nil {
+		return err
+	}
+
+	// there's no map file if all of the operations in the batch are deactivate operations
+	if af.MapFileHash == "" {
+		return nil
+	}
+
+	referenced[af.MapFileHash] = true
+
+	mf, err := gc.provider.getMapFile(af.MapFileHash, *p)
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range mf.Chunks {
+		referenced[chunk.ChunkFileURI] = true
+	}
+
+	return nil
+}
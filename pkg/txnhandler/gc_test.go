@@ -0,0 +1,336 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txnhandler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/cas"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+	"github.com/trustbloc/sidetree-core-go/pkg/compression"
+	"github.com/trustbloc/sidetree-core-go/pkg/mocks"
+)
+
+func TestGarbageCollector(t *testing.T) {
+	cp := compression.New(compression.WithDefaultAlgorithms())
+
+	t.Run("success", func(t *testing.T) {
+		cas := &failingCasClient{MockCasClient: mocks.NewMockCasClient(nil), failAt: 3}
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		handler := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp)
+
+		// the chunk and map files of the failed attempt are orphans
+		_, err := handler.PrepareTxnFiles(getTestOperations(2, 1, 1, 1))
+		require.Error(t, err)
+
+		orphans := handler.manifest.Addresses()
+
+		// the second batch contains only a deactivate operation (i.e. there's no map file)
+		for _, ops := range [][]*batch.Operation{getTestOperations(1, 1, 0, 0), getTestOperations(0, 0, 1, 0)} {
+			anchorString, e := handler.PrepareTxnFiles(ops)
+			require.NoError(t, e)
+			require.NoError(t, blockchain.WriteAnchor(anchorString))
+		}
+
+		// the files of a batch that was never anchored are orphans
+		anchorString, err := handler.PrepareTxnFiles(getTestOperations(0, 2, 0, 0))
+		require.NoError(t, err)
+
+		orphans = append(orphans, getBatchFileAddresses(t, cas, anchorString)...)
+
+		gc := NewGarbageCollector(defaultNS, cas, blockchain, mocks.NewMockProtocolClientProvider(), cp, WithGracePeriod(0))
+
+		addresses, err := gc.Orphans()
+		require.NoError(t, err)
+		require.ElementsMatch(t, orphans, addresses)
+
+		deleted, err := gc.Collect()
+		require.NoError(t, err)
+		require.ElementsMatch(t, addresses, deleted)
+
+		addresses, err = gc.Orphans()
+		require.NoError(t, err)
+		require.Empty(t, addresses)
+
+		// the files of the anchored transactions weren't deleted
+		requireTxnOperations(t, cas, blockchain, 0, 1)
+	})
+
+	t.Run("success - batch files are retained until the anchor is confirmed", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		blockchain := mocks.NewMockBlockchainClient(nil)
+		manifest := NewWriteManifest()
+
+		handler := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp, WithWriteManifest(manifest))
+
+		gc := NewGarbageCollector(defaultNS, cas, blockchain, mocks.NewMockProtocolClientProvider(), cp,
+			WithRetainedFiles(manifest), WithGracePeriod(0))
+
+		anchorString, err := handler.PrepareTxnFiles(getTestOperations(1, 1, 1, 1))
+		require.NoError(t, err)
+
+		// the garbage collector runs before the transaction is on the ledger
+		deleted, err := gc.Collect()
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+
+		require.NoError(t, blockchain.WriteAnchor(anchorString))
+
+		// the files are released once the anchor was confirmed
+		handler.ReleaseTxnFiles(anchorString)
+
+		deleted, err = gc.Collect()
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+
+		requireTxnOperations(t, cas, blockchain, 0)
+	})
+
+	t.Run("success - retained files", func(t *testing.T) {
+		cas := &failingCasClient{MockCasClient: mocks.NewMockCasClient(nil), failAt: 2}
+		manifest := NewWriteManifest()
+
+		handler := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp, WithWriteManifest(manifest))
+
+		_, err := handler.PrepareTxnFiles(getTestOperations(1, 1, 0, 0))
+		require.Error(t, err)
+		require.Len(t, manifest.Addresses(), 1)
+
+		gc := NewGarbageCollector(defaultNS, cas, mocks.NewMockBlockchainClient(nil),
+			mocks.NewMockProtocolClientProvider(), cp, WithRetainedFiles(manifest), WithGracePeriod(0))
+
+		addresses, err := gc.Collect()
+		require.NoError(t, err)
+		require.Empty(t, addresses)
+
+		// the retained file is reused on retry
+		_, err = handler.PrepareTxnFiles(getTestOperations(1, 1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, 4, cas.writes)
+	})
+
+	t.Run("success - grace period", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		manifest := NewWriteManifest()
+
+		orphan1, err := cas.Write([]byte("orphan1"))
+		require.NoError(t, err)
+
+		gc := NewGarbageCollector(defaultNS, cas, mocks.NewMockBlockchainClient(nil),
+			mocks.NewMockProtocolClientProvider(), cp, WithRetainedFiles(manifest))
+
+		now := time.Now()
+		gc.now = func() time.Time { return now }
+
+		deleted, err := gc.Collect()
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+
+		orphan2, err := cas.Write([]byte("orphan2"))
+		require.NoError(t, err)
+
+		// the file is referenced again (e.g. it's reused by a batch), so its grace period starts over
+		manifest.Put([]byte("orphan1"), orphan1)
+
+		now = now.Add(DefaultGracePeriod / 2)
+
+		deleted, err = gc.Collect()
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+
+		manifest.Prepared("anchor", nil)
+
+		now = now.Add(DefaultGracePeriod / 2)
+
+		deleted, err = gc.Collect()
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+
+		now = now.Add(DefaultGracePeriod / 2)
+
+		deleted, err = gc.Collect()
+		require.NoError(t, err)
+		require.Equal(t, []string{orphan2}, deleted)
+
+		now = now.Add(DefaultGracePeriod / 2)
+
+		deleted, err = gc.Collect()
+		require.NoError(t, err)
+		require.Equal(t, []string{orphan1}, deleted)
+	})
+
+	t.Run("success - shared namespaces", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		handler := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp)
+
+		for _, namespace := range []string{defaultNS, "other"} {
+			anchorString, err := handler.PrepareTxnFiles(getTestOperations(1, 1, 0, 0))
+			require.NoError(t, err)
+			require.NoError(t, blockchain.WriteNamespaceAnchor(namespace, anchorString))
+		}
+
+		pcp := &mocks.MockProtocolClientProvider{
+			ProtocolClients: map[string]protocol.Client{
+				defaultNS: mocks.NewMockProtocolClient(),
+				"other":   mocks.NewMockProtocolClient(),
+			},
+		}
+
+		gc := NewGarbageCollector(defaultNS, cas, blockchain, pcp, cp, WithSharedNamespaces("other"), WithGracePeriod(0))
+
+		deleted, err := gc.Collect()
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+	})
+
+	t.Run("error - CAS is shared with another namespace", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		anchorString, err := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp).
+			PrepareTxnFiles(getTestOperations(1, 1, 0, 0))
+		require.NoError(t, err)
+		require.NoError(t, blockchain.WriteNamespaceAnchor("other", anchorString))
+
+		// the anchor of another namespace whose files aren't stored in CAS is ignored
+		require.NoError(t, blockchain.WriteNamespaceAnchor("other", "1.address"))
+
+		gc := NewGarbageCollector(defaultNS, cas, blockchain, mocks.NewMockProtocolClientProvider(), cp, WithGracePeriod(0))
+
+		// nothing is deleted since the files of the other namespace are unknown
+		deleted, err := gc.Collect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "CAS is shared with namespace [other] of transaction[0]")
+		require.Empty(t, deleted)
+	})
+
+	t.Run("error - transaction files can't be read", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		anchorString, err := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp).
+			PrepareTxnFiles(getTestOperations(1, 1, 0, 0))
+		require.NoError(t, err)
+		require.NoError(t, blockchain.WriteAnchor(anchorString))
+
+		anchorData, err := ParseAnchorData(anchorString)
+		require.NoError(t, err)
+		require.NoError(t, cas.Delete(anchorData.AnchorAddress))
+
+		gc := NewGarbageCollector(defaultNS, cas, blockchain, mocks.NewMockProtocolClientProvider(), cp)
+
+		// nothing is deleted since the files of the transaction are unknown
+		addresses, err := gc.Collect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to get files of transaction[0]")
+		require.Empty(t, addresses)
+
+		addresses, err = cas.List()
+		require.NoError(t, err)
+		require.Len(t, addresses, 2)
+	})
+
+	t.Run("error - invalid anchor string", func(t *testing.T) {
+		blockchain := mocks.NewMockBlockchainClient(nil)
+		require.NoError(t, blockchain.WriteAnchor("invalid"))
+
+		gc := NewGarbageCollector(defaultNS, mocks.NewMockCasClient(nil), blockchain, mocks.NewMockProtocolClientProvider(), cp)
+
+		_, err := gc.Orphans()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to get files of transaction[0]")
+	})
+
+	t.Run("error - protocol not found", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		blockchain := mocks.NewMockBlockchainClient(nil)
+
+		anchorString, err := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp).
+			PrepareTxnFiles(getTestOperations(1, 1, 0, 0))
+		require.NoError(t, err)
+		require.NoError(t, blockchain.WriteAnchor(anchorString))
+
+		gc := NewGarbageCollector(defaultNS, cas, blockchain, &mocks.MockProtocolClientProvider{}, cp)
+
+		_, err = gc.Orphans()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "protocol client not found")
+	})
+
+	t.Run("error - CAS list error", func(t *testing.T) {
+		gc := NewGarbageCollector(defaultNS, mocks.NewMockCasClient(errors.New("CAS error")),
+			mocks.NewMockBlockchainClient(nil), mocks.NewMockProtocolClientProvider(), cp)
+
+		_, err := gc.Orphans()
+		require.EqualError(t, err, "failed to list CAS content: CAS error")
+
+		_, err = gc.Collect()
+		require.Error(t, err)
+	})
+
+	t.Run("error - CAS delete error", func(t *testing.T) {
+		cas := &deleteErrorCasClient{MockCasClient: mocks.NewMockCasClient(nil)}
+
+		_, err := cas.Write([]byte("orphan"))
+		require.NoError(t, err)
+
+		gc := NewGarbageCollector(defaultNS, cas, mocks.NewMockBlockchainClient(nil), mocks.NewMockProtocolClientProvider(), cp,
+			WithGracePeriod(0))
+
+		addresses, err := gc.Collect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to delete CAS content")
+		require.Empty(t, addresses)
+	})
+}
+
+// getBatchFileAddresses returns the addresses of the anchor, map and chunk files of the given anchor string
+func getBatchFileAddresses(t *testing.T, cas *failingCasClient, anchorString string) []string {
+	referenced := make(map[string]bool)
+
+	gc := NewGarbageCollector(defaultNS, cas, mocks.NewMockBlockchainClient(nil), mocks.NewMockProtocolClientProvider(),
+		compression.New(compression.WithDefaultAlgorithms()))
+
+	require.NoError(t, gc.addReferencedFiles(&txn.SidetreeTxn{Namespace: defaultNS, AnchorString: anchorString}, referenced))
+
+	var addresses []string
+	for address := range referenced {
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// requireTxnOperations requires that the operations of the given transactions can be resolved
+func requireTxnOperations(t *testing.T, cas cas.Client, blockchain *mocks.MockBlockchainClient, txnNumbers ...int) {
+	provider := NewOperationProvider(cas, mocks.NewMockProtocolClientProvider(), compression.New(compression.WithDefaultAlgorithms()))
+
+	for _, txnNumber := range txnNumbers {
+		_, sidetreeTxn := blockchain.Read(txnNumber - 1)
+		require.NotNil(t, sidetreeTxn)
+
+		_, err := provider.GetTxnOperations(sidetreeTxn)
+		require.NoError(t, err)
+	}
+}
+
+type deleteErrorCasClient struct {
+	*mocks.MockCasClient
+}
+
+func (c *deleteErrorCasClient) Delete(string) error {
+	return errors.New("delete error")
+}
@@ -20,48 +20,140 @@ type compressionProvider interface {
 	Compress(alg string, data []byte) ([]byte, error)
 }
 
+// ExcludedOperationsError is returned by PrepareTxnFiles if some of the operations can't be included in the batch
+// files (e.g. the delta of the operation doesn't fit into a chunk file). No files are created in this case, however,
+// the batch files may be prepared for the remaining operations.
+type ExcludedOperationsError struct {
+	Operations []*batch.RejectedOperation
+}
+
+// Error returns the error message
+func (e *ExcludedOperationsError) Error() string {
+	return fmt.Sprintf("%d operation(s) can't be included in the batch files: %s",
+		len(e.Operations), e.Operations[0].Reason)
+}
+
 // OperationHandler creates batch files(chunk, map, anchor) from batch operations
 type OperationHandler struct {
 	cas      cas.Client
 	protocol protocol.Client
 	cp       compressionProvider
+	manifest *WriteManifest
+}
+
+// Option is an option for operation handler
+type Option func(h *OperationHandler)
+
+// WithWriteManifest sets the manifest that tracks the files of the prepared batches (until they're released) and
+// the files written by failed attempts to prepare a batch. The manifest must not be shared by handlers that prepare
+// batches concurrently.
+func WithWriteManifest(manifest *WriteManifest) Option {
+	return func(h *OperationHandler) {
+		h.manifest = manifest
+	}
 }
 
 // NewOperationHandler returns new operations handler
-func NewOperationHandler(cas cas.Client, p protocol.Client, cp compressionProvider) *OperationHandler {
-	return &OperationHandler{cas: cas, protocol: p, cp: cp}
+func NewOperationHandler(cas cas.Client, p protocol.Client, cp compressionProvider, opts ...Option) *OperationHandler {
+	h := &OperationHandler{cas: cas, protocol: p, cp: cp, manifest: NewWriteManifest()}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 // PrepareTxnFiles will create batch files(chunk, map, anchor) from batch operations,
 // store those files in CAS and return anchor string
+// An ExcludedOperationsError is returned if some of the operations can't be included in the batch files.
+// If an error occurs after some of the files were stored then the addresses of those files are reused
+// when the files are prepared again (see WriteManifest). The files of the batch are retained (i.e. they aren't
+// deleted by the garbage collector) until the batch is released (see ReleaseTxnFiles).
 func (h *OperationHandler) PrepareTxnFiles(ops []*batch.Operation) (string, error) {
+	if excluded := h.getExcludedOperations(ops); len(excluded) > 0 {
+		return "", &ExcludedOperationsError{Operations: excluded}
+	}
+
+	addresses, err := h.createBatchFiles(ops)
+	if err != nil {
+		return "", err
+	}
+
+	ad := AnchorData{
+		NumberOfOperations: len(ops),
+		// the anchor file is created last
+		AnchorAddress: addresses[len(addresses)-1],
+	}
+
+	anchorString := ad.GetAnchorString()
+
+	if err := h.manifest.Prepared(anchorString, addresses); err != nil {
+		return "", err
+	}
+
+	return anchorString, nil
+}
+
+// ReleaseTxnFiles stops retaining the files of the batch with the given anchor string. It's invoked once the anchor
+// was confirmed (i.e. the files are referenced by a transaction on the ledger) or if the batch was abandoned (i.e. the
+// files are left to garbage collection).
+func (h *OperationHandler) ReleaseTxnFiles(anchorString string) {
+	if err := h.manifest.Release(anchorString); err != nil {
+		logger.Errorf("Error releasing files of anchor [%s]: %s", anchorString, err)
+	}
+}
+
+// RetainedTxnFiles returns the anchor strings of the prepared batches whose files are retained, including the
+// batches that were prepared before a restart if the write manifest is persistent (see NewPersistentWriteManifest)
+func (h *OperationHandler) RetainedTxnFiles() []string {
+	return h.manifest.Batches()
+}
+
+// createBatchFiles creates the batch files and writes them to CAS
+// returns the addresses of the batch files (the address of the anchor file is last)
+func (h *OperationHandler) createBatchFiles(ops []*batch.Operation) ([]string, error) {
 	deactivateOps := getOperations(batch.OperationTypeDeactivate, ops)
 
+	var addresses []string
+
 	// special case: if all ops are deactivate don't create chunk and map files
 	mapFileAddr := ""
 	if len(deactivateOps) != len(ops) {
-		chunkFileAddr, err := h.createChunkFile(ops)
+		chunkFileAddrs, err := h.createChunkFiles(ops)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		mapFileAddr, err = h.createMapFile([]string{chunkFileAddr}, ops)
+		mapFileAddr, err = h.createMapFile(chunkFileAddrs, ops)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+
+		addresses = append(chunkFileAddrs, mapFileAddr)
 	}
 
-	anchorAddr, err := h.createAnchorFile(mapFileAddr, ops)
+	anchorFileAddr, err := h.createAnchorFile(mapFileAddr, ops)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	ad := AnchorData{
-		NumberOfOperations: len(ops),
-		AnchorAddress:      anchorAddr,
+	return append(addresses, anchorFileAddr), nil
+}
+
+// getExcludedOperations returns the operations that can't be included in the batch files, i.e. operations
+// that would exceed the maximum size of a batch file even if they were the only operation in the batch
+func (h *OperationHandler) getExcludedOperations(ops []*batch.Operation) []*batch.RejectedOperation {
+	p := h.protocol.Current()
+
+	var excluded []*batch.RejectedOperation
+	for _, op := range ops {
+		if err := models.NewBatchSizeEstimator(p, h.cp).Add(op); err != nil {
+			excluded = append(excluded, &batch.RejectedOperation{Operation: op, Reason: err.Error()})
+		}
 	}
 
-	return ad.GetAnchorString(), nil
+	return excluded
 }
 
 // createAnchorFile will create anchor file from operations and map file and write it to CAS
@@ -72,12 +164,27 @@ func (h *OperationHandler) createAnchorFile(mapAddress string, ops []*batch.Oper
 	return h.writeModelToCAS(anchorFile, "anchor")
 }
 
-// createChunkFile will create chunk file from operations and write it to CAS
-// returns chunk file address
-func (h *OperationHandler) createChunkFile(ops []*batch.Operation) (string, error) {
-	chunkFile := models.CreateChunkFile(ops)
+// createChunkFiles will create chunk files from operations and write them to CAS.
+// Operation deltas are split across multiple chunk files if they don't fit into maximum chunk file size.
+// returns chunk file addresses
+func (h *OperationHandler) createChunkFiles(ops []*batch.Operation) ([]string, error) {
+	// the chunk files are split by their estimated compressed size (in the same way as the batch was cut)
+	chunkFiles, err := models.NewBatchSizeEstimator(h.protocol.Current(), h.cp).CreateChunkFiles(ops)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk files: %s", err.Error())
+	}
+
+	var addresses []string
+	for _, chunkFile := range chunkFiles {
+		address, err := h.writeModelToCAS(chunkFile, "chunk")
+		if err != nil {
+			return nil, err
+		}
 
-	return h.writeModelToCAS(chunkFile, "chunk")
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
 }
 
 // createMapFile will create map file from operations and chunk file URIs and write it to CAS
@@ -101,11 +208,20 @@ func (h *OperationHandler) writeModelToCAS(model interface{}, alias string) (str
 		return "", err
 	}
 
+	// reuse the file if it was already written by a failed attempt
+	if address, ok := h.manifest.Get(compressedBytes); ok {
+		logger.Debugf("%s file was already stored in CAS: %s", alias, address)
+
+		return address, nil
+	}
+
 	// make file available in CAS
 	address, err := h.cas.Write(compressedBytes)
 	if err != nil {
 		return "", fmt.Errorf("failed to store %s file: %s", alias, err.Error())
 	}
 
+	h.manifest.Put(compressedBytes, address)
+
 	return address, nil
 }
@@ -10,14 +10,19 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	mrand "math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/txn"
+	"github.com/trustbloc/sidetree-core-go/pkg/batch/cutter"
+	"github.com/trustbloc/sidetree-core-go/pkg/batch/opqueue"
 	"github.com/trustbloc/sidetree-core-go/pkg/commitment"
 	"github.com/trustbloc/sidetree-core-go/pkg/compression"
 	"github.com/trustbloc/sidetree-core-go/pkg/jws"
@@ -110,6 +115,93 @@ func TestOperationHandler_PrepareTxnFiles(t *testing.T) {
 		require.Equal(t, createOpsNum+recoverOpsNum+updateOpsNum, len(cf.Deltas))
 	})
 
+	t.Run("success - multiple chunk files", func(t *testing.T) {
+		ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
+
+		// the chunk files are split by their compressed size so the deltas must not compress well
+		for _, op := range ops {
+			data := make([]byte, 150)
+			_, err := rand.Read(data)
+			require.NoError(t, err)
+
+			op.EncodedDelta = base64.URLEncoding.EncodeToString(data)
+		}
+
+		pc := mocks.NewMockProtocolClient()
+		pc.Protocol.MaxChunkFileSize = 400
+
+		handler := NewOperationHandler(mocks.NewMockCasClient(nil), pc, compression)
+
+		anchorString, err := handler.PrepareTxnFiles(ops)
+		require.NoError(t, err)
+		require.NotEmpty(t, anchorString)
+
+		anchorData, err := ParseAnchorData(anchorString)
+		require.NoError(t, err)
+
+		bytes, err := handler.cas.Read(anchorData.AnchorAddress)
+		require.NoError(t, err)
+
+		content, err := compression.Decompress(compressionAlgorithm, bytes)
+		require.NoError(t, err)
+
+		var af models.AnchorFile
+		err = json.Unmarshal(content, &af)
+		require.NoError(t, err)
+
+		bytes, err = handler.cas.Read(af.MapFileHash)
+		require.NoError(t, err)
+
+		content, err = compression.Decompress(compressionAlgorithm, bytes)
+		require.NoError(t, err)
+
+		var mf models.MapFile
+		err = json.Unmarshal(content, &mf)
+		require.NoError(t, err)
+		require.True(t, len(mf.Chunks) > 1)
+
+		deltas := 0
+		for _, chunk := range mf.Chunks {
+			bytes, err = handler.cas.Read(chunk.ChunkFileURI)
+			require.NoError(t, err)
+			require.True(t, len(bytes) <= int(pc.Protocol.MaxChunkFileSize))
+
+			content, err = compression.Decompress(compressionAlgorithm, bytes)
+			require.NoError(t, err)
+
+			var cf models.ChunkFile
+			err = json.Unmarshal(content, &cf)
+			require.NoError(t, err)
+
+			deltas += len(cf.Deltas)
+		}
+
+		require.Equal(t, createOpsNum+recoverOpsNum+updateOpsNum, deltas)
+	})
+
+	t.Run("error - delta exceeds maximum chunk file size", func(t *testing.T) {
+		ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
+
+		pc := mocks.NewMockProtocolClient()
+		pc.Protocol.MaxChunkFileSize = 20
+
+		handler := NewOperationHandler(mocks.NewMockCasClient(nil), pc, compression)
+
+		anchorString, err := handler.PrepareTxnFiles(ops)
+		require.Error(t, err)
+		require.Empty(t, anchorString)
+		require.Contains(t, err.Error(), "4 operation(s) can't be included in the batch files")
+
+		excludedErr := &ExcludedOperationsError{}
+		require.True(t, errors.As(err, &excludedErr))
+		require.Len(t, excludedErr.Operations, createOpsNum+recoverOpsNum+updateOpsNum)
+
+		for _, excluded := range excludedErr.Operations {
+			require.NotEqual(t, batch.OperationTypeDeactivate, excluded.Operation.Type)
+			require.Contains(t, excluded.Reason, "exceeds maximum chunk file size")
+		}
+	})
+
 	t.Run("error - write to CAS error for chunk file", func(t *testing.T) {
 		ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
 
@@ -139,6 +231,176 @@ func TestOperationHandler_PrepareTxnFiles(t *testing.T) {
 	})
 }
 
+func TestOperationHandler_PrepareTxnFiles_Retry(t *testing.T) {
+	cp := compression.New(compression.WithDefaultAlgorithms())
+
+	t.Run("files written by failed attempt are reused", func(t *testing.T) {
+		// chunk and map files are written but writing the anchor file fails
+		cas := &failingCasClient{MockCasClient: mocks.NewMockCasClient(nil), failAt: 3}
+		manifest := NewWriteManifest()
+
+		handler := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp, WithWriteManifest(manifest))
+
+		ops := getTestOperations(2, 1, 1, 1)
+
+		anchorString, err := handler.PrepareTxnFiles(ops)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to store anchor file")
+		require.Empty(t, anchorString)
+		require.Len(t, manifest.Addresses(), 2)
+
+		anchorString, err = handler.PrepareTxnFiles(ops)
+		require.NoError(t, err)
+
+		// only the anchor file was written on retry
+		require.Equal(t, 4, cas.writes)
+
+		// the files of the batch are retained until they're released
+		require.Len(t, manifest.Addresses(), 3)
+
+		handler.ReleaseTxnFiles(anchorString)
+		require.Empty(t, manifest.Addresses())
+
+		provider := NewOperationProvider(cas, mocks.NewMockProtocolClientProvider(), cp)
+
+		txnOps, err := provider.GetTxnOperations(&txn.SidetreeTxn{
+			Namespace:         defaultNS,
+			AnchorString:      anchorString,
+			TransactionNumber: 1,
+			TransactionTime:   1,
+		})
+		require.NoError(t, err)
+		require.Len(t, txnOps, len(ops))
+	})
+
+	t.Run("files of another batch aren't reused", func(t *testing.T) {
+		cas := &failingCasClient{MockCasClient: mocks.NewMockCasClient(nil), failAt: 3}
+
+		handler := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp)
+
+		_, err := handler.PrepareTxnFiles(getTestOperations(2, 1, 1, 1))
+		require.Error(t, err)
+
+		anchorString, err := handler.PrepareTxnFiles(getTestOperations(1, 1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, 6, cas.writes)
+
+		// the files of the failed attempt are no longer retained
+		anchorData, err := ParseAnchorData(anchorString)
+		require.NoError(t, err)
+		require.Len(t, handler.manifest.Addresses(), 3)
+		require.Contains(t, handler.manifest.Addresses(), anchorData.AnchorAddress)
+	})
+
+	t.Run("files are reused if the manifest can't be stored", func(t *testing.T) {
+		cas := &failingCasClient{MockCasClient: mocks.NewMockCasClient(nil)}
+		store := &manifestStore{}
+
+		manifest, err := NewPersistentWriteManifest(store)
+		require.NoError(t, err)
+
+		store.err = errors.New("injected store error")
+
+		handler := NewOperationHandler(cas, mocks.NewMockProtocolClient(), cp, WithWriteManifest(manifest))
+
+		ops := getTestOperations(2, 1, 1, 1)
+
+		anchorString, err := handler.PrepareTxnFiles(ops)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to store write manifest")
+		require.Empty(t, anchorString)
+		require.Empty(t, handler.RetainedTxnFiles())
+
+		store.err = nil
+
+		anchorString, err = handler.PrepareTxnFiles(ops)
+		require.NoError(t, err)
+		require.Equal(t, 3, cas.writes)
+		require.Equal(t, []string{anchorString}, handler.RetainedTxnFiles())
+
+		handler.ReleaseTxnFiles(anchorString)
+		require.Empty(t, handler.RetainedTxnFiles())
+		require.Empty(t, store.batches)
+	})
+}
+
+// TestOperationHandler_BatchFileSizes verifies that the batch files created for batches that are cut by the batch
+// cutter never exceed the protocol limits, i.e. that they're always accepted by the operation provider.
+func TestOperationHandler_BatchFileSizes(t *testing.T) {
+	const iterations = 20
+	const opsNum = 30
+	const seed = 20200601
+
+	cp := compression.New(compression.WithDefaultAlgorithms())
+
+	// a fixed seed so that the test is deterministic
+	r := mrand.New(mrand.NewSource(seed)) //nolint:gosec
+
+	for i := 0; i < iterations; i++ {
+		pc := mocks.NewMockProtocolClient()
+		pc.Protocol.MaxOperationsPerBatch = uint(1 + r.Intn(opsNum))
+		pc.Protocol.MaxAnchorFileSize = uint(1000 + r.Intn(4000))
+		pc.Protocol.MaxMapFileSize = uint(1000 + r.Intn(4000))
+		pc.Protocol.MaxChunkFileSize = uint(1000 + r.Intn(4000))
+
+		pcp := mocks.NewMockProtocolClientProvider()
+		pcp.ProtocolClients[defaultNS] = pc
+
+		cas := mocks.NewMockCasClient(nil)
+		handler := NewOperationHandler(cas, pc, cp)
+		provider := NewOperationProvider(cas, pcp, cp)
+
+		c := cutter.New(pc, &opqueue.MemQueue{})
+
+		for j := 0; j < opsNum; j++ {
+			op, err := generateRandomOperationInfo(r, j)
+			require.NoError(t, err)
+
+			_, err = c.Add(op)
+			require.NoError(t, err)
+		}
+
+		for {
+			ops, _, commit, err := c.Cut(true)
+			require.NoError(t, err)
+
+			if len(ops) == 0 {
+				break
+			}
+
+			var batchOps []*batch.Operation
+			for _, op := range ops {
+				parsed, e := operation.ParseOperation(op.Namespace, op.Data, pc.Current())
+				require.NoError(t, e)
+
+				batchOps = append(batchOps, parsed)
+			}
+
+			anchorString, err := handler.PrepareTxnFiles(batchOps)
+
+			excludedErr := &ExcludedOperationsError{}
+			if errors.As(err, &excludedErr) {
+				// an operation that doesn't fit into the batch files on its own is cut in a batch of its own
+				require.Equal(t, 1, len(batchOps), "seed: %d", seed)
+			} else {
+				require.NoError(t, err, "seed: %d", seed)
+
+				txnOps, e := provider.GetTxnOperations(&txn.SidetreeTxn{
+					Namespace:         defaultNS,
+					AnchorString:      anchorString,
+					TransactionNumber: 1,
+					TransactionTime:   1,
+				})
+				require.NoError(t, e, "seed: %d", seed)
+				require.Len(t, txnOps, len(batchOps))
+			}
+
+			_, err = commit()
+			require.NoError(t, err)
+		}
+	}
+}
+
 func TestWriteModelToCAS(t *testing.T) {
 	handler := NewOperationHandler(
 		mocks.NewMockCasClient(nil),
@@ -187,6 +449,23 @@ func TestWriteModelToCAS(t *testing.T) {
 	})
 }
 
+// failingCasClient fails the write with the given (1-based) sequence number
+type failingCasClient struct {
+	*mocks.MockCasClient
+	failAt int
+	writes int
+}
+
+func (c *failingCasClient) Write(content []byte) (string, error) {
+	c.writes++
+
+	if c.writes == c.failAt {
+		return "", errors.New("CAS error")
+	}
+
+	return c.MockCasClient.Write(content)
+}
+
 func getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum int) []*batch.Operation {
 	var ops []*batch.Operation
 	ops = append(ops, generateOperations(createOpsNum, batch.OperationTypeCreate)...)
@@ -333,6 +612,81 @@ func generateUpdateOperation(num int) (*batch.Operation, error) {
 	return operation.ParseOperation(defaultNS, request, mocks.NewMockProtocolClient().Current())
 }
 
+// generateRandomOperationInfo generates an operation of a random type with a random-sized delta
+func generateRandomOperationInfo(r *mrand.Rand, num int) (*batch.OperationInfo, error) {
+	value := make([]byte, r.Intn(600))
+	r.Read(value)
+
+	encodedValue := base64.RawURLEncoding.EncodeToString(value)
+	doc := fmt.Sprintf(`{"test":"%s"}`, encodedValue)
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := commitment.Calculate(testJWK, sha2_256)
+	if err != nil {
+		return nil, err
+	}
+
+	var request []byte
+
+	switch opType := r.Intn(4); opType {
+	case 0:
+		request, err = helper.NewCreateRequest(&helper.CreateRequestInfo{
+			OpaqueDocument:     doc,
+			RecoveryCommitment: c,
+			UpdateCommitment:   c,
+			MultihashCode:      sha2_256,
+		})
+	case 1:
+		jwk, e := pubkey.GetPublicKeyJWK(&privateKey.PublicKey)
+		if e != nil {
+			return nil, e
+		}
+
+		request, err = helper.NewRecoverRequest(&helper.RecoverRequestInfo{
+			DidSuffix:          fmt.Sprintf("recover-%d", num),
+			OpaqueDocument:     doc,
+			RecoveryCommitment: c,
+			UpdateCommitment:   c,
+			RecoveryKey:        jwk,
+			MultihashCode:      sha2_256,
+			Signer:             ecsigner.New(privateKey, "ES256", ""),
+		})
+	case 2:
+		p, e := patch.NewJSONPatch(fmt.Sprintf(`[{"op": "replace", "path": "/name", "value": "%s"}]`, encodedValue))
+		if e != nil {
+			return nil, e
+		}
+
+		request, err = helper.NewUpdateRequest(&helper.UpdateRequestInfo{
+			DidSuffix:        fmt.Sprintf("update-%d", num),
+			Signer:           ecsigner.New(privateKey, "ES256", "key-1"),
+			UpdateCommitment: c,
+			UpdateKey:        testJWK,
+			Patch:            p,
+			MultihashCode:    sha2_256,
+		})
+	default:
+		request, err = helper.NewDeactivateRequest(&helper.DeactivateRequestInfo{
+			DidSuffix: fmt.Sprintf("deactivate-%d", num),
+			Signer:    ecsigner.New(privateKey, "ES256", ""),
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch.OperationInfo{
+		Namespace:    defaultNS,
+		UniqueSuffix: fmt.Sprintf("%d", num),
+		Data:         request,
+	}, nil
+}
+
 func getTestPatch() (patch.Patch, error) {
 	return patch.NewJSONPatch(`[{"op": "replace", "path": "/name", "value": "Jane"}]`)
 }
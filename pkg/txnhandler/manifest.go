@@ -0,0 +1,185 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txnhandler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// WriteManifest tracks the batch files that were written to CAS but aren't referenced by a transaction on the ledger
+// yet, i.e. the files of the batches whose anchors weren't confirmed yet and the files that were written by attempts
+// to prepare a batch that failed (e.g. the map file was written but writing the anchor file failed). The addresses of
+// the files of failed attempts are reused when the same content is written on retry, so a retry doesn't write the
+// files again. Once a batch is prepared successfully its files are retained until they're released (see
+// OperationHandler.ReleaseTxnFiles) and the files of failed attempts that weren't reused are left to garbage
+// collection (see GarbageCollector).
+//
+// The prepared batches are kept in memory unless a store is provided (see NewPersistentWriteManifest), in which case
+// the files of the batches that weren't released before a restart (e.g. the files of an anchor that wasn't confirmed
+// yet) are still retained after the restart. The files of failed attempts are always kept in memory only.
+type WriteManifest struct {
+	mutex sync.RWMutex
+	store WriteManifestStore
+	// files maps the digest of the content of a file that was written by the current attempt (or a failed attempt)
+	// to its address in CAS
+	files map[string]string
+	// batches maps the anchor string of a prepared batch to the addresses of its files
+	batches map[string][]string
+}
+
+// WriteManifestStore stores the prepared batches of a write manifest
+type WriteManifestStore interface {
+	// Get returns the addresses of the files of the prepared batches keyed by anchor string
+	Get() (map[string][]string, error)
+
+	// Put stores (or replaces) the addresses of the files of the prepared batches keyed by anchor string
+	Put(batches map[string][]string) error
+}
+
+// NewWriteManifest returns a new (empty) write manifest
+func NewWriteManifest() *WriteManifest {
+	return &WriteManifest{
+		files:   make(map[string]string),
+		batches: make(map[string][]string),
+	}
+}
+
+// NewPersistentWriteManifest returns a write manifest whose prepared batches are stored in the given store.
+// The batches that were stored before a restart are loaded from the store.
+func NewPersistentWriteManifest(store WriteManifestStore) (*WriteManifest, error) {
+	batches, err := store.Get()
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load write manifest")
+	}
+
+	if batches == nil {
+		batches = make(map[string][]string)
+	}
+
+	return &WriteManifest{
+		store:   store,
+		files:   make(map[string]string),
+		batches: batches,
+	}, nil
+}
+
+// Get returns the address of the given content if the content was already written to CAS
+func (m *WriteManifest) Get(content []byte) (string, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	address, ok := m.files[digest(content)]
+
+	return address, ok
+}
+
+// Put records that the given content was written to CAS at the given address
+func (m *WriteManifest) Put(content []byte, address string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.files[digest(content)] = address
+}
+
+// Prepared records the files of the batch with the given anchor string, which are retained until the batch is
+// released. The files of failed attempts are no longer tracked. An error is returned (and the files of failed
+// attempts are still tracked) if the batch can't be stored.
+func (m *WriteManifest) Prepared(anchorString string, addresses []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	batches := m.copyBatches()
+	batches[anchorString] = addresses
+
+	if err := m.save(batches); err != nil {
+		return err
+	}
+
+	m.files = make(map[string]string)
+
+	return nil
+}
+
+// Release stops retaining the files of the batch with the given anchor string
+func (m *WriteManifest) Release(anchorString string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.batches[anchorString]; !ok {
+		return nil
+	}
+
+	batches := m.copyBatches()
+	delete(batches, anchorString)
+
+	return m.save(batches)
+}
+
+// Batches returns the anchor strings of the prepared batches that weren't released
+func (m *WriteManifest) Batches() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	anchorStrings := make([]string, 0, len(m.batches))
+	for anchorString := range m.batches {
+		anchorStrings = append(anchorStrings, anchorString)
+	}
+
+	sort.Strings(anchorStrings)
+
+	return anchorStrings
+}
+
+// Addresses returns the addresses of all of the files in the manifest
+func (m *WriteManifest) Addresses() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	addresses := make([]string, 0, len(m.files))
+	for _, address := range m.files {
+		addresses = append(addresses, address)
+	}
+
+	for _, batchAddresses := range m.batches {
+		addresses = append(addresses, batchAddresses...)
+	}
+
+	return addresses
+}
+
+func (m *WriteManifest) copyBatches() map[string][]string {
+	batches := make(map[string][]string, len(m.batches)+1)
+	for anchorString, addresses := range m.batches {
+		batches[anchorString] = addresses
+	}
+
+	return batches
+}
+
+// save stores the given batches (if the manifest has a store) and replaces the batches of the manifest
+func (m *WriteManifest) save(batches map[string][]string) error {
+	if m.store != nil {
+		if err := m.store.Put(batches); err != nil {
+			return errors.WithMessage(err, "failed to store write manifest")
+		}
+	}
+
+	m.batches = batches
+
+	return nil
+}
+
+func digest(content []byte) string {
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,152 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txnhandler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteManifest(t *testing.T) {
+	manifest := NewWriteManifest()
+	require.Empty(t, manifest.Addresses())
+
+	address, ok := manifest.Get([]byte("content1"))
+	require.False(t, ok)
+	require.Empty(t, address)
+
+	manifest.Put([]byte("content1"), "address1")
+	manifest.Put([]byte("content2"), "address2")
+
+	address, ok = manifest.Get([]byte("content1"))
+	require.True(t, ok)
+	require.Equal(t, "address1", address)
+
+	require.ElementsMatch(t, []string{"address1", "address2"}, manifest.Addresses())
+
+	// the files of the prepared batch are retained and the files of failed attempts are no longer tracked
+	require.NoError(t, manifest.Prepared("anchor1", []string{"address2", "address3"}))
+	require.ElementsMatch(t, []string{"address2", "address3"}, manifest.Addresses())
+
+	_, ok = manifest.Get([]byte("content1"))
+	require.False(t, ok)
+
+	manifest.Put([]byte("content4"), "address4")
+	require.NoError(t, manifest.Prepared("anchor2", []string{"address4"}))
+	require.ElementsMatch(t, []string{"address2", "address3", "address4"}, manifest.Addresses())
+	require.Equal(t, []string{"anchor1", "anchor2"}, manifest.Batches())
+
+	require.NoError(t, manifest.Release("anchor1"))
+	require.Equal(t, []string{"address4"}, manifest.Addresses())
+
+	require.NoError(t, manifest.Release("anchor2"))
+	require.Empty(t, manifest.Addresses())
+	require.Empty(t, manifest.Batches())
+}
+
+func TestPersistentWriteManifest(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		store := &manifestStore{}
+
+		manifest, err := NewPersistentWriteManifest(store)
+		require.NoError(t, err)
+		require.Empty(t, manifest.Addresses())
+
+		manifest.Put([]byte("content1"), "address1")
+		require.NoError(t, manifest.Prepared("anchor1", []string{"address2", "address3"}))
+		require.NoError(t, manifest.Prepared("anchor2", []string{"address4"}))
+		require.NoError(t, manifest.Release("anchor2"))
+
+		// the prepared batches are loaded after a restart (the files of failed attempts aren't stored)
+		manifest.Put([]byte("content5"), "address5")
+
+		manifest, err = NewPersistentWriteManifest(store)
+		require.NoError(t, err)
+		require.Equal(t, []string{"anchor1"}, manifest.Batches())
+		require.ElementsMatch(t, []string{"address2", "address3"}, manifest.Addresses())
+
+		require.NoError(t, manifest.Release("anchor1"))
+		require.Empty(t, store.batches)
+	})
+
+	t.Run("error - load", func(t *testing.T) {
+		manifest, err := NewPersistentWriteManifest(&manifestStore{err: errors.New("injected store error")})
+		require.Error(t, err)
+		require.Nil(t, manifest)
+		require.Contains(t, err.Error(), "failed to load write manifest: injected store error")
+	})
+
+	t.Run("error - store", func(t *testing.T) {
+		store := &manifestStore{}
+
+		manifest, err := NewPersistentWriteManifest(store)
+		require.NoError(t, err)
+
+		manifest.Put([]byte("content1"), "address1")
+		require.NoError(t, manifest.Prepared("anchor1", []string{"address2"}))
+		manifest.Put([]byte("content3"), "address3")
+
+		store.err = errors.New("injected store error")
+
+		err = manifest.Prepared("anchor2", []string{"address3", "address4"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to store write manifest: injected store error")
+
+		// the files of the failed attempt are still tracked
+		address, ok := manifest.Get([]byte("content3"))
+		require.True(t, ok)
+		require.Equal(t, "address3", address)
+		require.Equal(t, []string{"anchor1"}, manifest.Batches())
+
+		err = manifest.Release("anchor1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to store write manifest: injected store error")
+		require.Equal(t, []string{"anchor1"}, manifest.Batches())
+
+		// releasing a batch that isn't retained doesn't store the manifest
+		require.NoError(t, manifest.Release("anchor2"))
+	})
+}
+
+// manifestStore stores copies of the batches of a write manifest (as if they were loaded from storage)
+type manifestStore struct {
+	batches map[string][]string
+	err     error
+}
+
+func (s *manifestStore) Get() (map[string][]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return copyBatches(s.batches), nil
+}
+
+func (s *manifestStore) Put(batches map[string][]string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.batches = copyBatches(batches)
+
+	return nil
+}
+
+func copyBatches(batches map[string][]string) map[string][]string {
+	if batches == nil {
+		return nil
+	}
+
+	copied := make(map[string][]string, len(batches))
+	for anchorString, addresses := range batches {
+		copied[anchorString] = append([]string(nil), addresses...)
+	}
+
+	return copied
+}
@@ -8,10 +8,14 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
 )
 
+// emptyChunkFileSize is the size of marshalled chunk file without deltas: {"deltas":[]}
+const emptyChunkFileSize = 13
+
 // ChunkFile defines chunk file schema
 type ChunkFile struct {
 	// Deltas included in this chunk file, each delta is an encoded string
@@ -21,11 +25,55 @@ type ChunkFile struct {
 // CreateChunkFile will combine all operation deltas into chunk file
 // returns chunk file model
 func CreateChunkFile(ops []*batch.Operation) *ChunkFile {
-	var deltas []string
+	return &ChunkFile{Deltas: getAllDeltas(ops)}
+}
 
-	deltas = append(deltas, getDeltas(batch.OperationTypeCreate, ops)...)
-	deltas = append(deltas, getDeltas(batch.OperationTypeRecover, ops)...)
-	deltas = append(deltas, getDeltas(batch.OperationTypeUpdate, ops)...)
+// CreateChunkFiles will split operation deltas into chunk files so that the size of
+// each marshalled chunk file doesn't exceed the given maximum size (in bytes).
+// Deltas keep the same order as in a single chunk file so that they can be concatenated by the reader.
+// returns chunk file models
+func CreateChunkFiles(ops []*batch.Operation, maxSize uint) ([]*ChunkFile, error) {
+	var chunks []*ChunkFile
+
+	current := &ChunkFile{}
+	size := emptyChunkFileSize
+
+	for _, delta := range getAllDeltas(ops) {
+		deltaSize, err := validateDeltaSize(delta, maxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(current.Deltas) > 0 {
+			// delta separator
+			deltaSize++
+		}
+
+		if size+deltaSize > int(maxSize) {
+			chunks = append(chunks, current)
+
+			current = &ChunkFile{}
+			size = emptyChunkFileSize
+			deltaSize--
+		}
+
+		current.Deltas = append(current.Deltas, delta)
+		size += deltaSize
+	}
+
+	if len(current.Deltas) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks, nil
+}
+
+// MergeChunkFiles will combine deltas from the given chunk files (in order) into a single chunk file
+func MergeChunkFiles(chunks []*ChunkFile) *ChunkFile {
+	var deltas []string
+	for _, cf := range chunks {
+		deltas = append(deltas, cf.Deltas...)
+	}
 
 	return &ChunkFile{Deltas: deltas}
 }
@@ -41,6 +89,39 @@ func ParseChunkFile(content []byte) (*ChunkFile, error) {
 	return cf, nil
 }
 
+func getAllDeltas(ops []*batch.Operation) []string {
+	var deltas []string
+
+	deltas = append(deltas, getDeltas(batch.OperationTypeCreate, ops)...)
+	deltas = append(deltas, getDeltas(batch.OperationTypeRecover, ops)...)
+	deltas = append(deltas, getDeltas(batch.OperationTypeUpdate, ops)...)
+
+	return deltas
+}
+
+func validateDeltaSize(delta string, maxSize uint) (int, error) {
+	deltaSize, err := getDeltaSize(delta)
+	if err != nil {
+		return 0, err
+	}
+
+	if emptyChunkFileSize+deltaSize > int(maxSize) {
+		return 0, fmt.Errorf("delta size %d exceeds maximum chunk file size %d", deltaSize, maxSize)
+	}
+
+	return deltaSize, nil
+}
+
+// getDeltaSize returns the size of delta within marshalled chunk file
+func getDeltaSize(delta string) (int, error) {
+	bytes, err := json.Marshal(delta)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(bytes), nil
+}
+
 func getDeltas(filter batch.OperationType, ops []*batch.Operation) []string {
 	var deltas []string
 	for _, op := range ops {
@@ -43,3 +43,49 @@ func TestParseChunkFile(t *testing.T) {
 
 	require.Equal(t, createOpsNum+updateOpsNum+recoverOpsNum, len(parsed.Deltas))
 }
+
+func TestCreateChunkFiles(t *testing.T) {
+	const createOpsNum = 5
+	const updateOpsNum = 4
+	const deactivateOpsNum = 3
+	const recoverOpsNum = 1
+
+	ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
+
+	t.Run("success - single chunk file", func(t *testing.T) {
+		chunks, err := CreateChunkFiles(ops, 1000)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(chunks))
+		require.Equal(t, CreateChunkFile(ops), chunks[0])
+	})
+
+	t.Run("success - multiple chunk files", func(t *testing.T) {
+		// each delta is 7 bytes ("delta" in quotes) so only two deltas fit into 30 bytes: {"deltas":["delta","delta"]}
+		const maxSize = 30
+
+		chunks, err := CreateChunkFiles(ops, maxSize)
+		require.NoError(t, err)
+		require.Equal(t, 5, len(chunks))
+
+		for _, chunk := range chunks {
+			bytes, err := json.Marshal(chunk)
+			require.NoError(t, err)
+			require.True(t, len(bytes) <= maxSize)
+		}
+
+		require.Equal(t, CreateChunkFile(ops), MergeChunkFiles(chunks))
+	})
+
+	t.Run("success - no deltas", func(t *testing.T) {
+		chunks, err := CreateChunkFiles(getTestOperations(0, 0, deactivateOpsNum, 0), 1000)
+		require.NoError(t, err)
+		require.Empty(t, chunks)
+	})
+
+	t.Run("error - delta exceeds maximum chunk file size", func(t *testing.T) {
+		chunks, err := CreateChunkFiles(ops, 15)
+		require.Error(t, err)
+		require.Nil(t, chunks)
+		require.Contains(t, err.Error(), "delta size 7 exceeds maximum chunk file size 15")
+	})
+}
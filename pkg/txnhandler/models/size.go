@@ -0,0 +1,351 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package models
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+)
+
+// maxAddressSize is the maximum size of a CAS address (e.g. the map file hash in the anchor file)
+// assumed by the size estimates
+const maxAddressSize = 128
+
+const (
+	// anchorFileOverhead is the size of the anchor file without operations (the size of one empty operation
+	// per operation type is included so that the keys of the operation arrays are accounted for)
+	anchorFileOverhead = maxAddressSize + uint(len(`{"mapFileHash":"","operations":{"create":[{"suffix_data":""}],`+
+		`"recover":[{"did_suffix":"","signed_data":""}],"deactivate":[{"did_suffix":"","signed_data":""}]}}`))
+
+	// mapFileOverhead is the size of the map file without chunk file URIs and operations
+	mapFileOverhead = uint(len(`{"chunks":[],"operations":{"update":[{"did_suffix":"","signed_data":""}]}}`))
+
+	// chunkURISize is the size of a chunk file URI in the map file (including the separator)
+	chunkURISize = maxAddressSize + uint(len(`{"chunk_file_uri":""},`))
+)
+
+// MaxCompressedSize returns the maximum size of a file of the given size after compression, i.e. the size
+// plus the worst-case overhead of GZIP compression (header and trailer plus the header of each deflate block).
+func MaxCompressedSize(size uint) uint {
+	return size + size/1024 + 64
+}
+
+// MaxUncompressedSize returns the maximum size of a file before compression so that the compressed file
+// doesn't exceed the given maximum size
+func MaxUncompressedSize(maxSize uint) uint {
+	if maxSize <= MaxCompressedSize(0) {
+		return 0
+	}
+
+	size := (maxSize - MaxCompressedSize(0)) * 1024 / 1025
+	for MaxCompressedSize(size+1) <= maxSize {
+		size++
+	}
+
+	return size
+}
+
+// OperationSize contains the number of bytes that an operation adds to the (uncompressed) batch files
+type OperationSize struct {
+	// Anchor is the size of the operation in the anchor file
+	Anchor uint
+	// Map is the size of the operation in the map file
+	Map uint
+	// Chunk is the size of the operation's delta in the chunk files
+	Chunk uint
+}
+
+// GetOperationSize returns the number of bytes that the given operation adds to the batch files
+func GetOperationSize(op *batch.Operation) (OperationSize, error) {
+	var size OperationSize
+
+	if op.Type != batch.OperationTypeDeactivate {
+		deltaSize, err := getDeltaSize(op.EncodedDelta)
+		if err != nil {
+			return OperationSize{}, err
+		}
+
+		size.Chunk = uint(deltaSize)
+	}
+
+	var element interface{} = &SignedOperation{DidSuffix: op.UniqueSuffix, SignedData: op.SignedData}
+	if op.Type == batch.OperationTypeCreate {
+		element = &CreateOperation{SuffixData: op.EncodedSuffixData}
+	}
+
+	bytes, err := json.Marshal(element)
+	if err != nil {
+		return OperationSize{}, err
+	}
+
+	// each element of an array is followed by a separator
+	if op.Type == batch.OperationTypeUpdate {
+		size.Map = uint(len(bytes)) + 1
+	} else {
+		size.Anchor = uint(len(bytes)) + 1
+	}
+
+	if size.Chunk > 0 {
+		size.Chunk++
+	}
+
+	return size, nil
+}
+
+// Compressor compresses data with the given compression algorithm
+type Compressor interface {
+	Compress(alg string, data []byte) ([]byte, error)
+}
+
+// BatchSizeEstimator estimates the compressed sizes of the anchor, map and chunk files of a batch as operations
+// are added to the batch so that the batch files created from the accepted operations don't exceed the protocol
+// limits.
+//
+// The sizes are estimated incrementally: the estimated size of a file is the compressed size that was last measured
+// plus the worst-case compressed size of the content that was added since. The file is compressed (with
+// placeholders for the CAS addresses, which are at least as large as the actual addresses) to measure its size only
+// if the estimate exceeds the limit, i.e. the files are compressed only as the batch approaches the limits.
+// The chunk files are split by their estimated compressed size as well (see CreateChunkFiles). A single delta must
+// fit into a chunk file even if it doesn't compress, so only the size of a single delta is checked against the
+// maximum chunk file size.
+type BatchSizeEstimator struct {
+	protocol    protocol.Protocol
+	compressor  Compressor
+	maxChunk    uint
+	ops         []*batch.Operation
+	anchor      fileSize
+	mapFile     fileSize
+	deltasSize  uint
+	numOfDeltas uint
+	chunkFiles  uint
+}
+
+// fileSize is the estimated compressed size of a batch file
+type fileSize struct {
+	// compressed is the compressed size of the file when it was last measured
+	compressed uint
+	// added is the uncompressed size of the content that was added since the file was last measured
+	added uint
+}
+
+func (s fileSize) add(size uint) fileSize {
+	return fileSize{compressed: s.compressed, added: s.added + size}
+}
+
+func (s fileSize) estimate() uint {
+	if s.added == 0 {
+		return s.compressed
+	}
+
+	return s.compressed + MaxCompressedSize(s.added)
+}
+
+// NewBatchSizeEstimator returns a new estimator for an empty batch with the size limits and the compression
+// algorithm of the given protocol
+func NewBatchSizeEstimator(p protocol.Protocol, compressor Compressor) *BatchSizeEstimator {
+	return &BatchSizeEstimator{
+		protocol:   p,
+		compressor: compressor,
+		maxChunk:   MaxUncompressedSize(p.MaxChunkFileSize),
+		anchor:     fileSize{added: anchorFileOverhead},
+		mapFile:    fileSize{added: mapFileOverhead},
+	}
+}
+
+// Add adds the given operation to the batch. An error is returned (and the operation isn't added)
+// if any of the batch files would exceed the protocol limits.
+func (e *BatchSizeEstimator) Add(op *batch.Operation) error {
+	size, err := GetOperationSize(op)
+	if err != nil {
+		return err
+	}
+
+	if size.Chunk > 0 && emptyChunkFileSize+size.Chunk-1 > e.maxChunk {
+		return fmt.Errorf("delta size %d exceeds maximum chunk file size %d", size.Chunk-1, e.maxChunk)
+	}
+
+	numOfDeltas := e.numOfDeltas
+	if size.Chunk > 0 {
+		numOfDeltas++
+	}
+
+	chunkFiles := e.maxChunkFiles(e.deltasSize+size.Chunk, numOfDeltas)
+
+	ops := append(e.ops[:len(e.ops):len(e.ops)], op)
+
+	anchor, err := e.checkSize(e.anchor.add(size.Anchor), e.protocol.MaxAnchorFileSize, "anchor", func() interface{} {
+		return CreateAnchorFile(placeholderAddress(0), ops)
+	})
+	if err != nil {
+		return err
+	}
+
+	mapFile, err := e.checkSize(e.mapFile.add(size.Map+(chunkFiles-e.chunkFiles)*chunkURISize), e.protocol.MaxMapFileSize, "map",
+		func() interface{} {
+			return CreateMapFile(placeholderAddresses(chunkFiles), ops)
+		})
+	if err != nil {
+		return err
+	}
+
+	e.ops = ops
+	e.anchor = anchor
+	e.mapFile = mapFile
+	e.deltasSize += size.Chunk
+	e.numOfDeltas = numOfDeltas
+	e.chunkFiles = chunkFiles
+
+	return nil
+}
+
+// checkSize returns an error if the estimated size of a file exceeds the given maximum size. If the estimate
+// exceeds the maximum size the file is created (see createFile) and compressed to measure its size.
+func (e *BatchSizeEstimator) checkSize(size fileSize, maxSize uint, alias string, createFile func() interface{}) (fileSize, error) {
+	if size.estimate() <= maxSize {
+		return size, nil
+	}
+
+	compressed, err := e.compressedSize(createFile(), alias)
+	if err != nil {
+		return fileSize{}, err
+	}
+
+	if compressed > maxSize {
+		return fileSize{}, fmt.Errorf("%s file size %d exceeds maximum %s file size %d", alias, compressed, alias, maxSize)
+	}
+
+	return fileSize{compressed: compressed}, nil
+}
+
+// compressedSize marshals and compresses the given file and returns its compressed size
+func (e *BatchSizeEstimator) compressedSize(file interface{}, alias string) (uint, error) {
+	bytes, err := docutil.MarshalCanonical(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal %s file: %s", alias, err.Error())
+	}
+
+	compressed, err := e.compressor.Compress(e.protocol.CompressionAlgorithm, bytes)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(len(compressed)), nil
+}
+
+// CreateChunkFiles splits the deltas of the given operations into chunk files so that the compressed size of each
+// chunk file doesn't exceed the maximum chunk file size. The size of a chunk file is estimated in the same way as
+// the sizes of the batch files (i.e. the chunk file is compressed only if its estimated size exceeds the limit) and
+// a delta is added to a new chunk file only if the current chunk file would exceed the limit.
+// Deltas keep the same order as in a single chunk file so that they can be concatenated by the reader.
+func (e *BatchSizeEstimator) CreateChunkFiles(ops []*batch.Operation) ([]*ChunkFile, error) {
+	var chunks []*ChunkFile
+
+	var current *ChunkFile
+
+	var size fileSize
+
+	for _, delta := range getAllDeltas(ops) {
+		deltaSize, err := validateDeltaSize(delta, e.maxChunk)
+		if err != nil {
+			return nil, err
+		}
+
+		if current != nil {
+			// delta separator
+			next, fits, err := e.fitsChunkFile(current, size.add(uint(deltaSize)+1), delta)
+			if err != nil {
+				return nil, err
+			}
+
+			if fits {
+				current.Deltas = append(current.Deltas, delta)
+				size = next
+
+				continue
+			}
+
+			chunks = append(chunks, current)
+		}
+
+		current = &ChunkFile{Deltas: []string{delta}}
+		size = fileSize{added: uint(emptyChunkFileSize + deltaSize)}
+	}
+
+	if current != nil {
+		chunks = append(chunks, current)
+	}
+
+	return chunks, nil
+}
+
+// fitsChunkFile returns true (along with the new estimated size of the chunk file) if the given delta can be
+// added to the given chunk file without exceeding the maximum chunk file size
+func (e *BatchSizeEstimator) fitsChunkFile(chunk *ChunkFile, size fileSize, delta string) (fileSize, bool, error) {
+	maxSize := e.protocol.MaxChunkFileSize
+
+	if size.estimate() <= maxSize {
+		return size, true, nil
+	}
+
+	n := len(chunk.Deltas)
+
+	compressed, err := e.compressedSize(&ChunkFile{Deltas: append(chunk.Deltas[:n:n], delta)}, "chunk")
+	if err != nil {
+		return fileSize{}, false, err
+	}
+
+	if compressed > maxSize {
+		return fileSize{}, false, nil
+	}
+
+	return fileSize{compressed: compressed}, true, nil
+}
+
+// maxChunkFiles returns the maximum number of chunk files for the deltas of the given total size. Since a delta
+// is added to a new chunk file only if it doesn't fit into the current one, the contents of any two consecutive
+// chunk files exceed the chunk file capacity. A chunk file that is split by its estimated compressed size holds
+// at least as many deltas as one that is split by its uncompressed size (the compressed size never exceeds the
+// worst-case size), so the uncompressed capacity gives an upper bound.
+func (e *BatchSizeEstimator) maxChunkFiles(deltasSize, numOfDeltas uint) uint {
+	if numOfDeltas == 0 {
+		return 0
+	}
+
+	capacity := e.maxChunk - emptyChunkFileSize
+
+	maxChunkFiles := 2*deltasSize/capacity + 1
+	if maxChunkFiles > numOfDeltas {
+		return numOfDeltas
+	}
+
+	return maxChunkFiles
+}
+
+// placeholderAddresses returns the given number of (distinct) placeholder addresses
+func placeholderAddresses(num uint) []string {
+	addresses := make([]string, num)
+	for i := range addresses {
+		addresses[i] = placeholderAddress(i + 1)
+	}
+
+	return addresses
+}
+
+// placeholderAddress returns a placeholder for a CAS address. The placeholder (hex encoded hash) is as large
+// as the maximum address size and doesn't compress better than an actual (multihash) address.
+func placeholderAddress(i int) string {
+	hash := sha512.Sum512([]byte(strconv.Itoa(i)))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,339 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package models
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/sidetree-core-go/pkg/api/batch"
+	"github.com/trustbloc/sidetree-core-go/pkg/api/protocol"
+	"github.com/trustbloc/sidetree-core-go/pkg/compression"
+	"github.com/trustbloc/sidetree-core-go/pkg/compression/gzip"
+	"github.com/trustbloc/sidetree-core-go/pkg/docutil"
+)
+
+func TestFileOverhead(t *testing.T) {
+	address := strings.Repeat("a", maxAddressSize)
+
+	bytes, err := json.Marshal(&AnchorFile{
+		MapFileHash: address,
+		Operations: Operations{
+			Create:     []CreateOperation{{}},
+			Recover:    []SignedOperation{{}},
+			Deactivate: []SignedOperation{{}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, anchorFileOverhead, uint(len(bytes)))
+
+	bytes, err = json.Marshal(&MapFile{
+		Chunks:     []Chunk{},
+		Operations: Operations{Update: []SignedOperation{{}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, mapFileOverhead, uint(len(bytes)))
+
+	bytes, err = json.Marshal(&Chunk{ChunkFileURI: address})
+	require.NoError(t, err)
+	require.Equal(t, chunkURISize, uint(len(bytes))+1)
+}
+
+func TestMaxCompressedSize(t *testing.T) {
+	alg := gzip.New()
+
+	for _, size := range []int{0, 1, 100, 1024, 5000, 100000} {
+		// random data can't be compressed
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+
+		compressed, err := alg.Compress(data)
+		require.NoError(t, err)
+		require.True(t, uint(len(compressed)) <= MaxCompressedSize(uint(size)))
+	}
+}
+
+func TestMaxUncompressedSize(t *testing.T) {
+	require.Zero(t, MaxUncompressedSize(0))
+	require.Zero(t, MaxUncompressedSize(MaxCompressedSize(0)))
+
+	for _, maxSize := range []uint{MaxCompressedSize(0) + 1, 100, 1024, 2000, 20000, 1000000} {
+		size := MaxUncompressedSize(maxSize)
+		require.True(t, MaxCompressedSize(size) <= maxSize)
+		require.True(t, MaxCompressedSize(size+1) > maxSize)
+	}
+}
+
+func TestGetOperationSize(t *testing.T) {
+	t.Run("create", func(t *testing.T) {
+		size, err := GetOperationSize(generateOperation(1, batch.OperationTypeCreate))
+		require.NoError(t, err)
+		require.Equal(t, OperationSize{Anchor: uint(len(`{"suffix_data":"suffix-data"},`)), Chunk: uint(len(`"delta",`))}, size)
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		size, err := GetOperationSize(generateOperation(1, batch.OperationTypeRecover))
+		require.NoError(t, err)
+		require.Equal(t, OperationSize{Anchor: uint(len(`{"did_suffix":"recover-1","signed_data":"signed-data"},`)), Chunk: uint(len(`"delta",`))}, size)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		size, err := GetOperationSize(generateOperation(1, batch.OperationTypeUpdate))
+		require.NoError(t, err)
+		require.Equal(t, OperationSize{Map: uint(len(`{"did_suffix":"update-1","signed_data":"signed-data"},`)), Chunk: uint(len(`"delta",`))}, size)
+	})
+
+	t.Run("deactivate", func(t *testing.T) {
+		size, err := GetOperationSize(generateOperation(1, batch.OperationTypeDeactivate))
+		require.NoError(t, err)
+		require.Equal(t, OperationSize{Anchor: uint(len(`{"did_suffix":"deactivate-1","signed_data":"signed-data"},`))}, size)
+	})
+}
+
+func TestBatchSizeEstimator(t *testing.T) {
+	p := protocol.Protocol{
+		CompressionAlgorithm: "GZIP",
+		MaxAnchorFileSize:    1000,
+		MaxMapFileSize:       1000,
+		MaxChunkFileSize:     1000,
+	}
+
+	cp := compression.New(compression.WithDefaultAlgorithms())
+
+	t.Run("success", func(t *testing.T) {
+		ops := getTestOperations(2, 2, 2, 2)
+
+		estimator := NewBatchSizeEstimator(p, cp)
+
+		for _, op := range ops {
+			require.NoError(t, estimator.Add(op))
+		}
+
+		chunks, err := estimator.CreateChunkFiles(ops)
+		require.NoError(t, err)
+
+		var uris []string
+		for range chunks {
+			uris = append(uris, placeholderAddress(len(uris)))
+		}
+
+		anchorFile := compress(t, cp, CreateAnchorFile(uris[0], ops))
+		require.True(t, uint(len(anchorFile)) <= estimator.anchor.estimate())
+
+		mapFile := compress(t, cp, CreateMapFile(uris, ops))
+		require.True(t, uint(len(mapFile)) <= estimator.mapFile.estimate())
+	})
+
+	t.Run("success - compressed size", func(t *testing.T) {
+		estimator := NewBatchSizeEstimator(p, cp)
+
+		// the operations compress well so more operations fit into the batch than their uncompressed size suggests
+		var uncompressed uint
+		for i := 1; ; i++ {
+			op := generateOperation(i, batch.OperationTypeCreate)
+			op.EncodedSuffixData = strings.Repeat("suffix-data", 5)
+
+			if err := estimator.Add(op); err != nil {
+				require.Contains(t, err.Error(), "exceeds maximum")
+
+				break
+			}
+
+			size, err := GetOperationSize(op)
+			require.NoError(t, err)
+
+			uncompressed += size.Anchor
+		}
+
+		require.True(t, MaxCompressedSize(anchorFileOverhead+uncompressed) > p.MaxAnchorFileSize)
+
+		anchorFile := compress(t, cp, CreateAnchorFile(placeholderAddress(0), estimator.ops))
+		require.True(t, uint(len(anchorFile)) <= p.MaxAnchorFileSize)
+		require.True(t, uint(len(anchorFile)) <= estimator.anchor.estimate())
+	})
+
+	t.Run("error - delta exceeds maximum chunk file size", func(t *testing.T) {
+		estimator := NewBatchSizeEstimator(p, cp)
+
+		op := generateOperation(1, batch.OperationTypeCreate)
+		op.EncodedDelta = strings.Repeat("a", 1000)
+
+		err := estimator.Add(op)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "exceeds maximum chunk file size")
+	})
+
+	t.Run("error - anchor file size", func(t *testing.T) {
+		estimator := NewBatchSizeEstimator(p, cp)
+
+		op := generateOperation(1, batch.OperationTypeDeactivate)
+		op.SignedData = randomString(t, 700)
+		require.NoError(t, estimator.Add(op))
+
+		op = generateOperation(2, batch.OperationTypeDeactivate)
+		op.SignedData = randomString(t, 700)
+
+		err := estimator.Add(op)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "exceeds maximum anchor file size 1000")
+
+		// the operation wasn't added
+		require.NoError(t, estimator.Add(generateOperation(3, batch.OperationTypeDeactivate)))
+		require.Len(t, estimator.ops, 2)
+	})
+
+	t.Run("error - map file size", func(t *testing.T) {
+		estimator := NewBatchSizeEstimator(p, cp)
+
+		op := generateOperation(1, batch.OperationTypeUpdate)
+		op.SignedData = randomString(t, 700)
+		require.NoError(t, estimator.Add(op))
+
+		op = generateOperation(2, batch.OperationTypeUpdate)
+		op.SignedData = randomString(t, 700)
+
+		err := estimator.Add(op)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "exceeds maximum map file size 1000")
+	})
+
+	t.Run("error - map file size with chunk file URIs", func(t *testing.T) {
+		estimator := NewBatchSizeEstimator(p, cp)
+
+		// each of the deltas may end up in its own chunk file
+		var err error
+		for i := 1; i <= 20 && err == nil; i++ {
+			op := generateOperation(i, batch.OperationTypeCreate)
+			op.EncodedDelta = randomString(t, 600)
+
+			err = estimator.Add(op)
+		}
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "exceeds maximum map file size 1000")
+	})
+
+	t.Run("error - compression", func(t *testing.T) {
+		p := p
+		p.CompressionAlgorithm = "unknown"
+
+		estimator := NewBatchSizeEstimator(p, cp)
+
+		op := generateOperation(1, batch.OperationTypeDeactivate)
+		op.SignedData = strings.Repeat("a", 1000)
+
+		err := estimator.Add(op)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "compression algorithm 'unknown' not supported")
+	})
+}
+
+func TestBatchSizeEstimator_CreateChunkFiles(t *testing.T) {
+	p := protocol.Protocol{
+		CompressionAlgorithm: "GZIP",
+		MaxChunkFileSize:     1000,
+	}
+
+	cp := compression.New(compression.WithDefaultAlgorithms())
+
+	t.Run("success - deltas compress well", func(t *testing.T) {
+		var ops []*batch.Operation
+		for i := 1; i <= 20; i++ {
+			op := generateOperation(i, batch.OperationTypeCreate)
+			op.EncodedDelta = strings.Repeat("delta", 20)
+			ops = append(ops, op)
+		}
+
+		uncompressedChunks, err := CreateChunkFiles(ops, MaxUncompressedSize(p.MaxChunkFileSize))
+		require.NoError(t, err)
+		require.True(t, len(uncompressedChunks) > 1)
+
+		// the deltas fit into a single chunk file since they compress well
+		chunks, err := NewBatchSizeEstimator(p, cp).CreateChunkFiles(ops)
+		require.NoError(t, err)
+		require.Len(t, chunks, 1)
+		require.Equal(t, CreateChunkFile(ops), chunks[0])
+		require.True(t, uint(len(compress(t, cp, chunks[0]))) <= p.MaxChunkFileSize)
+	})
+
+	t.Run("success - deltas don't compress", func(t *testing.T) {
+		var ops []*batch.Operation
+		for i := 1; i <= 20; i++ {
+			op := generateOperation(i, batch.OperationTypeUpdate)
+			op.EncodedDelta = randomString(t, 100)
+			ops = append(ops, op)
+		}
+
+		uncompressedChunks, err := CreateChunkFiles(ops, MaxUncompressedSize(p.MaxChunkFileSize))
+		require.NoError(t, err)
+
+		chunks, err := NewBatchSizeEstimator(p, cp).CreateChunkFiles(ops)
+		require.NoError(t, err)
+		require.True(t, len(chunks) > 1)
+		require.True(t, len(chunks) <= len(uncompressedChunks))
+		require.Equal(t, CreateChunkFile(ops), MergeChunkFiles(chunks))
+
+		for _, chunk := range chunks {
+			require.True(t, uint(len(compress(t, cp, chunk))) <= p.MaxChunkFileSize)
+		}
+	})
+
+	t.Run("success - no deltas", func(t *testing.T) {
+		chunks, err := NewBatchSizeEstimator(p, cp).CreateChunkFiles(getTestOperations(0, 0, 2, 0))
+		require.NoError(t, err)
+		require.Empty(t, chunks)
+	})
+
+	t.Run("error - delta exceeds maximum chunk file size", func(t *testing.T) {
+		op := generateOperation(1, batch.OperationTypeCreate)
+		op.EncodedDelta = strings.Repeat("a", 1000)
+
+		chunks, err := NewBatchSizeEstimator(p, cp).CreateChunkFiles([]*batch.Operation{op})
+		require.Error(t, err)
+		require.Nil(t, chunks)
+		require.Contains(t, err.Error(), "exceeds maximum chunk file size")
+	})
+
+	t.Run("error - compression", func(t *testing.T) {
+		p := p
+		p.CompressionAlgorithm = "unknown"
+
+		ops := getTestOperations(20, 0, 0, 0)
+		for _, op := range ops {
+			op.EncodedDelta = strings.Repeat("delta", 20)
+		}
+
+		chunks, err := NewBatchSizeEstimator(p, cp).CreateChunkFiles(ops)
+		require.Error(t, err)
+		require.Nil(t, chunks)
+		require.Contains(t, err.Error(), "compression algorithm 'unknown' not supported")
+	})
+}
+
+func compress(t *testing.T, cp *compression.Registry, model interface{}) []byte {
+	bytes, err := docutil.MarshalCanonical(model)
+	require.NoError(t, err)
+
+	compressed, err := cp.Compress("GZIP", bytes)
+	require.NoError(t, err)
+
+	return compressed
+}
+
+// randomString returns a random (i.e. incompressible) base64url encoded string of the given size
+func randomString(t *testing.T, size int) string {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	return docutil.EncodeToString(data)[:size]
+}
@@ -51,12 +51,12 @@ func (h *OperationProvider) GetTxnOperations(txn *txn.SidetreeTxn) ([]*batch.Ope
 		return nil, err
 	}
 
-	pc, err := h.pcp.ForNamespace(txn.Namespace)
+	p, err := h.getProtocol(txn)
 	if err != nil {
 		return nil, err
 	}
 
-	af, err := h.getAnchorFile(anchorData.AnchorAddress, pc.Current())
+	af, err := h.getAnchorFile(anchorData.AnchorAddress, *p)
 	if err != nil {
 		return nil, err
 	}
@@ -71,13 +71,12 @@ func (h *OperationProvider) GetTxnOperations(txn *txn.SidetreeTxn) ([]*batch.Ope
 		return anchorOps.Deactivate, nil
 	}
 
-	mf, err := h.getMapFile(af.MapFileHash, pc.Current())
+	mf, err := h.getMapFile(af.MapFileHash, *p)
 	if err != nil {
 		return nil, err
 	}
 
-	chunkAddress := mf.Chunks[0].ChunkFileURI
-	cf, err := h.getChunkFile(chunkAddress, pc.Current())
+	cf, err := h.getChunkFiles(mf, *p)
 	if err != nil {
 		return nil, err
 	}
@@ -115,12 +114,18 @@ func (h *OperationProvider) assembleBatchOperations(af *models.AnchorFile, mf *m
 
 	// TODO: Add checks here to makes sure that file sizes match - part of validation tickets
 
-	for i, delta := range cf.Deltas {
-		p, err := h.getProtocol(operations[i].Namespace)
-		if err != nil {
-			return nil, err
-		}
+	// deactivate operations don't have deltas
+	expectedDeltas := len(operations) - len(anchorOps.Deactivate)
+	if len(cf.Deltas) != expectedDeltas {
+		return nil, fmt.Errorf("number of deltas[%d] in chunk files doesn't match number of operations with deltas[%d]", len(cf.Deltas), expectedDeltas)
+	}
 
+	p, err := h.getProtocol(txn)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, delta := range cf.Deltas {
 		deltaModel, err := operation.ParseDelta(delta, p.HashAlgorithmInMultiHashCode)
 		if err != nil {
 			return nil, fmt.Errorf("parse delta: %s", err.Error())
@@ -165,6 +170,25 @@ func (h *OperationProvider) getMapFile(address string, p protocol.Protocol) (*mo
 	return mf, nil
 }
 
+// getChunkFiles will download all chunk files referenced in map file and combine them into single chunk file model
+func (h *OperationProvider) getChunkFiles(mf *models.MapFile, p protocol.Protocol) (*models.ChunkFile, error) {
+	if len(mf.Chunks) == 0 {
+		return nil, errors.New("map file doesn't contain chunk file URIs")
+	}
+
+	var chunks []*models.ChunkFile
+	for _, chunk := range mf.Chunks {
+		cf, err := h.getChunkFile(chunk.ChunkFileURI, p)
+		if err != nil {
+			return nil, err
+		}
+
+		chunks = append(chunks, cf)
+	}
+
+	return models.MergeChunkFiles(chunks), nil
+}
+
 // getChunkFile will download chunk file from cas and parse it into chunk file model
 func (h *OperationProvider) getChunkFile(address string, p protocol.Protocol) (*models.ChunkFile, error) {
 	content, err := h.readFromCAS(address, p.CompressionAlgorithm, p.MaxChunkFileSize)
@@ -210,7 +234,7 @@ type anchorOperations struct {
 func (h *OperationProvider) parseAnchorOperations(af *models.AnchorFile, txn *txn.SidetreeTxn) (*anchorOperations, error) { //nolint: funlen
 	logger.Debugf("parsing anchor operations for anchor address: %s", txn.AnchorString)
 
-	p, err := h.getProtocol(txn.Namespace)
+	p, err := h.getProtocol(txn)
 	if err != nil {
 		return nil, err
 	}
@@ -279,14 +303,17 @@ func (h *OperationProvider) parseAnchorOperations(af *models.AnchorFile, txn *tx
 	}, nil
 }
 
-func (h *OperationProvider) getProtocol(namespace string) (*protocol.Protocol, error) {
-	pc, err := h.pcp.ForNamespace(namespace)
+// getProtocol returns the protocol version that was in effect when the given transaction was anchored
+func (h *OperationProvider) getProtocol(txn *txn.SidetreeTxn) (*protocol.Protocol, error) {
+	pc, err := h.pcp.ForNamespace(txn.Namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: get protocol based on Sidetree transaction - for now use current
-	p := pc.Current()
+	p, err := pc.Get(txn.TransactionTime)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get protocol for transaction time [%d]", txn.TransactionTime)
+	}
 
 	return &p, nil
 }
@@ -64,6 +64,98 @@ func TestHandler_GetTxnOperations(t *testing.T) {
 		require.Equal(t, createOpsNum+updateOpsNum+deactivateOpsNum+recoverOpsNum, len(txnOps))
 	})
 
+	t.Run("success - multiple chunk files", func(t *testing.T) {
+		multiChunkPC := mocks.NewMockProtocolClient()
+		multiChunkPC.Protocol.MaxChunkFileSize = 400
+
+		cas := mocks.NewMockCasClient(nil)
+		handler := NewOperationHandler(cas, multiChunkPC, cp)
+
+		ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
+
+		anchorString, err := handler.PrepareTxnFiles(ops)
+		require.NoError(t, err)
+		require.NotEmpty(t, anchorString)
+
+		pcp := mocks.NewMockProtocolClientProvider()
+		pcp.ProtocolClients[mocks.DefaultNS] = multiChunkPC
+		provider := NewOperationProvider(cas, pcp, cp)
+
+		txnOps, err := provider.GetTxnOperations(&txn.SidetreeTxn{
+			Namespace:         defaultNS,
+			AnchorString:      anchorString,
+			TransactionNumber: 1,
+			TransactionTime:   1,
+		})
+		require.NoError(t, err)
+		require.Equal(t, createOpsNum+updateOpsNum+deactivateOpsNum+recoverOpsNum, len(txnOps))
+
+		for _, op := range txnOps {
+			if op.Type != batch.OperationTypeDeactivate {
+				require.NotNil(t, op.Delta)
+				require.NotEmpty(t, op.EncodedDelta)
+			}
+		}
+	})
+
+	t.Run("error - map file without chunk files", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		handler := NewOperationHandler(cas, pc, cp)
+
+		ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
+
+		mapAddr, err := handler.writeModelToCAS(models.CreateMapFile(nil, ops), "map")
+		require.NoError(t, err)
+
+		anchorAddr, err := handler.writeModelToCAS(models.CreateAnchorFile(mapAddr, ops), "anchor")
+		require.NoError(t, err)
+
+		ad := AnchorData{NumberOfOperations: len(ops), AnchorAddress: anchorAddr}
+
+		provider := NewOperationProvider(cas, mocks.NewMockProtocolClientProvider(), cp)
+
+		txnOps, err := provider.GetTxnOperations(&txn.SidetreeTxn{
+			Namespace:         defaultNS,
+			AnchorString:      ad.GetAnchorString(),
+			TransactionNumber: 1,
+			TransactionTime:   1,
+		})
+		require.Error(t, err)
+		require.Nil(t, txnOps)
+		require.Contains(t, err.Error(), "map file doesn't contain chunk file URIs")
+	})
+
+	t.Run("error - number of deltas doesn't match number of operations", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		handler := NewOperationHandler(cas, pc, cp)
+
+		ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
+
+		// chunk file is missing deltas for update operations
+		chunkAddr, err := handler.writeModelToCAS(models.CreateChunkFile(getOperations(batch.OperationTypeCreate, ops)), "chunk")
+		require.NoError(t, err)
+
+		mapAddr, err := handler.writeModelToCAS(models.CreateMapFile([]string{chunkAddr}, ops), "map")
+		require.NoError(t, err)
+
+		anchorAddr, err := handler.writeModelToCAS(models.CreateAnchorFile(mapAddr, ops), "anchor")
+		require.NoError(t, err)
+
+		ad := AnchorData{NumberOfOperations: len(ops), AnchorAddress: anchorAddr}
+
+		provider := NewOperationProvider(cas, mocks.NewMockProtocolClientProvider(), cp)
+
+		txnOps, err := provider.GetTxnOperations(&txn.SidetreeTxn{
+			Namespace:         defaultNS,
+			AnchorString:      ad.GetAnchorString(),
+			TransactionNumber: 1,
+			TransactionTime:   1,
+		})
+		require.Error(t, err)
+		require.Nil(t, txnOps)
+		require.Contains(t, err.Error(), "number of deltas[2] in chunk files doesn't match number of operations with deltas[7]")
+	})
+
 	t.Run("error - number of operations doesn't match", func(t *testing.T) {
 		cas := mocks.NewMockCasClient(nil)
 		handler := NewOperationHandler(cas, pc, cp)
@@ -211,6 +303,44 @@ func TestHandler_GetTxnOperations(t *testing.T) {
 		require.Contains(t, err.Error(), "protocol client not found for namespace [did:sidetree]")
 		require.Nil(t, txnOps)
 	})
+
+	t.Run("error - protocol version not found for transaction time", func(t *testing.T) {
+		cas := mocks.NewMockCasClient(nil)
+		handler := NewOperationHandler(cas, pc, cp)
+
+		ops := getTestOperations(createOpsNum, updateOpsNum, deactivateOpsNum, recoverOpsNum)
+
+		anchorString, err := handler.PrepareTxnFiles(ops)
+		require.NoError(t, err)
+		require.NotEmpty(t, anchorString)
+
+		// protocol is defined only for transactions anchored at time 10 and later
+		versioned := mocks.NewMockProtocolClient()
+		versioned.Protocol.StartingBlockChainTime = 10
+
+		pcp := mocks.NewMockProtocolClientProvider()
+		pcp.ProtocolClients[mocks.DefaultNS] = versioned
+		provider := NewOperationProvider(cas, pcp, cp)
+
+		txnOps, err := provider.GetTxnOperations(&txn.SidetreeTxn{
+			Namespace:         mocks.DefaultNS,
+			AnchorString:      anchorString,
+			TransactionNumber: 1,
+			TransactionTime:   1,
+		})
+		require.Error(t, err)
+		require.Nil(t, txnOps)
+		require.Contains(t, err.Error(), "failed to get protocol for transaction time [1]")
+
+		txnOps, err = provider.GetTxnOperations(&txn.SidetreeTxn{
+			Namespace:         mocks.DefaultNS,
+			AnchorString:      anchorString,
+			TransactionNumber: 1,
+			TransactionTime:   10,
+		})
+		require.NoError(t, err)
+		require.Equal(t, createOpsNum+updateOpsNum+deactivateOpsNum+recoverOpsNum, len(txnOps))
+	})
 }
 
 func TestHandler_GetAnchorFile(t *testing.T) {